@@ -178,15 +178,34 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	// Host API Calls
 	if api.host != nil {
 		// Calls directly pertaining to the host.
-		router.GET("/host", api.hostHandlerGET)                                                   // Get the host status.
-		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))              // Change the settings of the host.
-		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword)) // Announce the host to the network.
+		router.GET("/host", api.hostHandlerGET)                                                                   // Get the host status.
+		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))                              // Change the settings of the host.
+		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword))                 // Announce the host to the network.
+		router.GET("/host/contractpolicy", api.hostContractPolicyHandlerGET)                                      // Get the contract policy of the host.
+		router.POST("/host/contractpolicy", RequirePassword(api.hostContractPolicyHandlerPOST, requiredPassword)) // Change the contract policy of the host.
+		router.GET("/host/contracts", api.hostContractsHandler)                                                   // List the storage obligations of the host.
+		router.GET("/host/contracts/:id", api.hostContractHandler)                                                // Get the details of a storage obligation.
+		router.GET("/host/ledger", api.hostLedgerHandler)                                                         // Export the ledger of resolved storage obligations.
+		router.GET("/host/maintenance", api.hostMaintenanceHandlerGET)                                            // Get the maintenance status of the host.
+		router.POST("/host/maintenance", RequirePassword(api.hostMaintenanceHandlerPOST, requiredPassword))       // Put the host in or out of maintenance mode.
+		router.GET("/host/pricing", api.hostPricingHandlerGET)                                                    // Get the pricing policy of the host.
+		router.POST("/host/pricing", RequirePassword(api.hostPricingHandlerPOST, requiredPassword))               // Change the pricing policy of the host.
+		router.GET("/host/proofs", api.hostProofsHandler)                                                         // List the storage proofs of the host.
+		router.GET("/host/renters", api.hostRentersHandlerGET)                                                    // List the policies that limit individual renters.
+		router.POST("/host/renters", RequirePassword(api.hostRentersHandlerPOST, requiredPassword))               // Set the policy of a renter.
+		router.POST("/host/renters/remove", RequirePassword(api.hostRentersRemoveHandler, requiredPassword))      // Remove the policy of a renter.
 
 		// Calls pertaining to the storage manager that the host uses.
 		router.GET("/host/storage", api.storageHandler)
+		router.GET("/host/storage/check", api.storageCheckHandlerGET)
+		router.POST("/host/storage/check", RequirePassword(api.storageCheckHandlerPOST, requiredPassword))
 		router.POST("/host/storage/folders/add", RequirePassword(api.storageFoldersAddHandler, requiredPassword))
+		router.GET("/host/storage/folders/migrate", api.storageFoldersMigrateHandlerGET)
+		router.POST("/host/storage/folders/migrate", RequirePassword(api.storageFoldersMigrateHandlerPOST, requiredPassword))
 		router.POST("/host/storage/folders/remove", RequirePassword(api.storageFoldersRemoveHandler, requiredPassword))
 		router.POST("/host/storage/folders/resize", RequirePassword(api.storageFoldersResizeHandler, requiredPassword))
+		router.GET("/host/storage/scrub", api.storageScrubHandlerGET)
+		router.POST("/host/storage/scrub", RequirePassword(api.storageScrubHandlerPOST, requiredPassword))
 		router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(api.storageSectorsDeleteHandler, requiredPassword))
 	}
 
@@ -203,9 +222,12 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	if api.renter != nil {
 		router.GET("/renter", api.renterHandlerGET)
 		router.POST("/renter", RequirePassword(api.renterHandlerPOST, requiredPassword))
+		router.GET("/renter/allowance/plan", api.renterAllowancePlanHandler)
 		router.GET("/renter/contracts", api.renterContractsHandler)
+		router.GET("/renter/contracts/attempts", api.renterContractAttemptsHandler)
 		router.GET("/renter/downloads", api.renterDownloadsHandler)
 		router.GET("/renter/files", api.renterFilesHandler)
+		router.GET("/renter/spending", api.renterSpendingHandler)
 
 		// TODO: re-enable these routes once the new .sia format has been
 		// standardized and implemented.
@@ -222,6 +244,16 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		// HostDB endpoints.
 		router.GET("/hostdb/active", api.renterHostsActiveHandler)
 		router.GET("/hostdb/all", api.renterHostsAllHandler)
+		router.GET("/hostdb/hosts", api.renterHostsSearchHandler)
+		router.GET("/hostdb/hosts/:pubkey", api.renterHostsDetailsHandler)
+		router.GET("/hostdb/weights", api.renterHostsWeightsHandlerGET)
+		router.POST("/hostdb/weights", RequirePassword(api.renterHostsWeightsHandlerPOST, requiredPassword))
+		router.GET("/hostdb/subnets", api.renterHostsSubnetsHandlerGET)
+		router.POST("/hostdb/subnets", RequirePassword(api.renterHostsSubnetsHandlerPOST, requiredPassword))
+		router.GET("/hostdb/benchmarks", api.renterHostsBenchmarksHandlerGET)
+		router.POST("/hostdb/benchmarks", RequirePassword(api.renterHostsBenchmarksHandlerPOST, requiredPassword))
+		router.GET("/hostdb/prune", api.renterHostsPruneHandlerGET)
+		router.POST("/hostdb/prune", RequirePassword(api.renterHostsPruneHandlerPOST, requiredPassword))
 	}
 
 	// TransactionPool API Calls
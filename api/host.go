@@ -1,11 +1,17 @@
 package api
 
 import (
+	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -31,6 +37,72 @@ type (
 		NetworkMetrics   modules.HostNetworkMetrics   `json:"networkmetrics"`
 	}
 
+	// HostContractsGET contains the storage obligations of the host that
+	// match the filters of a GET request to /host/contracts.
+	HostContractsGET struct {
+		Contracts []modules.HostContract `json:"contracts"`
+	}
+
+	// HostLedgerGET contains the ledger entries of the storage obligations
+	// that were resolved in the range of a GET request to /host/ledger.
+	HostLedgerGET struct {
+		Entries []modules.HostLedgerEntry `json:"entries"`
+	}
+
+	// HostContractGET contains the details of a storage obligation, returned
+	// by a GET request to /host/contracts/:id.
+	HostContractGET struct {
+		modules.HostContractDetails
+	}
+
+	// HostContractPolicyGET contains the contract policy of the host, returned
+	// by a GET request to /host/contractpolicy.
+	HostContractPolicyGET struct {
+		modules.HostContractPolicy
+	}
+
+	// HostMaintenanceGET contains the maintenance status of the host, returned
+	// by a GET request to /host/maintenance.
+	HostMaintenanceGET struct {
+		modules.HostMaintenanceStatus
+	}
+
+	// HostPricingGET contains the pricing policy of the host, returned by a GET
+	// request to /host/pricing.
+	HostPricingGET struct {
+		modules.HostPricingPolicy
+	}
+
+	// HostProofsGET contains the storage proofs of the unresolved storage
+	// obligations of the host, returned by a GET request to /host/proofs.
+	HostProofsGET struct {
+		Proofs []modules.HostStorageProof `json:"proofs"`
+	}
+
+	// HostRentersGET contains the policies that limit individual renters,
+	// returned by a GET request to /host/renters.
+	HostRentersGET struct {
+		Policies []modules.HostRenterPolicy `json:"policies"`
+	}
+
+	// StorageCheckGET contains the results of a sector consistency check,
+	// returned by a GET or POST request to /host/storage/check.
+	StorageCheckGET struct {
+		modules.StorageCheckReport
+	}
+
+	// StorageMigrationGET contains the progress of a storage folder
+	// migration, returned by a GET request to /host/storage/folders/migrate.
+	StorageMigrationGET struct {
+		modules.StorageFolderMigrationStatus
+	}
+
+	// StorageScrubGET contains the settings and progress of the sector
+	// scrubber, returned by a GET request to /host/storage/scrub.
+	StorageScrubGET struct {
+		modules.StorageScrubStatus
+	}
+
 	// StorageGET contains the information that is returned after a GET request
 	// to /host/storage - a bunch of information about the status of storage
 	// management on the host.
@@ -79,6 +151,11 @@ func (api *API) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ http
 		"netaddress":           &settings.NetAddress,
 		"windowsize":           &settings.WindowSize,
 
+		"maxconnections":      &settings.MaxConnections,
+		"maxconnectionsperip": &settings.MaxConnectionsPerIP,
+		"maxdownloadspeed":    &settings.MaxDownloadSpeed,
+		"maxuploadspeed":      &settings.MaxUploadSpeed,
+
 		"collateral":       &settings.Collateral,
 		"collateralbudget": &settings.CollateralBudget,
 		"maxcollateral":    &settings.MaxCollateral,
@@ -100,6 +177,17 @@ func (api *API) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ http
 			}
 		}
 	}
+
+	// The additional addresses are a comma separated list. Unlike the other
+	// settings, an empty value is not skipped, but clears the list.
+	if _, ok := req.Form["additionaladdresses"]; ok {
+		settings.AdditionalAddresses = nil
+		for _, addr := range strings.Split(req.FormValue("additionaladdresses"), ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				settings.AdditionalAddresses = append(settings.AdditionalAddresses, modules.NetAddress(addr))
+			}
+		}
+	}
 	err := api.host.SetInternalSettings(settings)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
@@ -124,6 +212,335 @@ func (api *API) hostAnnounceHandler(w http.ResponseWriter, req *http.Request, _
 	WriteSuccess(w)
 }
 
+// hostMaintenanceHandlerGET handles the API call to get the maintenance status
+// of the host.
+func (api *API) hostMaintenanceHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostMaintenanceGET{api.host.MaintenanceStatus()})
+}
+
+// hostMaintenanceHandlerPOST handles the API call to put the host in or out of
+// maintenance mode.
+func (api *API) hostMaintenanceHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var enabled bool
+	_, err := fmt.Sscan(req.FormValue("enabled"), &enabled)
+	if err != nil {
+		WriteError(w, Error{"Malformed enabled"}, http.StatusBadRequest)
+		return
+	}
+	err = api.host.SetMaintenance(enabled)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostPricingHandlerGET handles the API call to get the pricing policy of the
+// host.
+func (api *API) hostPricingHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostPricingGET{api.host.PricingPolicy()})
+}
+
+// hostPricingHandlerPOST handles the API call to change the pricing policy of
+// the host.
+func (api *API) hostPricingHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Map each query string to a field in the pricing policy.
+	policy := api.host.PricingPolicy()
+	qsVars := map[string]interface{}{
+		"mode":          &policy.Mode,
+		"maxmultiplier": &policy.MaxMultiplier,
+		"percentile":    &policy.Percentile,
+	}
+	for qs := range qsVars {
+		if req.FormValue(qs) != "" { // skip empty values
+			_, err := fmt.Sscan(req.FormValue(qs), qsVars[qs])
+			if err != nil {
+				WriteError(w, Error{"Malformed " + qs}, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	err := api.host.SetPricingPolicy(policy)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostContractPolicyHandlerGET handles the API call to get the contract
+// policy of the host.
+func (api *API) hostContractPolicyHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostContractPolicyGET{api.host.ContractPolicy()})
+}
+
+// hostContractPolicyHandlerPOST handles the API call to change the contract
+// policy of the host.
+func (api *API) hostContractPolicyHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Map each query string to a field in the contract policy.
+	policy := api.host.ContractPolicy()
+	qsVars := map[string]interface{}{
+		"minduration":          &policy.MinDuration,
+		"maxduration":          &policy.MaxDuration,
+		"minpayout":            &policy.MinPayout,
+		"requireallowlist":     &policy.RequireAllowlist,
+		"maxcollateralpercent": &policy.MaxCollateralPercent,
+		"refusefailedrenewals": &policy.RefuseFailedRenewals,
+	}
+	for qs := range qsVars {
+		if req.FormValue(qs) != "" { // skip empty values
+			_, err := fmt.Sscan(req.FormValue(qs), qsVars[qs])
+			if err != nil {
+				WriteError(w, Error{"Malformed " + qs}, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	// The allowlist is replaced as a whole. An empty value clears it.
+	if _, ok := req.Form["allowedrenters"]; ok {
+		policy.AllowedRenters = nil
+		for _, key := range strings.Split(req.FormValue("allowedrenters"), ",") {
+			if key = strings.TrimSpace(key); key == "" {
+				continue
+			}
+			var spk types.SiaPublicKey
+			if err := spk.LoadString(key); err != nil {
+				WriteError(w, Error{"Couldn't parse renter key: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			policy.AllowedRenters = append(policy.AllowedRenters, spk)
+		}
+	}
+	err := api.host.SetContractPolicy(policy)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostContractsHandler handles the API call to list the storage obligations of
+// the host.
+func (api *API) hostContractsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	f := modules.HostContractFilter{
+		Status: req.FormValue("status"),
+	}
+	for _, param := range []struct {
+		name  string
+		value *types.BlockHeight
+	}{
+		{"minexpiration", &f.MinExpiration},
+		{"maxexpiration", &f.MaxExpiration},
+	} {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		if _, err := fmt.Sscan(req.FormValue(param.name), param.value); err != nil {
+			WriteError(w, Error{"Couldn't parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("proofconfirmed") != "" {
+		var proofConfirmed bool
+		if _, err := fmt.Sscan(req.FormValue("proofconfirmed"), &proofConfirmed); err != nil {
+			WriteError(w, Error{"Couldn't parse proofconfirmed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		f.ProofConfirmed = &proofConfirmed
+	}
+	contracts, err := api.host.Contracts(f)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostContractsGET{
+		Contracts: contracts,
+	})
+}
+
+// ledgerCSVHeader is the header row of the CSV export of the host ledger.
+var ledgerCSVHeader = []string{
+	"contractid", "status", "startheight", "endheight", "sectorcount", "datasize",
+	"contractcompensation", "storagerevenue", "downloadbandwidthrevenue", "uploadbandwidthrevenue", "lostrevenue",
+	"lockedcollateral", "lostcollateral", "transactionfeeexpense",
+	"resolvedheight", "resolvedtime",
+}
+
+// writeLedgerCSV writes the ledger entries to 'w' as CSV, one row per entry.
+// Amounts are in hastings.
+func writeLedgerCSV(w io.Writer, entries []modules.HostLedgerEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(ledgerCSVHeader); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err := cw.Write([]string{
+			e.ContractID.String(),
+			e.Status,
+			fmt.Sprint(e.StartHeight),
+			fmt.Sprint(e.EndHeight),
+			fmt.Sprint(e.SectorCount),
+			fmt.Sprint(e.DataSize),
+			e.ContractCompensation.String(),
+			e.StorageRevenue.String(),
+			e.DownloadBandwidthRevenue.String(),
+			e.UploadBandwidthRevenue.String(),
+			e.LostRevenue.String(),
+			e.LockedCollateral.String(),
+			e.LostCollateral.String(),
+			e.TransactionFeeExpense.String(),
+			fmt.Sprint(e.ResolvedHeight),
+			e.ResolvedTime.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// hostLedgerHandler handles the API call to export the ledger of the storage
+// obligations that the host has resolved, as JSON or CSV.
+func (api *API) hostLedgerHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var start, end time.Time
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"start", &start},
+		{"end", &end},
+	} {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		unix, err := strconv.ParseInt(req.FormValue(param.name), 10, 64)
+		if err != nil {
+			WriteError(w, Error{"Couldn't parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		*param.value = time.Unix(unix, 0)
+	}
+	format := req.FormValue("format")
+	if format != "" && format != "json" && format != "csv" {
+		WriteError(w, Error{"format must be json or csv"}, http.StatusBadRequest)
+		return
+	}
+
+	entries, err := api.host.Ledger(start, end)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		if writeLedgerCSV(w, entries) != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+		return
+	}
+	WriteJSON(w, HostLedgerGET{
+		Entries: entries,
+	})
+}
+
+// hostContractHandler handles the API call to get the details of a storage
+// obligation.
+func (api *API) hostContractHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	id, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	details, ok, err := api.host.Contract(types.FileContractID(id))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		WriteError(w, Error{"no contract with that id"}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostContractGET{details})
+}
+
+// hostProofsHandler handles the API call to list the storage proofs of the
+// host.
+func (api *API) hostProofsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	proofs, err := api.host.StorageProofs()
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, HostProofsGET{
+		Proofs: proofs,
+	})
+}
+
+// hostRentersHandlerGET handles the API call to list the policies that limit
+// individual renters.
+func (api *API) hostRentersHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostRentersGET{
+		Policies: api.host.RenterPolicies(),
+	})
+}
+
+// hostRentersHandlerPOST handles the API call to set the policy of a renter.
+// Limits that are not provided keep their current value.
+func (api *API) hostRentersHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var spk types.SiaPublicKey
+	if err := spk.LoadString(req.FormValue("key")); err != nil {
+		WriteError(w, Error{"Couldn't parse renter key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	policy := modules.HostRenterPolicy{RenterKey: spk}
+	for _, p := range api.host.RenterPolicies() {
+		if p.RenterKey.String() == spk.String() {
+			policy = p
+			break
+		}
+	}
+
+	// Map each query string to a field in the renter policy.
+	qsVars := map[string]interface{}{
+		"blocked":               &policy.Blocked,
+		"maxcontracts":          &policy.MaxContracts,
+		"maxrevisionsperminute": &policy.MaxRevisionsPerMinute,
+		"maxstorage":            &policy.MaxStorage,
+	}
+	for qs := range qsVars {
+		if req.FormValue(qs) != "" { // skip empty values
+			_, err := fmt.Sscan(req.FormValue(qs), qsVars[qs])
+			if err != nil {
+				WriteError(w, Error{"Malformed " + qs}, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	err := api.host.SetRenterPolicy(policy)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostRentersRemoveHandler handles the API call to remove the policy of a
+// renter.
+func (api *API) hostRentersRemoveHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var spk types.SiaPublicKey
+	if err := spk.LoadString(req.FormValue("key")); err != nil {
+		WriteError(w, Error{"Couldn't parse renter key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err := api.host.RemoveRenterPolicy(spk)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageHandler returns a bunch of information about storage management on
 // the host.
 func (api *API) storageHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -132,6 +549,62 @@ func (api *API) storageHandler(w http.ResponseWriter, req *http.Request, _ httpr
 	})
 }
 
+// storageCheckHandlerGET handles the API call that checks the consistency of
+// the host's sectors.
+func (api *API) storageCheckHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	report, err := api.host.CheckStorage(false)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, StorageCheckGET{report})
+}
+
+// storageCheckHandlerPOST handles the API call that checks the consistency of
+// the host's sectors and purges orphaned sector files.
+func (api *API) storageCheckHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	report, err := api.host.CheckStorage(true)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, StorageCheckGET{report})
+}
+
+// storageScrubHandlerGET handles the API call that returns the settings and
+// progress of the sector scrubber.
+func (api *API) storageScrubHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, StorageScrubGET{api.host.ScrubStatus()})
+}
+
+// storageScrubHandlerPOST handles the API call that changes the settings of
+// the sector scrubber.
+func (api *API) storageScrubHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	status := api.host.ScrubStatus()
+	period, rateLimit := status.Period, status.RateLimit
+	if req.FormValue("period") != "" {
+		var err error
+		period, err = time.ParseDuration(req.FormValue("period"))
+		if err != nil {
+			WriteError(w, Error{"Malformed period"}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("ratelimit") != "" {
+		_, err := fmt.Sscan(req.FormValue("ratelimit"), &rateLimit)
+		if err != nil {
+			WriteError(w, Error{"Malformed ratelimit"}, http.StatusBadRequest)
+			return
+		}
+	}
+	err := api.host.SetScrubSettings(period, rateLimit)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageFoldersAddHandler adds a storage folder to the storage manager.
 func (api *API) storageFoldersAddHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	folderPath := req.FormValue("path")
@@ -149,6 +622,49 @@ func (api *API) storageFoldersAddHandler(w http.ResponseWriter, req *http.Reques
 	WriteSuccess(w)
 }
 
+// storageFoldersMigrateHandlerGET handles the API call that returns the
+// progress of the storage folder migration.
+func (api *API) storageFoldersMigrateHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, StorageMigrationGET{api.host.MigrationStatus()})
+}
+
+// storageFoldersMigrateHandlerPOST handles the API call that starts moving the
+// sectors of a storage folder to a new path.
+func (api *API) storageFoldersMigrateHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	newPath := req.FormValue("newpath")
+	if newPath == "" {
+		WriteError(w, Error{"newpath parameter is required"}, http.StatusBadRequest)
+		return
+	}
+
+	storageFolders := api.host.StorageFolders()
+	folderIndex, err := folderIndex(folderPath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	var rateLimit uint64
+	if req.FormValue("ratelimit") != "" {
+		_, err = fmt.Sscan(req.FormValue("ratelimit"), &rateLimit)
+		if err != nil {
+			WriteError(w, Error{"Malformed ratelimit"}, http.StatusBadRequest)
+			return
+		}
+	}
+	err = api.host.MigrateStorageFolder(folderIndex, newPath, rateLimit)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageFoldersResizeHandler resizes a storage folder in the storage manager.
 func (api *API) storageFoldersResizeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	folderPath := req.FormValue("path")
@@ -1,16 +1,20 @@
 package api
 
 import (
+	"encoding/csv"
 	"io"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/host/storagemanager"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
@@ -111,6 +115,41 @@ func TestStorageHandler(t *testing.T) {
 	if used := sg.Folders[0].Capacity - sg.Folders[0].CapacityRemaining; used != modules.SectorSize {
 		t.Fatalf("expected used capacity to be the size of one sector (%v bytes), got %v bytes", modules.SectorSize, used)
 	}
+
+	// The host should list the contract formed by the renter, holding the
+	// uploaded sector.
+	var hcs HostContractsGET
+	if err := st.getAPI("/host/contracts", &hcs); err != nil {
+		t.Fatal(err)
+	}
+	if len(hcs.Contracts) != 1 || hcs.Contracts[0].SectorCount != 1 || hcs.Contracts[0].Status != "unresolved" {
+		t.Fatal("wrong host contracts:", hcs.Contracts)
+	}
+	var hc HostContractGET
+	if err := st.getAPI("/host/contracts/"+hcs.Contracts[0].ID.String(), &hc); err != nil {
+		t.Fatal(err)
+	}
+	if hc.ID != hcs.Contracts[0].ID || len(hc.ActionItems) == 0 {
+		t.Fatal("wrong contract details:", hc.HostContractDetails)
+	}
+	if err := st.getAPI("/host/contracts?status=failed&proofconfirmed=false", &hcs); err != nil {
+		t.Fatal(err)
+	}
+	if len(hcs.Contracts) != 0 {
+		t.Fatal("filter did not exclude the contract:", hcs.Contracts)
+	}
+	if err := st.getAPI("/host/contracts?status=foo", &hcs); err == nil {
+		t.Fatal("expected error for unknown status")
+	}
+
+	// The storage proof of the contract is not due yet.
+	var hpg HostProofsGET
+	if err := st.getAPI("/host/proofs", &hpg); err != nil {
+		t.Fatal(err)
+	}
+	if len(hpg.Proofs) != 1 || hpg.Proofs[0].ContractID != hc.ID || hpg.Proofs[0].Status != "pending" || hpg.Proofs[0].Warning {
+		t.Fatal("wrong storage proofs:", hpg.Proofs)
+	}
 }
 
 // TestAddFolderNoPath tests that an API call to add a storage folder fails if
@@ -653,6 +692,412 @@ func TestDeleteNonexistentSector(t *testing.T) {
 	}
 }
 
+// TestHostPricing checks that the pricing policy of the host can be viewed
+// and changed through the API.
+func TestHostPricing(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostPricing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var hp HostPricingGET
+	if err := st.getAPI("/host/pricing", &hp); err != nil {
+		t.Fatal(err)
+	}
+	if hp.Mode != "" {
+		t.Fatal("host should start with fixed pricing, got", hp.Mode)
+	}
+
+	pricingValues := url.Values{}
+	pricingValues.Set("mode", modules.HostPricingUtilisation)
+	pricingValues.Set("maxmultiplier", "2.5")
+	if err := st.stdPostAPI("/host/pricing", pricingValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/pricing", &hp); err != nil {
+		t.Fatal(err)
+	}
+	if hp.Mode != modules.HostPricingUtilisation || hp.MaxMultiplier != 2.5 {
+		t.Fatal("pricing policy was not updated:", hp)
+	}
+
+	// Invalid policies are rejected.
+	pricingValues = url.Values{}
+	pricingValues.Set("maxmultiplier", "0.5")
+	if err := st.stdPostAPI("/host/pricing", pricingValues); err == nil {
+		t.Fatal("expected an error for a max multiplier below 1")
+	}
+	pricingValues = url.Values{}
+	pricingValues.Set("mode", "foo")
+	if err := st.stdPostAPI("/host/pricing", pricingValues); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
+
+// TestHostMaintenance checks that the host can be put in and out of
+// maintenance mode through the API.
+func TestHostMaintenance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostMaintenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var hm HostMaintenanceGET
+	if err := st.getAPI("/host/maintenance", &hm); err != nil {
+		t.Fatal(err)
+	}
+	if hm.Enabled || hm.SafeToStop {
+		t.Fatal("host should start out of maintenance mode:", hm)
+	}
+	settingsValues := url.Values{}
+	settingsValues.Set("acceptingcontracts", "true")
+	if err := st.stdPostAPI("/host", settingsValues); err != nil {
+		t.Fatal(err)
+	}
+
+	maintenanceValues := url.Values{}
+	maintenanceValues.Set("enabled", "true")
+	if err := st.stdPostAPI("/host/maintenance", maintenanceValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/maintenance", &hm); err != nil {
+		t.Fatal(err)
+	}
+	if !hm.Enabled || !hm.SafeToStop || hm.ActiveOperations != 0 {
+		t.Fatal("idle host in maintenance mode should be safe to stop:", hm)
+	}
+	var hg HostGET
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if hg.ExternalSettings.AcceptingContracts {
+		t.Fatal("host in maintenance mode should not accept contracts")
+	}
+
+	// The enabled parameter is required.
+	if err := st.stdPostAPI("/host/maintenance", url.Values{}); err == nil {
+		t.Fatal("expected an error for a missing enabled parameter")
+	}
+}
+
+// TestHostAdditionalAddresses checks that the additional addresses of the host
+// can be set and cleared through the API.
+func TestHostAdditionalAddresses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostAdditionalAddresses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	settingsValues := url.Values{}
+	settingsValues.Set("netaddress", "foo.com:1234")
+	settingsValues.Set("additionaladdresses", "1.2.3.4:1234, [2001:db8::1]:1234")
+	if err := st.stdPostAPI("/host", settingsValues); err != nil {
+		t.Fatal(err)
+	}
+	var hg HostGET
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	addrs := hg.InternalSettings.AdditionalAddresses
+	if len(addrs) != 2 || addrs[0] != "1.2.3.4:1234" || addrs[1] != "[2001:db8::1]:1234" {
+		t.Fatal("additional addresses were not set:", addrs)
+	}
+
+	// Settings that do not mention the additional addresses keep them.
+	settingsValues = url.Values{}
+	settingsValues.Set("maxduration", "1000")
+	if err := st.stdPostAPI("/host", settingsValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if len(hg.InternalSettings.AdditionalAddresses) != 2 {
+		t.Fatal("additional addresses were dropped:", hg.InternalSettings.AdditionalAddresses)
+	}
+
+	// An invalid address is rejected, and an empty list clears the addresses.
+	settingsValues = url.Values{}
+	settingsValues.Set("additionaladdresses", "1.2.3.4")
+	if err := st.stdPostAPI("/host", settingsValues); err == nil {
+		t.Fatal("expected an error for an invalid additional address")
+	}
+	settingsValues.Set("additionaladdresses", "")
+	if err := st.stdPostAPI("/host", settingsValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if len(hg.InternalSettings.AdditionalAddresses) != 0 {
+		t.Fatal("additional addresses were not cleared:", hg.InternalSettings.AdditionalAddresses)
+	}
+}
+
+// TestHostRenters checks that the policies of individual renters can be
+// managed through the API.
+func TestHostRenters(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostRenters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var hr HostRentersGET
+	if err := st.getAPI("/host/renters", &hr); err != nil {
+		t.Fatal(err)
+	}
+	if len(hr.Policies) != 0 {
+		t.Fatal("host should start without renter policies:", hr.Policies)
+	}
+
+	_, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: pk[:]}
+	key := spk.String()
+	renterValues := url.Values{}
+	renterValues.Set("key", key)
+	renterValues.Set("maxstorage", "1000000")
+	renterValues.Set("maxcontracts", "2")
+	if err := st.stdPostAPI("/host/renters", renterValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// Limits that are not provided keep their value.
+	renterValues = url.Values{}
+	renterValues.Set("key", key)
+	renterValues.Set("blocked", "true")
+	if err := st.stdPostAPI("/host/renters", renterValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/renters", &hr); err != nil {
+		t.Fatal(err)
+	}
+	if len(hr.Policies) != 1 || hr.Policies[0].RenterKey.String() != key || !hr.Policies[0].Blocked || hr.Policies[0].MaxStorage != 1000000 || hr.Policies[0].MaxContracts != 2 || hr.Policies[0].MaxRevisionsPerMinute != 0 {
+		t.Fatal("renter policy was not set:", hr.Policies)
+	}
+
+	// Malformed keys and values are rejected.
+	renterValues = url.Values{}
+	renterValues.Set("key", "ed25519:abcd")
+	if err := st.stdPostAPI("/host/renters", renterValues); err == nil {
+		t.Fatal("expected an error for a short renter key")
+	}
+	renterValues.Set("key", key)
+	renterValues.Set("maxstorage", "-1")
+	if err := st.stdPostAPI("/host/renters", renterValues); err == nil {
+		t.Fatal("expected an error for a malformed maxstorage")
+	}
+
+	removeValues := url.Values{}
+	removeValues.Set("key", key)
+	if err := st.stdPostAPI("/host/renters/remove", removeValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/renters", &hr); err != nil {
+		t.Fatal(err)
+	}
+	if len(hr.Policies) != 0 {
+		t.Fatal("renter policy was not removed:", hr.Policies)
+	}
+	if err := st.stdPostAPI("/host/renters/remove", removeValues); err == nil {
+		t.Fatal("expected an error when removing an unknown renter policy")
+	}
+}
+
+// TestStorageCheck checks that the consistency of the host's sectors can be
+// checked through the API.
+func TestStorageCheck(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestStorageCheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	addValues := url.Values{}
+	addValues.Set("path", st.dir)
+	addValues.Set("size", mediumSizeFolderString)
+	if err := st.stdPostAPI("/host/storage/folders/add", addValues); err != nil {
+		t.Fatal(err)
+	}
+
+	var sc StorageCheckGET
+	if err := st.getAPI("/host/storage/check", &sc); err != nil {
+		t.Fatal(err)
+	}
+	if sc.SectorsChecked != 0 || len(sc.MissingSectors) != 0 || len(sc.OrphanedFiles) != 0 || len(sc.MiscountedFolders) != 0 {
+		t.Fatal("empty host should be consistent:", sc)
+	}
+	if err := st.stdPostAPI("/host/storage/check", url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStorageScrub checks that the sector scrubber can be configured through
+// the API.
+func TestStorageScrub(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestStorageScrub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var ss StorageScrubGET
+	if err := st.getAPI("/host/storage/scrub", &ss); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Period != 0 {
+		t.Fatal("scrubber should be disabled by default in testing")
+	}
+
+	scrubValues := url.Values{}
+	scrubValues.Set("period", "1h")
+	scrubValues.Set("ratelimit", "1024")
+	if err := st.stdPostAPI("/host/storage/scrub", scrubValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/storage/scrub", &ss); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Period != time.Hour || ss.RateLimit != 1024 {
+		t.Fatal("scrub settings were not updated:", ss.Period, ss.RateLimit)
+	}
+
+	// The rate limit is kept if only the period is changed.
+	scrubValues = url.Values{}
+	scrubValues.Set("period", "0s")
+	if err := st.stdPostAPI("/host/storage/scrub", scrubValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/storage/scrub", &ss); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Period != 0 || ss.RateLimit != 1024 {
+		t.Fatal("scrub settings were not updated:", ss.Period, ss.RateLimit)
+	}
+
+	scrubValues.Set("period", "-1h")
+	if err := st.stdPostAPI("/host/storage/scrub", scrubValues); err == nil {
+		t.Fatal("negative scrub period was accepted")
+	}
+}
+
+// TestHostLedger checks that the host ledger can be exported as JSON and CSV.
+func TestHostLedger(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostLedger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var hlg HostLedgerGET
+	if err := st.getAPI("/host/ledger?start=0", &hlg); err != nil {
+		t.Fatal(err)
+	}
+	if len(hlg.Entries) != 0 {
+		t.Fatal("host should start with an empty ledger:", hlg.Entries)
+	}
+	if err := st.getAPI("/host/ledger?format=xml", &hlg); err == nil {
+		t.Fatal("unknown format was accepted")
+	}
+	if err := st.getAPI("/host/ledger?start=10&end=5", &hlg); err == nil {
+		t.Fatal("range that ends before it starts was accepted")
+	}
+
+	resp, err := HttpGET("http://" + st.server.listener.Addr().String() + "/host/ledger?format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0]) != len(ledgerCSVHeader) || records[0][0] != "contractid" {
+		t.Fatal("CSV export should only contain the header:", records)
+	}
+}
+
+// TestStorageFolderMigrate checks that a storage folder can be moved to a new
+// path through /host/storage/folders/migrate.
+func TestStorageFolderMigrate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestStorageFolderMigrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+	newPath := build.TempDir("api", "TestStorageFolderMigrate", "newdisk")
+	if err := os.MkdirAll(newPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both paths are required.
+	migrateValues := url.Values{}
+	migrateValues.Set("path", st.dir)
+	if err := st.stdPostAPI("/host/storage/folders/migrate", migrateValues); err == nil {
+		t.Fatal("migration without a new path was accepted")
+	}
+	migrateValues.Set("newpath", newPath)
+	migrateValues.Set("ratelimit", "1048576")
+	if err := st.stdPostAPI("/host/storage/folders/migrate", migrateValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// The storage folder is empty, so the migration finishes quickly.
+	var ms StorageMigrationGET
+	for i := 0; i < 50; i++ {
+		if err := st.getAPI("/host/storage/folders/migrate", &ms); err != nil {
+			t.Fatal(err)
+		}
+		if !ms.Active {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if ms.Active || ms.Completed.IsZero() || ms.SourcePath != st.dir || ms.DestinationPath != newPath || ms.RateLimit != 1048576 {
+		t.Fatal("migration did not finish:", ms)
+	}
+	var sg StorageGET
+	if err := st.getAPI("/host/storage", &sg); err != nil {
+		t.Fatal(err)
+	}
+	if len(sg.Folders) != 1 || sg.Folders[0].Path != newPath {
+		t.Fatal("storage folder was not moved:", sg.Folders)
+	}
+}
+
 /*
 // TestIntegrationRenewing tests that the renter and host manage contract
 // renewals properly.
@@ -713,3 +1158,86 @@ func TestIntegrationRenewing(t *testing.T) {
 	}
 }
 */
+
+// TestHostContractPolicy checks that the contract policy of the host can be
+// viewed and changed through the API.
+func TestHostContractPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostContractPolicy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var hcp HostContractPolicyGET
+	if err := st.getAPI("/host/contractpolicy", &hcp); err != nil {
+		t.Fatal(err)
+	}
+	if hcp.MinDuration != 0 || hcp.MaxDuration != 0 || hcp.RequireAllowlist || len(hcp.AllowedRenters) != 0 {
+		t.Fatal("host should start with an empty contract policy:", hcp)
+	}
+
+	_, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: pk[:]}
+	key := spk.String()
+	policyValues := url.Values{}
+	policyValues.Set("minduration", "10")
+	policyValues.Set("maxduration", "1000")
+	policyValues.Set("minpayout", "1000000")
+	policyValues.Set("requireallowlist", "true")
+	policyValues.Set("allowedrenters", key)
+	policyValues.Set("maxcollateralpercent", "12.5")
+	policyValues.Set("refusefailedrenewals", "true")
+	if err := st.stdPostAPI("/host/contractpolicy", policyValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rules that are not provided keep their value.
+	policyValues = url.Values{}
+	policyValues.Set("minduration", "20")
+	if err := st.stdPostAPI("/host/contractpolicy", policyValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/contractpolicy", &hcp); err != nil {
+		t.Fatal(err)
+	}
+	if hcp.MinDuration != 20 || hcp.MaxDuration != 1000 || hcp.MinPayout.Cmp(types.NewCurrency64(1000000)) != 0 || !hcp.RequireAllowlist ||
+		len(hcp.AllowedRenters) != 1 || hcp.AllowedRenters[0].String() != key || hcp.MaxCollateralPercent != 12.5 || !hcp.RefuseFailedRenewals {
+		t.Fatal("contract policy was not set:", hcp)
+	}
+
+	// Malformed and invalid values are rejected.
+	policyValues = url.Values{}
+	policyValues.Set("allowedrenters", "ed25519:abcd")
+	if err := st.stdPostAPI("/host/contractpolicy", policyValues); err == nil {
+		t.Fatal("expected an error for a short renter key")
+	}
+	policyValues = url.Values{}
+	policyValues.Set("maxcollateralpercent", "150")
+	if err := st.stdPostAPI("/host/contractpolicy", policyValues); err == nil {
+		t.Fatal("expected an error for a collateral percentage above 100")
+	}
+	policyValues = url.Values{}
+	policyValues.Set("minduration", "2000")
+	if err := st.stdPostAPI("/host/contractpolicy", policyValues); err == nil {
+		t.Fatal("expected an error for a min duration above the max duration")
+	}
+
+	// An empty allowlist clears the list.
+	policyValues = url.Values{}
+	policyValues.Set("allowedrenters", "")
+	if err := st.stdPostAPI("/host/contractpolicy", policyValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/contractpolicy", &hcp); err != nil {
+		t.Fatal(err)
+	}
+	if len(hcp.AllowedRenters) != 0 || !hcp.RequireAllowlist {
+		t.Fatal("allowlist was not cleared:", hcp)
+	}
+}
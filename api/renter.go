@@ -1,10 +1,12 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
@@ -43,6 +45,10 @@ type (
 		NetAddress  modules.NetAddress   `json:"netaddress"`
 		RenterFunds types.Currency       `json:"renterfunds"`
 		Size        uint64               `json:"size"`
+
+		// SubnetConflict is true if the contract's host shares a subnet with
+		// the host of another contract.
+		SubnetConflict bool `json:"subnetconflict"`
 	}
 
 	// RenterContracts contains the renter's contracts.
@@ -50,6 +56,20 @@ type (
 		Contracts []RenterContract `json:"contracts"`
 	}
 
+	// RenterContractAttempts contains the results of the renter's most
+	// recent attempts to form and to renew contracts.
+	RenterContractAttempts struct {
+		LastFormation modules.ContractAttempt `json:"lastformation"`
+		LastRenewal   modules.ContractAttempt `json:"lastrenewal"`
+	}
+
+	// RenterSpending contains the spending report of a single contract
+	// period, along with the index of the current period.
+	RenterSpending struct {
+		CurrentPeriod uint64                       `json:"currentperiod"`
+		Spending      modules.RenterPeriodSpending `json:"spending"`
+	}
+
 	// DownloadQueue contains the renter's download queue.
 	RenterDownloadQueue struct {
 		Downloads []modules.DownloadInfo `json:"downloads"`
@@ -79,6 +99,42 @@ type (
 	AllHosts struct {
 		Hosts []modules.HostDBEntry `json:"hosts"`
 	}
+
+	// HostdbHosts contains a page of the hosts that matched a search, along
+	// with the total number of matching hosts.
+	HostdbHosts struct {
+		Hosts []modules.HostDBSummary `json:"hosts"`
+		Total int                     `json:"total"`
+	}
+
+	// HostDetails contains the hostdb's record of a single host, including
+	// its scan history, uptime, and score breakdown.
+	HostDetails struct {
+		modules.HostDBEntryDetails
+	}
+
+	// HostdbWeights contains the weights used by the hostdb to score hosts.
+	HostdbWeights struct {
+		Weights modules.HostScoreWeights `json:"weights"`
+	}
+
+	// HostdbSubnets contains the sizes of the subnets that the hostdb groups
+	// hosts by.
+	HostdbSubnets struct {
+		SubnetSizes modules.HostSubnetSizes `json:"subnetsizes"`
+	}
+
+	// HostdbBenchmarks contains the settings that control the hostdb's
+	// benchmarking of hosts.
+	HostdbBenchmarks struct {
+		BenchmarkSettings modules.HostBenchmarkSettings `json:"benchmarksettings"`
+	}
+
+	// HostdbPrune contains how long a host can be offline before it is
+	// removed from the hostdb.
+	HostdbPrune struct {
+		MaxDowntime time.Duration `json:"maxdowntime"`
+	}
 )
 
 // renterHandlerGET handles the API call to /renter.
@@ -89,13 +145,13 @@ func (api *API) renterHandlerGET(w http.ResponseWriter, req *http.Request, _ htt
 	})
 }
 
-// renterHandlerPOST handles the API call to set the Renter's settings.
-func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+// scanRenterSettings parses the renter settings of a POST to /renter. The
+// renewal policy is only changed if specified.
+func (api *API) scanRenterSettings(req *http.Request) (modules.RenterSettings, error) {
 	// scan values
 	funds, ok := scanAmount(req.FormValue("funds"))
 	if !ok {
-		WriteError(w, Error{"Couldn't parse funds"}, http.StatusBadRequest)
-		return
+		return modules.RenterSettings{}, errors.New("Couldn't parse funds")
 	}
 	// var hosts uint64
 	// _, err := fmt.Sscan(req.FormValue("hosts"), &hosts)
@@ -106,26 +162,49 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 	var period types.BlockHeight
 	_, err := fmt.Sscan(req.FormValue("period"), &period)
 	if err != nil {
-		WriteError(w, Error{"Couldn't parse period: " + err.Error()}, http.StatusBadRequest)
-		return
+		return modules.RenterSettings{}, errors.New("Couldn't parse period: " + err.Error())
 	}
-	// var renewWindow types.BlockHeight
-	// _, err = fmt.Sscan(req.FormValue("renewwindow"), &renewWindow)
-	// if err != nil {
-	// 	WriteError(w, Error{"Couldn't parse renewwindow: "+err.Error()}, http.StatusBadRequest)
-	// 	return
-	// }
-
-	err = api.renter.SetSettings(modules.RenterSettings{
+	renewWindow := period / 2
+	if req.FormValue("renewwindow") != "" {
+		_, err = fmt.Sscan(req.FormValue("renewwindow"), &renewWindow)
+		if err != nil {
+			return modules.RenterSettings{}, errors.New("Couldn't parse renewwindow: " + err.Error())
+		}
+	}
+	policy := api.renter.Settings().RenewalPolicy
+	if req.FormValue("maxpriceincrease") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxpriceincrease"), &policy.MaxPriceIncrease)
+		if err != nil {
+			return modules.RenterSettings{}, errors.New("Couldn't parse maxpriceincrease: " + err.Error())
+		}
+	}
+	if req.FormValue("maxfailurerate") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxfailurerate"), &policy.MaxFailureRate)
+		if err != nil {
+			return modules.RenterSettings{}, errors.New("Couldn't parse maxfailurerate: " + err.Error())
+		}
+	}
+	return modules.RenterSettings{
 		Allowance: modules.Allowance{
-			Funds:  funds,
-			Period: period,
+			Funds:       funds,
+			Period:      period,
+			RenewWindow: renewWindow,
 
-			// TODO: let user specify these
-			Hosts:       recommendedHosts,
-			RenewWindow: period / 2,
+			// TODO: let user specify this
+			Hosts: recommendedHosts,
 		},
-	})
+		RenewalPolicy: policy,
+	}, nil
+}
+
+// renterHandlerPOST handles the API call to set the Renter's settings.
+func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	settings, err := api.scanRenterSettings(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.SetSettings(settings)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -133,16 +212,38 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 	WriteSuccess(w)
 }
 
+// renterAllowancePlanHandler handles the API call to preview the contracts
+// that setting an allowance would form or renew. It takes the same parameters
+// as a POST to /renter, and does not change the renter's settings.
+func (api *API) renterAllowancePlanHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	settings, err := api.scanRenterSettings(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	plan, err := api.renter.PlanAllowance(settings)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, plan)
+}
+
 // renterContractsHandler handles the API call to request the Renter's contracts.
 func (api *API) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	conflicts := make(map[types.FileContractID]bool)
+	for _, id := range api.renter.SubnetConflicts() {
+		conflicts[id] = true
+	}
 	contracts := []RenterContract{}
 	for _, c := range api.renter.Contracts() {
 		contracts = append(contracts, RenterContract{
-			EndHeight:   c.EndHeight(),
-			ID:          c.ID,
-			NetAddress:  c.NetAddress,
-			RenterFunds: c.RenterFunds(),
-			Size:        modules.SectorSize * uint64(len(c.MerkleRoots)),
+			EndHeight:      c.EndHeight(),
+			ID:             c.ID,
+			NetAddress:     c.NetAddress,
+			RenterFunds:    c.RenterFunds(),
+			Size:           modules.SectorSize * uint64(len(c.MerkleRoots)),
+			SubnetConflict: conflicts[c.ID],
 		})
 	}
 	WriteJSON(w, RenterContracts{
@@ -150,6 +251,41 @@ func (api *API) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _
 	})
 }
 
+// renterContractAttemptsHandler handles the API call to request the results
+// of the most recent contract formation and renewal attempts.
+func (api *API) renterContractAttemptsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	formation, renewal := api.renter.ContractAttempts()
+	WriteJSON(w, RenterContractAttempts{
+		LastFormation: formation,
+		LastRenewal:   renewal,
+	})
+}
+
+// renterSpendingHandler handles the API call to request the spending report
+// of a contract period. If no period is specified, the current period is
+// reported.
+func (api *API) renterSpendingHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	current := api.renter.CurrentPeriodSpending()
+	spending := current
+	if req.FormValue("period") != "" {
+		var period uint64
+		_, err := fmt.Sscan(req.FormValue("period"), &period)
+		if err != nil {
+			WriteError(w, Error{"Couldn't parse period: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		spending, err = api.renter.PeriodSpending(period)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	WriteJSON(w, RenterSpending{
+		CurrentPeriod: current.Period,
+		Spending:      spending,
+	})
+}
+
 // renterDownloadsHandler handles the API call to request the download queue.
 func (api *API) renterDownloadsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	WriteJSON(w, RenterDownloadQueue{
@@ -323,3 +459,206 @@ func (api *API) renterHostsAllHandler(w http.ResponseWriter, req *http.Request,
 		Hosts: api.renter.AllHosts(),
 	})
 }
+
+// renterHostsDetailsHandler handles the API call asking for the scan history
+// and uptime of a single host.
+func (api *API) renterHostsDetailsHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var pk types.SiaPublicKey
+	if err := pk.LoadString(ps.ByName("pubkey")); err != nil {
+		WriteError(w, Error{"Couldn't parse public key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	details, ok := api.renter.HostDetails(pk)
+	if !ok {
+		WriteError(w, Error{"no host with that public key is known to the hostdb"}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostDetails{details})
+}
+
+// renterHostsWeightsHandlerGET handles the API call asking for the weights
+// used to score hosts.
+func (api *API) renterHostsWeightsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostdbWeights{
+		Weights: api.renter.HostScoreWeights(),
+	})
+}
+
+// renterHostsWeightsHandlerPOST handles the API call to set the weights used
+// to score hosts. Factors that are not specified keep their current weight.
+func (api *API) renterHostsWeightsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	weights := api.renter.HostScoreWeights()
+	for _, factor := range []struct {
+		name   string
+		weight *float64
+	}{
+		{"uptime", &weights.Uptime},
+		{"age", &weights.Age},
+		{"storage", &weights.Storage},
+		{"version", &weights.Version},
+		{"latency", &weights.Latency},
+		{"throughput", &weights.Throughput},
+	} {
+		if req.FormValue(factor.name) == "" {
+			continue
+		}
+		if _, err := fmt.Sscan(req.FormValue(factor.name), factor.weight); err != nil {
+			WriteError(w, Error{"Couldn't parse " + factor.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := api.renter.SetHostScoreWeights(weights); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterHostsSubnetsHandlerGET handles the API call asking for the sizes of
+// the subnets that hosts are grouped by.
+func (api *API) renterHostsSubnetsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostdbSubnets{
+		SubnetSizes: api.renter.HostSubnetSizes(),
+	})
+}
+
+// renterHostsSubnetsHandlerPOST handles the API call to set the sizes of the
+// subnets that hosts are grouped by. Sizes that are not specified are
+// unchanged.
+func (api *API) renterHostsSubnetsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sizes := api.renter.HostSubnetSizes()
+	if req.FormValue("ipv4") != "" {
+		if _, err := fmt.Sscan(req.FormValue("ipv4"), &sizes.IPv4); err != nil {
+			WriteError(w, Error{"Couldn't parse ipv4: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("ipv6") != "" {
+		if _, err := fmt.Sscan(req.FormValue("ipv6"), &sizes.IPv6); err != nil {
+			WriteError(w, Error{"Couldn't parse ipv6: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := api.renter.SetHostSubnetSizes(sizes); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterHostsBenchmarksHandlerGET handles the API call asking for the
+// settings that control the benchmarking of hosts.
+func (api *API) renterHostsBenchmarksHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostdbBenchmarks{
+		BenchmarkSettings: api.renter.HostBenchmarkSettings(),
+	})
+}
+
+// renterHostsBenchmarksHandlerPOST handles the API call to set the settings
+// that control the benchmarking of hosts. Settings that are not specified are
+// unchanged.
+func (api *API) renterHostsBenchmarksHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	settings := api.renter.HostBenchmarkSettings()
+	if req.FormValue("enabled") != "" {
+		if _, err := fmt.Sscan(req.FormValue("enabled"), &settings.Enabled); err != nil {
+			WriteError(w, Error{"Couldn't parse enabled: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("downloads") != "" {
+		if _, err := fmt.Sscan(req.FormValue("downloads"), &settings.Downloads); err != nil {
+			WriteError(w, Error{"Couldn't parse downloads: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("interval") != "" {
+		interval, err := time.ParseDuration(req.FormValue("interval"))
+		if err != nil {
+			WriteError(w, Error{"Couldn't parse interval: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.Interval = interval
+	}
+	if err := api.renter.SetHostBenchmarkSettings(settings); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterHostsPruneHandlerGET handles the API call asking for how long a host
+// can be offline before it is removed from the hostdb.
+func (api *API) renterHostsPruneHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostdbPrune{
+		MaxDowntime: api.renter.HostPruneDowntime(),
+	})
+}
+
+// renterHostsPruneHandlerPOST handles the API call to set how long a host can
+// be offline before it is removed from the hostdb.
+func (api *API) renterHostsPruneHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	maxDowntime, err := time.ParseDuration(req.FormValue("maxdowntime"))
+	if err != nil {
+		WriteError(w, Error{"Couldn't parse maxdowntime: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.SetHostPruneDowntime(maxDowntime); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterHostsSearchHandler handles the API call to search for hosts that
+// match a set of filters.
+func (api *API) renterHostsSearchHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var f modules.HostDBFilter
+	for _, param := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"maxstorageprice", &f.MaxStoragePrice},
+		{"maxuploadprice", &f.MaxUploadPrice},
+		{"maxdownloadprice", &f.MaxDownloadPrice},
+		{"minremainingstorage", &f.MinRemainingStorage},
+		{"acceptingcontracts", &f.AcceptingContracts},
+		{"online", &f.Online},
+		{"minuptime", &f.MinUptime},
+		{"offset", &f.Offset},
+		{"limit", &f.Limit},
+	} {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		if _, err := fmt.Sscan(req.FormValue(param.name), param.value); err != nil {
+			WriteError(w, Error{"Couldn't parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if f.Offset < 0 || f.Limit < 0 {
+		WriteError(w, Error{"offset and limit must be non-negative"}, http.StatusBadRequest)
+		return
+	}
+	f.MinVersion = req.FormValue("minversion")
+	if f.MinVersion != "" && !build.IsVersion(f.MinVersion) {
+		WriteError(w, Error{"minversion is not a valid version"}, http.StatusBadRequest)
+		return
+	}
+	switch f.SortBy = req.FormValue("sortby"); f.SortBy {
+	case "":
+		f.SortBy = modules.HostSortScore
+	case modules.HostSortScore, modules.HostSortPrice:
+	default:
+		WriteError(w, Error{"sortby must be either score or price"}, http.StatusBadRequest)
+		return
+	}
+
+	hosts, total := api.renter.SearchHosts(f)
+	if hosts == nil {
+		hosts = []modules.HostDBSummary{}
+	}
+	WriteJSON(w, HostdbHosts{
+		Hosts: hosts,
+		Total: total,
+	})
+}
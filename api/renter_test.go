@@ -7,9 +7,11 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/types"
@@ -220,6 +222,151 @@ func TestRenterHostsActiveHandler(t *testing.T) {
 	if len(ah.Hosts) != 1 {
 		t.Fatal(len(ah.Hosts))
 	}
+
+	// The active host should have a successful scan in its history.
+	var hd HostDetails
+	err = st.getAPI("/hostdb/hosts/"+ah.Hosts[0].PublicKey.String(), &hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hd.Entry.NetAddress != ah.Hosts[0].NetAddress || !hd.Online {
+		t.Fatal("wrong host details:", hd.Entry.NetAddress, hd.Online)
+	}
+	if len(hd.ScanHistory) == 0 || !hd.ScanHistory[len(hd.ScanHistory)-1].Success {
+		t.Fatal("expected a successful scan, got", hd.ScanHistory)
+	}
+	if hd.Uptime == 0 {
+		t.Fatal("expected non-zero uptime")
+	}
+	if len(hd.AddressHistory) != 1 || hd.AddressHistory[0].NetAddress != hd.Entry.NetAddress {
+		t.Fatal("wrong address history:", hd.AddressHistory)
+	}
+	if hd.ScoreBreakdown.Score.IsZero() || hd.ScoreBreakdown.Uptime != 1 {
+		t.Fatal("wrong score breakdown:", hd.ScoreBreakdown)
+	}
+
+	// Ignoring uptime and age should not change the uptime multiplier of a
+	// host that has always been online, but should remove the age penalty.
+	var hw HostdbWeights
+	if err = st.getAPI("/hostdb/weights", &hw); err != nil {
+		t.Fatal(err)
+	}
+	if hw.Weights.Uptime == 0 {
+		t.Fatal("expected default weights, got", hw.Weights)
+	}
+	weightValues := url.Values{}
+	weightValues.Set("uptime", "0")
+	weightValues.Set("age", "0")
+	if err = st.stdPostAPI("/hostdb/weights", weightValues); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/hostdb/hosts/"+ah.Hosts[0].PublicKey.String(), &hd); err != nil {
+		t.Fatal(err)
+	}
+	if hd.ScoreBreakdown.Age != 1 || hd.ScoreBreakdown.Uptime != 1 {
+		t.Fatal("weights were not applied:", hd.ScoreBreakdown)
+	}
+	weightValues.Set("latency", "11")
+	if err = st.stdPostAPI("/hostdb/weights", weightValues); err == nil {
+		t.Fatal("expected error for out-of-range weight")
+	}
+
+	// Benchmarks are disabled by default.
+	var hb HostdbBenchmarks
+	if err = st.getAPI("/hostdb/benchmarks", &hb); err != nil {
+		t.Fatal(err)
+	}
+	if hb.BenchmarkSettings.Enabled || hb.BenchmarkSettings.Downloads {
+		t.Fatal("benchmarks should be disabled by default:", hb.BenchmarkSettings)
+	}
+	benchmarkValues := url.Values{}
+	benchmarkValues.Set("enabled", "true")
+	benchmarkValues.Set("interval", "30m")
+	if err = st.stdPostAPI("/hostdb/benchmarks", benchmarkValues); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/hostdb/benchmarks", &hb); err != nil {
+		t.Fatal(err)
+	}
+	if !hb.BenchmarkSettings.Enabled || hb.BenchmarkSettings.Downloads || hb.BenchmarkSettings.Interval != 30*time.Minute {
+		t.Fatal("benchmark settings were not updated:", hb.BenchmarkSettings)
+	}
+	benchmarkValues.Set("interval", "1m")
+	if err = st.stdPostAPI("/hostdb/benchmarks", benchmarkValues); err == nil {
+		t.Fatal("expected error for short benchmark interval")
+	}
+
+	// Offline hosts are pruned after 30 days by default.
+	var hp HostdbPrune
+	if err = st.getAPI("/hostdb/prune", &hp); err != nil {
+		t.Fatal(err)
+	}
+	if hp.MaxDowntime != 30*24*time.Hour {
+		t.Fatal("wrong default prune downtime:", hp.MaxDowntime)
+	}
+	pruneValues := url.Values{}
+	pruneValues.Set("maxdowntime", "72h")
+	if err = st.stdPostAPI("/hostdb/prune", pruneValues); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/hostdb/prune", &hp); err != nil {
+		t.Fatal(err)
+	}
+	if hp.MaxDowntime != 72*time.Hour {
+		t.Fatal("prune downtime was not updated:", hp.MaxDowntime)
+	}
+	pruneValues.Set("maxdowntime", "1h")
+	if err = st.stdPostAPI("/hostdb/prune", pruneValues); err == nil {
+		t.Fatal("expected error for short prune downtime")
+	}
+
+	// Check the default subnet sizes, then change them.
+	var hs HostdbSubnets
+	if err = st.getAPI("/hostdb/subnets", &hs); err != nil {
+		t.Fatal(err)
+	}
+	if hs.SubnetSizes.IPv4 != 24 || hs.SubnetSizes.IPv6 != 48 {
+		t.Fatal("wrong default subnet sizes:", hs.SubnetSizes)
+	}
+	subnetValues := url.Values{}
+	subnetValues.Set("ipv4", "16")
+	if err = st.stdPostAPI("/hostdb/subnets", subnetValues); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/hostdb/subnets", &hs); err != nil {
+		t.Fatal(err)
+	}
+	if hs.SubnetSizes.IPv4 != 16 || hs.SubnetSizes.IPv6 != 48 {
+		t.Fatal("subnet sizes were not updated:", hs.SubnetSizes)
+	}
+	subnetValues.Set("ipv6", "129")
+	if err = st.stdPostAPI("/hostdb/subnets", subnetValues); err == nil {
+		t.Fatal("expected error for out-of-range subnet size")
+	}
+	// Search for hosts.
+	var hh HostdbHosts
+	if err = st.getAPI("/hostdb/hosts?online=true&sortby=price", &hh); err != nil {
+		t.Fatal(err)
+	}
+	if hh.Total != 1 || len(hh.Hosts) != 1 || !hh.Hosts[0].Online {
+		t.Fatal("expected one online host, got", hh)
+	}
+	if err = st.getAPI("/hostdb/hosts?maxstorageprice=1", &hh); err != nil {
+		t.Fatal(err)
+	}
+	if hh.Total != 0 || len(hh.Hosts) != 0 {
+		t.Fatal("expected the host to be filtered out, got", hh)
+	}
+	if err = st.getAPI("/hostdb/hosts?sortby=foo", &hh); err == nil {
+		t.Fatal("expected error for invalid sortby")
+	}
+	// Unknown and malformed keys should be rejected.
+	if err = st.getAPI("/hostdb/hosts/ed25519:00", &hd); err == nil {
+		t.Fatal("expected error for unknown host")
+	}
+	if err = st.getAPI("/hostdb/hosts/foo", &hd); err == nil {
+		t.Fatal("expected error for malformed public key")
+	}
 }
 
 // TestRenterHostsAllHandler checks that announcing a host adds it to the list
@@ -302,6 +449,9 @@ func TestRenterHandlerContracts(t *testing.T) {
 	if len(contracts.Contracts) != 1 {
 		t.Fatalf("expected renter to have 1 contract; got %v", len(contracts.Contracts))
 	}
+	if contracts.Contracts[0].SubnetConflict {
+		t.Fatal("a single contract cannot conflict with another contract")
+	}
 
 	// Check the renter's contract spending.
 	var get RenterGET
@@ -316,6 +466,163 @@ func TestRenterHandlerContracts(t *testing.T) {
 		t.Fatalf("expected contract spending to be %v; got %v", expectedContractSpending, got)
 	}
 
+	// The formation attempt should be reported.
+	var attempts RenterContractAttempts
+	if err = st.getAPI("/renter/contracts/attempts", &attempts); err != nil {
+		t.Fatal(err)
+	}
+	if f := attempts.LastFormation; f.Wanted != 1 || f.Formed != 1 || len(f.Failures) != 0 {
+		t.Fatalf("expected 1 of 1 contracts formed without failures; got %+v", f)
+	}
+}
+
+// TestRenterHandlerSpending checks that /renter/spending reports the cost of
+// the contracts formed in the current period.
+func TestRenterHandlerSpending(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	st, err := createServerTester("TestRenterHandlerSpending")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Anounce the host and start accepting contracts.
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an allowance for the renter, allowing a contract to be formed.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// The current period should account for the contract.
+	var spending RenterSpending
+	if err = st.getAPI("/renter/spending", &spending); err != nil {
+		t.Fatal(err)
+	}
+	if spending.CurrentPeriod != 0 || spending.Spending.Period != 0 {
+		t.Fatalf("expected period 0; got %v", spending.Spending.Period)
+	}
+	var contracts RenterContracts
+	if err = st.getAPI("/renter/contracts", &contracts); err != nil {
+		t.Fatal(err)
+	}
+	var expectedRefund types.Currency
+	for _, contract := range contracts.Contracts {
+		expectedRefund = expectedRefund.Add(contract.RenterFunds)
+	}
+	if got := spending.Spending.ExpectedRefund; got.Cmp(expectedRefund) != 0 {
+		t.Fatalf("expected refund to be %v; got %v", expectedRefund, got)
+	}
+	if spending.Spending.ContractSpending.Cmp(spending.Spending.LockedFunds) <= 0 {
+		t.Fatal("contract spending should include fees")
+	}
+
+	// Requesting a period that does not exist should fail.
+	if err = st.getAPI("/renter/spending?period=5", &spending); err == nil {
+		t.Fatal("expected an error for an unknown period")
+	}
+}
+
+// TestRenterHandlerAllowancePlan checks that /renter/allowance/plan reports
+// the contracts that setting an allowance would form, without forming them.
+func TestRenterHandlerAllowancePlan(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	st, err := createServerTester("TestRenterHandlerAllowancePlan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid parameters should be rejected.
+	var plan modules.AllowancePlan
+	if err = st.getAPI("/renter/allowance/plan?funds=foo&period="+testPeriod, &plan); err == nil {
+		t.Fatal("expected error for invalid funds")
+	}
+	if err = st.getAPI("/renter/allowance/plan?funds="+testFunds+"&period=0", &plan); err == nil {
+		t.Fatal("expected error for zero period")
+	}
+	if err = st.getAPI("/renter/allowance/plan?funds="+testFunds+"&period="+testPeriod+"&renewwindow="+testPeriod, &plan); err == nil {
+		t.Fatal("expected error for renew window equal to period")
+	}
+	if err = st.getAPI("/renter/allowance/plan?funds="+testFunds+"&period="+testPeriod+"&maxfailurerate=101", &plan); err == nil {
+		t.Fatal("expected error for failure rate above 100")
+	}
+
+	// The renew window should be used if specified.
+	if err = st.getAPI("/renter/allowance/plan?funds="+testFunds+"&period="+testPeriod+"&renewwindow=1", &plan); err != nil {
+		t.Fatal(err)
+	}
+	if plan.Allowance.RenewWindow != 1 {
+		t.Fatal("expected renew window of 1; got", plan.Allowance.RenewWindow)
+	}
+
+	// Plan an allowance. A single contract should be formed with the host.
+	if err = st.getAPI("/renter/allowance/plan?funds="+testFunds+"&period="+testPeriod, &plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Contracts) != 1 {
+		t.Fatalf("expected plan to contain 1 contract; got %v", len(plan.Contracts))
+	}
+	cp := plan.Contracts[0]
+	if cp.Action != modules.PlanActionForm {
+		t.Fatalf("expected action %q; got %q", modules.PlanActionForm, cp.Action)
+	} else if cp.Sectors == 0 {
+		t.Fatal("planned contract has no sectors")
+	} else if cp.Cost.IsZero() || cp.Cost.Cmp(plan.TotalCost) != 0 {
+		t.Fatalf("contract cost %v does not match total cost %v", cp.Cost, plan.TotalCost)
+	}
+
+	// No contracts should have been formed.
+	var contracts RenterContracts
+	if err = st.getAPI("/renter/contracts", &contracts); err != nil {
+		t.Fatal(err)
+	}
+	if len(contracts.Contracts) != 0 {
+		t.Fatalf("expected renter to have 0 contracts; got %v", len(contracts.Contracts))
+	}
+
+	// After setting the same allowance, the plan should keep the contract.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/renter/allowance/plan?funds="+testFunds+"&period="+testPeriod, &plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Contracts) != 1 || plan.Contracts[0].Action != modules.PlanActionKeep {
+		t.Fatalf("expected plan to keep 1 contract; got %v", plan.Contracts)
+	} else if !plan.TotalCost.IsZero() {
+		t.Fatal("keeping contracts should not cost anything, got", plan.TotalCost)
+	}
 }
 
 // TestRenterHandlerGetAndPost checks that valid /renter calls successfully set
@@ -414,6 +721,32 @@ func TestRenterHandlerGetAndPost(t *testing.T) {
 	if err == nil || err.Error() != contractor.ErrAllowanceZeroWindow.Error() {
 		t.Errorf("expected error to be %v, got %v", contractor.ErrAllowanceZeroWindow, err)
 	}
+
+	// Set the renew window and renewal policy explicitly.
+	allowanceValues.Set("period", testPeriod)
+	allowanceValues.Set("renewwindow", "1")
+	allowanceValues.Set("maxpriceincrease", "10")
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/renter", &get); err != nil {
+		t.Fatal(err)
+	}
+	if got := get.Settings.Allowance.RenewWindow; got != 1 {
+		t.Fatalf("expected renew window to be 1; got %v", got)
+	}
+	if got := get.Settings.RenewalPolicy.MaxPriceIncrease; got != 10 {
+		t.Fatalf("expected max price increase to be 10; got %v", got)
+	}
+	// The failure rate was not specified, so it should be unchanged.
+	if got := get.Settings.RenewalPolicy.MaxFailureRate; got == 0 {
+		t.Fatal("max failure rate should not have been reset")
+	}
+	// Try an invalid failure rate.
+	allowanceValues.Set("maxfailurerate", "101")
+	if err = st.stdPostAPI("/renter", allowanceValues); err == nil {
+		t.Fatal("expected error for failure rate above 100 percent")
+	}
 }
 
 // TestRenterLoadNonexistent checks that attempting to upload or download a
@@ -148,3 +148,119 @@ func VerifySegment(base []byte, hashSet []Hash, numSegments, proofIndex uint64,
 	}
 	return merkletree.VerifyProof(NewHash(), root[:], proofSet, proofIndex, numSegments)
 }
+
+// leafHash returns the Merkle tree hash of a single segment of data.
+func leafHash(segment []byte) (h Hash) {
+	hasher := NewHash()
+	hasher.Write([]byte{0x00})
+	hasher.Write(segment)
+	copy(h[:], hasher.Sum(nil))
+	return
+}
+
+// nodeHash returns the Merkle tree hash of two sibling subtrees.
+func nodeHash(left, right Hash) (h Hash) {
+	hasher := NewHash()
+	hasher.Write([]byte{0x01})
+	hasher.Write(left[:])
+	hasher.Write(right[:])
+	copy(h[:], hasher.Sum(nil))
+	return
+}
+
+// splitSegments returns the number of segments in the left subtree of a tree
+// holding 'n' segments, which is the largest power of two smaller than 'n'.
+func splitSegments(n uint64) uint64 {
+	split := uint64(1)
+	for split*2 < n {
+		split *= 2
+	}
+	return split
+}
+
+// segmentHashes splits 'b' into segments and returns the leaf hash of each.
+func segmentHashes(b []byte) []Hash {
+	leaves := make([]Hash, 0, CalculateLeaves(uint64(len(b))))
+	buf := bytes.NewBuffer(b)
+	for buf.Len() > 0 {
+		leaves = append(leaves, leafHash(buf.Next(SegmentSize)))
+	}
+	return leaves
+}
+
+// subtreeRoot returns the Merkle root of a list of leaf hashes.
+func subtreeRoot(leaves []Hash) Hash {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	split := splitSegments(uint64(len(leaves)))
+	return nodeHash(subtreeRoot(leaves[:split]), subtreeRoot(leaves[split:]))
+}
+
+// MerkleRangeProof builds a Merkle proof that the segments [start, end) of 'b'
+// are a part of the Merkle root formed by 'b'. The proof holds the roots of
+// the subtrees that do not overlap the range, ordered from left to right. An
+// invalid range results in an empty proof.
+func MerkleRangeProof(b []byte, start, end uint64) []Hash {
+	leaves := segmentHashes(b)
+	if start >= end || end > uint64(len(leaves)) {
+		return nil
+	}
+	var proof []Hash
+	var build func(lo, hi uint64)
+	build = func(lo, hi uint64) {
+		if hi <= start || lo >= end {
+			proof = append(proof, subtreeRoot(leaves[lo:hi]))
+			return
+		} else if start <= lo && hi <= end {
+			return
+		}
+		split := lo + splitSegments(hi-lo)
+		build(lo, split)
+		build(split, hi)
+	}
+	build(0, uint64(len(leaves)))
+	return proof
+}
+
+// VerifyRangeProof verifies that 'data' holds the segments [start, end) of a
+// Merkle tree with 'numSegments' segments and root 'root', using a proof built
+// by MerkleRangeProof. Only the last segment of the tree may be shorter than
+// SegmentSize.
+func VerifyRangeProof(data []byte, proof []Hash, start, end, numSegments uint64, root Hash) bool {
+	if start >= end || end > numSegments || CalculateLeaves(uint64(len(data))) != end-start {
+		return false
+	}
+	if end < numSegments && uint64(len(data)) != (end-start)*SegmentSize {
+		return false
+	}
+	leaves := segmentHashes(data)
+
+	// Rebuild the root, taking subtrees outside of the range from the proof
+	// and subtrees inside of the range from the data.
+	var verify func(lo, hi uint64) (Hash, bool)
+	verify = func(lo, hi uint64) (Hash, bool) {
+		if hi <= start || lo >= end {
+			if len(proof) == 0 {
+				return Hash{}, false
+			}
+			h := proof[0]
+			proof = proof[1:]
+			return h, true
+		} else if start <= lo && hi <= end {
+			return subtreeRoot(leaves[lo-start : hi-start]), true
+		}
+		split := lo + splitSegments(hi-lo)
+		left, ok := verify(lo, split)
+		if !ok {
+			return Hash{}, false
+		}
+		right, ok := verify(split, hi)
+		if !ok {
+			return Hash{}, false
+		}
+		return nodeHash(left, right), true
+	}
+	h, ok := verify(0, numSegments)
+	return ok && len(proof) == 0 && h == root
+}
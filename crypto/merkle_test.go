@@ -179,3 +179,51 @@ func TestOddDataSize(t *testing.T) {
 		}
 	}
 }
+
+// TestMerkleRangeProof checks that range proofs verify against the Merkle root
+// of the data for every range, and that they do not verify tampered data.
+func TestMerkleRangeProof(t *testing.T) {
+	for _, size := range []int{SegmentSize, SegmentSize * 7, SegmentSize*12 + 20} {
+		data, err := RandBytes(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := MerkleRoot(data)
+		numSegments := CalculateLeaves(uint64(size))
+		for start := uint64(0); start < numSegments; start++ {
+			for end := start + 1; end <= numSegments; end++ {
+				rangeEnd := end * SegmentSize
+				if rangeEnd > uint64(size) {
+					rangeEnd = uint64(size)
+				}
+				rangeData := data[start*SegmentSize : rangeEnd]
+				proof := MerkleRangeProof(data, start, end)
+				if !VerifyRangeProof(rangeData, proof, start, end, numSegments, root) {
+					t.Fatal("range proof failed for", size, start, end)
+				}
+
+				// Tampered data, a shifted range, or a truncated proof
+				// should not verify.
+				tampered := append([]byte(nil), rangeData...)
+				tampered[0]++
+				if VerifyRangeProof(tampered, proof, start, end, numSegments, root) {
+					t.Fatal("range proof verified tampered data", size, start, end)
+				}
+				if (end+1)*SegmentSize <= uint64(size) && VerifyRangeProof(data[(start+1)*SegmentSize:(end+1)*SegmentSize], proof, start+1, end+1, numSegments, root) {
+					t.Fatal("range proof verified a shifted range", size, start, end)
+				}
+				if len(proof) > 0 && VerifyRangeProof(rangeData, proof[1:], start, end, numSegments, root) {
+					t.Fatal("truncated range proof verified", size, start, end)
+				}
+			}
+		}
+	}
+
+	// Invalid ranges have empty proofs.
+	if proof := MerkleRangeProof(make([]byte, SegmentSize*4), 2, 2); proof != nil {
+		t.Error("empty range has a proof")
+	}
+	if proof := MerkleRangeProof(make([]byte, SegmentSize*4), 3, 5); proof != nil {
+		t.Error("out of bounds range has a proof")
+	}
+}
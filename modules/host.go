@@ -1,6 +1,9 @@
 package modules
 
 import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -9,6 +12,21 @@ const (
 	HostDir = "host"
 )
 
+const (
+	// HostPricingFixed is the pricing mode in which the host charges exactly
+	// the minimum prices in its internal settings.
+	HostPricingFixed = "fixed"
+
+	// HostPricingUtilisation is the pricing mode in which the host raises
+	// its prices above the minimum prices as its storage fills up.
+	HostPricingUtilisation = "utilisation"
+
+	// HostPricingNetwork is the pricing mode in which the host follows the
+	// prices of the other hosts on the network, never going below the minimum
+	// prices in its internal settings.
+	HostPricingNetwork = "network"
+)
+
 var (
 	// BytesPerTerabyte is the conversion rate between bytes and terabytes.
 	BytesPerTerabyte = types.NewCurrency64(1e12)
@@ -57,6 +75,19 @@ type (
 		NetAddress           NetAddress        `json:"netaddress"`
 		WindowSize           types.BlockHeight `json:"windowsize"`
 
+		// AdditionalAddresses are announced after the NetAddress of the host,
+		// typically the IPv4 and IPv6 endpoints of a host that announces a DNS
+		// name as its NetAddress. Renters try the addresses in order.
+		AdditionalAddresses []NetAddress `json:"additionaladdresses"`
+
+		// Connection and bandwidth limits of the host. MaxDownloadSpeed limits
+		// the data sent to renters and MaxUploadSpeed limits the data received
+		// from renters, both in bytes per second. A limit of 0 is unlimited.
+		MaxConnections      uint64 `json:"maxconnections"`
+		MaxConnectionsPerIP uint64 `json:"maxconnectionsperip"`
+		MaxDownloadSpeed    uint64 `json:"maxdownloadspeed"`
+		MaxUploadSpeed      uint64 `json:"maxuploadspeed"`
+
 		Collateral       types.Currency `json:"collateral"`
 		CollateralBudget types.Currency `json:"collateralbudget"`
 		MaxCollateral    types.Currency `json:"maxcollateral"`
@@ -67,16 +98,186 @@ type (
 		MinUploadBandwidthPrice   types.Currency `json:"minuploadbandwidthprice"`
 	}
 
+	// HostContract summarizes a storage obligation of the host, which is the
+	// host's side of a file contract.
+	HostContract struct {
+		ID            types.FileContractID `json:"id"`
+		Status        string               `json:"status"`
+		SectorCount   uint64               `json:"sectorcount"`
+		DataSize      uint64               `json:"datasize"`
+		Expiration    types.BlockHeight    `json:"expiration"`
+		ProofDeadline types.BlockHeight    `json:"proofdeadline"`
+
+		ContractCost             types.Currency `json:"contractcost"`
+		LockedCollateral         types.Currency `json:"lockedcollateral"`
+		PotentialDownloadRevenue types.Currency `json:"potentialdownloadrevenue"`
+		PotentialStorageRevenue  types.Currency `json:"potentialstoragerevenue"`
+		PotentialUploadRevenue   types.Currency `json:"potentialuploadrevenue"`
+		RiskedCollateral         types.Currency `json:"riskedcollateral"`
+		TransactionFeesAdded     types.Currency `json:"transactionfeesadded"`
+
+		OriginConfirmed   bool `json:"originconfirmed"`
+		RevisionConfirmed bool `json:"revisionconfirmed"`
+		ProofConfirmed    bool `json:"proofconfirmed"`
+	}
+
+	// HostContractPolicy is a set of rules that the host applies to the file
+	// contracts proposed by renters, on top of the checks derived from its
+	// settings. Zero values are ignored. A contract that breaks a rule is
+	// rejected, and the reason is sent to the renter.
+	HostContractPolicy struct {
+		// MinDuration and MaxDuration bound the number of blocks between the
+		// proposal of a contract and the start of its proof window.
+		MinDuration types.BlockHeight `json:"minduration"`
+		MaxDuration types.BlockHeight `json:"maxduration"`
+
+		// MinPayout is the minimum total payout of a contract.
+		MinPayout types.Currency `json:"minpayout"`
+
+		// If RequireAllowlist is set, only the renters in AllowedRenters can
+		// form and renew contracts with the host.
+		RequireAllowlist bool                 `json:"requireallowlist"`
+		AllowedRenters   []types.SiaPublicKey `json:"allowedrenters"`
+
+		// MaxCollateralPercent caps the collateral of a single contract at a
+		// percentage of the collateral budget of the host.
+		MaxCollateralPercent float64 `json:"maxcollateralpercent"`
+
+		// If RefuseFailedRenewals is set, the host will not renew the
+		// contracts of a renter once it has failed to get a storage proof
+		// confirmed for an earlier contract of that renter.
+		RefuseFailedRenewals bool `json:"refusefailedrenewals"`
+	}
+
+	// HostContractDetails contains everything the host knows about one of its
+	// storage obligations, including the heights at which the host will next
+	// check on the obligation.
+	HostContractDetails struct {
+		HostContract
+		RevisionNumber uint64              `json:"revisionnumber"`
+		MerkleRoot     crypto.Hash         `json:"merkleroot"`
+		ActionItems    []types.BlockHeight `json:"actionitems"`
+	}
+
+	// HostContractFilter selects the storage obligations returned by
+	// Host.Contracts. Zero values are ignored.
+	HostContractFilter struct {
+		Status         string
+		MinExpiration  types.BlockHeight
+		MaxExpiration  types.BlockHeight
+		ProofConfirmed *bool
+	}
+
+	// HostLedgerEntry records the outcome of a storage obligation of the host.
+	// An entry is added to the ledger when the obligation is resolved, and is
+	// kept after the obligation itself has been cleaned up. Revenue is only
+	// earned by obligations that succeeded, the revenue of a failed obligation
+	// is counted as lost revenue instead.
+	HostLedgerEntry struct {
+		ContractID  types.FileContractID `json:"contractid"`
+		Status      string               `json:"status"`
+		StartHeight types.BlockHeight    `json:"startheight"`
+		EndHeight   types.BlockHeight    `json:"endheight"`
+		SectorCount uint64               `json:"sectorcount"`
+		DataSize    uint64               `json:"datasize"`
+
+		ContractCompensation     types.Currency `json:"contractcompensation"`
+		StorageRevenue           types.Currency `json:"storagerevenue"`
+		DownloadBandwidthRevenue types.Currency `json:"downloadbandwidthrevenue"`
+		UploadBandwidthRevenue   types.Currency `json:"uploadbandwidthrevenue"`
+		LostRevenue              types.Currency `json:"lostrevenue"`
+
+		LockedCollateral      types.Currency `json:"lockedcollateral"`
+		LostCollateral        types.Currency `json:"lostcollateral"`
+		TransactionFeeExpense types.Currency `json:"transactionfeeexpense"`
+
+		ResolvedHeight types.BlockHeight `json:"resolvedheight"`
+		ResolvedTime   time.Time         `json:"resolvedtime"`
+	}
+
+	// HostRenterPolicy limits the resources that a renter, identified by the
+	// public key in the unlock conditions of its contracts, can use on the
+	// host. A limit of 0 is unlimited. A blocked renter cannot form, renew,
+	// or revise contracts with the host.
+	HostRenterPolicy struct {
+		RenterKey             types.SiaPublicKey `json:"renterkey"`
+		Blocked               bool               `json:"blocked"`
+		MaxContracts          uint64             `json:"maxcontracts"`
+		MaxRevisionsPerMinute uint64             `json:"maxrevisionsperminute"`
+		MaxStorage            uint64             `json:"maxstorage"`
+	}
+
+	// HostStorageProof reports the state of the storage proof of a storage
+	// obligation. Status is one of "pending", "built", "broadcast",
+	// "confirmed", or "failed". Fee is the fee paid by the most recent proof
+	// that was accepted by the transaction pool, and Error is the reason the
+	// most recent attempt failed. Warning is set when the proof is not
+	// confirmed and the end of the proof window is close.
+	HostStorageProof struct {
+		ContractID  types.FileContractID `json:"contractid"`
+		WindowStart types.BlockHeight    `json:"windowstart"`
+		WindowEnd   types.BlockHeight    `json:"windowend"`
+		Status      string               `json:"status"`
+		Attempts    uint64               `json:"attempts"`
+		LastAttempt types.BlockHeight    `json:"lastattempt"`
+		Fee         types.Currency       `json:"fee"`
+		Error       string               `json:"error"`
+		Warning     bool                 `json:"warning"`
+	}
+
+	// HostPricingPolicy configures how the host derives the prices that it
+	// advertises from the minimum prices in its internal settings. The prices
+	// are re-evaluated periodically, and the host makes a new announcement
+	// when they change.
+	HostPricingPolicy struct {
+		// Mode is one of HostPricingFixed, HostPricingUtilisation, or
+		// HostPricingNetwork. An empty mode is the same as HostPricingFixed.
+		Mode string `json:"mode"`
+
+		// MaxMultiplier caps the prices at a multiple of the minimum prices.
+		// In utilisation mode, a full host charges exactly MaxMultiplier times
+		// the minimum prices. In network mode, a MaxMultiplier of 0 leaves the
+		// prices uncapped.
+		MaxMultiplier float64 `json:"maxmultiplier"`
+
+		// Percentile is the point in the distribution of network prices that
+		// the host targets in network mode, from 0 (the cheapest host) to 100
+		// (the most expensive host).
+		Percentile float64 `json:"percentile"`
+	}
+
+	// HostPriceSource provides the settings of the other hosts on the
+	// network, which are needed by the network pricing mode.
+	HostPriceSource interface {
+		ActiveHosts() []HostDBEntry
+	}
+
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
 	// has been made to the host.
 	HostNetworkMetrics struct {
 		DownloadCalls     uint64 `json:"downloadcalls"`
 		ErrorCalls        uint64 `json:"errorcalls"`
 		FormContractCalls uint64 `json:"formcontractcalls"`
+		LostSectorsCalls  uint64 `json:"lostsectorscalls"`
 		RenewCalls        uint64 `json:"renewcalls"`
 		ReviseCalls       uint64 `json:"revisecalls"`
 		SettingsCalls     uint64 `json:"settingscalls"`
 		UnrecognizedCalls uint64 `json:"unrecognizedcalls"`
+
+		// Connections that were refused because the host had reached its
+		// maximum number of connections, in total or for the IP address.
+		ConnectionLimitRejections uint64 `json:"connectionlimitrejections"`
+		IPLimitRejections         uint64 `json:"iplimitrejections"`
+	}
+
+	// HostMaintenanceStatus reports whether the host is in maintenance mode,
+	// and whether it is safe to stop. The host is safe to stop when it is in
+	// maintenance mode and no operation that changes a storage obligation is
+	// in progress.
+	HostMaintenanceStatus struct {
+		Enabled          bool   `json:"enabled"`
+		ActiveOperations uint64 `json:"activeoperations"`
+		SafeToStop       bool   `json:"safetostop"`
 	}
 
 	// A Host can take storage from disk and offer it to the network, managing
@@ -89,6 +290,23 @@ type (
 		// AnnounceAddress submits an announcement using the given address.
 		AnnounceAddress(NetAddress) error
 
+		// CheckStorage compares the sectors of the storage manager with the
+		// sectors referenced by the host's storage obligations, optionally
+		// purging orphaned sector files.
+		CheckStorage(purge bool) (StorageCheckReport, error)
+
+		// ContractPolicy returns the rules that the host applies to the file
+		// contracts proposed by renters.
+		ContractPolicy() HostContractPolicy
+
+		// Contract returns the details of the storage obligation with the
+		// given id, and false if the host has no such obligation.
+		Contract(types.FileContractID) (HostContractDetails, bool, error)
+
+		// Contracts returns the storage obligations that match the filter,
+		// ordered by expiration.
+		Contracts(HostContractFilter) ([]HostContract, error)
+
 		// ExternalSettings returns the settings of the host as seen by an
 		// untrusted node querying the host for settings.
 		ExternalSettings() HostExternalSettings
@@ -100,13 +318,58 @@ type (
 		// potentially private or sensitive information.
 		InternalSettings() HostInternalSettings
 
+		// Ledger returns the ledger entries of the storage obligations that
+		// were resolved between 'start' and 'end', ordered by the time of
+		// resolution. A zero 'end' has no upper bound.
+		Ledger(start, end time.Time) ([]HostLedgerEntry, error)
+
+		// MaintenanceStatus returns the maintenance mode of the host, and
+		// whether the host is safe to stop.
+		MaintenanceStatus() HostMaintenanceStatus
+
 		// NetworkMetrics returns information on the types of RPC calls that
 		// have been made to the host.
 		NetworkMetrics() HostNetworkMetrics
 
+		// PricingPolicy returns the policy the host uses to set its prices.
+		PricingPolicy() HostPricingPolicy
+
+		// RemoveRenterPolicy removes the policy of the renter with the given
+		// public key, so that the renter is no longer limited.
+		RemoveRenterPolicy(types.SiaPublicKey) error
+
+		// RenterPolicies returns the policies that limit individual renters.
+		RenterPolicies() []HostRenterPolicy
+
+		// SetContractPolicy sets the rules that the host applies to the file
+		// contracts proposed by renters.
+		SetContractPolicy(HostContractPolicy) error
+
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
+		// SetMaintenance puts the host in or out of maintenance mode. In
+		// maintenance mode the host refuses new contracts, renewals and
+		// revisions, and ends revision loops after the current iteration,
+		// while downloads and storage proofs continue.
+		SetMaintenance(bool) error
+
+		// SetPriceSource sets the source of the network prices used by the
+		// network pricing mode.
+		SetPriceSource(HostPriceSource)
+
+		// SetPricingPolicy sets the policy the host uses to set its prices.
+		SetPricingPolicy(HostPricingPolicy) error
+
+		// SetRenterPolicy sets the policy of a renter, replacing any earlier
+		// policy of the same renter.
+		SetRenterPolicy(HostRenterPolicy) error
+
+		// StorageProofs returns the storage proofs of the unresolved storage
+		// obligations and of recently failed obligations, ordered by the end
+		// of their proof window.
+		StorageProofs() ([]HostStorageProof, error)
+
 		// The storage manager provides an interface for adding and removing
 		// storage folders and data sectors to the host.
 		StorageManager
@@ -17,6 +17,7 @@ var (
 )
 
 // announce creates an announcement transaction and submits it to the network.
+// The additional addresses of the host are announced after addr.
 func (h *Host) announce(addr modules.NetAddress) error {
 	// The wallet needs to be unlocked to add fees to the transaction, and the
 	// host needs to have an active unlock hash that renters can make payment
@@ -31,15 +32,18 @@ func (h *Host) announce(addr modules.NetAddress) error {
 
 	// Create the announcement that's going to be added to the arbitrary data
 	// field of the transaction.
-	signedAnnouncement, err := modules.CreateAnnouncement(addr, h.publicKey, h.secretKey)
+	addrs := append([]modules.NetAddress{addr}, h.settings.AdditionalAddresses...)
+	signedAnnouncement, err := modules.CreateAnnouncementAddresses(addrs, h.publicKey, h.secretKey)
 	if err != nil {
 		return err
 	}
 
 	// Create a transaction, with a fee, that contains the full announcement.
+	// Each additional address adds an estimated 100 bytes to the
+	// announcement.
 	txnBuilder := h.wallet.StartTransaction()
 	_, fee := h.tpool.FeeEstimation()
-	fee = fee.Mul64(500) // Estimated txn size (in bytes) of a host announcement.
+	fee = fee.Mul64(500 + 100*uint64(len(addrs)-1)) // Estimated txn size (in bytes) of a host announcement.
 	err = txnBuilder.FundSiacoins(fee)
 	if err != nil {
 		txnBuilder.Drop()
@@ -60,7 +64,7 @@ func (h *Host) announce(addr modules.NetAddress) error {
 		return err
 	}
 	h.announced = true
-	h.log.Printf("INFO: Successfully announced as %v", addr)
+	h.log.Printf("INFO: Successfully announced as %v", addrs)
 	return nil
 }
 
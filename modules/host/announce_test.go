@@ -13,9 +13,10 @@ import (
 type announcementFinder struct {
 	cs modules.ConsensusSet
 
-	// Announcements that have been seen. The two slices are wedded.
-	netAddresses []modules.NetAddress
-	publicKeys   []types.SiaPublicKey
+	// Announcements that have been seen. The slices are wedded.
+	netAddresses        []modules.NetAddress
+	additionalAddresses [][]modules.NetAddress
+	publicKeys          []types.SiaPublicKey
 }
 
 // ProcessConsensusChange receives consensus changes from the consensus set and
@@ -24,9 +25,10 @@ func (af *announcementFinder) ProcessConsensusChange(cc modules.ConsensusChange)
 	for _, block := range cc.AppliedBlocks {
 		for _, txn := range block.Transactions {
 			for _, arb := range txn.ArbitraryData {
-				addr, pubKey, err := modules.DecodeAnnouncement(arb)
+				addrs, pubKey, err := modules.DecodeAnnouncementAddresses(arb)
 				if err == nil {
-					af.netAddresses = append(af.netAddresses, addr)
+					af.netAddresses = append(af.netAddresses, addrs[0])
+					af.additionalAddresses = append(af.additionalAddresses, addrs[1:])
 					af.publicKeys = append(af.publicKeys, pubKey)
 				}
 			}
@@ -136,3 +138,62 @@ func TestHostAnnounceAddress(t *testing.T) {
 		t.Error("announcement has wrong host key")
 	}
 }
+
+// TestHostAnnounceAdditionalAddresses checks that the host announces its
+// additional addresses after its NetAddress.
+func TestHostAnnounceAdditionalAddresses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestHostAnnounceAdditionalAddresses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	af, err := newAnnouncementFinder(ht.cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer af.Close()
+
+	// Invalid addresses and too many addresses are rejected.
+	settings := ht.host.InternalSettings()
+	settings.NetAddress = "foo.com:1234"
+	settings.AdditionalAddresses = []modules.NetAddress{"1.2.3.4"}
+	if err := ht.host.SetInternalSettings(settings); err == nil {
+		t.Fatal("expected an error for an invalid additional address")
+	}
+	settings.AdditionalAddresses = make([]modules.NetAddress, modules.MaxAnnouncedAddresses)
+	for i := range settings.AdditionalAddresses {
+		settings.AdditionalAddresses[i] = "1.2.3.4:1234"
+	}
+	if err := ht.host.SetInternalSettings(settings); err == nil {
+		t.Fatal("expected an error for too many additional addresses")
+	}
+
+	settings.AdditionalAddresses = []modules.NetAddress{"1.2.3.4:1234", "[2001:db8::1]:1234"}
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.Announce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(af.netAddresses) != 1 {
+		t.Fatal("could not find host announcement in blockchain")
+	}
+	if af.netAddresses[0] != settings.NetAddress {
+		t.Error("announcement has wrong address")
+	}
+	additional := af.additionalAddresses[0]
+	if len(additional) != 2 || additional[0] != settings.AdditionalAddresses[0] || additional[1] != settings.AdditionalAddresses[1] {
+		t.Error("announcement has wrong additional addresses:", additional)
+	}
+}
@@ -26,11 +26,22 @@ const (
 	// connection.
 	iteratedConnectionTime = 1200 * time.Second
 
+	// priceChangeThreshold is the relative change in any of the host's
+	// dynamic prices that will cause the host to make a new announcement.
+	// Smaller changes are still reflected in the host's settings, but are not
+	// worth the fee of an announcement.
+	priceChangeThreshold = 0.05
+
 	// resubmissionTimeout defines the number of blocks that a host will wait
 	// before attempting to resubmit a transaction to the blockchain.
 	// Typically, this transaction will contain either a file contract, a file
 	// contract revision, or a storage proof.
 	resubmissionTimeout = 3
+
+	// storageProofFeeIncrease is the factor by which the host raises the fee
+	// of a storage proof that was dropped from the transaction pool before
+	// being confirmed, unless the current fee estimate is higher.
+	storageProofFeeIncrease = 2
 )
 
 var (
@@ -191,6 +202,21 @@ var (
 		panic("unrecognized release constant in host - obligationLockTimeout")
 	}()
 
+	// pricingFrequency defines how often the host re-evaluates its prices
+	// when a dynamic pricing policy is in place.
+	pricingFrequency = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Minute * 10
+		}
+		if build.Release == "standard" {
+			return time.Hour * 6
+		}
+		if build.Release == "testing" {
+			return time.Second * 3
+		}
+		panic("unrecognized release constant in host - pricingFrequency")
+	}()
+
 	// revisionSubmissionBuffer describes the number of blocks ahead of time
 	// that the host will submit a file contract revision. The host will not
 	// accept any more revisions once inside the submission buffer.
@@ -206,6 +232,38 @@ var (
 		}
 		panic("unrecognized release constant in host - revision submission buffer")
 	}()
+
+	// storageProofWarningBuffer is the number of blocks before the end of a
+	// proof window at which the host starts warning that the storage proof
+	// has not been confirmed.
+	storageProofWarningBuffer = func() types.BlockHeight {
+		if build.Release == "dev" {
+			return 10 // About 1 minute
+		}
+		if build.Release == "standard" {
+			return 36 // 6 hours.
+		}
+		if build.Release == "testing" {
+			return 3
+		}
+		panic("unrecognized release constant in host - storage proof warning buffer")
+	}()
+
+	// failedProofRetention is the number of blocks after the end of a proof
+	// window during which a failed storage proof is still reported, so that
+	// the operator has time to notice it.
+	failedProofRetention = func() types.BlockHeight {
+		if build.Release == "dev" {
+			return 60 // About 6 minutes
+		}
+		if build.Release == "standard" {
+			return 1008 // 1 week.
+		}
+		if build.Release == "testing" {
+			return 5
+		}
+		panic("unrecognized release constant in host - failed proof retention")
+	}()
 )
 
 // All of the following variables define the names of buckets used by the host
@@ -221,6 +279,12 @@ var (
 	// using the id.
 	bucketActionItems = []byte("BucketActionItems")
 
+	// bucketLedger contains a serialized 'modules.HostLedgerEntry' for every
+	// storage obligation that has been resolved. The key is the time of
+	// resolution as big endian unix nanoseconds followed by the file contract
+	// id, which means that bolt will store the entries sorted by time.
+	bucketLedger = []byte("BucketLedger")
+
 	// bucketStorageObligations contains a set of serialized
 	// 'storageObligations' sorted by their file contract id.
 	bucketStorageObligations = []byte("BucketStorageObligations")
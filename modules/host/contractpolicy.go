@@ -0,0 +1,158 @@
+package host
+
+// contractpolicy.go applies the contract policy of the host to the file
+// contracts proposed by renters. The fixed checks in
+// managedVerifyNewContract and managedVerifyRenewedContract make sure that a
+// contract is well formed and pays the host according to its settings. The
+// contract policy lets the host operator refuse contracts that are valid, but
+// that the host does not want, such as very short or very small contracts, or
+// contracts with renters that the operator does not know.
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errPolicyBadDurations is returned if a contract policy has a minimum
+	// duration that is larger than its maximum duration.
+	errPolicyBadDurations = errors.New("contract policy min duration must not be larger than its max duration")
+
+	// errPolicyCollateralPercent is returned if the maximum collateral
+	// percentage of a contract policy is out of range.
+	errPolicyCollateralPercent = errors.New("contract policy max collateral percent must be between 0 and 100")
+
+	// errPolicyCollateral is returned if the renter proposes a file contract
+	// that would lock up a larger part of the collateral budget than the host
+	// allows for a single contract.
+	errPolicyCollateral = ErrorCommunication("rejected because the contract requires more collateral than the host allows for a single contract")
+
+	// errPolicyFailedProof is returned if the renter tries to renew a contract
+	// after the host failed to get a storage proof confirmed for an earlier
+	// contract of the renter.
+	errPolicyFailedProof = ErrorCommunication("rejected because the host does not renew contracts of renters with a failed storage proof")
+
+	// errPolicyLongDuration is returned if the renter proposes a file contract
+	// that ends later than the contract policy of the host allows.
+	errPolicyLongDuration = ErrorCommunication("rejected because the contract duration is longer than the host allows")
+
+	// errPolicyLowPayout is returned if the renter proposes a file contract
+	// with a smaller payout than the contract policy of the host requires.
+	errPolicyLowPayout = ErrorCommunication("rejected because the contract payout is lower than the host requires")
+
+	// errPolicyRenterNotAllowed is returned if the host only accepts contracts
+	// from an allowlist of renters, and the renter is not on the list.
+	errPolicyRenterNotAllowed = ErrorCommunication("rejected because the renter is not on the allowlist of the host")
+
+	// errPolicyShortDuration is returned if the renter proposes a file
+	// contract that ends sooner than the contract policy of the host allows.
+	errPolicyShortDuration = ErrorCommunication("rejected because the contract duration is shorter than the host allows")
+)
+
+// validateContractPolicy checks that the fields of a contract policy are
+// sensible.
+func validateContractPolicy(policy modules.HostContractPolicy) error {
+	if policy.MaxDuration != 0 && policy.MinDuration > policy.MaxDuration {
+		return errPolicyBadDurations
+	}
+	if math.IsNaN(policy.MaxCollateralPercent) || math.IsInf(policy.MaxCollateralPercent, 0) || policy.MaxCollateralPercent < 0 || policy.MaxCollateralPercent > 100 {
+		return errPolicyCollateralPercent
+	}
+	for _, spk := range policy.AllowedRenters {
+		if spk.Algorithm != types.SignatureEd25519 || len(spk.Key) != crypto.PublicKeySize {
+			return errBadRenterKey
+		}
+	}
+	return nil
+}
+
+// renterAllowed returns whether the contract policy allows the renter to form
+// and renew contracts with the host.
+func renterAllowed(policy modules.HostContractPolicy, renterPK crypto.PublicKey) bool {
+	if !policy.RequireAllowlist {
+		return true
+	}
+	key := renterKeyString(renterSiaKey(renterPK))
+	for _, spk := range policy.AllowedRenters {
+		if renterKeyString(spk) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContractPolicy checks a proposed file contract against the contract
+// policy of the host. 'collateral' is the collateral that the host would add
+// to the contract. When a contract is renewed, 'failures' is the number of
+// storage proofs that the host missed for earlier contracts of the renter; it
+// is 0 when a new contract is formed. The contract has already passed the fixed checks, so
+// its proof window starts after 'blockHeight'.
+func checkContractPolicy(policy modules.HostContractPolicy, fc types.FileContract, renterPK crypto.PublicKey, blockHeight types.BlockHeight, collateral, collateralBudget types.Currency, failures uint64) error {
+	if !renterAllowed(policy, renterPK) {
+		return errPolicyRenterNotAllowed
+	}
+	if policy.RefuseFailedRenewals && failures > 0 {
+		return errPolicyFailedProof
+	}
+
+	duration := fc.WindowStart - blockHeight
+	if duration < policy.MinDuration {
+		return errPolicyShortDuration
+	}
+	if policy.MaxDuration != 0 && duration > policy.MaxDuration {
+		return errPolicyLongDuration
+	}
+	if fc.Payout.Cmp(policy.MinPayout) < 0 {
+		return errPolicyLowPayout
+	}
+	if policy.MaxCollateralPercent != 0 {
+		limit := collateralBudget.MulRat(new(big.Rat).SetFloat64(policy.MaxCollateralPercent / 100))
+		if collateral.Cmp(limit) > 0 {
+			return errPolicyCollateral
+		}
+	}
+	return nil
+}
+
+// ContractPolicy returns the rules that the host applies to the file
+// contracts proposed by renters.
+func (h *Host) ContractPolicy() modules.HostContractPolicy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to ContractPolicy after close")
+	}
+	defer h.tg.Done()
+	return h.contractPolicy
+}
+
+// SetContractPolicy sets the rules that the host applies to the file
+// contracts proposed by renters. The policy applies to contracts that are
+// proposed after the call, existing contracts are not affected.
+func (h *Host) SetContractPolicy(policy modules.HostContractPolicy) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	err = validateContractPolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.contractPolicy = policy
+	err = h.saveSync()
+	if err != nil {
+		return errors.New("contract policy updated, but failed saving to disk: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package host
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCheckContractPolicy probes the rules of the contract policy.
+func TestCheckContractPolicy(t *testing.T) {
+	_, renterPK, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPK, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := types.FileContract{
+		WindowStart: 150,
+		Payout:      types.NewCurrency64(1000),
+	}
+	budget := types.NewCurrency64(1000)
+	collateral := types.NewCurrency64(100)
+	tests := []struct {
+		policy   modules.HostContractPolicy
+		failures uint64
+		err      error
+	}{
+		{modules.HostContractPolicy{}, 0, nil},
+		{modules.HostContractPolicy{}, 1, nil},
+		{modules.HostContractPolicy{MinDuration: 50}, 0, nil},
+		{modules.HostContractPolicy{MinDuration: 51}, 0, errPolicyShortDuration},
+		{modules.HostContractPolicy{MaxDuration: 50}, 0, nil},
+		{modules.HostContractPolicy{MaxDuration: 49}, 0, errPolicyLongDuration},
+		{modules.HostContractPolicy{MinPayout: types.NewCurrency64(1000)}, 0, nil},
+		{modules.HostContractPolicy{MinPayout: types.NewCurrency64(1001)}, 0, errPolicyLowPayout},
+		{modules.HostContractPolicy{MaxCollateralPercent: 10}, 0, nil},
+		{modules.HostContractPolicy{MaxCollateralPercent: 9.9}, 0, errPolicyCollateral},
+		{modules.HostContractPolicy{RequireAllowlist: true}, 0, errPolicyRenterNotAllowed},
+		{modules.HostContractPolicy{RequireAllowlist: true, AllowedRenters: []types.SiaPublicKey{renterSiaKey(otherPK)}}, 0, errPolicyRenterNotAllowed},
+		{modules.HostContractPolicy{RequireAllowlist: true, AllowedRenters: []types.SiaPublicKey{renterSiaKey(otherPK), renterSiaKey(renterPK)}}, 0, nil},
+		{modules.HostContractPolicy{AllowedRenters: []types.SiaPublicKey{renterSiaKey(otherPK)}}, 0, nil},
+		{modules.HostContractPolicy{RefuseFailedRenewals: true}, 0, nil},
+		{modules.HostContractPolicy{RefuseFailedRenewals: true}, 1, errPolicyFailedProof},
+	}
+	for i, test := range tests {
+		err := checkContractPolicy(test.policy, fc, renterPK, 100, collateral, budget, test.failures)
+		if err != test.err {
+			t.Errorf("%v: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// TestSetContractPolicy checks that the host validates and persists its
+// contract policy.
+func TestSetContractPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestSetContractPolicy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	bad := []struct {
+		policy modules.HostContractPolicy
+		err    error
+	}{
+		{modules.HostContractPolicy{MinDuration: 10, MaxDuration: 5}, errPolicyBadDurations},
+		{modules.HostContractPolicy{MaxCollateralPercent: -1}, errPolicyCollateralPercent},
+		{modules.HostContractPolicy{MaxCollateralPercent: 101}, errPolicyCollateralPercent},
+		{modules.HostContractPolicy{MaxCollateralPercent: math.NaN()}, errPolicyCollateralPercent},
+		{modules.HostContractPolicy{MaxCollateralPercent: math.Inf(1)}, errPolicyCollateralPercent},
+		{modules.HostContractPolicy{AllowedRenters: []types.SiaPublicKey{{Algorithm: types.SignatureEd25519}}}, errBadRenterKey},
+	}
+	for _, test := range bad {
+		if err := ht.host.SetContractPolicy(test.policy); err != test.err {
+			t.Errorf("expected %v, got %v", test.err, err)
+		}
+	}
+
+	_, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := modules.HostContractPolicy{
+		MinDuration:          10,
+		MaxDuration:          1000,
+		MinPayout:            types.SiacoinPrecision,
+		RequireAllowlist:     true,
+		AllowedRenters:       []types.SiaPublicKey{renterSiaKey(pk)},
+		MaxCollateralPercent: 5,
+		RefuseFailedRenewals: true,
+	}
+	err = ht.host.SetContractPolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The policy survives a restart.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := ht.host.ContractPolicy()
+	if loaded.MinDuration != policy.MinDuration || loaded.MaxDuration != policy.MaxDuration || loaded.MinPayout.Cmp(policy.MinPayout) != 0 ||
+		!loaded.RequireAllowlist || len(loaded.AllowedRenters) != 1 || loaded.AllowedRenters[0].String() != policy.AllowedRenters[0].String() ||
+		loaded.MaxCollateralPercent != policy.MaxCollateralPercent || !loaded.RefuseFailedRenewals {
+		t.Fatal("contract policy was not persisted:", loaded)
+	}
+}
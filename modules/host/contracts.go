@@ -0,0 +1,172 @@
+package host
+
+// contracts.go lets the host operator inspect the storage obligations of the
+// host. The financial metrics only report totals, so the operator otherwise
+// has no way to tell which contracts still need a storage proof, or which
+// ones have failed.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errUnknownObligationStatus is returned when contracts are filtered by
+	// a status that does not exist.
+	errUnknownObligationStatus = errors.New("unknown contract status; must be one of unresolved, rejected, succeeded, or failed")
+)
+
+// byExpiration sorts contracts by the height at which they expire.
+type byExpiration []modules.HostContract
+
+func (s byExpiration) Len() int           { return len(s) }
+func (s byExpiration) Less(i, j int) bool { return s[i].Expiration < s[j].Expiration }
+func (s byExpiration) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// summary returns the information about the storage obligation that is
+// reported by the host API.
+func (so storageObligation) summary() modules.HostContract {
+	return modules.HostContract{
+		ID:            so.id(),
+		Status:        so.ObligationStatus.String(),
+		SectorCount:   uint64(len(so.SectorRoots)),
+		DataSize:      so.fileSize(),
+		Expiration:    so.expiration(),
+		ProofDeadline: so.proofDeadline(),
+
+		ContractCost:             so.ContractCost,
+		LockedCollateral:         so.LockedCollateral,
+		PotentialDownloadRevenue: so.PotentialDownloadRevenue,
+		PotentialStorageRevenue:  so.PotentialStorageRevenue,
+		PotentialUploadRevenue:   so.PotentialUploadRevenue,
+		RiskedCollateral:         so.RiskedCollateral,
+		TransactionFeesAdded:     so.TransactionFeesAdded,
+
+		OriginConfirmed:   so.OriginConfirmed,
+		RevisionConfirmed: so.RevisionConfirmed,
+		ProofConfirmed:    so.ProofConfirmed,
+	}
+}
+
+// revisionNumber returns the revision number of the most recent revision of
+// the storage obligation.
+func (so storageObligation) revisionNumber() uint64 {
+	if len(so.RevisionTransactionSet) > 0 {
+		return so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0].NewRevisionNumber
+	}
+	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContracts[0].RevisionNumber
+}
+
+// matches returns whether the contract is selected by the filter.
+func matches(c modules.HostContract, f modules.HostContractFilter) bool {
+	if f.Status != "" && c.Status != f.Status {
+		return false
+	}
+	if f.MinExpiration != 0 && c.Expiration < f.MinExpiration {
+		return false
+	}
+	if f.MaxExpiration != 0 && c.Expiration > f.MaxExpiration {
+		return false
+	}
+	if f.ProofConfirmed != nil && c.ProofConfirmed != *f.ProofConfirmed {
+		return false
+	}
+	return true
+}
+
+// pendingActionItems returns the heights above the current height at which
+// an action item is queued for the storage obligation.
+func (h *Host) pendingActionItems(tx *bolt.Tx, soid types.FileContractID) []types.BlockHeight {
+	var heights []types.BlockHeight
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, uint64(h.blockHeight+1))
+	c := tx.Bucket(bucketActionItems).Cursor()
+	for heightBytes, ids := c.Seek(start); heightBytes != nil; heightBytes, ids = c.Next() {
+		for i := 0; i+crypto.HashSize <= len(ids); i += crypto.HashSize {
+			if types.FileContractID(crypto.Hash(ids[i:i+crypto.HashSize])) == soid {
+				heights = append(heights, types.BlockHeight(binary.BigEndian.Uint64(heightBytes)))
+				break
+			}
+		}
+	}
+	return heights
+}
+
+// Contracts returns the storage obligations of the host that match the filter,
+// ordered by expiration.
+func (h *Host) Contracts(f modules.HostContractFilter) ([]modules.HostContract, error) {
+	switch f.Status {
+	case "", "unresolved", "rejected", "succeeded", "failed":
+	default:
+		return nil, errUnknownObligationStatus
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to Contracts after close")
+	}
+	defer h.tg.Done()
+
+	var contracts []modules.HostContract
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			if err := json.Unmarshal(soBytes, &so); err != nil {
+				return err
+			}
+			if c := so.summary(); matches(c, f) {
+				contracts = append(contracts, c)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byExpiration(contracts))
+	return contracts, nil
+}
+
+// Contract returns the details of the storage obligation with the given id.
+// False is returned if the host has no such obligation.
+func (h *Host) Contract(id types.FileContractID) (modules.HostContractDetails, bool, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to Contract after close")
+	}
+	defer h.tg.Done()
+
+	var details modules.HostContractDetails
+	err = h.db.View(func(tx *bolt.Tx) error {
+		so, err := getStorageObligation(tx, id)
+		if err != nil {
+			return err
+		}
+		details = modules.HostContractDetails{
+			HostContract:   so.summary(),
+			RevisionNumber: so.revisionNumber(),
+			MerkleRoot:     so.merkleRoot(),
+			ActionItems:    h.pendingActionItems(tx, id),
+		}
+		return nil
+	})
+	if err == errNoStorageObligation {
+		return modules.HostContractDetails{}, false, nil
+	} else if err != nil {
+		return modules.HostContractDetails{}, false, err
+	}
+	return details, true, nil
+}
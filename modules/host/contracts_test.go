@@ -0,0 +1,123 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestContracts checks that the storage obligations of the host can be listed,
+// filtered, and inspected.
+func TestContracts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestContracts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	contracts, err := ht.host.Contracts(modules.HostContractFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contracts) != 0 {
+		t.Fatal("host should start without contracts:", contracts)
+	}
+
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.addStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+
+	contracts, err = ht.host.Contracts(modules.HostContractFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contracts) != 1 {
+		t.Fatal("expected 1 contract, got", len(contracts))
+	}
+	c := contracts[0]
+	if c.ID != so.id() || c.Status != "unresolved" || c.Expiration != so.expiration() || c.ProofDeadline != so.proofDeadline() {
+		t.Fatal("wrong contract summary:", c)
+	}
+
+	// Filters that exclude the contract.
+	no := false
+	yes := true
+	for _, f := range []modules.HostContractFilter{
+		{Status: "succeeded"},
+		{MinExpiration: so.expiration() + 1},
+		{MaxExpiration: so.expiration() - 1},
+		{ProofConfirmed: &yes},
+	} {
+		contracts, err = ht.host.Contracts(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(contracts) != 0 {
+			t.Error("filter did not exclude the contract:", f)
+		}
+	}
+	contracts, err = ht.host.Contracts(modules.HostContractFilter{
+		Status:         "unresolved",
+		MaxExpiration:  so.expiration(),
+		ProofConfirmed: &no,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contracts) != 1 {
+		t.Fatal("filter excluded the contract")
+	}
+	if _, err = ht.host.Contracts(modules.HostContractFilter{Status: "foo"}); err != errUnknownObligationStatus {
+		t.Fatal("expected errUnknownObligationStatus, got", err)
+	}
+
+	// The details should list the action items queued by
+	// addStorageObligation.
+	details, ok, err := ht.host.Contract(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("contract not found")
+	}
+	if details.ID != c.ID || details.RevisionNumber != 0 || details.MerkleRoot != so.merkleRoot() {
+		t.Fatal("wrong contract details:", details)
+	}
+	if len(details.ActionItems) == 0 {
+		t.Fatal("expected the contract to have action items")
+	}
+	for _, height := range details.ActionItems {
+		if height <= ht.host.blockHeight {
+			t.Fatal("action item is not pending:", height)
+		}
+	}
+	if _, ok, err := ht.host.Contract(types.FileContractID{}); ok || err != nil {
+		t.Fatal("unknown contract was found:", ok, err)
+	}
+
+	// Database errors are not reported as a missing contract.
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		id := so.id()
+		return tx.Bucket(bucketStorageObligations).Put(id[:], []byte("garbage"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := ht.host.Contract(so.id()); ok || err == nil {
+		t.Fatal("corrupt contract was not reported as an error:", ok, err)
+	}
+}
@@ -119,12 +119,18 @@ type Host struct {
 	atomicDownloadCalls       uint64
 	atomicErroredCalls        uint64
 	atomicFormContractCalls   uint64
+	atomicLostSectorsCalls    uint64
 	atomicRenewCalls          uint64
 	atomicReviseCalls         uint64
 	atomicRecentRevisionCalls uint64
 	atomicSettingsCalls       uint64
 	atomicUnrecognizedCalls   uint64
 
+	// Connection limit metrics, counting the connections that were refused
+	// because of the connection limits in the settings.
+	atomicConnectionLimitRejections uint64
+	atomicIPLimitRejections         uint64
+
 	// Error management. There are a few different types of errors returned by
 	// the host. These errors intentionally not persistent, so that the logging
 	// limits of each error type will be reset each time the host is reset.
@@ -167,11 +173,44 @@ type Host struct {
 	settings         modules.HostInternalSettings
 	unlockHash       types.UnlockHash // A wallet address that can receive coins.
 
+	// Pricing. The prices are derived from the minimum prices in the settings
+	// according to the pricing policy, and are re-evaluated periodically. The
+	// price source provides the network prices for the network pricing mode.
+	priceSource   modules.HostPriceSource
+	prices        hostPrices
+	pricingPolicy modules.HostPricingPolicy
+
+	// The contract policy holds the rules that the host applies to proposed
+	// file contracts, on top of the checks derived from the settings.
+	contractPolicy modules.HostContractPolicy
+
 	// A map of storage obligations that are currently being modified. Locks on
 	// storage obligations can be long-running, and each storage obligation can
 	// be locked separately.
 	lockedStorageObligations map[types.FileContractID]*siasync.TryMutex
 
+	// Connection and bandwidth limits. The open connections are counted in
+	// total and per IP address, and the rate limiters are shared by all
+	// connections. The limits themselves are part of the internal settings.
+	downloadLimiter rateLimiter
+	ipConnections   map[string]uint64
+	openConnections uint64
+	uploadLimiter   rateLimiter
+
+	// Renter policies. The policies, usage, and missed storage proofs of renters
+	// are keyed by the string form of the renter's public key, and the
+	// revisions of renters with a revision limit are counted in one minute
+	// windows.
+	renterPolicies  map[string]modules.HostRenterPolicy
+	renterRevisions map[string]*revisionWindow
+	renterUsages    map[string]renterUsage
+	renterFailures  map[string]uint64
+
+	// Maintenance. The host counts the operations that change storage
+	// obligations, so that it can report when it is safe to stop.
+	activeOperations uint64
+	maintenance      bool
+
 	// Utilities.
 	db         *persist.BoltDatabase
 	listener   net.Listener
@@ -229,7 +268,12 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		wallet:       wallet,
 		dependencies: dependencies,
 
+		ipConnections:            make(map[string]uint64),
 		lockedStorageObligations: make(map[types.FileContractID]*siasync.TryMutex),
+		renterPolicies:           make(map[string]modules.HostRenterPolicy),
+		renterRevisions:          make(map[string]*revisionWindow),
+		renterUsages:             make(map[string]renterUsage),
+		renterFailures:           make(map[string]uint64),
 
 		persistDir: persistDir,
 	}
@@ -305,6 +349,13 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		h.log.Println("Could not initialize host networking:", err)
 		return nil, err
 	}
+
+	// Periodically re-evaluate the prices of the host.
+	threadedUpdatePricesClosedChan := make(chan struct{})
+	go h.threadedUpdatePrices(threadedUpdatePricesClosedChan)
+	h.tg.OnStop(func() {
+		<-threadedUpdatePricesClosedChan
+	})
 	return h, nil
 }
 
@@ -370,16 +421,36 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 			return errors.New("internal settings not updated, invalid NetAddress: " + err.Error())
 		}
 	}
+	if len(settings.AdditionalAddresses)+1 > modules.MaxAnnouncedAddresses {
+		return fmt.Errorf("internal settings not updated, at most %v additional addresses can be announced", modules.MaxAnnouncedAddresses-1)
+	}
+	for _, addr := range settings.AdditionalAddresses {
+		err := addr.IsValid()
+		if err != nil {
+			return errors.New("internal settings not updated, invalid additional address: " + err.Error())
+		}
+	}
+
+	err = validateRateLimits(settings)
+	if err != nil {
+		return errors.New("internal settings not updated, " + err.Error())
+	}
 
 	// Check if the net address for the host has changed. If it has, and it's
 	// not equal to the auto address, then the host is going to need to make
-	// another blockchain announcement.
+	// another blockchain announcement. The same is true if the additional
+	// addresses have changed.
 	if h.settings.NetAddress != settings.NetAddress && settings.NetAddress != h.autoAddress {
 		h.announced = false
 	}
+	if !modules.SameAddresses(h.settings.AdditionalAddresses, settings.AdditionalAddresses) {
+		h.announced = false
+	}
 
 	h.settings = settings
 	h.revisionNumber++
+	h.downloadLimiter.setRate(settings.MaxDownloadSpeed)
+	h.uploadLimiter.setRate(settings.MaxUploadSpeed)
 
 	err = h.saveSync()
 	if err != nil {
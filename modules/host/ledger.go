@@ -0,0 +1,119 @@
+package host
+
+// ledger.go keeps a permanent record of the outcome of every storage
+// obligation. The financial metrics only keep running totals, and a storage
+// obligation loses its sector roots once it is resolved, so the ledger is the
+// only place where the host operator can see what each contract earned or
+// lost. Entries are stored by the time at which the obligation was resolved,
+// so that the ledger can be exported by date range for accounting.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errLedgerRange is returned when the ledger is requested for a range
+	// that ends before it starts.
+	errLedgerRange = errors.New("ledger range ends before it starts")
+)
+
+// ledgerKey returns the key of a ledger entry in the ledger bucket.
+func ledgerKey(t time.Time, id types.FileContractID) []byte {
+	key := make([]byte, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	copy(key[8:], id[:])
+	return key
+}
+
+// ledgerEntry returns the ledger entry of a storage obligation that is being
+// resolved at the given height and time. The obligation status must already
+// be set.
+func (so storageObligation) ledgerEntry(height types.BlockHeight, t time.Time) modules.HostLedgerEntry {
+	entry := modules.HostLedgerEntry{
+		ContractID:  so.id(),
+		Status:      so.ObligationStatus.String(),
+		StartHeight: so.NegotiationHeight,
+		EndHeight:   so.expiration(),
+		SectorCount: uint64(len(so.SectorRoots)),
+		DataSize:    so.fileSize(),
+
+		LockedCollateral: so.LockedCollateral,
+
+		ResolvedHeight: height,
+		ResolvedTime:   t,
+	}
+	switch so.ObligationStatus {
+	case obligationSucceeded:
+		entry.ContractCompensation = so.ContractCost
+		entry.StorageRevenue = so.PotentialStorageRevenue
+		entry.DownloadBandwidthRevenue = so.PotentialDownloadRevenue
+		entry.UploadBandwidthRevenue = so.PotentialUploadRevenue
+		entry.TransactionFeeExpense = so.TransactionFeesAdded
+	case obligationFailed:
+		entry.LostRevenue = so.ContractCost.Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue)
+		entry.LostCollateral = so.RiskedCollateral
+		entry.TransactionFeeExpense = so.TransactionFeesAdded
+	}
+	return entry
+}
+
+// putLedgerEntry adds an entry to the ledger.
+func putLedgerEntry(tx *bolt.Tx, entry modules.HostLedgerEntry) error {
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketLedger).Put(ledgerKey(entry.ResolvedTime, entry.ContractID), entryBytes)
+}
+
+// Ledger returns the ledger entries of the storage obligations that were
+// resolved between 'start' and 'end', ordered by the time of resolution. A
+// zero 'end' has no upper bound.
+func (h *Host) Ledger(start, end time.Time) ([]modules.HostLedgerEntry, error) {
+	if !end.IsZero() && end.Before(start) {
+		return nil, errLedgerRange
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to Ledger after close")
+	}
+	defer h.tg.Done()
+
+	var entries []modules.HostLedgerEntry
+	err = h.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketLedger).Cursor()
+		var k, v []byte
+		if start.IsZero() {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(ledgerKey(start, types.FileContractID{}))
+		}
+		for ; k != nil; k, v = c.Next() {
+			var entry modules.HostLedgerEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if !end.IsZero() && entry.ResolvedTime.After(end) {
+				break
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,128 @@
+package host
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestLedger checks that the host records the outcome of resolved storage
+// obligations in the ledger, and that the ledger can be read by time range.
+func TestLedger(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestLedger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	entries, err := ht.host.Ledger(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatal("host should start with an empty ledger:", entries)
+	}
+
+	// Add two obligations, and resolve one as succeeded and the other as
+	// failed.
+	var sos []storageObligation
+	for i := 0; i < 2; i++ {
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		so.ContractCost = types.NewCurrency64(5)
+		so.PotentialStorageRevenue = types.NewCurrency64(7)
+		so.PotentialDownloadRevenue = types.NewCurrency64(11)
+		so.LockedCollateral = types.NewCurrency64(13)
+		so.RiskedCollateral = types.NewCurrency64(3)
+		so.NegotiationHeight = ht.host.blockHeight
+		ht.host.managedLockStorageObligation(so.id())
+		err = ht.host.addStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ht.host.managedUnlockStorageObligation(so.id())
+		sos = append(sos, so)
+	}
+	beforeResolve := time.Now()
+	ht.host.mu.Lock()
+	err = ht.host.removeStorageObligation(sos[0], obligationSucceeded)
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	betweenResolves := time.Now()
+	ht.host.mu.Lock()
+	err = ht.host.removeStorageObligation(sos[1], obligationFailed)
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = ht.host.Ledger(beforeResolve, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatal("expected 2 ledger entries, got", len(entries))
+	}
+	succeeded, failed := entries[0], entries[1]
+	if succeeded.ContractID != sos[0].id() || succeeded.Status != "succeeded" || failed.ContractID != sos[1].id() || failed.Status != "failed" {
+		t.Fatal("ledger entries are in the wrong order or have the wrong status:", entries)
+	}
+	if succeeded.StartHeight != sos[0].NegotiationHeight || succeeded.EndHeight != sos[0].expiration() || succeeded.ResolvedHeight != ht.host.blockHeight {
+		t.Error("ledger entry has the wrong heights:", succeeded)
+	}
+	if succeeded.ContractCompensation.Cmp(types.NewCurrency64(5)) != 0 || succeeded.StorageRevenue.Cmp(types.NewCurrency64(7)) != 0 || succeeded.DownloadBandwidthRevenue.Cmp(types.NewCurrency64(11)) != 0 || !succeeded.LostRevenue.IsZero() || !succeeded.LostCollateral.IsZero() {
+		t.Error("succeeded obligation has the wrong revenue:", succeeded)
+	}
+	if !failed.ContractCompensation.IsZero() || failed.LostRevenue.Cmp(types.NewCurrency64(23)) != 0 || failed.LostCollateral.Cmp(types.NewCurrency64(3)) != 0 || failed.LockedCollateral.Cmp(types.NewCurrency64(13)) != 0 {
+		t.Error("failed obligation has the wrong losses:", failed)
+	}
+
+	// The ledger can be limited to a range of time.
+	entries, err = ht.host.Ledger(beforeResolve, betweenResolves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ContractID != sos[0].id() {
+		t.Fatal("range did not select the first entry:", entries)
+	}
+	entries, err = ht.host.Ledger(betweenResolves, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ContractID != sos[1].id() {
+		t.Fatal("range did not select the second entry:", entries)
+	}
+	_, err = ht.host.Ledger(betweenResolves, beforeResolve)
+	if err != errLedgerRange {
+		t.Fatal("expected errLedgerRange, got", err)
+	}
+
+	// The ledger survives a restart.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err = ht.host.Ledger(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatal("ledger was not persisted:", entries)
+	}
+}
@@ -0,0 +1,82 @@
+package host
+
+// maintenance.go implements the maintenance mode of the host, which allows the
+// host to be stopped without cutting off a renter in the middle of a revision.
+// In maintenance mode the host advertises that it is not accepting contracts,
+// refuses renewals and revisions with modules.ErrHostMaintenance, and ends
+// revision loops after the current iteration. Downloads and storage proofs are
+// not affected.
+//
+// The host counts the operations that change storage obligations: iterations
+// of the revision and download loops, contract formation and renewal, and the
+// handling of action items. Connections that are idle between iterations are
+// not counted, as the host has already saved the latest revision. Once the
+// host is in maintenance mode and no operations are active, it is safe to
+// stop.
+//
+// Maintenance mode is not persisted, a restarted host is out of maintenance
+// mode.
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// managedBeginOperation marks the start of an operation that changes a storage
+// obligation. Every call must be followed by a call to managedEndOperation.
+func (h *Host) managedBeginOperation() {
+	h.mu.Lock()
+	h.activeOperations++
+	h.mu.Unlock()
+}
+
+// managedEndOperation marks the end of an operation that was started with
+// managedBeginOperation.
+func (h *Host) managedEndOperation() {
+	h.mu.Lock()
+	h.activeOperations--
+	h.mu.Unlock()
+}
+
+// managedInMaintenance returns whether the host is in maintenance mode.
+func (h *Host) managedInMaintenance() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.maintenance
+}
+
+// MaintenanceStatus returns the maintenance mode of the host, and whether the
+// host is safe to stop.
+func (h *Host) MaintenanceStatus() modules.HostMaintenanceStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return modules.HostMaintenanceStatus{
+		Enabled:          h.maintenance,
+		ActiveOperations: h.activeOperations,
+		SafeToStop:       h.maintenance && h.activeOperations == 0,
+	}
+}
+
+// SetMaintenance puts the host in or out of maintenance mode.
+func (h *Host) SetMaintenance(enabled bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	if h.maintenance == enabled {
+		return nil
+	}
+	h.maintenance = enabled
+	// The external settings change with the maintenance mode, so the revision
+	// number needs to increase.
+	h.revisionNumber++
+	if enabled {
+		h.log.Println("Host entered maintenance mode")
+	} else {
+		h.log.Println("Host left maintenance mode")
+	}
+	return nil
+}
@@ -219,10 +219,11 @@ func (h *Host) managedFinalizeContract(builder modules.TransactionBuilder, rente
 	so := storageObligation{
 		SectorRoots: initialSectorRoots,
 
-		ContractCost:            h.settings.MinContractPrice,
+		ContractCost:            h.pricedSettings().MinContractPrice,
 		LockedCollateral:        hostCollateral,
 		PotentialStorageRevenue: hostInitialRevenue,
 		RiskedCollateral:        hostInitialRisk,
+		NegotiationHeight:       blockHeight,
 
 		OriginTransactionSet:   fullTxnSet,
 		RevisionTransactionSet: []types.Transaction{revisionTransaction},
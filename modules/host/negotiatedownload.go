@@ -4,6 +4,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -18,6 +19,11 @@ var (
 	// errRequestOutOfBounds is returned when a download request is made which
 	// asks for elements of a sector which do not exist.
 	errRequestOutOfBounds = ErrorCommunication("download request has invalid sector bounds")
+
+	// errRequestUnaligned is returned when a download request asks for part
+	// of a sector that does not start and end on a segment boundary. The host
+	// can only prove segments against the Merkle root of the sector.
+	errRequestUnaligned = ErrorCommunication("partial download request is not aligned to segment boundaries")
 )
 
 // managedDownloadIteration is responsible for managing a single iteration of
@@ -37,12 +43,14 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	if err != nil {
 		return extendErr("renter rejected host settings: ", ErrorCommunication(err.Error()))
 	}
+	h.managedBeginOperation()
+	defer h.managedEndOperation()
 
 	// Grab a set of variables that will be useful later in the function.
 	h.mu.RLock()
 	blockHeight := h.blockHeight
 	secretKey := h.secretKey
-	settings := h.settings
+	settings := h.pricedSettings()
 	h.mu.RUnlock()
 
 	// Read the download requests, followed by the file contract revision that
@@ -62,6 +70,8 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	// for the renter.
 	existingRevision := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0]
 	var payload [][]byte
+	var proofs [][]crypto.Hash
+	var partial bool
 	err = func() error {
 		// Check that the length of each file is in-bounds, and that the total
 		// size being requested is acceptable.
@@ -70,6 +80,10 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 			if request.Length > modules.SectorSize || request.Offset+request.Length > modules.SectorSize {
 				return extendErr("download iteration request failed: ", errRequestOutOfBounds)
 			}
+			if request.Partial() && (request.Length == 0 || request.Offset%crypto.SegmentSize != 0 || request.Length%crypto.SegmentSize != 0) {
+				return extendErr("download iteration request failed: ", errRequestUnaligned)
+			}
+			partial = partial || request.Partial()
 			totalSize += request.Length
 		}
 		if totalSize > settings.MaxDownloadBatchSize {
@@ -84,13 +98,23 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 			return extendErr("payment verification failed: ", err)
 		}
 
-		// Load the sectors and build the data payload.
+		// Load the sectors and build the data payload. If any of the requests
+		// is for part of a sector, build a Merkle range proof for each
+		// request. Requests for whole sectors get an empty proof, as the
+		// renter can check them against the sector root directly.
 		for _, request := range requests {
 			sectorData, err := h.ReadSector(request.MerkleRoot)
 			if err != nil {
 				return extendErr("failed to load sector: ", ErrorInternal(err.Error()))
 			}
 			payload = append(payload, sectorData[request.Offset:request.Offset+request.Length])
+			if partial {
+				var proof []crypto.Hash
+				if request.Partial() {
+					proof = crypto.MerkleRangeProof(sectorData, request.Offset/crypto.SegmentSize, (request.Offset+request.Length)/crypto.SegmentSize)
+				}
+				proofs = append(proofs, proof)
+			}
 		}
 		return nil
 	}()
@@ -139,6 +163,14 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	if err != nil {
 		return extendErr("failed to write payload: ", ErrorConnection(err.Error()))
 	}
+	// The range proofs are only sent if part of a sector was requested, so
+	// that renters which only download whole sectors see no change.
+	if partial {
+		err = encoding.WriteObject(conn, proofs)
+		if err != nil {
+			return extendErr("failed to write range proofs: ", ErrorConnection(err.Error()))
+		}
+	}
 	return nil
 }
 
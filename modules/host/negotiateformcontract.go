@@ -72,14 +72,18 @@ func (h *Host) managedRPCFormContract(conn net.Conn) error {
 	}
 	// If the host is not accepting contracts, the connection can be closed.
 	// The renter has been given enough information in the host settings to
-	// understand that the connection is going to be closed.
+	// understand that the connection is going to be closed. A host in
+	// maintenance mode reports that it is not accepting contracts.
 	h.mu.RLock()
-	settings := h.settings
+	settings := h.pricedSettings()
+	maintenance := h.maintenance
 	h.mu.RUnlock()
-	if !settings.AcceptingContracts {
+	if !settings.AcceptingContracts || maintenance {
 		h.log.Debugln("Turning down contract because the host is not accepting contracts.")
 		return nil
 	}
+	h.managedBeginOperation()
+	defer h.managedEndOperation()
 
 	// Extend the deadline to meet the rest of file contract negotiation.
 	conn.SetDeadline(time.Now().Add(modules.NegotiateFileContractTime))
@@ -106,6 +110,13 @@ func (h *Host) managedRPCFormContract(conn net.Conn) error {
 		return extendErr("could not read renter public key: ", ErrorConnection(err.Error()))
 	}
 
+	// The host checks that the renter is allowed to form another contract.
+	err = h.managedCheckRenterContract(renterSiaKey(renterPK), nil)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error ignored to preserve type in extendErr
+		return extendErr("renter policy rejected the contract: ", err)
+	}
+
 	// The host verifies that the file contract coming over the wire is
 	// acceptable.
 	err = h.managedVerifyNewContract(txnSet, renterPK)
@@ -210,9 +221,10 @@ func (h *Host) managedVerifyNewContract(txnSet []types.Transaction, renterPK cry
 
 	h.mu.RLock()
 	blockHeight := h.blockHeight
+	policy := h.contractPolicy
 	lockedStorageCollateral := h.financialMetrics.LockedStorageCollateral
 	publicKey := h.publicKey
-	settings := h.settings
+	settings := h.pricedSettings()
 	unlockHash := h.unlockHash
 	h.mu.RUnlock()
 	fc := txnSet[len(txnSet)-1].FileContracts[0]
@@ -275,6 +287,12 @@ func (h *Host) managedVerifyNewContract(txnSet []types.Transaction, renterPK cry
 	if lockedStorageCollateral.Add(expectedCollateral).Cmp(settings.CollateralBudget) > 0 {
 		return errCollateralBudgetExceeded
 	}
+	// Check that the contract is allowed by the contract policy of the host.
+	// The failed obligations of the renter only matter for renewals.
+	err := checkContractPolicy(policy, fc, renterPK, blockHeight, expectedCollateral, settings.CollateralBudget, 0)
+	if err != nil {
+		return err
+	}
 
 	// The unlock hash for the file contract must match the unlock hash that
 	// the host knows how to spend.
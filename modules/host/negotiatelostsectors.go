@@ -0,0 +1,46 @@
+package host
+
+import (
+	"net"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// managedRPCLostSectors sends the renter the Merkle roots of the sectors in a
+// file contract that the host can no longer serve, because they are missing
+// from disk or have been found to be corrupted. The renter proves ownership
+// of the contract through the recent revision challenge, so that the host
+// does not reveal which sectors it stores to a third party.
+func (h *Host) managedRPCLostSectors(conn net.Conn) error {
+	// Authenticate the renter and send the most recent revision, so that the
+	// renter can confirm that the host is talking about the right contract.
+	_, so, err := h.managedRPCRecentRevision(conn)
+	if err != nil {
+		return extendErr("RPCRecentRevision failed: ", err)
+	}
+	// The storage obligation is not modified, the lock only keeps the sector
+	// roots from changing while they are being checked.
+	defer h.managedUnlockStorageObligation(so.id())
+
+	// Extend the deadline to allow for checking every sector of the contract.
+	conn.SetDeadline(time.Now().Add(modules.NegotiateLostSectorsTime))
+	lost, err := h.LostSectors(so.SectorRoots)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err)
+		return extendErr("could not check sectors of "+so.id().String()+": ", ErrorInternal(err.Error()))
+	}
+	err = modules.WriteNegotiationAcceptance(conn)
+	if err != nil {
+		return extendErr("failed to write lost sectors acceptance: ", ErrorConnection(err.Error()))
+	}
+	err = encoding.WriteObject(conn, lost)
+	if err != nil {
+		return extendErr("failed to write lost sectors: ", ErrorConnection(err.Error()))
+	}
+	if len(lost) > 0 {
+		h.log.Printf("INFO: reported %v lost sectors to the renter of %v", len(lost), so.id())
+	}
+	return nil
+}
@@ -92,6 +92,16 @@ func (h *Host) managedVerifyChallengeResponse(fcid types.FileContractID, challen
 //
 // The storage obligation is returned under a storage obligation lock.
 func (h *Host) managedRPCRecentRevision(conn net.Conn) (types.FileContractID, storageObligation, error) {
+	return h.managedRecentRevision(conn, false)
+}
+
+// managedRecentRevision performs the recent revision exchange of
+// managedRPCRecentRevision. If 'modifying' is set, the renter intends to change
+// the contract, which the host refuses with modules.ErrHostMaintenance while
+// it is in maintenance mode.
+//
+// The storage obligation is returned under a storage obligation lock.
+func (h *Host) managedRecentRevision(conn net.Conn, modifying bool) (types.FileContractID, storageObligation, error) {
 	// Set the negotiation deadline.
 	conn.SetDeadline(time.Now().Add(modules.NegotiateRecentRevisionTime))
 
@@ -120,6 +130,12 @@ func (h *Host) managedRPCRecentRevision(conn net.Conn) (types.FileContractID, st
 	if err != nil {
 		return types.FileContractID{}, storageObligation{}, extendErr("could not read challenge response: ", ErrorConnection(err.Error()))
 	}
+	// Refuse changes to the contract in maintenance mode. The refusal comes
+	// before the storage obligation is locked.
+	if modifying && h.managedInMaintenance() {
+		modules.WriteNegotiationRejection(conn, modules.ErrHostMaintenance) // Error not reported to preserve error type in extendErr.
+		return types.FileContractID{}, storageObligation{}, extendErr("refused revision request: ", modules.ErrHostMaintenance)
+	}
 	// Verify the response. In the process, fetch the related storage
 	// obligation, file contract revision, and transaction signatures.
 	so, recentRevision, revisionSigs, err := h.managedVerifyChallengeResponse(fcid, challenge, challengeResponse)
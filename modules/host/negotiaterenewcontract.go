@@ -76,8 +76,8 @@ func (h *Host) managedAddRenewCollateral(so storageObligation, settings modules.
 // managedRenewContract accepts a request to renew a file contract.
 func (h *Host) managedRPCRenewContract(conn net.Conn) error {
 	// Perform the recent revision protocol to get the file contract being
-	// revised.
-	_, so, err := h.managedRPCRecentRevision(conn)
+	// revised. Renewals are refused in maintenance mode.
+	_, so, err := h.managedRecentRevision(conn, true)
 	if err != nil {
 		return extendErr("RPCRecentRevision failed: ", err)
 	}
@@ -101,6 +101,8 @@ func (h *Host) managedRPCRenewContract(conn net.Conn) error {
 	if err != nil {
 		return extendErr("renter rejected the host settings: ", ErrorCommunication(err.Error()))
 	}
+	h.managedBeginOperation()
+	defer h.managedEndOperation()
 	// If the renter sends an acceptance of the settings, it will be followed
 	// by an unsigned transaction containing funding from the renter and a file
 	// contract which matches what the final file contract should look like.
@@ -122,6 +124,14 @@ func (h *Host) managedRPCRenewContract(conn net.Conn) error {
 	settings := h.externalSettings()
 	h.mu.RUnlock()
 
+	// Check that the renter is allowed to renew the contract. The renewed
+	// contract replaces the old contract, and holds the same data.
+	err = h.managedCheckRenterContract(renterSiaKey(renterPK), &so)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored to preserve type for extendErr
+		return extendErr("renter policy rejected the renewal: ", err)
+	}
+
 	// Verify that the transaction coming over the wire is a proper renewal.
 	err = h.managedVerifyRenewedContract(so, txnSet, renterPK)
 	if err != nil {
@@ -223,8 +233,10 @@ func (h *Host) managedVerifyRenewedContract(so storageObligation, txnSet []types
 
 	h.mu.RLock()
 	blockHeight := h.blockHeight
+	policy := h.contractPolicy
+	failures := h.renterFailures[renterKeyString(renterSiaKey(renterPK))]
 	externalSettings := h.externalSettings()
-	internalSettings := h.settings
+	internalSettings := h.pricedSettings()
 	lockedStorageCollateral := h.financialMetrics.LockedStorageCollateral
 	publicKey := h.publicKey
 	unlockHash := h.unlockHash
@@ -272,6 +284,11 @@ func (h *Host) managedVerifyRenewedContract(so storageObligation, txnSet []types
 	if lockedStorageCollateral.Add(expectedCollateral).Cmp(internalSettings.CollateralBudget) > 0 {
 		return errCollateralBudgetExceeded
 	}
+	// Check that the renewal is allowed by the contract policy of the host.
+	err := checkContractPolicy(policy, fc, renterPK, blockHeight, expectedCollateral, internalSettings.CollateralBudget, failures)
+	if err != nil {
+		return err
+	}
 	// Check that the missed proof outputs contain enough money, and that the
 	// void output contains enough money.
 	basePrice := renewBasePrice(so, externalSettings, fc)
@@ -32,10 +32,12 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	if err != nil {
 		return extendErr("renter rejected settings: ", err)
 	}
+	h.managedBeginOperation()
+	defer h.managedEndOperation()
 
 	// Read some variables from the host for use later in the function.
 	h.mu.RLock()
-	settings := h.settings
+	settings := h.pricedSettings()
 	secretKey := h.secretKey
 	blockHeight := h.blockHeight
 	h.mu.RUnlock()
@@ -63,6 +65,13 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	var sectorsGained []crypto.Hash
 	var gainedSectorData [][]byte
 	err = func() error {
+		// Check that the renter is allowed to revise the contract.
+		err := h.managedCheckRenterRevision(*so)
+		if err != nil {
+			return err
+		}
+
+		oldSize := so.storedSize()
 		for _, modification := range modifications {
 			// Check that the index points to an existing sector root. If the type
 			// is ActionInsert, we permit inserting at the end.
@@ -132,6 +141,13 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				return errUnknownModification
 			}
 		}
+		// Check that the renter is allowed to store the added data.
+		if so.storedSize() > oldSize {
+			err := h.managedCheckRenterStorage(*so, so.storedSize()-oldSize)
+			if err != nil {
+				return err
+			}
+		}
 		newRevenue := storageRevenue.Add(bandwidthRevenue)
 		return extendErr("unable to verify revision: ", verifyRevision(*so, revision, blockHeight, newRevenue, newCollateral))
 	}()
@@ -169,10 +185,12 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	}
 
 	// Host will now send acceptance and its signature to the renter. This
-	// iteration is complete. If the finalIter flag is set, StopResponse will
-	// be sent instead. This indicates to the renter that the host wishes to
-	// terminate the revision loop.
-	if finalIter {
+	// iteration is complete. If the finalIter flag is set, or if the host has
+	// entered maintenance mode, StopResponse will be sent instead. This
+	// indicates to the renter that the host wishes to terminate the revision
+	// loop.
+	maintenance := h.managedInMaintenance()
+	if finalIter || maintenance {
 		err = modules.WriteNegotiationStop(conn)
 	} else {
 		err = modules.WriteNegotiationAcceptance(conn)
@@ -184,6 +202,11 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	if err != nil {
 		return extendErr("failed to write revision signatures: ", ErrorConnection(err.Error()))
 	}
+	if maintenance {
+		// The loop ends the same way as when the renter sends a
+		// StopResponse.
+		return modules.ErrStopResponse
+	}
 	return nil
 }
 
@@ -194,8 +217,9 @@ func (h *Host) managedRPCReviseContract(conn net.Conn) error {
 	startTime := time.Now()
 	// Perform the file contract revision exchange, giving the renter the most
 	// recent file contract revision and getting the storage obligation that
-	// will be used to pay for the data.
-	_, so, err := h.managedRPCRecentRevision(conn)
+	// will be used to pay for the data. Revisions are refused in maintenance
+	// mode.
+	_, so, err := h.managedRecentRevision(conn, true)
 	if err != nil {
 		return extendErr("RPCRecentRevision failed: ", err)
 	}
@@ -206,7 +230,8 @@ func (h *Host) managedRPCReviseContract(conn net.Conn) error {
 	}()
 
 	// Begin the revision loop. The host will process revisions until a
-	// timeout is reached, or until the renter sends a StopResponse.
+	// timeout is reached, the host enters maintenance mode, or the renter
+	// sends a StopResponse.
 	for timeoutReached := false; !timeoutReached; {
 		timeoutReached = time.Since(startTime) > iteratedConnectionTime
 		err := h.managedRevisionIteration(conn, &so, timeoutReached)
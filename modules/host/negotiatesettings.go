@@ -26,6 +26,7 @@ func (h *Host) capacity() (total, remaining uint64) {
 // externalSettings compiles and returns the external settings for the host.
 func (h *Host) externalSettings() modules.HostExternalSettings {
 	totalStorage, remainingStorage := h.capacity()
+	settings := h.pricedSettings()
 	var netAddr modules.NetAddress
 	if h.settings.NetAddress != "" {
 		netAddr = h.settings.NetAddress
@@ -33,7 +34,7 @@ func (h *Host) externalSettings() modules.HostExternalSettings {
 		netAddr = h.autoAddress
 	}
 	return modules.HostExternalSettings{
-		AcceptingContracts:   h.settings.AcceptingContracts,
+		AcceptingContracts:   h.settings.AcceptingContracts && !h.maintenance,
 		MaxDownloadBatchSize: h.settings.MaxDownloadBatchSize,
 		MaxDuration:          h.settings.MaxDuration,
 		MaxReviseBatchSize:   h.settings.MaxReviseBatchSize,
@@ -47,10 +48,10 @@ func (h *Host) externalSettings() modules.HostExternalSettings {
 		Collateral:    h.settings.Collateral,
 		MaxCollateral: h.settings.MaxCollateral,
 
-		ContractPrice:          h.settings.MinContractPrice,
-		DownloadBandwidthPrice: h.settings.MinDownloadBandwidthPrice,
-		StoragePrice:           h.settings.MinStoragePrice,
-		UploadBandwidthPrice:   h.settings.MinUploadBandwidthPrice,
+		ContractPrice:          settings.MinContractPrice,
+		DownloadBandwidthPrice: settings.MinDownloadBandwidthPrice,
+		StoragePrice:           settings.MinStoragePrice,
+		UploadBandwidthPrice:   settings.MinUploadBandwidthPrice,
 
 		RevisionNumber: h.revisionNumber,
 		Version:        build.Version,
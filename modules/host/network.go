@@ -114,6 +114,28 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 	}
 	defer h.tg.Done()
 
+	// Refuse the connection if it would exceed the connection limits of the
+	// host.
+	ip := connIP(conn)
+	err = h.managedAddConnection(ip)
+	if err != nil {
+		if err == errConnectionLimit {
+			atomic.AddUint64(&h.atomicConnectionLimitRejections, 1)
+		} else {
+			atomic.AddUint64(&h.atomicIPLimitRejections, 1)
+		}
+		h.log.Debugf("WARN: incoming conn %v was refused: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	defer h.managedRemoveConnection(ip)
+	conn = &rateLimitedConn{
+		Conn:     conn,
+		download: &h.downloadLimiter,
+		upload:   &h.uploadLimiter,
+		stop:     h.tg.StopChan(),
+	}
+
 	// Close the conn on host.Close or when the method terminates, whichever comes
 	// first.
 	connCloseChan := make(chan struct{})
@@ -155,6 +177,9 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 	case modules.RPCReviseContract:
 		atomic.AddUint64(&h.atomicReviseCalls, 1)
 		err = h.managedRPCReviseContract(conn)
+	case modules.RPCLostSectors:
+		atomic.AddUint64(&h.atomicLostSectorsCalls, 1)
+		err = extendErr("incoming RPCLostSectors failed: ", h.managedRPCLostSectors(conn))
 	case modules.RPCRecentRevision:
 		atomic.AddUint64(&h.atomicRecentRevisionCalls, 1)
 		var so storageObligation
@@ -217,9 +242,13 @@ func (h *Host) NetworkMetrics() modules.HostNetworkMetrics {
 		DownloadCalls:     atomic.LoadUint64(&h.atomicDownloadCalls),
 		ErrorCalls:        atomic.LoadUint64(&h.atomicErroredCalls),
 		FormContractCalls: atomic.LoadUint64(&h.atomicFormContractCalls),
+		LostSectorsCalls:  atomic.LoadUint64(&h.atomicLostSectorsCalls),
 		RenewCalls:        atomic.LoadUint64(&h.atomicRenewCalls),
 		ReviseCalls:       atomic.LoadUint64(&h.atomicReviseCalls),
 		SettingsCalls:     atomic.LoadUint64(&h.atomicSettingsCalls),
 		UnrecognizedCalls: atomic.LoadUint64(&h.atomicUnrecognizedCalls),
+
+		ConnectionLimitRejections: atomic.LoadUint64(&h.atomicConnectionLimitRejections),
+		IPLimitRejections:         atomic.LoadUint64(&h.atomicIPLimitRejections),
 	}
 }
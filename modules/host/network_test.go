@@ -1,8 +1,16 @@
 package host
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
 )
 
 // blockingPortForward is a dependency set that causes the host port forward
@@ -56,6 +64,179 @@ func TestPortForwardBlocking(t *testing.T) {
 	time.Sleep(time.Second * 4)
 }
 
+// TestRateLimiter checks that the rate limiter spaces out reservations
+// according to its rate, and that rate limited connections respect it.
+func TestRateLimiter(t *testing.T) {
+	var rl rateLimiter
+	if rl.reserve(1e6) != 0 {
+		t.Fatal("unlimited rate limiter should never wait")
+	}
+	rl.setRate(1000)
+	if rl.reserve(500) != 0 {
+		t.Fatal("first reservation should not wait")
+	}
+	if wait := rl.reserve(500); wait < 400*time.Millisecond || wait > 500*time.Millisecond {
+		t.Fatal("second reservation should wait for the first:", wait)
+	}
+	if wait := rl.reserve(1000); wait < 900*time.Millisecond || wait > time.Second {
+		t.Fatal("third reservation should wait for the first two:", wait)
+	}
+	rl.setRate(0)
+	if rl.reserve(1e6) != 0 {
+		t.Fatal("disabled rate limiter should not wait")
+	}
+
+	// Write three chunks through a rate limited connection. The first chunk
+	// is sent immediately, the other two need to wait.
+	download := new(rateLimiter)
+	download.setRate(rateLimitChunkSize * 10)
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	rlc := &rateLimitedConn{
+		Conn:     c1,
+		download: download,
+		upload:   new(rateLimiter),
+		stop:     make(chan struct{}),
+	}
+	data := bytes.Repeat([]byte{1}, rateLimitChunkSize*3)
+	go io.Copy(ioutil.Discard, c2)
+	start := time.Now()
+	n, err := rlc.Write(data)
+	if err != nil || n != len(data) {
+		t.Fatal("rate limited write failed:", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatal("rate limited write was not limited:", elapsed)
+	}
+}
+
+// TestValidateRateLimits checks that bandwidth limits that are too low to
+// transfer a sector to every allowed connection within the negotiation
+// deadlines are rejected.
+func TestValidateRateLimits(t *testing.T) {
+	minDownload := minTransferSpeed(modules.NegotiateDownloadTime, 0)
+	if minDownload*uint64(modules.NegotiateDownloadTime/time.Second) < modules.SectorSize {
+		t.Fatal("minimum download speed cannot transfer a sector within the deadline:", minDownload)
+	}
+	if minTransferSpeed(modules.NegotiateDownloadTime, 10) != 10*minDownload {
+		t.Fatal("minimum speed should scale with the number of connections")
+	}
+	if minTransferSpeed(modules.NegotiateDownloadTime, math.MaxUint64) != math.MaxUint64 {
+		t.Fatal("minimum speed should not overflow")
+	}
+
+	tests := []struct {
+		settings modules.HostInternalSettings
+		valid    bool
+	}{
+		{modules.HostInternalSettings{}, true},
+		{modules.HostInternalSettings{MaxDownloadSpeed: minDownload}, true},
+		{modules.HostInternalSettings{MaxDownloadSpeed: minDownload - 1}, false},
+		{modules.HostInternalSettings{MaxDownloadSpeed: minDownload, MaxConnections: 2}, false},
+		{modules.HostInternalSettings{MaxDownloadSpeed: 2 * minDownload, MaxConnections: 2}, true},
+		{modules.HostInternalSettings{MaxUploadSpeed: 1}, false},
+		{modules.HostInternalSettings{MaxUploadSpeed: minTransferSpeed(modules.NegotiateFileContractRevisionTime, 0)}, true},
+	}
+	for i, test := range tests {
+		if err := validateRateLimits(test.settings); (err == nil) != test.valid {
+			t.Errorf("%v: expected valid=%v, got %v", i, test.valid, err)
+		}
+	}
+}
+
+// TestConnectionLimits checks that the host refuses connections beyond its
+// connection limits, and counts the refused connections.
+func TestConnectionLimits(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestConnectionLimits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+	settings := ht.host.InternalSettings()
+	settings.MaxConnections = 2
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// openConns waits until the host counts 'n' open connections.
+	openConns := func(n uint64) bool {
+		for i := 0; i < 100; i++ {
+			ht.host.mu.RLock()
+			open := ht.host.openConnections
+			ht.host.mu.RUnlock()
+			if open == n {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+	// refused checks whether the host closes a new connection right away.
+	refused := func() bool {
+		conn, err := net.Dial("tcp", string(ht.host.NetAddress()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return false
+		}
+		return err != nil
+	}
+
+	// Open two connections and leave them idle, reaching the limit.
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", string(ht.host.NetAddress()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	if !openConns(2) {
+		t.Fatal("host did not count the open connections")
+	}
+	if !refused() {
+		t.Fatal("connection beyond the limit was not refused")
+	}
+	if n := atomic.LoadUint64(&ht.host.atomicConnectionLimitRejections); n != 1 {
+		t.Fatal("refused connection was not counted:", n)
+	}
+
+	// Swap the total limit for a per IP limit. All connections come from
+	// the same IP address.
+	settings.MaxConnections = 0
+	settings.MaxConnectionsPerIP = 2
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !refused() {
+		t.Fatal("connection beyond the IP limit was not refused")
+	}
+	if n := ht.host.NetworkMetrics().IPLimitRejections; n != 1 {
+		t.Fatal("refused connection was not counted:", n)
+	}
+
+	// Closing a connection makes room for a new one.
+	conns[0].Close()
+	if !openConns(1) {
+		t.Fatal("host did not release the closed connection")
+	}
+	if refused() {
+		t.Fatal("connection within the limits was refused")
+	}
+}
+
 /*
 import (
 	"path/filepath"
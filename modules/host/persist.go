@@ -19,12 +19,17 @@ type persistence struct {
 	DownloadCalls       uint64 `json:"downloadcalls"`
 	ErroredCalls        uint64 `json:"erroredcalls"`
 	FormContractCalls   uint64 `json:"formcontractcalls"`
+	LostSectorsCalls    uint64 `json:"lostsectorscalls"`
 	RenewCalls          uint64 `json:"renewcalls"`
 	ReviseCalls         uint64 `json:"revisecalls"`
 	RecentRevisionCalls uint64 `json:"recentrevisioncalls"`
 	SettingsCalls       uint64 `json:"settingscalls"`
 	UnrecognizedCalls   uint64 `json:"unrecognizedcalls"`
 
+	// Connection limit metrics.
+	ConnectionLimitRejections uint64 `json:"connectionlimitrejections"`
+	IPLimitRejections         uint64 `json:"iplimitrejections"`
+
 	// Consensus Tracking.
 	BlockHeight  types.BlockHeight         `json:"blockheight"`
 	RecentChange modules.ConsensusChangeID `json:"recentchange"`
@@ -38,6 +43,16 @@ type persistence struct {
 	SecretKey        crypto.SecretKey             `json:"secretkey"`
 	Settings         modules.HostInternalSettings `json:"settings"`
 	UnlockHash       types.UnlockHash             `json:"unlockhash"`
+
+	// Pricing.
+	Prices        hostPrices                `json:"prices"`
+	PricingPolicy modules.HostPricingPolicy `json:"pricingpolicy"`
+
+	// Renter policies.
+	RenterPolicies []modules.HostRenterPolicy `json:"renterpolicies"`
+
+	// Contract policy.
+	ContractPolicy modules.HostContractPolicy `json:"contractpolicy"`
 }
 
 // persistData returns the data in the Host that will be saved to disk.
@@ -47,12 +62,17 @@ func (h *Host) persistData() persistence {
 		DownloadCalls:       atomic.LoadUint64(&h.atomicDownloadCalls),
 		ErroredCalls:        atomic.LoadUint64(&h.atomicErroredCalls),
 		FormContractCalls:   atomic.LoadUint64(&h.atomicFormContractCalls),
+		LostSectorsCalls:    atomic.LoadUint64(&h.atomicLostSectorsCalls),
 		RenewCalls:          atomic.LoadUint64(&h.atomicRenewCalls),
 		ReviseCalls:         atomic.LoadUint64(&h.atomicReviseCalls),
 		RecentRevisionCalls: atomic.LoadUint64(&h.atomicRecentRevisionCalls),
 		SettingsCalls:       atomic.LoadUint64(&h.atomicSettingsCalls),
 		UnrecognizedCalls:   atomic.LoadUint64(&h.atomicUnrecognizedCalls),
 
+		// Connection limit metrics.
+		ConnectionLimitRejections: atomic.LoadUint64(&h.atomicConnectionLimitRejections),
+		IPLimitRejections:         atomic.LoadUint64(&h.atomicIPLimitRejections),
+
 		// Consensus Tracking.
 		BlockHeight:  h.blockHeight,
 		RecentChange: h.recentChange,
@@ -66,6 +86,16 @@ func (h *Host) persistData() persistence {
 		SecretKey:        h.secretKey,
 		Settings:         h.settings,
 		UnlockHash:       h.unlockHash,
+
+		// Pricing.
+		Prices:        h.prices,
+		PricingPolicy: h.pricingPolicy,
+
+		// Renter policies.
+		RenterPolicies: h.renterPolicyList(),
+
+		// Contract policy.
+		ContractPolicy: h.contractPolicy,
 	}
 }
 
@@ -128,6 +158,7 @@ func (h *Host) initDB() (err error) {
 		// database needs to be initialized. Create the database buckets.
 		buckets := [][]byte{
 			bucketActionItems,
+			bucketLedger,
 			bucketStorageObligations,
 		}
 		for _, bucket := range buckets {
@@ -155,11 +186,14 @@ func (h *Host) load() error {
 	atomic.StoreUint64(&h.atomicDownloadCalls, p.DownloadCalls)
 	atomic.StoreUint64(&h.atomicErroredCalls, p.ErroredCalls)
 	atomic.StoreUint64(&h.atomicFormContractCalls, p.FormContractCalls)
+	atomic.StoreUint64(&h.atomicLostSectorsCalls, p.LostSectorsCalls)
 	atomic.StoreUint64(&h.atomicRenewCalls, p.RenewCalls)
 	atomic.StoreUint64(&h.atomicReviseCalls, p.ReviseCalls)
 	atomic.StoreUint64(&h.atomicRecentRevisionCalls, p.RecentRevisionCalls)
 	atomic.StoreUint64(&h.atomicSettingsCalls, p.SettingsCalls)
 	atomic.StoreUint64(&h.atomicUnrecognizedCalls, p.UnrecognizedCalls)
+	atomic.StoreUint64(&h.atomicConnectionLimitRejections, p.ConnectionLimitRejections)
+	atomic.StoreUint64(&h.atomicIPLimitRejections, p.IPLimitRejections)
 
 	// Copy over consensus tracking.
 	h.blockHeight = p.BlockHeight
@@ -177,12 +211,27 @@ func (h *Host) load() error {
 	h.revisionNumber = p.RevisionNumber
 	h.secretKey = p.SecretKey
 	h.settings = p.Settings
+	h.downloadLimiter.setRate(h.settings.MaxDownloadSpeed)
+	h.uploadLimiter.setRate(h.settings.MaxUploadSpeed)
 	if err := p.Settings.NetAddress.IsValid(); err != nil {
 		h.log.Printf("WARN: NetAddress '%v' loaded from persist is invalid: %v", p.Settings.NetAddress, err)
 		h.settings.NetAddress = ""
 	}
 	h.unlockHash = p.UnlockHash
 
+	// Copy over the pricing policy.
+	h.prices = p.Prices
+	h.pricingPolicy = p.PricingPolicy
+
+	// Copy over the renter policies.
+	h.renterPolicies = make(map[string]modules.HostRenterPolicy)
+	for _, policy := range p.RenterPolicies {
+		h.renterPolicies[renterKeyString(policy.RenterKey)] = policy
+	}
+
+	// Copy over the contract policy.
+	h.contractPolicy = p.ContractPolicy
+
 	// Get the number of storage obligations by looking at the storage
 	// obligation database.
 	err = h.db.View(func(tx *bolt.Tx) error {
@@ -193,6 +242,12 @@ func (h *Host) load() error {
 		return err
 	}
 
+	// Count the unresolved storage obligations of each renter.
+	err = h.loadRenterUsages()
+	if err != nil {
+		return err
+	}
+
 	// COMPAT v1.0.0
 	//
 	// Load compatibility fields which may have data leftover. This call should
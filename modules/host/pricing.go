@@ -0,0 +1,312 @@
+package host
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errPricingMaxMultiplier is returned if a utilisation pricing policy
+	// would allow prices to fall below the minimum prices of the host.
+	errPricingMaxMultiplier = errors.New("pricing policy max multiplier must be a finite number of at least 1")
+
+	// errPricingPercentile is returned if the percentile of a network pricing
+	// policy is out of range.
+	errPricingPercentile = errors.New("pricing policy percentile must be between 0 and 100")
+
+	// errUnknownPricingMode is returned if a pricing policy has a mode that
+	// the host does not recognize.
+	errUnknownPricingMode = errors.New("unknown pricing mode")
+)
+
+// hostPrices are the prices that the host derives from its pricing policy.
+type hostPrices struct {
+	ContractPrice          types.Currency `json:"contractprice"`
+	DownloadBandwidthPrice types.Currency `json:"downloadbandwidthprice"`
+	StoragePrice           types.Currency `json:"storageprice"`
+	UploadBandwidthPrice   types.Currency `json:"uploadbandwidthprice"`
+}
+
+// byPrice sorts a list of prices from cheapest to most expensive.
+type byPrice []types.Currency
+
+func (bp byPrice) Len() int           { return len(bp) }
+func (bp byPrice) Less(i, j int) bool { return bp[i].Cmp(bp[j]) < 0 }
+func (bp byPrice) Swap(i, j int)      { bp[i], bp[j] = bp[j], bp[i] }
+
+// dynamicPricing returns whether the policy derives prices that may differ
+// from the minimum prices of the host.
+func dynamicPricing(policy modules.HostPricingPolicy) bool {
+	return policy.Mode == modules.HostPricingUtilisation || policy.Mode == modules.HostPricingNetwork
+}
+
+// validatePricingPolicy checks that the fields of a pricing policy are
+// sensible.
+func validatePricingPolicy(policy modules.HostPricingPolicy) error {
+	switch policy.Mode {
+	case "", modules.HostPricingFixed:
+	case modules.HostPricingUtilisation:
+		if policy.MaxMultiplier < 1 || math.IsNaN(policy.MaxMultiplier) || math.IsInf(policy.MaxMultiplier, 0) {
+			return errPricingMaxMultiplier
+		}
+	case modules.HostPricingNetwork:
+		if policy.MaxMultiplier != 0 && policy.MaxMultiplier < 1 || math.IsNaN(policy.MaxMultiplier) || math.IsInf(policy.MaxMultiplier, 0) {
+			return errPricingMaxMultiplier
+		}
+		if policy.Percentile < 0 || policy.Percentile > 100 || math.IsNaN(policy.Percentile) {
+			return errPricingPercentile
+		}
+	default:
+		return errUnknownPricingMode
+	}
+	return nil
+}
+
+// maxCurrency returns the larger of two currencies.
+func maxCurrency(a, b types.Currency) types.Currency {
+	if a.Cmp(b) < 0 {
+		return b
+	}
+	return a
+}
+
+// mulFloat multiplies a currency by a non-negative float.
+func mulFloat(c types.Currency, f float64) types.Currency {
+	return c.MulRat(new(big.Rat).SetFloat64(f))
+}
+
+// utilisationPrice scales a minimum price along a quadratic curve, so that
+// an empty host charges the minimum price and a full host charges
+// maxMultiplier times the minimum price. The price rises slowly while the host
+// has plenty of space and quickly as the host fills up, pushing utilisation
+// towards the high end without running the host out of space.
+func utilisationPrice(min types.Currency, utilisation, maxMultiplier float64) types.Currency {
+	return mulFloat(min, 1+(maxMultiplier-1)*utilisation*utilisation)
+}
+
+// networkPrice returns the price at the given percentile of the network
+// prices, bounded below by the minimum price. If maxMultiplier is non-zero,
+// the price is also capped at maxMultiplier times the minimum price.
+func networkPrice(min types.Currency, network []types.Currency, percentile, maxMultiplier float64) types.Currency {
+	if len(network) == 0 {
+		return min
+	}
+	sort.Sort(byPrice(network))
+	price := network[int(percentile/100*float64(len(network)-1)+0.5)]
+	if maxMultiplier != 0 && !min.IsZero() {
+		limit := mulFloat(min, maxMultiplier)
+		if price.Cmp(limit) > 0 {
+			price = limit
+		}
+	}
+	return maxCurrency(min, price)
+}
+
+// computePrices applies a pricing policy to the minimum prices in the
+// settings of the host. The capacity of the host is used in utilisation mode,
+// and the settings of the other hosts on the network are used in network
+// mode.
+func computePrices(policy modules.HostPricingPolicy, settings modules.HostInternalSettings, total, remaining uint64, hosts []modules.HostDBEntry) hostPrices {
+	prices := hostPrices{
+		ContractPrice:          settings.MinContractPrice,
+		DownloadBandwidthPrice: settings.MinDownloadBandwidthPrice,
+		StoragePrice:           settings.MinStoragePrice,
+		UploadBandwidthPrice:   settings.MinUploadBandwidthPrice,
+	}
+	switch policy.Mode {
+	case modules.HostPricingUtilisation:
+		var utilisation float64
+		if total > 0 && remaining < total {
+			utilisation = float64(total-remaining) / float64(total)
+		}
+		prices.ContractPrice = utilisationPrice(prices.ContractPrice, utilisation, policy.MaxMultiplier)
+		prices.DownloadBandwidthPrice = utilisationPrice(prices.DownloadBandwidthPrice, utilisation, policy.MaxMultiplier)
+		prices.StoragePrice = utilisationPrice(prices.StoragePrice, utilisation, policy.MaxMultiplier)
+		prices.UploadBandwidthPrice = utilisationPrice(prices.UploadBandwidthPrice, utilisation, policy.MaxMultiplier)
+	case modules.HostPricingNetwork:
+		var contract, download, storage, upload []types.Currency
+		for _, host := range hosts {
+			contract = append(contract, host.ContractPrice)
+			download = append(download, host.DownloadBandwidthPrice)
+			storage = append(storage, host.StoragePrice)
+			upload = append(upload, host.UploadBandwidthPrice)
+		}
+		prices.ContractPrice = networkPrice(prices.ContractPrice, contract, policy.Percentile, policy.MaxMultiplier)
+		prices.DownloadBandwidthPrice = networkPrice(prices.DownloadBandwidthPrice, download, policy.Percentile, policy.MaxMultiplier)
+		prices.StoragePrice = networkPrice(prices.StoragePrice, storage, policy.Percentile, policy.MaxMultiplier)
+		prices.UploadBandwidthPrice = networkPrice(prices.UploadBandwidthPrice, upload, policy.Percentile, policy.MaxMultiplier)
+	}
+	return prices
+}
+
+// priceChanged returns whether a price has moved by more than the
+// priceChangeThreshold.
+func priceChanged(before, after types.Currency) bool {
+	if before.IsZero() {
+		return !after.IsZero()
+	}
+	var diff types.Currency
+	if after.Cmp(before) > 0 {
+		diff = after.Sub(before)
+	} else {
+		diff = before.Sub(after)
+	}
+	return diff.Cmp(mulFloat(before, priceChangeThreshold)) > 0
+}
+
+// pricesChanged returns whether any of the prices have moved by more than the
+// priceChangeThreshold.
+func pricesChanged(before, after hostPrices) bool {
+	return priceChanged(before.ContractPrice, after.ContractPrice) ||
+		priceChanged(before.DownloadBandwidthPrice, after.DownloadBandwidthPrice) ||
+		priceChanged(before.StoragePrice, after.StoragePrice) ||
+		priceChanged(before.UploadBandwidthPrice, after.UploadBandwidthPrice)
+}
+
+// pricedSettings returns the internal settings of the host with the minimum
+// prices replaced by the prices derived from the pricing policy. The settings
+// are used everywhere the host advertises or charges a price. The minimum
+// prices are always respected, even if the user has raised them since the
+// prices were last evaluated.
+func (h *Host) pricedSettings() modules.HostInternalSettings {
+	settings := h.settings
+	if !dynamicPricing(h.pricingPolicy) {
+		return settings
+	}
+	settings.MinContractPrice = maxCurrency(settings.MinContractPrice, h.prices.ContractPrice)
+	settings.MinDownloadBandwidthPrice = maxCurrency(settings.MinDownloadBandwidthPrice, h.prices.DownloadBandwidthPrice)
+	settings.MinStoragePrice = maxCurrency(settings.MinStoragePrice, h.prices.StoragePrice)
+	settings.MinUploadBandwidthPrice = maxCurrency(settings.MinUploadBandwidthPrice, h.prices.UploadBandwidthPrice)
+	return settings
+}
+
+// managedUpdatePrices re-evaluates the pricing policy of the host. If the
+// prices have changed significantly, the host starts charging the new prices
+// and makes a new announcement so that renters learn about the change.
+func (h *Host) managedUpdatePrices() {
+	h.mu.RLock()
+	policy := h.pricingPolicy
+	settings := h.settings
+	source := h.priceSource
+	h.mu.RUnlock()
+	if !dynamicPricing(policy) {
+		return
+	}
+
+	// Gather the inputs of the policy without holding the host lock, the
+	// price source belongs to another module.
+	total, remaining := h.capacity()
+	var hosts []modules.HostDBEntry
+	if policy.Mode == modules.HostPricingNetwork {
+		// Without any network prices the host keeps its current prices.
+		if source == nil {
+			h.log.Println("WARN: network pricing requires the renter module, prices were not updated")
+			return
+		}
+		hosts = source.ActiveHosts()
+		if len(hosts) == 0 {
+			return
+		}
+	}
+	prices := computePrices(policy, settings, total, remaining, hosts)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !pricesChanged(h.prices, prices) {
+		return
+	}
+	h.prices = prices
+	h.revisionNumber++
+	h.log.Printf("INFO: prices updated: contract %v, download %v, storage %v, upload %v", prices.ContractPrice, prices.DownloadBandwidthPrice, prices.StoragePrice, prices.UploadBandwidthPrice)
+	err := h.save()
+	if err != nil {
+		h.log.Println("WARN: could not save the updated prices:", err)
+	}
+
+	// Announce the host so that renters notice the new prices. An
+	// announcement is only needed if the host is in the market for new
+	// contracts and has announced before.
+	if !h.announced || !h.settings.AcceptingContracts {
+		return
+	}
+	addr := h.settings.NetAddress
+	if addr == "" {
+		addr = h.autoAddress
+	}
+	if addr == "" {
+		return
+	}
+	err = h.announce(addr)
+	if err != nil {
+		h.log.Println("WARN: could not announce the updated prices:", err)
+	}
+}
+
+// threadedUpdatePrices periodically re-evaluates the pricing policy of the
+// host. The prices are persisted, so there is no need to evaluate them at
+// startup, before the price source has been set.
+func (h *Host) threadedUpdatePrices(closeChan chan struct{}) {
+	defer close(closeChan)
+	for {
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(pricingFrequency):
+		}
+		h.managedUpdatePrices()
+	}
+}
+
+// PricingPolicy returns the policy the host uses to set its prices.
+func (h *Host) PricingPolicy() modules.HostPricingPolicy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to PricingPolicy after close")
+	}
+	defer h.tg.Done()
+	return h.pricingPolicy
+}
+
+// SetPriceSource sets the source of the network prices used by the network
+// pricing mode. Typically the source is the renter, which tracks the settings
+// of the other hosts in its hostdb.
+func (h *Host) SetPriceSource(source modules.HostPriceSource) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.priceSource = source
+}
+
+// SetPricingPolicy sets the policy the host uses to set its prices. The
+// prices are re-evaluated immediately.
+func (h *Host) SetPricingPolicy(policy modules.HostPricingPolicy) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	err = validatePricingPolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.pricingPolicy = policy
+	h.prices = hostPrices{}
+	h.revisionNumber++
+	err = h.saveSync()
+	h.mu.Unlock()
+	if err != nil {
+		return errors.New("pricing policy updated, but failed saving to disk: " + err.Error())
+	}
+	h.managedUpdatePrices()
+	return nil
+}
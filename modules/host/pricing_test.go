@@ -0,0 +1,196 @@
+package host
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// stubPriceSource is a price source that returns a fixed set of hosts.
+type stubPriceSource []modules.HostDBEntry
+
+func (sps stubPriceSource) ActiveHosts() []modules.HostDBEntry { return sps }
+
+// networkHosts returns hosts with storage prices of 1 through n.
+func networkHosts(n uint64) stubPriceSource {
+	var hosts stubPriceSource
+	for i := n; i > 0; i-- {
+		var entry modules.HostDBEntry
+		entry.StoragePrice = types.NewCurrency64(i)
+		hosts = append(hosts, entry)
+	}
+	return hosts
+}
+
+// TestComputePrices checks the prices produced by each pricing mode.
+func TestComputePrices(t *testing.T) {
+	var settings modules.HostInternalSettings
+	settings.MinStoragePrice = types.NewCurrency64(100)
+
+	// Fixed pricing always charges the minimum price.
+	prices := computePrices(modules.HostPricingPolicy{Mode: modules.HostPricingFixed}, settings, 100, 0, nil)
+	if prices.StoragePrice.Cmp(settings.MinStoragePrice) != 0 {
+		t.Fatal("fixed pricing changed the price:", prices.StoragePrice)
+	}
+
+	// Utilisation pricing follows a quadratic curve from the minimum price to
+	// MaxMultiplier times the minimum price.
+	policy := modules.HostPricingPolicy{Mode: modules.HostPricingUtilisation, MaxMultiplier: 3}
+	tests := []struct {
+		total, remaining uint64
+		price            uint64
+	}{
+		{0, 0, 100},
+		{100, 100, 100},
+		{100, 50, 150},
+		{100, 0, 300},
+	}
+	for _, test := range tests {
+		prices = computePrices(policy, settings, test.total, test.remaining, nil)
+		if prices.StoragePrice.Cmp(types.NewCurrency64(test.price)) != 0 {
+			t.Errorf("%v/%v remaining: expected %v, got %v", test.remaining, test.total, test.price, prices.StoragePrice)
+		}
+	}
+
+	// Network pricing picks the price at the requested percentile, bounded
+	// below by the minimum price and above by MaxMultiplier.
+	hosts := networkHosts(1000)
+	policy = modules.HostPricingPolicy{Mode: modules.HostPricingNetwork, Percentile: 50}
+	prices = computePrices(policy, settings, 0, 0, hosts)
+	if prices.StoragePrice.Cmp(types.NewCurrency64(501)) != 0 {
+		t.Error("expected the median network price, got", prices.StoragePrice)
+	}
+	policy.Percentile = 0
+	prices = computePrices(policy, settings, 0, 0, hosts)
+	if prices.StoragePrice.Cmp(settings.MinStoragePrice) != 0 {
+		t.Error("network price went below the minimum price:", prices.StoragePrice)
+	}
+	policy.Percentile = 100
+	policy.MaxMultiplier = 2
+	prices = computePrices(policy, settings, 0, 0, hosts)
+	if prices.StoragePrice.Cmp(types.NewCurrency64(200)) != 0 {
+		t.Error("network price was not capped:", prices.StoragePrice)
+	}
+	prices = computePrices(policy, settings, 0, 0, nil)
+	if prices.StoragePrice.Cmp(settings.MinStoragePrice) != 0 {
+		t.Error("expected the minimum price without network prices, got", prices.StoragePrice)
+	}
+}
+
+// TestPricesChanged checks that only significant price changes are reported.
+func TestPricesChanged(t *testing.T) {
+	before := hostPrices{StoragePrice: types.NewCurrency64(100)}
+	after := before
+	if pricesChanged(before, after) {
+		t.Error("identical prices were reported as changed")
+	}
+	after.StoragePrice = types.NewCurrency64(104)
+	if pricesChanged(before, after) {
+		t.Error("a small price change was reported")
+	}
+	after.StoragePrice = types.NewCurrency64(90)
+	if !pricesChanged(before, after) {
+		t.Error("a large price change was not reported")
+	}
+	after = before
+	after.ContractPrice = types.NewCurrency64(1)
+	if !pricesChanged(before, after) {
+		t.Error("a price rising from zero was not reported")
+	}
+}
+
+// TestValidatePricingPolicy checks that pricing policies with out of range or
+// non-finite numbers are rejected.
+func TestValidatePricingPolicy(t *testing.T) {
+	tests := []struct {
+		policy modules.HostPricingPolicy
+		err    error
+	}{
+		{modules.HostPricingPolicy{Mode: modules.HostPricingUtilisation, MaxMultiplier: 1}, nil},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingUtilisation, MaxMultiplier: 0.5}, errPricingMaxMultiplier},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingUtilisation, MaxMultiplier: math.NaN()}, errPricingMaxMultiplier},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingUtilisation, MaxMultiplier: math.Inf(1)}, errPricingMaxMultiplier},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingNetwork, Percentile: 50}, nil},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingNetwork, Percentile: 50, MaxMultiplier: math.NaN()}, errPricingMaxMultiplier},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingNetwork, Percentile: 50, MaxMultiplier: math.Inf(1)}, errPricingMaxMultiplier},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingNetwork, Percentile: math.NaN()}, errPricingPercentile},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingNetwork, Percentile: math.Inf(1)}, errPricingPercentile},
+		{modules.HostPricingPolicy{Mode: modules.HostPricingNetwork, Percentile: math.Inf(-1)}, errPricingPercentile},
+	}
+	for _, test := range tests {
+		if err := validatePricingPolicy(test.policy); err != test.err {
+			t.Errorf("%v: expected %v, got %v", test.policy, test.err, err)
+		}
+	}
+}
+
+// TestSetPricingPolicy checks that the pricing policy of the host is
+// validated, applied to the advertised prices, and persisted.
+func TestSetPricingPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestSetPricingPolicy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	for _, policy := range []modules.HostPricingPolicy{
+		{Mode: "foo"},
+		{Mode: modules.HostPricingUtilisation, MaxMultiplier: 0.5},
+		{Mode: modules.HostPricingNetwork, Percentile: 101},
+	} {
+		if err := ht.host.SetPricingPolicy(policy); err == nil {
+			t.Error("invalid policy was accepted:", policy)
+		}
+	}
+
+	// Follow the network, which is more expensive than the host's minimum
+	// storage price.
+	minPrice := ht.host.InternalSettings().MinStoragePrice
+	ht.host.SetPriceSource(stubPriceSource{{HostExternalSettings: modules.HostExternalSettings{StoragePrice: minPrice.Mul64(2)}}})
+	policy := modules.HostPricingPolicy{Mode: modules.HostPricingNetwork, Percentile: 50}
+	err = ht.host.SetPricingPolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.host.PricingPolicy() != policy {
+		t.Fatal("pricing policy was not set")
+	}
+	if ht.host.ExternalSettings().StoragePrice.Cmp(minPrice.Mul64(2)) != 0 {
+		t.Fatal("host is not advertising the network price:", ht.host.ExternalSettings().StoragePrice)
+	}
+	if ht.host.InternalSettings().MinStoragePrice.Cmp(minPrice) != 0 {
+		t.Fatal("dynamic pricing changed the minimum price")
+	}
+
+	// The policy and prices should survive a restart.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.host.PricingPolicy() != policy {
+		t.Fatal("pricing policy was not persisted")
+	}
+	if ht.host.ExternalSettings().StoragePrice.Cmp(minPrice.Mul64(2)) != 0 {
+		t.Fatal("prices were not persisted")
+	}
+
+	// Switching back to fixed pricing restores the minimum prices.
+	err = ht.host.SetPricingPolicy(modules.HostPricingPolicy{Mode: modules.HostPricingFixed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.host.ExternalSettings().StoragePrice.Cmp(minPrice) != 0 {
+		t.Fatal("fixed pricing is not advertising the minimum price")
+	}
+}
@@ -0,0 +1,206 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// ratelimit.go enforces the connection and bandwidth limits of the host. The
+// limits are applied in threadedHandleConn, before any RPC is read, so that a
+// single busy renter cannot starve the host or the other renters of
+// connections and bandwidth.
+//
+// The bandwidth limits are shared by all connections, and the renter gives up
+// on a download or a revision that does not finish within the negotiation
+// deadline. A bandwidth limit is therefore only accepted if every connection
+// that the host allows can transfer a full sector within the deadline at the
+// same time.
+
+const (
+	// rateLimitChunkSize is the largest number of bytes that a rate limited
+	// connection writes at once. Large writes are split into chunks so that
+	// concurrent connections share the available bandwidth.
+	rateLimitChunkSize = 1 << 14
+)
+
+var (
+	// errConnectionLimit is returned when a connection is refused because the
+	// host already has the maximum number of concurrent connections open.
+	errConnectionLimit = errors.New("host has reached its maximum number of connections")
+
+	// errIPConnectionLimit is returned when a connection is refused because
+	// the host already has the maximum number of concurrent connections open
+	// to the IP address of the connection.
+	errIPConnectionLimit = errors.New("host has reached its maximum number of connections for this IP address")
+
+	// errRateLimitStopped is returned by a rate limited connection if the host
+	// shuts down while the connection is waiting for bandwidth.
+	errRateLimitStopped = errors.New("host shut down while waiting for bandwidth")
+)
+
+type (
+	// A rateLimiter limits the combined rate at which data is transferred over
+	// all of the connections that share the limiter. The limiter tracks the
+	// time at which all previously reserved bytes will have been transferred.
+	rateLimiter struct {
+		mu   sync.Mutex
+		rate uint64 // bytes per second, 0 is unlimited
+		next time.Time
+	}
+
+	// A rateLimitedConn is a net.Conn that limits reads with the upload
+	// limiter and writes with the download limiter of the host. The names
+	// follow the renter's point of view, in line with the bandwidth prices.
+	rateLimitedConn struct {
+		net.Conn
+		download *rateLimiter
+		upload   *rateLimiter
+		stop     <-chan struct{}
+	}
+)
+
+// minTransferSpeed returns the lowest bandwidth limit, in bytes per second,
+// at which 'maxConnections' connections can each transfer a sector within
+// 'deadline' at the same time. A maxConnections of 0 is unlimited, in which
+// case the limit only has to allow a single connection to transfer a sector.
+func minTransferSpeed(deadline time.Duration, maxConnections uint64) uint64 {
+	perConn := (modules.SectorSize*uint64(time.Second) + uint64(deadline) - 1) / uint64(deadline)
+	if maxConnections == 0 {
+		return perConn
+	}
+	if maxConnections > math.MaxUint64/perConn {
+		return math.MaxUint64
+	}
+	return maxConnections * perConn
+}
+
+// validateRateLimits checks that the bandwidth limits of the settings allow
+// sectors to be transferred within the negotiation deadlines.
+func validateRateLimits(settings modules.HostInternalSettings) error {
+	minDownload := minTransferSpeed(modules.NegotiateDownloadTime, settings.MaxConnections)
+	if settings.MaxDownloadSpeed != 0 && settings.MaxDownloadSpeed < minDownload {
+		return fmt.Errorf("maxdownloadspeed must be 0 or at least %v bytes per second to send a sector to every connection within the download deadline", minDownload)
+	}
+	minUpload := minTransferSpeed(modules.NegotiateFileContractRevisionTime, settings.MaxConnections)
+	if settings.MaxUploadSpeed != 0 && settings.MaxUploadSpeed < minUpload {
+		return fmt.Errorf("maxuploadspeed must be 0 or at least %v bytes per second to receive a sector from every connection within the revision deadline", minUpload)
+	}
+	return nil
+}
+
+// setRate changes the rate of the limiter to 'rate' bytes per second. A rate
+// of 0 disables the limiter.
+func (rl *rateLimiter) setRate(rate uint64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.rate = rate
+	rl.next = time.Time{}
+}
+
+// reserve reserves bandwidth for 'n' bytes, returning how long the caller
+// needs to wait before the bytes may be transferred.
+func (rl *rateLimiter) reserve(n int) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.rate == 0 || n <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	wait := rl.next.Sub(now)
+	rl.next = rl.next.Add(time.Duration(uint64(n) * uint64(time.Second) / rl.rate))
+	return wait
+}
+
+// wait blocks for 'd', returning early with an error if the host shuts down.
+func (rlc *rateLimitedConn) wait(d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-rlc.stop:
+		return errRateLimitStopped
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// Read reads data from the connection, and then waits until the upload limiter
+// has bandwidth for the data that was read.
+func (rlc *rateLimitedConn) Read(b []byte) (int, error) {
+	n, err := rlc.Conn.Read(b)
+	if waitErr := rlc.wait(rlc.upload.reserve(n)); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+// Write waits for the download limiter to have bandwidth for the data, and
+// then writes the data to the connection, one chunk at a time.
+func (rlc *rateLimitedConn) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > rateLimitChunkSize {
+			chunk = chunk[:rateLimitChunkSize]
+		}
+		if err := rlc.wait(rlc.download.reserve(len(chunk))); err != nil {
+			return written, err
+		}
+		n, err := rlc.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// connIP returns the IP address of the remote end of a connection, which is
+// used to count the connections of each IP address.
+func connIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// managedAddConnection registers an incoming connection from 'ip', returning
+// an error if accepting the connection would exceed the connection limits of
+// the host. Every successful call must be followed by a call to
+// managedRemoveConnection once the connection is finished.
+func (h *Host) managedAddConnection(ip string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.settings.MaxConnections > 0 && h.openConnections >= h.settings.MaxConnections {
+		return errConnectionLimit
+	}
+	if h.settings.MaxConnectionsPerIP > 0 && h.ipConnections[ip] >= h.settings.MaxConnectionsPerIP {
+		return errIPConnectionLimit
+	}
+	h.openConnections++
+	h.ipConnections[ip]++
+	return nil
+}
+
+// managedRemoveConnection unregisters a connection from 'ip' that was
+// registered by managedAddConnection.
+func (h *Host) managedRemoveConnection(ip string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.openConnections--
+	h.ipConnections[ip]--
+	if h.ipConnections[ip] == 0 {
+		delete(h.ipConnections, ip)
+	}
+}
@@ -0,0 +1,324 @@
+package host
+
+// renterpolicy.go lets the host operator limit the resources that a single
+// renter can use on the host, so that one renter cannot use up all of the
+// host's storage or flood the host with revisions. Renters are identified by
+// the public key in the unlock conditions of their contracts. A policy can
+// block a renter entirely, or cap its total storage, its number of contracts,
+// and the rate at which it revises its contracts. Renters without a policy
+// are not limited.
+//
+// The storage and contract limits are checked against the unresolved storage
+// obligations of the renter. The host counts the obligations and the data of
+// every renter in memory as obligations are added, modified, and removed, and
+// recounts them from the database on startup. The failed obligations of every
+// renter are counted the same way, for the contract policy. The revision rate
+// is counted in memory, over windows of one minute.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errBadRenterKey is returned when a renter policy is set for a public
+	// key that is not an ed25519 key, which renters always use.
+	errBadRenterKey = errors.New("renter key must be an ed25519 public key")
+
+	// errRenterBlocked is returned to a renter that has been blocked by the
+	// host operator.
+	errRenterBlocked = ErrorCommunication("rejected because the renter has been blocked by the host")
+
+	// errRenterContractLimit is returned when a renter tries to form a
+	// contract while it already has the maximum number of contracts that the
+	// host allows it.
+	errRenterContractLimit = ErrorCommunication("rejected because the renter has reached the maximum number of contracts allowed by the host")
+
+	// errRenterRevisionLimit is returned when a renter revises its contracts
+	// more often than the host allows it.
+	errRenterRevisionLimit = ErrorCommunication("rejected because the renter has reached the maximum number of revisions per minute allowed by the host")
+
+	// errRenterStorageLimit is returned when a renter tries to store more
+	// data than the host allows it.
+	errRenterStorageLimit = ErrorCommunication("rejected because the renter has reached the maximum amount of storage allowed by the host")
+
+	// errUnknownRenterPolicy is returned when removing the policy of a renter
+	// that has no policy.
+	errUnknownRenterPolicy = errors.New("no policy exists for that renter")
+)
+
+// renterUsage counts the unresolved storage obligations of a renter, and the
+// amount of data they hold.
+type renterUsage struct {
+	contracts uint64
+	storage   uint64
+}
+
+// revisionWindow counts the revisions of a renter during one minute.
+type revisionWindow struct {
+	start time.Time
+	count uint64
+}
+
+// byRenterKey sorts renter policies by renter key.
+type byRenterKey []modules.HostRenterPolicy
+
+func (s byRenterKey) Len() int      { return len(s) }
+func (s byRenterKey) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byRenterKey) Less(i, j int) bool {
+	return bytes.Compare(s[i].RenterKey.Key, s[j].RenterKey.Key) < 0
+}
+
+// renterSiaKey returns the renter key that is used in the unlock conditions
+// of the renter's contracts.
+func renterSiaKey(pk crypto.PublicKey) types.SiaPublicKey {
+	return types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+}
+
+// renterKeyString returns the string that identifies the renter in the
+// renter policy maps of the host.
+func renterKeyString(spk types.SiaPublicKey) string {
+	return spk.String()
+}
+
+// renterKey returns the public key of the renter of the storage obligation.
+func (so storageObligation) renterKey() types.SiaPublicKey {
+	if len(so.RevisionTransactionSet) == 0 {
+		return types.SiaPublicKey{}
+	}
+	uc := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0].UnlockConditions
+	if len(uc.PublicKeys) == 0 {
+		return types.SiaPublicKey{}
+	}
+	return uc.PublicKeys[0]
+}
+
+// storedSize returns the amount of data that the host stores for the storage
+// obligation.
+func (so storageObligation) storedSize() uint64 {
+	return uint64(len(so.SectorRoots)) * modules.SectorSize
+}
+
+// addRenterUsage counts an unresolved storage obligation towards the usage of
+// its renter. h.mu should be held.
+func (h *Host) addRenterUsage(so storageObligation) {
+	if so.ObligationStatus != obligationUnresolved {
+		return
+	}
+	key := renterKeyString(so.renterKey())
+	usage := h.renterUsages[key]
+	usage.contracts++
+	usage.storage += so.storedSize()
+	h.renterUsages[key] = usage
+}
+
+// subtractRenterUsage removes an unresolved storage obligation from the usage
+// of its renter. The obligation should have been counted by addRenterUsage.
+// h.mu should be held.
+func (h *Host) subtractRenterUsage(so storageObligation) {
+	if so.ObligationStatus != obligationUnresolved {
+		return
+	}
+	key := renterKeyString(so.renterKey())
+	usage, exists := h.renterUsages[key]
+	if !exists {
+		h.log.Critical("storage obligation was not counted in the usage of its renter, id", so.id())
+		return
+	}
+	usage.contracts--
+	if usage.storage < so.storedSize() {
+		h.log.Critical("storage obligation holds more data than its renter, id", so.id())
+		usage.storage = 0
+	} else {
+		usage.storage -= so.storedSize()
+	}
+	if usage.contracts == 0 {
+		delete(h.renterUsages, key)
+		return
+	}
+	h.renterUsages[key] = usage
+}
+
+// addRenterFailure counts a missed storage proof towards the failures of the
+// renter. Obligations that failed without a proof being required, such as
+// expired contracts that hold no data, are not counted. h.mu should be held.
+func (h *Host) addRenterFailure(so storageObligation) {
+	if so.ObligationStatus != obligationFailed || so.ProofStatus != proofFailed {
+		return
+	}
+	h.renterFailures[renterKeyString(so.renterKey())]++
+}
+
+// loadRenterUsages counts the storage obligations in the database towards the
+// usage and the failures of their renters.
+func (h *Host) loadRenterUsages() error {
+	h.renterUsages = make(map[string]renterUsage)
+	h.renterFailures = make(map[string]uint64)
+	return h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			if err := json.Unmarshal(soBytes, &so); err != nil {
+				return err
+			}
+			h.addRenterUsage(so)
+			h.addRenterFailure(so)
+			return nil
+		})
+	})
+}
+
+// managedCheckRenterContract checks that the policy of the renter allows it
+// to form a contract. When a contract is renewed, 'renewed' is the storage
+// obligation being renewed, which is replaced by the new contract holding the
+// same data; it is nil when a new contract is formed.
+func (h *Host) managedCheckRenterContract(spk types.SiaPublicKey, renewed *storageObligation) error {
+	key := renterKeyString(spk)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	policy, exists := h.renterPolicies[key]
+	if !exists {
+		return nil
+	}
+	if policy.Blocked {
+		return errRenterBlocked
+	}
+	usage := h.renterUsages[key]
+	var size uint64
+	if renewed != nil {
+		size = renewed.storedSize()
+		if renewed.ObligationStatus == obligationUnresolved && renterKeyString(renewed.renterKey()) == key && usage.contracts > 0 {
+			usage.contracts--
+			if usage.storage >= size {
+				usage.storage -= size
+			}
+		}
+	}
+	if policy.MaxContracts != 0 && usage.contracts >= policy.MaxContracts {
+		return errRenterContractLimit
+	}
+	if policy.MaxStorage != 0 && usage.storage+size > policy.MaxStorage {
+		return errRenterStorageLimit
+	}
+	return nil
+}
+
+// managedCheckRenterRevision checks that the policy of the renter allows it to
+// revise the storage obligation, counting the revision towards the renter's
+// revision rate.
+func (h *Host) managedCheckRenterRevision(so storageObligation) error {
+	key := renterKeyString(so.renterKey())
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	policy, exists := h.renterPolicies[key]
+	if !exists {
+		return nil
+	}
+	if policy.Blocked {
+		return errRenterBlocked
+	}
+	if policy.MaxRevisionsPerMinute == 0 {
+		return nil
+	}
+	window, exists := h.renterRevisions[key]
+	if !exists || time.Since(window.start) >= time.Minute {
+		window = &revisionWindow{start: time.Now()}
+		h.renterRevisions[key] = window
+	}
+	if window.count >= policy.MaxRevisionsPerMinute {
+		return errRenterRevisionLimit
+	}
+	window.count++
+	return nil
+}
+
+// managedCheckRenterStorage checks that the policy of the renter allows the
+// storage obligation to grow by 'added' bytes.
+func (h *Host) managedCheckRenterStorage(so storageObligation, added uint64) error {
+	key := renterKeyString(so.renterKey())
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	policy, exists := h.renterPolicies[key]
+	if !exists || policy.MaxStorage == 0 {
+		return nil
+	}
+	if h.renterUsages[key].storage+added > policy.MaxStorage {
+		return errRenterStorageLimit
+	}
+	return nil
+}
+
+// renterPolicyList returns the renter policies of the host, ordered by renter
+// key.
+func (h *Host) renterPolicyList() []modules.HostRenterPolicy {
+	policies := make([]modules.HostRenterPolicy, 0, len(h.renterPolicies))
+	for _, policy := range h.renterPolicies {
+		policies = append(policies, policy)
+	}
+	sort.Sort(byRenterKey(policies))
+	return policies
+}
+
+// RemoveRenterPolicy removes the policy of the renter with the given public
+// key, so that the renter is no longer limited.
+func (h *Host) RemoveRenterPolicy(spk types.SiaPublicKey) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := renterKeyString(spk)
+	if _, exists := h.renterPolicies[key]; !exists {
+		return errUnknownRenterPolicy
+	}
+	delete(h.renterPolicies, key)
+	delete(h.renterRevisions, key)
+	return h.saveSync()
+}
+
+// RenterPolicies returns the policies that limit individual renters, ordered
+// by renter key.
+func (h *Host) RenterPolicies() []modules.HostRenterPolicy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to RenterPolicies after close")
+	}
+	defer h.tg.Done()
+
+	return h.renterPolicyList()
+}
+
+// SetRenterPolicy sets the policy of a renter, replacing any earlier policy of
+// the same renter.
+func (h *Host) SetRenterPolicy(policy modules.HostRenterPolicy) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	if policy.RenterKey.Algorithm != types.SignatureEd25519 || len(policy.RenterKey.Key) != crypto.PublicKeySize {
+		return errBadRenterKey
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.renterPolicies[renterKeyString(policy.RenterKey)] = policy
+	return h.saveSync()
+}
@@ -0,0 +1,258 @@
+package host
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestRenterPolicies checks that the host enforces the policies of individual
+// renters, and that the policies are persisted.
+func TestRenterPolicies(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestRenterPolicies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	_, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := renterSiaKey(pk)
+	err = ht.host.SetRenterPolicy(modules.HostRenterPolicy{RenterKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519}})
+	if err != errBadRenterKey {
+		t.Fatal("expected errBadRenterKey, got", err)
+	}
+
+	// Give the renter a storage obligation holding two sectors.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so.SectorRoots = []crypto.Hash{{1}, {2}}
+	so.RevisionTransactionSet = []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID: so.id(),
+			UnlockConditions: types.UnlockConditions{
+				PublicKeys: []types.SiaPublicKey{key, ht.host.publicKey},
+			},
+		}},
+	}}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.mu.Lock()
+	ht.host.addRenterUsage(so)
+	ht.host.mu.Unlock()
+
+	// Renters without a policy are not limited.
+	if err := ht.host.managedCheckRenterContract(key, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedCheckRenterRevision(so); err != nil {
+		t.Fatal(err)
+	}
+
+	// Contract limit. A renewal replaces the contract being renewed.
+	err = ht.host.SetRenterPolicy(modules.HostRenterPolicy{RenterKey: key, MaxContracts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedCheckRenterContract(key, nil); err != errRenterContractLimit {
+		t.Fatal("expected errRenterContractLimit, got", err)
+	}
+	if err := ht.host.managedCheckRenterContract(key, &so); err != nil {
+		t.Fatal("renewal was rejected:", err)
+	}
+
+	// Storage limit.
+	err = ht.host.SetRenterPolicy(modules.HostRenterPolicy{RenterKey: key, MaxStorage: 3 * modules.SectorSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedCheckRenterStorage(so, modules.SectorSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedCheckRenterStorage(so, 2*modules.SectorSize); err != errRenterStorageLimit {
+		t.Fatal("expected errRenterStorageLimit, got", err)
+	}
+	if err := ht.host.managedCheckRenterContract(key, &so); err != nil {
+		t.Fatal("renewal was rejected:", err)
+	}
+	err = ht.host.SetRenterPolicy(modules.HostRenterPolicy{RenterKey: key, MaxStorage: modules.SectorSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedCheckRenterContract(key, &so); err != errRenterStorageLimit {
+		t.Fatal("expected errRenterStorageLimit, got", err)
+	}
+
+	// Revision limit.
+	err = ht.host.SetRenterPolicy(modules.HostRenterPolicy{RenterKey: key, MaxRevisionsPerMinute: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := ht.host.managedCheckRenterRevision(so); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ht.host.managedCheckRenterRevision(so); err != errRenterRevisionLimit {
+		t.Fatal("expected errRenterRevisionLimit, got", err)
+	}
+
+	// Blocked renters are refused everything.
+	err = ht.host.SetRenterPolicy(modules.HostRenterPolicy{RenterKey: key, Blocked: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedCheckRenterContract(key, &so); err != errRenterBlocked {
+		t.Fatal("expected errRenterBlocked, got", err)
+	}
+	if err := ht.host.managedCheckRenterRevision(so); err != errRenterBlocked {
+		t.Fatal("expected errRenterBlocked, got", err)
+	}
+
+	// The policies survive a restart.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage := ht.host.renterUsages[renterKeyString(key)]; usage.contracts != 1 || usage.storage != so.storedSize() {
+		t.Fatal("usage of the renter was not recounted on startup:", usage)
+	}
+	policies := ht.host.RenterPolicies()
+	if len(policies) != 1 || policies[0].RenterKey.String() != key.String() || !policies[0].Blocked {
+		t.Fatal("renter policies were not persisted:", policies)
+	}
+	if err := ht.host.RemoveRenterPolicy(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.RemoveRenterPolicy(key); err != errUnknownRenterPolicy {
+		t.Fatal("expected errUnknownRenterPolicy, got", err)
+	}
+	if err := ht.host.managedCheckRenterRevision(so); err != nil {
+		t.Fatal("renter is still limited after its policy was removed:", err)
+	}
+}
+
+// TestRenterFailures checks that the host counts the missed storage proofs of
+// each renter, that expired contracts without data are not counted, and that
+// the failures are recounted on startup.
+func TestRenterFailures(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestRenterFailures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	_, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := renterKeyString(renterSiaKey(pk))
+	addObligation := func() storageObligation {
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		so.RevisionTransactionSet = []types.Transaction{{
+			FileContractRevisions: []types.FileContractRevision{{
+				ParentID:       so.id(),
+				NewWindowStart: so.expiration(),
+				NewWindowEnd:   so.proofDeadline(),
+				UnlockConditions: types.UnlockConditions{
+					PublicKeys: []types.SiaPublicKey{renterSiaKey(pk), ht.host.publicKey},
+				},
+			}},
+		}}
+		ht.host.managedLockStorageObligation(so.id())
+		err = ht.host.addStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ht.host.managedUnlockStorageObligation(so.id())
+		return so
+	}
+	policy := modules.HostContractPolicy{RefuseFailedRenewals: true}
+	fc := types.FileContract{
+		WindowStart: 150,
+		Payout:      types.NewCurrency64(1000),
+	}
+
+	// Unresolved obligations are not failures.
+	empty := addObligation()
+	if ht.host.renterFailures[key] != 0 {
+		t.Fatal("unresolved obligation was counted as a failure")
+	}
+
+	// An expired contract without data fails without a storage proof, which
+	// does not stop the renter from renewing.
+	ht.host.mu.Lock()
+	err = ht.host.removeStorageObligation(empty, obligationFailed)
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.host.renterFailures[key] != 0 {
+		t.Fatal("expired empty obligation was counted as a failure:", ht.host.renterFailures[key])
+	}
+	err = checkContractPolicy(policy, fc, pk, 100, types.ZeroCurrency, types.ZeroCurrency, ht.host.renterFailures[key])
+	if err != nil {
+		t.Fatal("renewal was refused after an empty contract expired:", err)
+	}
+
+	// A missed storage proof is counted.
+	missed := addObligation()
+	missed.ProofStatus = proofFailed
+	ht.host.mu.Lock()
+	err = ht.host.removeStorageObligation(missed, obligationFailed)
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.host.renterFailures[key] != 1 {
+		t.Fatal("missed storage proof was not counted:", ht.host.renterFailures[key])
+	}
+	if _, exists := ht.host.renterUsages[key]; exists {
+		t.Fatal("failed obligation is still counted in the usage of the renter")
+	}
+	err = checkContractPolicy(policy, fc, pk, 100, types.ZeroCurrency, types.ZeroCurrency, ht.host.renterFailures[key])
+	if err != errPolicyFailedProof {
+		t.Fatal("expected renewal to be refused after a missed proof, got", err)
+	}
+
+	// The failures are recounted on startup.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.host.renterFailures[key] != 1 {
+		t.Fatal("failures of the renter were not recounted on startup:", ht.host.renterFailures[key])
+	}
+}
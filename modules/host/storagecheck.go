@@ -0,0 +1,52 @@
+package host
+
+import (
+	"encoding/json"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// CheckStorage runs a sector consistency check on the storage manager,
+// comparing its sectors against the sector roots of the host's storage
+// obligations. If purge is set, orphaned sector files are deleted and the
+// remaining capacity of the storage folders is corrected.
+func (h *Host) CheckStorage(purge bool) (modules.StorageCheckReport, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.StorageCheckReport{}, err
+	}
+	defer h.tg.Done()
+
+	// Collect the sector roots of every storage obligation. Obligations that
+	// have been resolved no longer have any sector roots. The obligations are
+	// not locked, so sectors that are added or removed while the check runs
+	// may be reported as unreferenced or missing.
+	var roots []crypto.Hash
+	h.mu.RLock()
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			if err := json.Unmarshal(soBytes, &so); err != nil {
+				return err
+			}
+			roots = append(roots, so.SectorRoots...)
+			return nil
+		})
+	})
+	h.mu.RUnlock()
+	if err != nil {
+		return modules.StorageCheckReport{}, err
+	}
+
+	report, err := h.CheckSectors(roots, purge)
+	if err != nil {
+		return report, err
+	}
+	if len(report.MissingSectors) > 0 || len(report.CorruptedSectors) > 0 {
+		h.log.Printf("WARN: storage check found %v missing and %v corrupted sectors", len(report.MissingSectors), len(report.CorruptedSectors))
+	}
+	return report, nil
+}
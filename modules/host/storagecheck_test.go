@@ -0,0 +1,69 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestCheckStorage checks that sectors referenced by the storage obligations
+// but missing from the storage manager are reported.
+func TestCheckStorage(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestCheckStorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.addStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+
+	// Store one of the two sectors of the obligation.
+	storedRoot, sectorData, err := randSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.AddSector(storedRoot, so.expiration(), sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lostRoot, _, err := randSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so.SectorRoots = []crypto.Hash{storedRoot, lostRoot}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ht.host.CheckStorage(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SectorsChecked != 1 || report.UnreferencedSectors != 0 || report.MissingFiles != 0 {
+		t.Fatal("wrong sector counts:", report)
+	}
+	if len(report.MissingSectors) != 1 || report.MissingSectors[0] != lostRoot {
+		t.Fatal("lost sector was not reported:", report.MissingSectors)
+	}
+	if len(report.CorruptedSectors) != 0 || len(report.OrphanedFiles) != 0 || len(report.MiscountedFolders) != 0 {
+		t.Fatal("unexpected problems were reported:", report)
+	}
+}
@@ -0,0 +1,14 @@
+// +build !linux
+
+package storagemanager
+
+import (
+	"os"
+)
+
+// allocateFile reserves 'length' bytes of disk space for the file starting at
+// 'offset'. It is not supported on this system, so the file is left sparse
+// instead.
+func allocateFile(*os.File, int64, int64) error {
+	return errAllocateUnsupported
+}
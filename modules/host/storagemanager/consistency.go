@@ -1,7 +1,17 @@
 package storagemanager
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
 )
 
 // consistency.go contains a bunch of consistency checks for the host. Because
@@ -86,3 +96,219 @@ func (sm *StorageManager) storageFolderSizeConsistency() error {
 	}
 	return nil
 }
+
+// isSectorFile returns whether a file in a storage folder is named like a
+// sector. Other files are never reported or purged, in case the storage folder
+// is shared with files that do not belong to the host.
+func isSectorFile(name string) bool {
+	if len(name) != base64.RawURLEncoding.EncodedLen(12) {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(name)
+	return err == nil
+}
+
+// CheckSectors compares the sector database with the slabs and sector files in
+// the storage folders and with the provided sector roots, which should be
+// every sector root referenced by a storage obligation of the host. A sector
+// in a slab is missing if the slab does not reach the slot of the sector.
+// Sector files are orphaned if the database does not place the sector in that
+// file, which includes the files of sectors that have been moved into a slab.
+//
+// If purge is set, orphaned files are deleted and the remaining capacity of
+// each storage folder is recalculated from the sectors that are left on disk.
+// Files that cannot be deleted still count against the capacity. Sectors that
+// are in the database but are not referenced are only reported, because a
+// sector is added to the database before the storage obligation that uses it
+// is updated.
+func (sm *StorageManager) CheckSectors(roots []crypto.Hash, purge bool) (report modules.StorageCheckReport, err error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return report, errStorageManagerClosed
+	}
+
+	// List the sector files and the size of the slab in each storage folder.
+	files := make(map[string]map[string]int64)
+	slabSizes := make(map[string]int64)
+	for _, sf := range sm.storageFolders {
+		infos, err := sm.dependencies.readDir(filepath.Join(sm.persistDir, sf.uidString()))
+		if err != nil {
+			sf.FailedReads++
+			return report, err
+		}
+		sf.SuccessfulReads++
+		folderFiles := make(map[string]int64)
+		for _, info := range infos {
+			if !info.IsDir() && isSectorFile(info.Name()) {
+				folderFiles[info.Name()] = info.Size()
+			}
+			if !info.IsDir() && info.Name() == slabFilename {
+				slabSizes[sf.uidString()] = info.Size()
+			}
+		}
+		files[sf.uidString()] = folderFiles
+	}
+
+	// Compare the sector database with the files on disk and with the
+	// referenced sectors.
+	referenced := make(map[string]struct{})
+	for _, root := range roots {
+		referenced[string(sm.sectorID(root[:]))] = struct{}{}
+	}
+	usages := make(map[string]sectorUsage)
+	onDisk := func(sectorKey string, usage sectorUsage) (size int64, exists bool) {
+		folder := hex.EncodeToString(usage.StorageFolder)
+		if usage.HasSlot {
+			return int64(modules.SectorSize), uint64(slabSizes[folder]) >= (usage.Slot+1)*modules.SectorSize
+		}
+		size, exists = files[folder][sectorKey]
+		return size, exists
+	}
+	err = sm.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSectorUsage).ForEach(func(sectorKey, usageBytes []byte) error {
+			var usage sectorUsage
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+			usages[string(sectorKey)] = usage
+			report.SectorsChecked++
+			if _, exists := onDisk(string(sectorKey), usage); !exists {
+				report.MissingFiles++
+			}
+			if _, exists := referenced[string(sectorKey)]; !exists {
+				report.UnreferencedSectors++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return report, err
+	}
+	checked := make(map[crypto.Hash]struct{})
+	for _, root := range roots {
+		if _, exists := checked[root]; exists {
+			continue
+		}
+		checked[root] = struct{}{}
+		sectorKey := string(sm.sectorID(root[:]))
+		usage, exists := usages[sectorKey]
+		if !exists {
+			report.MissingSectors = append(report.MissingSectors, root)
+			continue
+		}
+		size, exists := onDisk(sectorKey, usage)
+		if !exists {
+			report.MissingSectors = append(report.MissingSectors, root)
+		} else if usage.Corrupted || uint64(size) != modules.SectorSize {
+			report.CorruptedSectors = append(report.CorruptedSectors, root)
+		}
+	}
+
+	// Find the orphaned files in each storage folder, and check the remaining
+	// capacity against the sectors that are actually stored. The remaining
+	// capacity is calculated rather than adjusted, so that it is correct even
+	// if some of the files could not be removed.
+	for _, sf := range sm.storageFolders {
+		var stored uint64
+		for _, usage := range usages {
+			if usage.HasSlot && bytes.Equal(usage.StorageFolder, sf.UID) {
+				stored++
+			}
+		}
+		for name := range files[sf.uidString()] {
+			stored++
+			if usage, exists := usages[name]; exists && !usage.HasSlot && bytes.Equal(usage.StorageFolder, sf.UID) {
+				continue
+			}
+			report.OrphanedFiles = append(report.OrphanedFiles, filepath.Join(sf.Path, name))
+			if !purge {
+				continue
+			}
+			err := sm.dependencies.removeFile(filepath.Join(sm.persistDir, sf.uidString(), name))
+			if err != nil {
+				sm.log.Printf("WARN: could not purge orphaned file %v: %v", name, err)
+				sf.FailedWrites++
+				continue
+			}
+			sf.SuccessfulWrites++
+			report.PurgedFiles++
+			stored--
+		}
+
+		var sizeRemaining uint64
+		if used := stored * modules.SectorSize; used < sf.Size {
+			sizeRemaining = sf.Size - used
+		}
+		if sf.SizeRemaining != sizeRemaining {
+			report.MiscountedFolders = append(report.MiscountedFolders, sf.Path)
+			if purge {
+				sm.log.Printf("INFO: correcting the remaining capacity of storage folder %v from %v to %v", sf.Path, sf.SizeRemaining, sizeRemaining)
+				sf.SizeRemaining = sizeRemaining
+			}
+		}
+	}
+	if purge {
+		err = sm.save()
+	}
+	return report, err
+}
+
+// LostSectors returns the roots that the storage manager can no longer serve:
+// sectors that are not tracked, whose slot is beyond the end of the slab,
+// whose file is missing or has the wrong size, or that have been marked as
+// corrupted. Unlike CheckSectors, only the given
+// roots are inspected, so the check is cheap enough to run for a single
+// contract. Each lost root is returned once.
+func (sm *StorageManager) LostSectors(roots []crypto.Hash) (lost []crypto.Hash, err error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return nil, errStorageManagerClosed
+	}
+
+	checked := make(map[crypto.Hash]struct{})
+	err = sm.db.View(func(tx *bolt.Tx) error {
+		bsu := tx.Bucket(bucketSectorUsage)
+		for _, root := range roots {
+			if _, exists := checked[root]; exists {
+				continue
+			}
+			checked[root] = struct{}{}
+
+			sectorKey := sm.sectorID(root[:])
+			usageBytes := bsu.Get(sectorKey)
+			if usageBytes == nil {
+				lost = append(lost, root)
+				continue
+			}
+			var usage sectorUsage
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+			if usage.Corrupted {
+				lost = append(lost, root)
+				continue
+			}
+			if usage.HasSlot {
+				info, err := sm.dependencies.stat(sm.slabPath(usage.StorageFolder))
+				if err != nil || uint64(info.Size()) < (usage.Slot+1)*modules.SectorSize {
+					lost = append(lost, root)
+				}
+				continue
+			}
+			info, err := sm.dependencies.stat(sm.legacySectorPath(usage.StorageFolder, sectorKey))
+			if err != nil || uint64(info.Size()) != modules.SectorSize {
+				lost = append(lost, root)
+			}
+		}
+		return nil
+	})
+	return lost, err
+}
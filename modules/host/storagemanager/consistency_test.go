@@ -0,0 +1,218 @@
+package storagemanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestCheckSectors checks that the sector consistency check reports missing,
+// corrupted, unreferenced, and orphaned sectors, and that purging removes the
+// orphaned files and corrects the remaining capacity.
+func TestCheckSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestCheckSectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := smt.sm.storageFolders[0]
+	folderDir := filepath.Join(smt.sm.persistDir, sf.uidString())
+
+	// Add four sectors. The first is left alone, the second is deleted from
+	// disk, the third is truncated, and the fourth is not referenced. The
+	// second and third are moved into files of their own, as older versions
+	// of the storage manager stored them.
+	var roots []crypto.Hash
+	for i := 0; i < 4; i++ {
+		root, data, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(root, 10, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	for _, root := range roots[1:3] {
+		err = smt.makeLegacy(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.Remove(filepath.Join(folderDir, string(smt.sm.sectorID(roots[1][:]))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(folderDir, string(smt.sm.sectorID(roots[2][:]))), []byte("short"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A sector that was never stored, an orphaned sector file, and a file
+	// that does not belong to the host.
+	lostRoot, _, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphanRoot, orphanData, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphanPath := filepath.Join(folderDir, string(smt.sm.sectorID(orphanRoot[:])))
+	err = ioutil.WriteFile(orphanPath, orphanData, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPath := filepath.Join(folderDir, "notes.txt")
+	err = ioutil.WriteFile(otherPath, []byte("not a sector"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	referenced := []crypto.Hash{roots[0], roots[1], roots[2], lostRoot, roots[0]}
+	report, err := smt.sm.CheckSectors(referenced, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SectorsChecked != 4 || report.MissingFiles != 1 || report.UnreferencedSectors != 1 {
+		t.Fatal("wrong sector counts:", report)
+	}
+	if len(report.MissingSectors) != 2 || report.MissingSectors[0] != roots[1] || report.MissingSectors[1] != lostRoot {
+		t.Fatal("wrong missing sectors:", report.MissingSectors)
+	}
+	if len(report.CorruptedSectors) != 1 || report.CorruptedSectors[0] != roots[2] {
+		t.Fatal("wrong corrupted sectors:", report.CorruptedSectors)
+	}
+	if len(report.OrphanedFiles) != 1 || report.OrphanedFiles[0] != filepath.Join(sf.Path, string(smt.sm.sectorID(orphanRoot[:]))) {
+		t.Fatal("wrong orphaned files:", report.OrphanedFiles)
+	}
+	// Three sector files and the orphan are on disk, which matches the four
+	// sectors that the folder counts.
+	if len(report.MiscountedFolders) != 0 || report.PurgedFiles != 0 {
+		t.Fatal("folder should not be miscounted before purging:", report)
+	}
+	if _, err := os.Stat(orphanPath); err != nil {
+		t.Fatal("orphaned file was removed without purging")
+	}
+
+	// Purge the orphaned file. Only the three remaining sector files should
+	// count against the capacity of the folder, which needs correcting.
+	report, err = smt.sm.CheckSectors(referenced, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.PurgedFiles != 1 || len(report.MiscountedFolders) != 1 {
+		t.Fatal("wrong purge results:", report)
+	}
+	if _, err := os.Stat(orphanPath); !os.IsNotExist(err) {
+		t.Fatal("orphaned file was not purged")
+	}
+	if _, err := os.Stat(otherPath); err != nil {
+		t.Fatal("a file that is not a sector was purged")
+	}
+	if sf.SizeRemaining != sf.Size-3*modules.SectorSize {
+		t.Fatal("remaining capacity was not corrected:", sf.SizeRemaining)
+	}
+
+	// A second check finds no orphans or miscounted folders.
+	report, err = smt.sm.CheckSectors(referenced, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.OrphanedFiles) != 0 || len(report.MiscountedFolders) != 0 {
+		t.Fatal("purge did not fix the storage folder:", report)
+	}
+}
+
+// TestLostSectors checks that the storage manager reports the sectors that it
+// can no longer serve.
+func TestLostSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestLostSectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folderDir := filepath.Join(smt.sm.persistDir, smt.sm.storageFolders[0].uidString())
+
+	// Add three sectors. The first is left alone, the second is deleted from
+	// disk, and the third is truncated. The second and third are moved into
+	// files of their own, as older versions of the storage manager stored
+	// them.
+	var roots []crypto.Hash
+	for i := 0; i < 3; i++ {
+		root, data, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(root, 10, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	for _, root := range roots[1:3] {
+		err = smt.makeLegacy(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.Remove(filepath.Join(folderDir, string(smt.sm.sectorID(roots[1][:]))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(folderDir, string(smt.sm.sectorID(roots[2][:]))), []byte("short"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownRoot, _, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lost, err := smt.sm.LostSectors([]crypto.Hash{roots[0], roots[1], roots[2], unknownRoot, roots[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []crypto.Hash{roots[1], roots[2], unknownRoot}
+	if len(lost) != len(expected) {
+		t.Fatal("wrong lost sectors:", lost)
+	}
+	for i := range expected {
+		if lost[i] != expected[i] {
+			t.Error("wrong lost sector at", i)
+		}
+	}
+
+	// Cut the slab short, so that it no longer reaches the first sector.
+	err = os.Truncate(filepath.Join(folderDir, slabFilename), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost, err = smt.sm.LostSectors(roots[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lost) != 1 || lost[0] != roots[0] {
+		t.Fatal("sector beyond the end of the slab was not reported:", lost)
+	}
+}
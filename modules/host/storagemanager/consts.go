@@ -1,6 +1,8 @@
 package storagemanager
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/build"
 )
 
@@ -88,6 +90,70 @@ var (
 		panic("unrecognized release constant in host - minimum storage folder size")
 	}()
 
+	// defaultScrubPeriod is the default amount of time that the scrubber takes
+	// to read every sector stored by the host. Scrubbing is disabled by
+	// default in testing, so that tests can control when sectors are read.
+	defaultScrubPeriod = func() time.Duration {
+		if build.Release == "dev" {
+			return 24 * time.Hour
+		}
+		if build.Release == "standard" {
+			return 30 * 24 * time.Hour
+		}
+		if build.Release == "testing" {
+			return 0
+		}
+		panic("unrecognized release constant in host - default scrub period")
+	}()
+
+	// defaultScrubRateLimit is the default number of bytes per second that the
+	// scrubber is allowed to read from disk. The scrubber runs alongside the
+	// host serving renters, and should not compete with them for disk
+	// bandwidth.
+	defaultScrubRateLimit = func() uint64 {
+		if build.Release == "dev" {
+			return 1 << 22 // 4 MiB/s
+		}
+		if build.Release == "standard" {
+			return 1 << 23 // 8 MiB/s
+		}
+		if build.Release == "testing" {
+			return 0
+		}
+		panic("unrecognized release constant in host - default scrub rate limit")
+	}()
+
+	// scrubIdleFrequency is how often the scrubber checks for sectors to
+	// scrub when scrubbing is disabled or the host is not storing any sectors.
+	scrubIdleFrequency = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Minute
+		}
+		if build.Release == "standard" {
+			return 10 * time.Minute
+		}
+		if build.Release == "testing" {
+			return 100 * time.Millisecond
+		}
+		panic("unrecognized release constant in host - scrub idle frequency")
+	}()
+
+	// legacyMoveRetryFrequency is how often the thread that moves sectors out
+	// of their own files and into the slabs tries again while the slab of a
+	// storage folder is being allocated.
+	legacyMoveRetryFrequency = func() time.Duration {
+		if build.Release == "dev" {
+			return 10 * time.Second
+		}
+		if build.Release == "standard" {
+			return time.Minute
+		}
+		if build.Release == "testing" {
+			return 100 * time.Millisecond
+		}
+		panic("unrecognized release constant in host - legacy move retry frequency")
+	}()
+
 	// storageFolderUIDSize determines the number of bytes used to determine
 	// the storage folder UID. Production and development environments use 4
 	// bytes to minimize the possibility of accidental collisions, and testing
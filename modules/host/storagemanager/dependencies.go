@@ -24,6 +24,10 @@ var (
 	mockErrWriteFile    = errors.New("simulated WriteFile failure")
 )
 
+// errAllocateUnsupported is returned by allocateFile on systems and
+// filesystems where disk space cannot be reserved without writing to it.
+var errAllocateUnsupported = errors.New("allocating disk space is not supported")
+
 // These interfaces define the StorageManager's dependencies. Mocking
 // implementation complexity can be reduced by defining each dependency as the
 // minimum possible subset of the real dependency.
@@ -48,17 +52,35 @@ type (
 		// randRead fills the input bytes with random data.
 		randRead([]byte) (int, error)
 
+		// readDir lists the contents of a directory.
+		readDir(string) ([]os.FileInfo, error)
+
 		// readFile reads a file in full from the filesystem.
 		readFile(string) ([]byte, error)
 
+		// readFileAt fills the input bytes with data read from a file,
+		// starting at the provided offset.
+		readFileAt(string, []byte, int64) error
+
 		// removeFile removes a file from file filesystem.
 		removeFile(string) error
 
+		// stat returns information about a file.
+		stat(string) (os.FileInfo, error)
+
 		// symlink creates a sym link between a source and a destination.
 		symlink(s1, s2 string) error
 
+		// resizeFile sets the size of a file, creating the file if it does
+		// not exist. Space added to the file is allocated on disk.
+		resizeFile(string, int64) error
+
 		// writeFile writes data to the filesystem using the provided filename.
 		writeFile(string, []byte, os.FileMode) error
+
+		// writeFileAt writes data into an existing file, starting at the
+		// provided offset.
+		writeFileAt(string, []byte, int64) error
 	}
 )
 
@@ -117,22 +139,102 @@ func (productionDependencies) randRead(b []byte) (int, error) {
 	return rand.Read(b)
 }
 
+// readDir lists the contents of a directory.
+func (productionDependencies) readDir(s string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(s)
+}
+
 // readFile reads a file from the filesystem.
 func (productionDependencies) readFile(s string) ([]byte, error) {
 	return ioutil.ReadFile(s)
 }
 
+// readFileAt fills the input bytes with data read from a file, starting at
+// the provided offset.
+func (productionDependencies) readFileAt(s string, b []byte, off int64) error {
+	f, err := os.Open(s)
+	if err != nil {
+		return err
+	}
+	_, err = f.ReadAt(b, off)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // removeFile removes a file from the filesystem.
 func (productionDependencies) removeFile(s string) error {
 	return os.Remove(s)
 }
 
+// stat returns information about a file.
+func (productionDependencies) stat(s string) (os.FileInfo, error) {
+	return os.Stat(s)
+}
+
 // symlink creates a symlink between a source and a destination file.
 func (productionDependencies) symlink(s1, s2 string) error {
 	return os.Symlink(s1, s2)
 }
 
+// resizeFile sets the size of a file, creating the file if it does not exist.
+// Space added to the file is reserved with allocateFile where the filesystem
+// supports it, so that later writes into the file cannot fail because the
+// disk has filled up. Otherwise the file is extended as a sparse file, as
+// writing out the space would block for as long as it takes to fill the
+// storage folder. If the space cannot be allocated, the file is returned to
+// its original size.
+func (productionDependencies) resizeFile(s string, size int64) error {
+	f, err := os.OpenFile(s, os.O_RDWR|os.O_CREATE, 0700)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if size <= info.Size() {
+		err = f.Truncate(size)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	err = allocateFile(f, info.Size(), size-info.Size())
+	if err == errAllocateUnsupported {
+		err = f.Truncate(size)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		_ = f.Truncate(info.Size())
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // writeFile writes a file to the filesystem.
 func (productionDependencies) writeFile(s string, b []byte, fm os.FileMode) error {
 	return ioutil.WriteFile(s, b, fm)
 }
+
+// writeFileAt writes data into an existing file, starting at the provided
+// offset.
+func (productionDependencies) writeFileAt(s string, b []byte, off int64) error {
+	f, err := os.OpenFile(s, os.O_WRONLY, 0700)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(b, off)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,442 @@
+package storagemanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// migrate.go moves all of the sectors of a storage folder to a new path, so
+// that a disk can be replaced without the free space of the other storage
+// folders that removing the storage folder would need. A new storage folder is
+// created at the destination path with the same size as the source folder,
+// and a background thread moves the sectors over one at a time. Once the
+// source folder is empty, it is removed and the destination folder takes its
+// place in the list of storage folders.
+//
+// While the migration is in progress, no new sectors are placed in the source
+// folder, and the destination folder only accepts new sectors while it keeps
+// enough room for the sectors that are still waiting in the source folder.
+//
+// The sector usage database is updated as each sector is moved, so the
+// migration does not need to track which sectors have been moved. After a
+// restart, the migration scans the database from the beginning and picks up
+// the sectors that are still in the source folder.
+
+var (
+	// errMigrationInProgress is returned when starting a migration, or when
+	// removing or resizing one of the storage folders of a migration, while a
+	// migration is in progress.
+	errMigrationInProgress = errors.New("a storage folder migration is already in progress")
+)
+
+// storageFolderMigration identifies the storage folders of a migration.
+type storageFolderMigration struct {
+	Source      []byte
+	Destination []byte
+}
+
+// migratingFolder returns whether the storage folder is the source or the
+// destination of the migration in progress.
+func (sm *StorageManager) migratingFolder(sf *storageFolder) bool {
+	if sm.migration == nil {
+		return false
+	}
+	return bytes.Equal(sf.UID, sm.migration.Source) || bytes.Equal(sf.UID, sm.migration.Destination)
+}
+
+// placementFolders returns the storage folders that may receive new sectors.
+// The source folder of a migration is excluded, and the destination folder is
+// only included if it has room for a new sector on top of the sectors that
+// are still waiting to be migrated.
+func (sm *StorageManager) placementFolders() []*storageFolder {
+	if sm.migration == nil {
+		return append([]*storageFolder(nil), sm.storageFolders...)
+	}
+	source := sm.storageFolder(sm.migration.Source)
+	var sfs []*storageFolder
+	for _, sf := range sm.storageFolders {
+		if sf == source {
+			continue
+		}
+		if source != nil && bytes.Equal(sf.UID, sm.migration.Destination) {
+			waiting := source.Size - source.SizeRemaining
+			if sf.SizeRemaining < waiting+modules.SectorSize {
+				continue
+			}
+		}
+		sfs = append(sfs, sf)
+	}
+	return sfs
+}
+
+// migrateDelay returns how long the migration should wait after moving a
+// sector, so that no more than 'rateLimit' bytes are moved per second.
+func migrateDelay(rateLimit uint64) time.Duration {
+	if rateLimit == 0 {
+		return 0
+	}
+	return time.Duration(modules.SectorSize * uint64(time.Second) / rateLimit)
+}
+
+// finishMigration ends the migration in progress. If the source folder is
+// empty, it is removed and the destination folder takes its place in the list
+// of storage folders.
+func (sm *StorageManager) finishMigration() error {
+	source := sm.storageFolder(sm.migration.Source)
+	destination := sm.storageFolder(sm.migration.Destination)
+	sm.migration = nil
+	sm.migrationCursor = nil
+	sm.migrationStatus.Active = false
+	sm.migrationStatus.Completed = time.Now()
+
+	if source == nil || destination == nil || source.SizeRemaining != source.Size {
+		sm.log.Printf("WARN: migration of storage folder %v to %v finished, %v sectors could not be moved", sm.migrationStatus.SourcePath, sm.migrationStatus.DestinationPath, sm.migrationStatus.SectorsFailed)
+		return sm.saveSync()
+	}
+	var sfs []*storageFolder
+	for _, sf := range sm.storageFolders {
+		if sf == source {
+			sfs = append(sfs, destination)
+		} else if sf != destination {
+			sfs = append(sfs, sf)
+		}
+	}
+	sm.storageFolders = sfs
+	sm.log.Printf("INFO: migration of storage folder %v to %v finished, %v sectors were moved", sm.migrationStatus.SourcePath, sm.migrationStatus.DestinationPath, sm.migrationStatus.SectorsMoved)
+
+	// The slab is already gone if the disk holding the storage folder has
+	// been removed.
+	slabErr := sm.dependencies.removeFile(sm.slabPath(source.UID))
+	if os.IsNotExist(slabErr) {
+		slabErr = nil
+	}
+	removeErr := sm.dependencies.removeFile(filepath.Join(sm.persistDir, source.uidString()))
+	saveErr := sm.saveSync()
+	return composeErrors(saveErr, slabErr, removeErr)
+}
+
+// managedNextMigrationSector returns the key and usage of the next sector in
+// the source folder of the migration, and allocates a slot for the sector in
+// the destination folder. A nil key is returned if the sector could not be
+// given a slot, or if no migration is in progress. False is returned if the
+// migration is not in progress, or if it has finished.
+func (sm *StorageManager) managedNextMigrationSector() (sectorKey []byte, usage sectorUsage, slot uint64, active bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed || sm.migration == nil {
+		return nil, usage, 0, false
+	}
+	source := sm.storageFolder(sm.migration.Source)
+	destination := sm.storageFolder(sm.migration.Destination)
+	if source == nil || destination == nil {
+		sm.log.Println("WARN: storage folder of the migration is missing, stopping the migration")
+		err := sm.finishMigration()
+		if err != nil {
+			sm.log.Println("WARN: could not finish the migration:", err)
+		}
+		return nil, usage, 0, false
+	}
+
+	// Find the next sector in the source folder.
+	err := sm.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketSectorUsage).Cursor()
+		var k, v []byte
+		if sm.migrationCursor == nil {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(sm.migrationCursor)
+			if k != nil && bytes.Equal(k, sm.migrationCursor) {
+				k, v = c.Next()
+			}
+		}
+		for ; k != nil; k, v = c.Next() {
+			err := json.Unmarshal(v, &usage)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(usage.StorageFolder, source.UID) {
+				sectorKey = append([]byte(nil), k...)
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		sm.log.Println("WARN: could not find the next sector to migrate:", err)
+		return nil, usage, 0, true
+	}
+
+	// The migration is finished once the end of the database is reached.
+	if sectorKey == nil {
+		err = sm.finishMigration()
+		if err != nil {
+			sm.log.Println("WARN: could not finish the migration:", err)
+		}
+		return nil, usage, 0, false
+	}
+
+	// Sectors that cannot be moved stay in the source folder, which is then
+	// kept when the migration finishes.
+	if destination.SizeRemaining >= modules.SectorSize {
+		slot, err = destination.allocateSlot()
+	}
+	if destination.SizeRemaining < modules.SectorSize || err != nil {
+		sm.migrationStatus.SectorsFailed++
+		sm.log.Printf("WARN: could not migrate sector %s: destination storage folder is full", sectorKey)
+		sm.advanceMigration(sectorKey)
+		return nil, usage, 0, true
+	}
+	return sectorKey, usage, slot, true
+}
+
+// managedRecordMigration points the sector at the slot in the destination
+// folder that its data was copied to, and frees the slot in the source folder.
+// The sector may have been removed or moved while it was being copied, in
+// which case the slot in the destination folder is freed instead.
+func (sm *StorageManager) managedRecordMigration(sectorKey []byte, migrated sectorUsage, slot uint64, readErr, writeErr error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed || sm.migration == nil {
+		return
+	}
+	source := sm.storageFolder(sm.migration.Source)
+	destination := sm.storageFolder(sm.migration.Destination)
+	if source == nil || destination == nil {
+		return
+	}
+	defer sm.advanceMigration(sectorKey)
+	if readErr != nil {
+		destination.clearSlot(slot)
+		source.FailedReads++
+		sm.migrationStatus.SectorsFailed++
+		sm.log.Printf("WARN: could not read sector %s for migration: %v", sectorKey, readErr)
+		return
+	}
+	source.SuccessfulReads++
+	if writeErr != nil {
+		destination.clearSlot(slot)
+		destination.FailedWrites++
+		sm.migrationStatus.SectorsFailed++
+		sm.log.Printf("WARN: could not write sector %s for migration: %v", sectorKey, writeErr)
+		return
+	}
+	destination.SuccessfulWrites++
+
+	var legacyPath string
+	var st slotTx
+	st.allocate(destination, slot)
+	err := sm.db.Update(func(tx *bolt.Tx) error {
+		bsu := tx.Bucket(bucketSectorUsage)
+		usageBytes := bsu.Get(sectorKey)
+		var usage sectorUsage
+		if usageBytes != nil {
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+		}
+		moved := !bytes.Equal(usage.StorageFolder, migrated.StorageFolder) || usage.HasSlot != migrated.HasSlot || usage.Slot != migrated.Slot
+		if usageBytes == nil || moved {
+			st.free(destination, slot)
+			return nil
+		}
+
+		// Point the database at the new slot before freeing the old one, so
+		// that the sector is not lost if the host crashes part way through.
+		usage.StorageFolder = destination.UID
+		usage.HasSlot = true
+		usage.Slot = slot
+		usageBytes, err := json.Marshal(usage)
+		if err != nil {
+			return err
+		}
+		err = bsu.Put(sectorKey, usageBytes)
+		if err != nil {
+			return err
+		}
+		if migrated.HasSlot {
+			st.free(source, migrated.Slot)
+		} else {
+			legacyPath = sm.legacySectorPath(source.UID, sectorKey)
+		}
+		source.SizeRemaining += modules.SectorSize
+		destination.SizeRemaining -= modules.SectorSize
+		sm.migrationStatus.SectorsMoved++
+		return nil
+	})
+	st.finish(err)
+	if err != nil {
+		sm.log.Println("WARN: could not migrate sector:", err)
+		return
+	}
+	if legacyPath != "" {
+		// A file that cannot be removed only wastes space, and is reported
+		// as an orphan by the consistency check.
+		_ = sm.dependencies.removeFile(legacyPath)
+	}
+}
+
+// advanceMigration records that the migration has finished with a sector.
+func (sm *StorageManager) advanceMigration(sectorKey []byte) {
+	sm.migrationCursor = sectorKey
+	err := sm.save()
+	if err != nil {
+		sm.log.Println("WARN: could not save the migration progress:", err)
+	}
+}
+
+// managedMigrateSector moves the next sector of the source folder to the
+// destination folder, returning how long the migration should wait before
+// moving the following sector. False is returned if no migration is in
+// progress.
+func (sm *StorageManager) managedMigrateSector() (time.Duration, bool) {
+	sectorKey, usage, slot, active := sm.managedNextMigrationSector()
+	if !active {
+		return 0, false
+	}
+	sm.mu.RLock()
+	delay := migrateDelay(sm.migrationStatus.RateLimit)
+	var slabPath string
+	if sm.migration != nil {
+		slabPath = sm.slabPath(sm.migration.Destination)
+	}
+	sm.mu.RUnlock()
+	if sectorKey == nil || slabPath == "" {
+		return delay, true
+	}
+
+	// Copy the sector without holding the lock, the host should not be
+	// blocked by the migration. The slot in the destination folder has
+	// already been allocated, so no other sector is written to it.
+	data, readErr := sm.readSector(sectorKey, usage)
+	var writeErr error
+	if readErr == nil {
+		writeErr = sm.dependencies.writeFileAt(slabPath, data, int64(slot*modules.SectorSize))
+	}
+	sm.managedRecordMigration(sectorKey, usage, slot, readErr, writeErr)
+	return delay, true
+}
+
+// threadedMigrate moves the sectors of migrating storage folders until the
+// storage manager is closed.
+func (sm *StorageManager) threadedMigrate() {
+	for {
+		delay, active := sm.managedMigrateSector()
+		var wait <-chan time.Time
+		if active {
+			wait = time.After(delay)
+		}
+		select {
+		case <-sm.migrateStop:
+			return
+		case <-sm.migrateWake:
+		case <-wait:
+		}
+	}
+}
+
+// MigrateStorageFolder creates a new storage folder at 'path' and moves all of
+// the sectors of the storage folder at 'index' to it in the background,
+// moving no more than 'rateLimit' bytes per second. A rate limit of 0 is
+// unlimited. Once all of the sectors have been moved, the old storage folder
+// is removed and the new storage folder takes its index. The slab of the new
+// storage folder is allocated without holding the lock, so that the host keeps
+// serving sectors while the disk space is claimed.
+func (sm *StorageManager) MigrateStorageFolder(index int, path string, rateLimit uint64) error {
+	source, destination, err := sm.managedCreateMigrationFolder(index, path)
+	if err != nil {
+		return err
+	}
+	err = sm.allocateSlab(destination, destination.Size/modules.SectorSize)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	sm.allocating = false
+	if err == nil && sm.closed {
+		err = errStorageManagerClosed
+	}
+	if err != nil {
+		sm.removeStorageFolderFiles(destination)
+		return err
+	}
+	destination.resizeSlots(destination.Size / modules.SectorSize)
+	sm.storageFolders = append(sm.storageFolders, destination)
+	sm.migration = &storageFolderMigration{
+		Source:      source.UID,
+		Destination: destination.UID,
+	}
+	sm.migrationCursor = nil
+	sm.migrationStatus = modules.StorageFolderMigrationStatus{
+		Active:          true,
+		SourcePath:      source.Path,
+		DestinationPath: destination.Path,
+		RateLimit:       rateLimit,
+		Started:         time.Now(),
+		SectorsTotal:    (source.Size - source.SizeRemaining) / modules.SectorSize,
+	}
+	err = sm.saveSync()
+	if err != nil {
+		return err
+	}
+	sm.log.Printf("INFO: migrating storage folder %v to %v", source.Path, destination.Path)
+
+	// Wake the migration thread so that the migration starts immediately.
+	select {
+	case sm.migrateWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// managedCreateMigrationFolder creates the storage folder at 'path' that the
+// storage folder at 'index' is migrated to, and marks the storage manager as
+// allocating, so that the slab of the new storage folder can be allocated
+// without holding the lock. The source folder cannot be removed or resized
+// while the storage manager is allocating.
+func (sm *StorageManager) managedCreateMigrationFolder(index int, path string) (source, destination *storageFolder, err error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return nil, nil, errStorageManagerClosed
+	}
+
+	if index >= len(sm.storageFolders) || index < 0 {
+		return nil, nil, errBadStorageFolderIndex
+	}
+	if sm.migration != nil {
+		return nil, nil, errMigrationInProgress
+	}
+	if sm.allocating {
+		return nil, nil, errAllocationInProgress
+	}
+	source = sm.storageFolders[index]
+	destination, err = sm.createStorageFolder(path, source.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+	sm.allocating = true
+	return source, destination, nil
+}
+
+// MigrationStatus returns the progress of the storage folder migration.
+func (sm *StorageManager) MigrationStatus() modules.StorageFolderMigrationStatus {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.migrationStatus
+}
@@ -0,0 +1,190 @@
+package storagemanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+// TestMigrateDelay checks that the migration respects its rate limit.
+func TestMigrateDelay(t *testing.T) {
+	if migrateDelay(0) != 0 {
+		t.Error("unlimited migration should not wait")
+	}
+	if migrateDelay(modules.SectorSize/2) != 2*time.Second {
+		t.Error("rate limit was not respected")
+	}
+}
+
+// TestMigrateStorageFolder moves the sectors of a storage folder to a new
+// path, restarting the storage manager part way through the migration.
+func TestMigrateStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestMigrateStorageFolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceFolder := smt.sm.storageFolders[0]
+	sourcePath := sourceFolder.Path
+
+	// Fill part of the storage folder, with one sector that has not been
+	// moved into the slab.
+	var roots []crypto.Hash
+	for i := 0; i < 5; i++ {
+		root, data, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(root, 10, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	err = smt.makeLegacy(roots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Start a slow migration.
+	destPath := filepath.Join(smt.persistDir, persist.RandomSuffix())
+	err = os.Mkdir(destPath, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.MigrateStorageFolder(1, destPath, 0)
+	if err != errBadStorageFolderIndex {
+		t.Fatal("expected errBadStorageFolderIndex, got", err)
+	}
+	err = smt.sm.MigrateStorageFolder(0, destPath, modules.SectorSize*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := smt.sm.MigrationStatus()
+	if !status.Active || status.SourcePath != sourcePath || status.DestinationPath != destPath || status.SectorsTotal != 5 {
+		t.Fatal("migration status is wrong:", status)
+	}
+	err = smt.sm.MigrateStorageFolder(0, destPath, 0)
+	if err != errMigrationInProgress {
+		t.Fatal("expected errMigrationInProgress, got", err)
+	}
+	err = smt.sm.RemoveStorageFolder(0, true)
+	if err != errMigrationInProgress {
+		t.Fatal("expected errMigrationInProgress, got", err)
+	}
+
+	// New sectors should go to the destination folder.
+	root, data, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.AddSector(root, 10, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = append(roots, root)
+	n, err := smt.folderSectors(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 1 {
+		t.Fatal("new sector was not placed in the destination folder")
+	}
+
+	// Restart the storage manager once the migration has made progress.
+	for i := 0; i < 100 && smt.sm.MigrationStatus().SectorsMoved == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	err = smt.sm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	smt.sm, err = New(filepath.Join(smt.persistDir, modules.StorageManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status = smt.sm.MigrationStatus()
+	if !status.Active || status.SectorsMoved == 0 {
+		t.Fatal("migration did not survive the restart:", status)
+	}
+
+	// Wait for the migration to finish.
+	for i := 0; i < 100 && smt.sm.MigrationStatus().Active; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	status = smt.sm.MigrationStatus()
+	if status.Active || status.Completed.IsZero() {
+		t.Fatal("migration did not finish")
+	}
+	if status.SectorsMoved != 5 || status.SectorsFailed != 0 {
+		t.Fatal("wrong number of sectors moved:", status)
+	}
+	sfs := smt.sm.StorageFolders()
+	if len(sfs) != 1 || sfs[0].Path != destPath {
+		t.Fatal("destination folder did not replace the source folder:", sfs)
+	}
+	if sfs[0].CapacityRemaining != sfs[0].Capacity-6*modules.SectorSize {
+		t.Error("destination folder has the wrong remaining capacity")
+	}
+	n, err = smt.folderSectors(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Fatal("expected 6 sectors in the destination folder, got", n)
+	}
+	for _, root := range roots {
+		data, err := smt.sm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crypto.MerkleRoot(data) != root {
+			t.Fatal("sector was damaged by the migration")
+		}
+	}
+	_, err = os.Stat(filepath.Join(smt.sm.persistDir, sourceFolder.uidString()))
+	if !os.IsNotExist(err) {
+		t.Error("symlink of the source folder was not removed")
+	}
+	_, err = os.Stat(filepath.Join(sourcePath, slabFilename))
+	if !os.IsNotExist(err) {
+		t.Error("slab of the source folder was not removed")
+	}
+}
+
+// TestPlacementFolders checks that the destination of a migration keeps room
+// for the sectors that are waiting in the source folder.
+func TestPlacementFolders(t *testing.T) {
+	source := &storageFolder{UID: []byte{1}, Size: 8 * modules.SectorSize, SizeRemaining: 3 * modules.SectorSize}
+	destination := &storageFolder{UID: []byte{2}, Size: 8 * modules.SectorSize, SizeRemaining: 6 * modules.SectorSize}
+	other := &storageFolder{UID: []byte{3}, Size: 8 * modules.SectorSize, SizeRemaining: 8 * modules.SectorSize}
+	sm := &StorageManager{
+		storageFolders: []*storageFolder{source, destination, other},
+	}
+	if len(sm.placementFolders()) != 3 {
+		t.Fatal("all folders should accept sectors without a migration")
+	}
+	sm.migration = &storageFolderMigration{Source: source.UID, Destination: destination.UID}
+	sfs := sm.placementFolders()
+	if len(sfs) != 2 || sfs[0] != destination || sfs[1] != other {
+		t.Fatal("source folder should not accept sectors during a migration")
+	}
+	destination.SizeRemaining = 5 * modules.SectorSize
+	sfs = sm.placementFolders()
+	if len(sfs) != 1 || sfs[0] != other {
+		t.Fatal("destination folder should keep room for the waiting sectors")
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"crypto/rand"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 
 	"github.com/NebulousLabs/bolt"
@@ -15,6 +17,18 @@ import (
 type persistence struct {
 	SectorSalt     crypto.Hash
 	StorageFolders []*storageFolder
+
+	// Scrubber settings and progress. The cursor is the key of the last
+	// sector that was scrubbed, so that a pass can resume after a restart.
+	ScrubPeriod    time.Duration
+	ScrubRateLimit uint64
+	ScrubCursor    []byte
+	ScrubStatus    modules.StorageScrubStatus
+
+	// The storage folder migration in progress, if any. The migration
+	// resumes after a restart.
+	Migration       *storageFolderMigration
+	MigrationStatus modules.StorageFolderMigrationStatus
 }
 
 // persistData returns the data in the StorageManager that will be saved to
@@ -23,6 +37,14 @@ func (sm *StorageManager) persistData() persistence {
 	return persistence{
 		SectorSalt:     sm.sectorSalt,
 		StorageFolders: sm.storageFolders,
+
+		ScrubPeriod:    sm.scrubPeriod,
+		ScrubRateLimit: sm.scrubRateLimit,
+		ScrubCursor:    sm.scrubCursor,
+		ScrubStatus:    sm.scrubStatus,
+
+		Migration:       sm.migration,
+		MigrationStatus: sm.migrationStatus,
 	}
 }
 
@@ -60,10 +82,17 @@ func (sm *StorageManager) initDB() error {
 // load extracts the saved data from disk and applies it to the storage
 // manager.
 func (sm *StorageManager) load() error {
-	p := new(persistence)
+	// The scrubber settings were added after the persist file, so they are
+	// given defaults before loading.
+	p := &persistence{
+		ScrubPeriod:    defaultScrubPeriod,
+		ScrubRateLimit: defaultScrubRateLimit,
+	}
 	err := sm.dependencies.loadFile(persistMetadata, p, filepath.Join(sm.persistDir, settingsFile))
 	if os.IsNotExist(err) {
 		// There is no host.json file, set up sane defaults.
+		sm.scrubPeriod = p.ScrubPeriod
+		sm.scrubRateLimit = p.ScrubRateLimit
 		return sm.establishDefaults()
 	} else if err != nil {
 		return err
@@ -71,6 +100,12 @@ func (sm *StorageManager) load() error {
 
 	sm.sectorSalt = p.SectorSalt
 	sm.storageFolders = p.StorageFolders
+	sm.scrubPeriod = p.ScrubPeriod
+	sm.scrubRateLimit = p.ScrubRateLimit
+	sm.scrubCursor = p.ScrubCursor
+	sm.scrubStatus = p.ScrubStatus
+	sm.migration = p.Migration
+	sm.migrationStatus = p.MigrationStatus
 	return nil
 }
 
@@ -0,0 +1,262 @@
+package storagemanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// scrub.go contains the sector scrubber. Sectors are written to disk once and
+// then read back rarely, meaning that a sector can silently rot on disk for a
+// long time before a renter tries to download it. The scrubber reads every
+// sector in the background over a configurable period and checks the data
+// against the sector root. Sectors that no longer match are marked as
+// corrupted, so that the host stops serving them and can report them as lost.
+//
+// Sectors are stored under a salted hash of their root, so the scrubber does
+// not need to know the root of a sector in advance - the root of the data on
+// disk is hashed the same way and compared against the sector id.
+
+var (
+	// ErrSectorCorrupted is returned when trying to read a sector that the
+	// scrubber has found to be corrupted.
+	ErrSectorCorrupted = errors.New("the sector is corrupted and can no longer be read")
+
+	// errNegativeScrubPeriod is returned if the scrubber is given a negative
+	// period.
+	errNegativeScrubPeriod = errors.New("scrub period must not be negative")
+)
+
+// scrubDelay returns how long the scrubber should wait between two sectors,
+// so that a pass over all of the sectors takes at least 'period', and the
+// scrubber reads no more than 'rateLimit' bytes per second.
+func scrubDelay(period time.Duration, rateLimit uint64, totalSectors uint64) time.Duration {
+	if totalSectors == 0 {
+		totalSectors = 1
+	}
+	delay := period / time.Duration(totalSectors)
+	if rateLimit > 0 {
+		rateDelay := time.Duration(modules.SectorSize * uint64(time.Second) / rateLimit)
+		if rateDelay > delay {
+			delay = rateDelay
+		}
+	}
+	return delay
+}
+
+// managedNextScrubSector returns the key and usage of the next sector that
+// the scrubber should read. If a pass over the sectors has completed, the
+// results are recorded and nil is returned.
+func (sm *StorageManager) managedNextScrubSector() (sectorKey []byte, usage sectorUsage, err error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return nil, usage, errStorageManagerClosed
+	}
+
+	err = sm.db.View(func(tx *bolt.Tx) error {
+		bsu := tx.Bucket(bucketSectorUsage)
+		c := bsu.Cursor()
+		var k, v []byte
+		if sm.scrubCursor == nil {
+			k, v = c.First()
+			if k == nil {
+				return nil
+			}
+			sm.scrubStatus.PassStarted = time.Now()
+			sm.scrubStatus.SectorsScrubbed = 0
+			sm.scrubStatus.SectorsTotal = uint64(bsu.Stats().KeyN)
+			sm.scrubStatus.CorruptedSectors = 0
+		} else {
+			k, v = c.Seek(sm.scrubCursor)
+			if k != nil && bytes.Equal(k, sm.scrubCursor) {
+				k, v = c.Next()
+			}
+		}
+		if k == nil {
+			// The pass is complete.
+			sm.scrubStatus.LastPassCompleted = time.Now()
+			sm.scrubStatus.LastPassCorrupted = sm.scrubStatus.CorruptedSectors
+			sm.scrubCursor = nil
+			return sm.save()
+		}
+		sectorKey = append([]byte(nil), k...)
+		return json.Unmarshal(v, &usage)
+	})
+	return sectorKey, usage, err
+}
+
+// managedRecordScrub records the result of scrubbing a sector. The sector may
+// have been removed or moved while it was being read, in which case the
+// result is discarded.
+func (sm *StorageManager) managedRecordScrub(sectorKey []byte, scrubbed sectorUsage, data []byte, readErr error, root crypto.Hash) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return errStorageManagerClosed
+	}
+
+	sm.scrubCursor = sectorKey
+	sm.scrubStatus.SectorsScrubbed++
+	corrupted := false
+	err := sm.db.Update(func(tx *bolt.Tx) error {
+		bsu := tx.Bucket(bucketSectorUsage)
+		usageBytes := bsu.Get(sectorKey)
+		if usageBytes == nil {
+			return nil
+		}
+		var usage sectorUsage
+		err := json.Unmarshal(usageBytes, &usage)
+		if err != nil {
+			return err
+		}
+		sf := sm.storageFolder(usage.StorageFolder)
+		moved := !bytes.Equal(usage.StorageFolder, scrubbed.StorageFolder) || usage.HasSlot != scrubbed.HasSlot || usage.Slot != scrubbed.Slot
+		if sf == nil || moved {
+			return nil
+		}
+		if usage.Corrupted {
+			sm.scrubStatus.CorruptedSectors++
+			return nil
+		}
+		if readErr != nil {
+			sf.FailedReads++
+			sm.log.Printf("WARN: scrubber could not read sector %s in storage folder %s: %v", sectorKey, sf.uidString(), readErr)
+			return nil
+		}
+		if uint64(len(data)) == modules.SectorSize && bytes.Equal(sm.sectorID(root[:]), sectorKey) {
+			sf.SuccessfulReads++
+			return nil
+		}
+
+		// The data on disk does not match the sector root.
+		sf.FailedReads++
+		sm.scrubStatus.CorruptedSectors++
+		corrupted = true
+		sm.log.Printf("WARN: scrubber found corrupted sector %s in storage folder %s", sectorKey, sf.uidString())
+		usage.Corrupted = true
+		usageBytes, err = json.Marshal(usage)
+		if err != nil {
+			return err
+		}
+		return bsu.Put(sectorKey, usageBytes)
+	})
+	if err != nil {
+		return err
+	}
+	// The cursor is only saved when a corruption is found, losing the cursor
+	// means that a few sectors are scrubbed twice.
+	if corrupted {
+		return sm.save()
+	}
+	return nil
+}
+
+// managedScrubSector scrubs the next sector, returning how long the scrubber
+// should wait before scrubbing the following sector.
+func (sm *StorageManager) managedScrubSector() time.Duration {
+	sm.mu.RLock()
+	period := sm.scrubPeriod
+	rateLimit := sm.scrubRateLimit
+	sm.mu.RUnlock()
+	if period == 0 {
+		return scrubIdleFrequency
+	}
+
+	sectorKey, usage, err := sm.managedNextScrubSector()
+	if err != nil {
+		if err != errStorageManagerClosed {
+			sm.log.Println("WARN: scrubber could not find the next sector:", err)
+		}
+		return scrubIdleFrequency
+	}
+	if sectorKey == nil {
+		return scrubIdleFrequency
+	}
+
+	// Read and hash the sector without holding the lock, the host should not
+	// be blocked by the scrubber. Sectors that are already known to be
+	// corrupted are not read again.
+	var data []byte
+	var readErr error
+	var root crypto.Hash
+	if !usage.Corrupted {
+		data, readErr = sm.readSector(sectorKey, usage)
+		if readErr == nil {
+			root = crypto.MerkleRoot(data)
+		}
+	}
+	err = sm.managedRecordScrub(sectorKey, usage, data, readErr, root)
+	if err != nil && err != errStorageManagerClosed {
+		sm.log.Println("WARN: scrubber could not record a scrubbed sector:", err)
+	}
+
+	sm.mu.RLock()
+	total := sm.scrubStatus.SectorsTotal
+	sm.mu.RUnlock()
+	return scrubDelay(period, rateLimit, total)
+}
+
+// threadedScrub scrubs the sectors of the storage manager until the storage
+// manager is closed.
+func (sm *StorageManager) threadedScrub() {
+	for {
+		delay := sm.managedScrubSector()
+		select {
+		case <-sm.scrubStop:
+			return
+		case <-sm.scrubWake:
+		case <-time.After(delay):
+		}
+	}
+}
+
+// ScrubStatus returns the settings and progress of the sector scrubber.
+func (sm *StorageManager) ScrubStatus() modules.StorageScrubStatus {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	status := sm.scrubStatus
+	status.Period = sm.scrubPeriod
+	status.RateLimit = sm.scrubRateLimit
+	return status
+}
+
+// SetScrubSettings sets how long a full pass of the sector scrubber should
+// take, and how many bytes per second the scrubber may read from disk. A
+// period of 0 disables the scrubber, and a rate limit of 0 is unlimited.
+func (sm *StorageManager) SetScrubSettings(period time.Duration, rateLimit uint64) error {
+	if period < 0 {
+		return errNegativeScrubPeriod
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return errStorageManagerClosed
+	}
+
+	sm.scrubPeriod = period
+	sm.scrubRateLimit = rateLimit
+	err := sm.saveSync()
+	if err != nil {
+		return err
+	}
+
+	// Wake the scrubber so that the new settings take effect immediately.
+	select {
+	case sm.scrubWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
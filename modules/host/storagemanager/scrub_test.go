@@ -0,0 +1,125 @@
+package storagemanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestScrubDelay checks that the scrubber spreads a pass over the scrub
+// period and respects the rate limit.
+func TestScrubDelay(t *testing.T) {
+	if scrubDelay(time.Hour, 0, 60) != time.Minute {
+		t.Error("pass is not spread over the scrub period")
+	}
+	if scrubDelay(time.Hour, 0, 0) != time.Hour {
+		t.Error("empty host should wait for the full period")
+	}
+	rateLimit := modules.SectorSize / 2
+	if scrubDelay(time.Second, rateLimit, 1000) != 2*time.Second {
+		t.Error("rate limit was not respected")
+	}
+}
+
+// TestScrubSectors checks that the scrubber finds sectors whose data no
+// longer matches the sector root, and marks them as corrupted.
+func TestScrubSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestScrubSectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smt.sm.ScrubStatus().Period != 0 {
+		t.Fatal("scrubber should be disabled by default in testing")
+	}
+
+	// Add three sectors, and replace the data of the second with data of the
+	// right size that does not match the sector root.
+	var roots []crypto.Hash
+	var datas [][]byte
+	for i := 0; i < 3; i++ {
+		root, data, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(root, 10, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		datas = append(datas, data)
+	}
+	_, badData, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sectors are placed in the lowest free slot, so the second sector is in
+	// the second slot of the slab.
+	sf := smt.sm.storageFolders[0]
+	err = smt.sm.dependencies.writeFileAt(smt.sm.slabPath(sf.UID), badData, int64(modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failedReads := smt.sm.StorageFolders()[0].FailedReads
+
+	// Enable the scrubber and wait for a pass to complete.
+	err = smt.sm.SetScrubSettings(time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && smt.sm.ScrubStatus().LastPassCompleted.IsZero(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	status := smt.sm.ScrubStatus()
+	if status.LastPassCompleted.IsZero() {
+		t.Fatal("scrubber did not complete a pass")
+	}
+	if status.LastPassCorrupted != 1 {
+		t.Fatal("expected one corrupted sector, got", status.LastPassCorrupted)
+	}
+	if smt.sm.StorageFolders()[0].FailedReads != failedReads+1 {
+		t.Error("corrupted sector was not counted as a failed read")
+	}
+	_, err = smt.sm.ReadSector(roots[1])
+	if err != ErrSectorCorrupted {
+		t.Fatal("expected ErrSectorCorrupted, got", err)
+	}
+	_, err = smt.sm.ReadSector(roots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := smt.sm.CheckSectors(roots, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.CorruptedSectors) != 1 || report.CorruptedSectors[0] != roots[1] {
+		t.Error("consistency check did not report the corrupted sector:", report.CorruptedSectors)
+	}
+
+	// Disable the scrubber, and repair the sector by uploading it again.
+	err = smt.sm.SetScrubSettings(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.AddSector(roots[1], 10, datas[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := smt.sm.ReadSector(roots[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.MerkleRoot(data) != roots[1] {
+		t.Fatal("sector was not repaired")
+	}
+}
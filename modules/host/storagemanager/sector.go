@@ -3,11 +3,8 @@ package storagemanager
 import (
 	"bytes"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"path/filepath"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
@@ -16,25 +13,10 @@ import (
 	"github.com/NebulousLabs/bolt"
 )
 
-// TODO: Write a sector consistency check - every sector in the host database
-// should be represented by a sector on disk, and vice-versa. This is closer to
-// a testing check, because the host is tolerant of disk corruption - it is
-// okay for there to be information in the sector usage struct that cannot be
-// retrieved from the disk. The consistency check should return information on
-// how much corruption there is and what shape it takes. If there are files
-// found on disk that are not represented in the usage struct, those files
-// should be reported as well. The consistency check should be accompanied by a
-// 'purge' mode (perhaps multiple modes) which will delete any files in the
-// storage folders which are not represented in the sector usage database.
-//
-// A similar check should exist for verifying that the host has the correct
-// folder structure. All of the standard files, plus all of the storage
-// folders, nothing more. This check belongs in storagefolders.go.
-//
-// A final check, the obligations check, should verify that every sector in the
-// sector usage database is represented correctly by the storage obligations,
-// and that every sector in the storage obligations is represented by the
-// sector usage database.
+// TODO: Write a check for verifying that the host has the correct folder
+// structure. All of the standard files, plus all of the storage folders,
+// nothing more. This check belongs in storagefolders.go. The sector
+// consistency check is in consistency.go.
 //
 // Disk inconsistencies should be handled by returning errors when trying to
 // read from the filesystem, which means the problem manifests at the lowest
@@ -43,14 +25,6 @@ import (
 // storage obligation database, and should be patched if there's a mismatch.
 // The storage obligation database gets preference. Any missing sectors will be
 // treated as if they were filesystem problems.
-//
-// The consistency check should be wary of 'SizeRemaining' when it is trying to
-// do cleanup - if sector removals fail, SizeRemaining should not update as
-// though the sectors are gone (but should also be correct such that it's the
-// size of the real sectors + the size of the unremovable files - calculated,
-// not relative)
-
-// TODO: Write an RPC that lets the host share which sectors it has lost.
 
 // TODO: Make sure the host will not stutter if it needs to perform operations
 // on sectors that have been manually deleted.
@@ -68,8 +42,9 @@ var (
 	// sector when there is not enough storage remaining on the host to accept
 	// the sector.
 	//
-	// Ideally, the host will adjust pricing as the host starts to fill up, so
-	// this error should be pretty rare. Demand should drive the price up
+	// Ideally, the host will adjust pricing as the host starts to fill up (see
+	// the utilisation pricing policy of the host), so this error should be
+	// pretty rare. Demand should drive the price up
 	// faster than the Host runs out of space, such that the host is always
 	// hovering around 95% capacity and rarely over 98% or under 90% capacity.
 	errInsufficientStorageForSector = errors.New("not enough storage remaining to accept sector")
@@ -87,11 +62,17 @@ var (
 // useful for file contract renewals, and really shouldn't be used otherwise.
 //
 // The StorageFolder field indicates which storage folder is housing the
-// sector.
+// sector, and the Slot field indicates where in the slab file of that storage
+// folder the sector is stored. Sectors that were stored before slab files were
+// introduced have their own file in the storage folder and have no slot until
+// they are migrated.
 type sectorUsage struct {
 	Corrupted     bool // If the corrupted flag is set, it means the sector is permanently unreachable.
 	Expiry        []types.BlockHeight
 	StorageFolder []byte
+
+	HasSlot bool
+	Slot    uint64
 }
 
 // sectorID returns the id that should be used when referring to a sector.
@@ -130,13 +111,17 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 	// Check that there is enough room for the sector in at least one storage
 	// folder - check will also guarantee that there is at least one storage folder.
 	enoughRoom := false
-	for _, sf := range sm.storageFolders {
+	for _, sf := range sm.placementFolders() {
 		if sf.SizeRemaining >= modules.SectorSize {
 			enoughRoom = true
 		}
 	}
 
-	// Determine which storage folder is going to receive the new sector.
+	// Determine which storage folder is going to receive the new sector. If a
+	// corrupted sector is repaired into the slab, its old file is removed
+	// once the database has been updated.
+	var legacyPath string
+	var st slotTx
 	err := sm.db.Update(func(tx *bolt.Tx) error {
 		// Check whether the sector is a virtual sector.
 		sectorKey := sm.sectorID(sectorRoot[:])
@@ -162,6 +147,23 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 			if len(usage.Expiry) >= maximumVirtualSectors {
 				return errMaxVirtualSectors
 			}
+			// If the sector has been found to be corrupted, the new data is
+			// used to repair it.
+			sf := sm.storageFolder(usage.StorageFolder)
+			if usage.Corrupted && sf != nil && uint64(len(sectorData)) == modules.SectorSize {
+				hadSlot := usage.HasSlot
+				err = sm.rewriteSector(sf, &usage, sectorData)
+				if err != nil {
+					sf.FailedWrites++
+				} else {
+					sf.SuccessfulWrites++
+					usage.Corrupted = false
+					if !hadSlot {
+						st.allocate(sf, usage.Slot)
+						legacyPath = sm.legacySectorPath(usage.StorageFolder, sectorKey)
+					}
+				}
+			}
 			usage.Expiry = append(usage.Expiry, expiryHeight)
 			usageBytes, err = json.Marshal(usage)
 			if err != nil {
@@ -185,21 +187,18 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 
 		// Try adding the sector to disk. In the event of a failure, the host
 		// will try the next storage folder until there is either a success or
-		// until all options have been exhausted.
-		potentialFolders := sm.storageFolders
+		// until all options have been exhausted. An incomplete write only
+		// leaves garbage in a free slot, so there is nothing to clean up.
+		potentialFolders := sm.placementFolders()
 		emptiestFolder, emptiestIndex := emptiestStorageFolder(potentialFolders)
 		for emptiestFolder != nil {
-			sectorPath := filepath.Join(sm.persistDir, emptiestFolder.uidString(), string(sectorKey))
-			err := sm.dependencies.writeFile(sectorPath, sectorData, 0700)
+			slot, err := sm.writeSector(emptiestFolder, sectorData)
 			if err != nil {
 				// Indicate to the user that the storage folder is having write
-				// trouble.
-				emptiestFolder.FailedWrites++
-
-				// Remove the attempted write - an an incomplete write can
-				// leave a partial file on disk. Error is not checked, we
-				// already know the disk is having trouble.
-				_ = sm.dependencies.removeFile(sectorPath)
+				// trouble. A folder without a free slot is not having trouble.
+				if err != errNoFreeSlot {
+					emptiestFolder.FailedWrites++
+				}
 
 				// Remove the failed folder from the list of folders that can
 				// be tried.
@@ -210,12 +209,16 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 				continue
 			}
 			emptiestFolder.SuccessfulWrites++
+			st.allocate(emptiestFolder, slot)
 
 			// File write succeeded - add the sector to the sector usage
 			// database and return.
 			usage := sectorUsage{
 				Expiry:        []types.BlockHeight{expiryHeight},
 				StorageFolder: emptiestFolder.UID,
+
+				HasSlot: true,
+				Slot:    slot,
 			}
 			emptiestFolder.SizeRemaining -= modules.SectorSize
 			usageBytes, err = json.Marshal(usage)
@@ -229,9 +232,13 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 		// has failed.
 		return errDiskTrouble
 	})
+	st.finish(err)
 	if err != nil {
 		return err
 	}
+	if legacyPath != "" {
+		_ = sm.dependencies.removeFile(legacyPath)
+	}
 	return sm.save()
 }
 
@@ -252,9 +259,11 @@ func (sm *StorageManager) ReadSector(sectorRoot crypto.Hash) (sectorBytes []byte
 		if err != nil {
 			return err
 		}
+		if su.Corrupted {
+			return ErrSectorCorrupted
+		}
 
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(su.StorageFolder), string(sectorKey))
-		sectorBytes, err = ioutil.ReadFile(sectorPath)
+		sectorBytes, err = sm.readSector(sectorKey, su)
 		sf := sm.storageFolder(su.StorageFolder)
 		if err != nil {
 			// Mark the read failure in the sector.
@@ -274,7 +283,8 @@ func (sm *StorageManager) RemoveSector(sectorRoot crypto.Hash, expiryHeight type
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	return sm.db.Update(func(tx *bolt.Tx) error {
+	var st slotTx
+	err := sm.db.Update(func(tx *bolt.Tx) error {
 		// Grab the existing sector usage information from the database.
 		bsu := tx.Bucket(bucketSectorUsage)
 		sectorKey := sm.sectorID(sectorRoot[:])
@@ -331,17 +341,21 @@ func (sm *StorageManager) RemoveSector(sectorRoot crypto.Hash, expiryHeight type
 			}
 		}
 
-		// Remove the sector from the physical disk and update the storage
-		// folder metadata.
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(usage.StorageFolder), string(sectorKey))
-		err = sm.dependencies.removeFile(sectorPath)
-		if err != nil {
-			// Indicate that the storage folder is having write troubles.
-			folder.FailedWrites++
-			return err
+		// Free the slot of the sector and update the storage folder metadata.
+		// A sector that has not been moved into a slab has its file removed
+		// from the physical disk instead.
+		if usage.HasSlot {
+			st.free(folder, usage.Slot)
+		} else {
+			err = sm.dependencies.removeFile(sm.legacySectorPath(usage.StorageFolder, sectorKey))
+			if err != nil {
+				// Indicate that the storage folder is having write troubles.
+				folder.FailedWrites++
+				return err
+			}
+			folder.SuccessfulWrites++
 		}
 		folder.SizeRemaining += modules.SectorSize
-		folder.SuccessfulWrites++
 		err = sm.save()
 		if err != nil {
 			return err
@@ -351,6 +365,8 @@ func (sm *StorageManager) RemoveSector(sectorRoot crypto.Hash, expiryHeight type
 		// this sector in the host.
 		return bsu.Delete(sm.sectorID(sectorRoot[:]))
 	})
+	st.finish(err)
+	return err
 }
 
 // DeleteSector deletes a sector from the host explicitly, meaning that the
@@ -367,7 +383,8 @@ func (sm *StorageManager) DeleteSector(sectorRoot crypto.Hash) error {
 		return errStorageManagerClosed
 	}
 
-	return sm.db.Update(func(tx *bolt.Tx) error {
+	var st slotTx
+	err := sm.db.Update(func(tx *bolt.Tx) error {
 		// Check that the sector exists in the database.
 		bsu := tx.Bucket(bucketSectorUsage)
 		sectorKey := sm.sectorID(sectorRoot[:])
@@ -390,15 +407,23 @@ func (sm *StorageManager) DeleteSector(sectorRoot crypto.Hash) error {
 		}
 
 		// Remove the sector from the physical disk and update the storage
-		// folder metadata. The file is removed from disk as early as possible
-		// to prevent potential errors from stopping the delete.
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(usage.StorageFolder), string(sectorKey))
-		err = sm.dependencies.removeFile(sectorPath)
+		// folder metadata. The data is removed from disk as early as possible
+		// to prevent potential errors from stopping the delete. The slot of
+		// the sector is overwritten, so that the data cannot be recovered
+		// from the slab.
+		if usage.HasSlot {
+			err = sm.dependencies.writeFileAt(sm.slabPath(usage.StorageFolder), make([]byte, modules.SectorSize), int64(usage.Slot*modules.SectorSize))
+		} else {
+			err = sm.dependencies.removeFile(sm.legacySectorPath(usage.StorageFolder, sectorKey))
+		}
 		if err != nil {
 			// Indicate that the storage folder is having write troubles.
 			folder.FailedWrites++
 			return err
 		}
+		if usage.HasSlot {
+			st.free(folder, usage.Slot)
+		}
 		folder.SizeRemaining += modules.SectorSize
 		folder.SuccessfulWrites++
 		err = sm.save()
@@ -410,4 +435,6 @@ func (sm *StorageManager) DeleteSector(sectorRoot crypto.Hash) error {
 		// database.
 		return bsu.Delete(sectorKey)
 	})
+	st.finish(err)
+	return err
 }
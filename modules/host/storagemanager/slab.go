@@ -0,0 +1,671 @@
+package storagemanager
+
+// slab.go stores the sectors of each storage folder in a single file, the
+// slab. The slab is divided into slots that are each the size of a sector, and
+// the sector usage database records which slot of which storage folder each
+// sector is stored in. Keeping millions of sectors in one large file instead of
+// millions of small files takes a lot of load off of the filesystem. The slab
+// is sized to the full capacity of the storage folder when the storage folder
+// is added or resized, so the space is claimed before the host promises it to
+// renters.
+//
+// Each storage folder keeps a bitmap of the slots that hold a sector. The
+// bitmap is not saved, it is rebuilt from the sector usage database when the
+// storage manager is loaded.
+//
+// Older versions of the storage manager kept each sector in its own file,
+// named after the sector id. When the storage manager is loaded, a background
+// thread moves those sectors into the slab of their storage folder, one at a
+// time, and the sectors are read from their own files until they have moved.
+// The slab of such a storage folder is grown one slot at a time as the sectors
+// are moved, and the file of each sector is removed once it has moved, so that
+// the move needs hardly more disk space than the storage folder already uses.
+// A sector that cannot be moved, for example because the disk is having
+// trouble, is left in its own file and is read from there until the move
+// succeeds on a later load.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// slabFilename is the name of the slab file in each storage folder.
+	slabFilename = "sectors.dat"
+)
+
+var (
+	// errNoFreeSlot is returned when a sector is written to a storage folder
+	// whose slab has no free slots.
+	errNoFreeSlot = errors.New("storage folder has no free slots in its slab")
+
+	// errBadLegacySector is returned when the file of a sector that is moved
+	// into a slab does not hold a full sector.
+	errBadLegacySector = errors.New("sector file does not hold a full sector")
+)
+
+// slotUsed returns whether a slot in the slab of the storage folder holds a
+// sector.
+func (sf *storageFolder) slotUsed(slot uint64) bool {
+	return slot < sf.slabSlots && sf.slots[slot/64]&(1<<(slot%64)) != 0
+}
+
+// setSlot marks a slot in the slab of the storage folder as holding a sector.
+func (sf *storageFolder) setSlot(slot uint64) {
+	sf.slots[slot/64] |= 1 << (slot % 64)
+}
+
+// clearSlot marks a slot in the slab of the storage folder as free.
+func (sf *storageFolder) clearSlot(slot uint64) {
+	sf.slots[slot/64] &^= 1 << (slot % 64)
+}
+
+// A slotTx collects the changes made to the slot bitmaps during a database
+// transaction. Slots are only marked free once the transaction that stops
+// using them has committed, so that a failed transaction never leaves a live
+// sector in a free slot. Slots allocated during a transaction that fails are
+// marked free again.
+type slotTx struct {
+	allocated []folderSlot
+	freed     []folderSlot
+}
+
+// A folderSlot is a slot in the slab of a storage folder.
+type folderSlot struct {
+	sf   *storageFolder
+	slot uint64
+}
+
+// allocate records that the transaction allocated a slot.
+func (st *slotTx) allocate(sf *storageFolder, slot uint64) {
+	st.allocated = append(st.allocated, folderSlot{sf, slot})
+}
+
+// free records that the transaction stopped using a slot.
+func (st *slotTx) free(sf *storageFolder, slot uint64) {
+	st.freed = append(st.freed, folderSlot{sf, slot})
+}
+
+// finish updates the slot bitmaps once the transaction has finished with the
+// error err.
+func (st *slotTx) finish(err error) {
+	if err != nil {
+		for _, fs := range st.allocated {
+			fs.sf.clearSlot(fs.slot)
+		}
+		return
+	}
+	for _, fs := range st.freed {
+		fs.sf.clearSlot(fs.slot)
+	}
+}
+
+// freeSlot returns the lowest free slot in the slab of the storage folder.
+func (sf *storageFolder) freeSlot() (uint64, error) {
+	for i, word := range sf.slots {
+		if word == ^uint64(0) {
+			continue
+		}
+		for bit := uint64(0); bit < 64; bit++ {
+			slot := uint64(i)*64 + bit
+			if slot >= sf.slabSlots {
+				return 0, errNoFreeSlot
+			}
+			if word&(1<<bit) == 0 {
+				return slot, nil
+			}
+		}
+	}
+	return 0, errNoFreeSlot
+}
+
+// allocateSlot marks the lowest free slot in the slab of the storage folder as
+// holding a sector, and returns the slot.
+func (sf *storageFolder) allocateSlot() (uint64, error) {
+	slot, err := sf.freeSlot()
+	if err != nil {
+		return 0, err
+	}
+	sf.setSlot(slot)
+	return slot, nil
+}
+
+// highestSlot returns the highest slot in the slab of the storage folder that
+// holds a sector. False is returned if no slot holds a sector.
+func (sf *storageFolder) highestSlot() (uint64, bool) {
+	for i := len(sf.slots) - 1; i >= 0; i-- {
+		if sf.slots[i] == 0 {
+			continue
+		}
+		for bit := uint64(63); ; bit-- {
+			if sf.slots[i]&(1<<bit) != 0 {
+				return uint64(i)*64 + bit, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// resizeSlots sets the number of slots in the bitmap of the storage folder.
+// Slots beyond the new number are dropped, the caller is responsible for
+// making sure that they do not hold a sector.
+func (sf *storageFolder) resizeSlots(slots uint64) {
+	words := int((slots + 63) / 64)
+	for len(sf.slots) < words {
+		sf.slots = append(sf.slots, 0)
+	}
+	sf.slots = sf.slots[:words]
+	if slots%64 != 0 {
+		sf.slots[words-1] &= 1<<(slots%64) - 1
+	}
+	sf.slabSlots = slots
+}
+
+// slabPath returns the path of the slab of the storage folder with the given
+// UID.
+func (sm *StorageManager) slabPath(uid []byte) string {
+	return filepath.Join(sm.persistDir, hex.EncodeToString(uid), slabFilename)
+}
+
+// legacySectorPath returns the path of the file of a sector that has not been
+// moved into the slab of its storage folder.
+func (sm *StorageManager) legacySectorPath(uid []byte, sectorKey []byte) string {
+	return filepath.Join(sm.persistDir, hex.EncodeToString(uid), string(sectorKey))
+}
+
+// readSector reads the data of a sector from disk. The storage manager does
+// not need to be locked, as long as the usage is current.
+func (sm *StorageManager) readSector(sectorKey []byte, usage sectorUsage) ([]byte, error) {
+	if !usage.HasSlot {
+		return sm.dependencies.readFile(sm.legacySectorPath(usage.StorageFolder, sectorKey))
+	}
+	data := make([]byte, modules.SectorSize)
+	err := sm.dependencies.readFileAt(sm.slabPath(usage.StorageFolder), data, int64(usage.Slot*modules.SectorSize))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeSector writes the data of a sector into a free slot in the slab of the
+// storage folder, returning the slot. The slot is only kept if the write
+// succeeds.
+func (sm *StorageManager) writeSector(sf *storageFolder, data []byte) (uint64, error) {
+	slot, err := sf.allocateSlot()
+	if err != nil {
+		return 0, err
+	}
+	err = sm.dependencies.writeFileAt(sm.slabPath(sf.UID), data, int64(slot*modules.SectorSize))
+	if err != nil {
+		sf.clearSlot(slot)
+		return 0, err
+	}
+	return slot, nil
+}
+
+// rewriteSector writes new data for a sector that is already stored in the
+// storage folder. A sector that is still in its own file is given a slot in
+// the slab, and the usage is updated to point at the slot. The old file is
+// left for the caller to remove once the usage has been saved.
+func (sm *StorageManager) rewriteSector(sf *storageFolder, usage *sectorUsage, data []byte) error {
+	if usage.HasSlot {
+		return sm.dependencies.writeFileAt(sm.slabPath(sf.UID), data, int64(usage.Slot*modules.SectorSize))
+	}
+	slot, err := sm.writeSector(sf, data)
+	if err != nil {
+		return err
+	}
+	usage.HasSlot = true
+	usage.Slot = slot
+	return nil
+}
+
+// allocateSlab sets the size of the slab of the storage folder on disk to
+// 'slots' slots, without changing the slot bitmap. The storage manager does
+// not need to be locked, as long as it is marked as allocating so that the
+// storage folder is not resized or removed in the meantime.
+func (sm *StorageManager) allocateSlab(sf *storageFolder, slots uint64) error {
+	return sm.dependencies.resizeFile(sm.slabPath(sf.UID), int64(slots*modules.SectorSize))
+}
+
+// resizeSlab sets the number of slots in the slab of the storage folder. The
+// slab is never made smaller than is needed to hold the slots that are in use.
+func (sm *StorageManager) resizeSlab(sf *storageFolder, slots uint64) error {
+	if highest, ok := sf.highestSlot(); ok && highest >= slots {
+		slots = highest + 1
+	}
+	err := sm.allocateSlab(sf, slots)
+	if err != nil {
+		return err
+	}
+	sf.resizeSlots(slots)
+	return nil
+}
+
+// shrinkSlab compacts the slab of the storage folder and shrinks it to 'slots'
+// slots, or as close to 'slots' as the sectors that could not be moved allow.
+// A slab that could not be shrunk only wastes disk space, so only database
+// errors are returned.
+func (sm *StorageManager) shrinkSlab(sf *storageFolder, slots uint64) error {
+	err := sm.compactSlab(sf, slots)
+	if err != nil {
+		return err
+	}
+	err = sm.resizeSlab(sf, slots)
+	if err != nil {
+		sf.FailedWrites++
+		sm.log.Printf("WARN: could not shrink the slab of storage folder %v: %v", sf.Path, err)
+	}
+	return nil
+}
+
+// compactSlab moves the sectors in the slots at or beyond 'slots' into free
+// slots below 'slots', so that the slab of the storage folder can be shrunk.
+// Sectors that cannot be moved are left in place, and keep the slab from
+// shrinking past them.
+func (sm *StorageManager) compactSlab(sf *storageFolder, slots uint64) error {
+	// Find the sectors that need to be moved.
+	var sectorKeys [][]byte
+	err := sm.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSectorUsage).ForEach(func(sectorKey, usageBytes []byte) error {
+			var usage sectorUsage
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+			if usage.HasSlot && usage.Slot >= slots && bytes.Equal(usage.StorageFolder, sf.UID) {
+				sectorKeys = append(sectorKeys, append([]byte(nil), sectorKey...))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Move the sectors one at a time, using a different database tx for each
+	// sector.
+	for _, sectorKey := range sectorKeys {
+		var st slotTx
+		err = sm.db.Update(func(tx *bolt.Tx) error {
+			bsu := tx.Bucket(bucketSectorUsage)
+			var usage sectorUsage
+			err := json.Unmarshal(bsu.Get(sectorKey), &usage)
+			if err != nil {
+				return err
+			}
+			data, err := sm.readSector(sectorKey, usage)
+			if err != nil {
+				sf.FailedReads++
+				return nil
+			}
+			sf.SuccessfulReads++
+
+			// Slots are allocated lowest first, so if the allocated slot is
+			// not below 'slots' there is no room left to compact into.
+			newSlot, err := sm.writeSector(sf, data)
+			if err == errNoFreeSlot {
+				return errNoFreeSlot
+			} else if err != nil {
+				sf.FailedWrites++
+				return nil
+			}
+			st.allocate(sf, newSlot)
+			if newSlot >= slots {
+				return errNoFreeSlot
+			}
+			sf.SuccessfulWrites++
+
+			st.free(sf, usage.Slot)
+			usage.Slot = newSlot
+			usageBytes, err := json.Marshal(usage)
+			if err != nil {
+				return err
+			}
+			return bsu.Put(sectorKey, usageBytes)
+		})
+		st.finish(err)
+		if err == errNoFreeSlot {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSlabs rebuilds the slot bitmaps of the storage folders from the sector
+// usage database, and makes sure that the slab of every storage folder is
+// large enough to hold all of its slots. If any sectors are still stored in
+// their own files, the storage manager is marked so that they are moved into
+// the slabs in the background.
+func (sm *StorageManager) loadSlabs() error {
+	// Rebuild the slot bitmaps, and find the storage folders with sectors that
+	// have not been moved into a slab. Corrupted sectors are not moved, they
+	// are replaced by the slab when the sector is uploaded again.
+	for _, sf := range sm.storageFolders {
+		sf.resizeSlots(sf.Size / modules.SectorSize)
+	}
+	hasLegacySectors := make(map[*storageFolder]bool)
+	err := sm.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSectorUsage).ForEach(func(sectorKey, usageBytes []byte) error {
+			var usage sectorUsage
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+			sf := sm.storageFolder(usage.StorageFolder)
+			if sf == nil {
+				return nil
+			}
+			if !usage.HasSlot {
+				if !usage.Corrupted {
+					hasLegacySectors[sf] = true
+				}
+				return nil
+			}
+			if usage.Slot >= sf.slabSlots {
+				sf.resizeSlots(usage.Slot + 1)
+			}
+			sf.setSlot(usage.Slot)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	sm.legacyActive = len(hasLegacySectors) > 0
+
+	// Create or grow any slabs that are too small. The slab of a storage
+	// folder with sectors in their own files is only grown to hold the slots
+	// in use, as the files still take up the rest of the space. A storage
+	// folder may be on a disk that is not mounted right now, in which case its
+	// sectors fail to read until the slab is created on a later load.
+	for _, sf := range sm.storageFolders {
+		slots := sf.slabSlots
+		if hasLegacySectors[sf] {
+			slots = 0
+			if highest, ok := sf.highestSlot(); ok {
+				slots = highest + 1
+			}
+		}
+		info, err := sm.dependencies.stat(sm.slabPath(sf.UID))
+		if err == nil && uint64(info.Size()) >= slots*modules.SectorSize {
+			continue
+		}
+		err = sm.allocateSlab(sf, slots)
+		if err != nil {
+			sf.FailedWrites++
+			sm.log.Printf("WARN: could not create the slab of storage folder %v: %v", sf.Path, err)
+		}
+	}
+	return nil
+}
+
+// managedNextLegacySector returns the key and usage of the next sector that is
+// still stored in its own file, and allocates a slot for the sector in the
+// slab of its storage folder. The storage manager is marked as allocating
+// until the move has been recorded, so that the storage folder is not resized
+// or removed while the sector is copied. A nil key is returned if the storage
+// manager is busy allocating, or if the sector could not be given a slot.
+// False is returned once every sector has been tried.
+func (sm *StorageManager) managedNextLegacySector() (sectorKey []byte, usage sectorUsage, slot uint64, active bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return nil, usage, 0, false
+	}
+	if sm.allocating {
+		return nil, usage, 0, true
+	}
+
+	// Find the next sector that is stored in its own file. The sectors of a
+	// migrating storage folder are left to the migration, which moves them
+	// into the slab of the destination folder.
+	var sf *storageFolder
+	err := sm.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketSectorUsage).Cursor()
+		var k, v []byte
+		if sm.legacyCursor == nil {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(sm.legacyCursor)
+			if k != nil && bytes.Equal(k, sm.legacyCursor) {
+				k, v = c.Next()
+			}
+		}
+		for ; k != nil; k, v = c.Next() {
+			err := json.Unmarshal(v, &usage)
+			if err != nil {
+				return err
+			}
+			if usage.HasSlot || usage.Corrupted {
+				continue
+			}
+			sf = sm.storageFolder(usage.StorageFolder)
+			if sf != nil && !sm.migratingFolder(sf) {
+				sectorKey = append([]byte(nil), k...)
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		sm.log.Println("WARN: could not find the next sector to move into a slab:", err)
+		return nil, usage, 0, false
+	}
+	if sectorKey == nil {
+		return nil, usage, 0, false
+	}
+	sm.legacyCursor = sectorKey
+
+	slot, err = sf.allocateSlot()
+	if err != nil {
+		sf.FailedWrites++
+		sm.log.Printf("WARN: could not move sector %s into the slab of storage folder %v: %v", sectorKey, sf.Path, err)
+		return nil, usage, 0, true
+	}
+	sm.allocating = true
+	return sectorKey, usage, slot, true
+}
+
+// managedRecordLegacyMove points the sector at the slot in the slab that its
+// data was copied to, and removes the file of the sector. The sector may have
+// been removed or rewritten while it was being copied, in which case the slot
+// is freed instead. A sector that could not be copied is left in its own file,
+// and is read from there until the move succeeds on a later load.
+func (sm *StorageManager) managedRecordLegacyMove(sectorKey []byte, moved sectorUsage, slot uint64, readErr, writeErr error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	sm.allocating = false
+	if sm.closed {
+		return
+	}
+	sf := sm.storageFolder(moved.StorageFolder)
+	if sf == nil {
+		return
+	}
+	if readErr != nil {
+		sf.clearSlot(slot)
+		sf.FailedReads++
+		sm.log.Printf("WARN: could not move sector %s into the slab of storage folder %v: %v", sectorKey, sf.Path, readErr)
+		return
+	}
+	sf.SuccessfulReads++
+	if writeErr != nil {
+		sf.clearSlot(slot)
+		sf.FailedWrites++
+		sm.log.Printf("WARN: could not move sector %s into the slab of storage folder %v: %v", sectorKey, sf.Path, writeErr)
+		return
+	}
+	sf.SuccessfulWrites++
+
+	// The file of the sector is only removed after the database points at the
+	// slot, so that the sector is not lost if the host crashes part way
+	// through.
+	var legacyPath string
+	var st slotTx
+	st.allocate(sf, slot)
+	err := sm.db.Update(func(tx *bolt.Tx) error {
+		bsu := tx.Bucket(bucketSectorUsage)
+		usageBytes := bsu.Get(sectorKey)
+		var usage sectorUsage
+		if usageBytes != nil {
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+		}
+		if usageBytes == nil || usage.HasSlot || !bytes.Equal(usage.StorageFolder, moved.StorageFolder) {
+			st.free(sf, slot)
+			return nil
+		}
+		usage.HasSlot = true
+		usage.Slot = slot
+		usageBytes, err := json.Marshal(usage)
+		if err != nil {
+			return err
+		}
+		legacyPath = sm.legacySectorPath(usage.StorageFolder, sectorKey)
+		return bsu.Put(sectorKey, usageBytes)
+	})
+	st.finish(err)
+	if err != nil {
+		sm.log.Printf("WARN: could not move sector %s into the slab of storage folder %v: %v", sectorKey, sf.Path, err)
+		return
+	}
+	if legacyPath != "" {
+		// A file that cannot be removed only wastes space, and is reported
+		// as an orphan by the consistency check.
+		_ = sm.dependencies.removeFile(legacyPath)
+		sm.legacyMoved++
+	}
+}
+
+// managedMoveLegacySector moves the next sector that is stored in its own file
+// into the slab of its storage folder, returning how long to wait before
+// moving the following sector. False is returned once every sector has been
+// tried.
+func (sm *StorageManager) managedMoveLegacySector() (time.Duration, bool) {
+	sectorKey, usage, slot, active := sm.managedNextLegacySector()
+	if !active {
+		return 0, false
+	}
+	if sectorKey == nil {
+		return legacyMoveRetryFrequency, true
+	}
+
+	// Copy the sector without holding the lock, the host should not be
+	// blocked by the move. The slab only grows one slot at a time while the
+	// sectors are moved, so that the move needs hardly more disk space than
+	// the storage folder already uses.
+	slabPath := sm.slabPath(usage.StorageFolder)
+	data, readErr := sm.readSector(sectorKey, usage)
+	if readErr == nil && uint64(len(data)) != modules.SectorSize {
+		readErr = errBadLegacySector
+	}
+	var writeErr error
+	if readErr == nil {
+		info, err := sm.dependencies.stat(slabPath)
+		if err != nil || uint64(info.Size()) < (slot+1)*modules.SectorSize {
+			writeErr = sm.dependencies.resizeFile(slabPath, int64((slot+1)*modules.SectorSize))
+		}
+	}
+	if readErr == nil && writeErr == nil {
+		writeErr = sm.dependencies.writeFileAt(slabPath, data, int64(slot*modules.SectorSize))
+	}
+	sm.managedRecordLegacyMove(sectorKey, usage, slot, readErr, writeErr)
+	return 0, true
+}
+
+// managedGrowSlabs grows the slabs that were kept small while sectors were
+// moved into them to the full size of their storage folders. The slabs are
+// grown without holding the lock. False is returned if the storage manager is
+// busy allocating, in which case the slabs should be grown later.
+func (sm *StorageManager) managedGrowSlabs() bool {
+	sm.mu.Lock()
+	if sm.closed {
+		sm.mu.Unlock()
+		return true
+	}
+	if sm.allocating {
+		sm.mu.Unlock()
+		return false
+	}
+	sm.allocating = true
+	sfs := append([]*storageFolder(nil), sm.storageFolders...)
+	sm.mu.Unlock()
+
+	// The storage folders cannot be resized or removed while the storage
+	// manager is allocating.
+	growErrs := make(map[*storageFolder]error)
+	for _, sf := range sfs {
+		info, err := sm.dependencies.stat(sm.slabPath(sf.UID))
+		if err == nil && uint64(info.Size()) >= sf.slabSlots*modules.SectorSize {
+			continue
+		}
+		err = sm.allocateSlab(sf, sf.slabSlots)
+		if err != nil {
+			growErrs[sf] = err
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	sm.allocating = false
+	sm.legacyActive = false
+	if sm.closed {
+		return true
+	}
+	for sf, err := range growErrs {
+		sf.FailedWrites++
+		sm.log.Printf("WARN: could not grow the slab of storage folder %v: %v", sf.Path, err)
+	}
+	if sm.legacyMoved > 0 {
+		sm.log.Printf("INFO: moved %v sectors into slab files", sm.legacyMoved)
+		err := sm.saveSync()
+		if err != nil {
+			sm.log.Println("WARN: could not save the storage manager:", err)
+		}
+	}
+	return true
+}
+
+// threadedMoveLegacySectors moves the sectors that are stored in their own
+// files into the slabs of their storage folders, and then grows the slabs to
+// their full size. The thread stops once every sector has been tried, or when
+// the storage manager is closed.
+func (sm *StorageManager) threadedMoveLegacySectors() {
+	for {
+		delay, active := sm.managedMoveLegacySector()
+		if !active {
+			break
+		}
+		select {
+		case <-sm.legacyStop:
+			return
+		case <-time.After(delay):
+		}
+	}
+	for !sm.managedGrowSlabs() {
+		select {
+		case <-sm.legacyStop:
+			return
+		case <-time.After(legacyMoveRetryFrequency):
+		}
+	}
+}
@@ -0,0 +1,580 @@
+package storagemanager
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestSlotBitmap checks the allocation of slots in the slot bitmap of a
+// storage folder.
+func TestSlotBitmap(t *testing.T) {
+	sf := new(storageFolder)
+	sf.resizeSlots(70)
+	for i := uint64(0); i < 70; i++ {
+		slot, err := sf.allocateSlot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slot != i {
+			t.Fatal("slots are not allocated lowest first:", slot, i)
+		}
+	}
+	_, err := sf.allocateSlot()
+	if err != errNoFreeSlot {
+		t.Fatal("expected errNoFreeSlot, got", err)
+	}
+	if highest, ok := sf.highestSlot(); !ok || highest != 69 {
+		t.Fatal("wrong highest slot:", highest, ok)
+	}
+
+	// Freed slots are reused before any others.
+	sf.clearSlot(65)
+	sf.clearSlot(3)
+	slot, err := sf.allocateSlot()
+	if err != nil || slot != 3 {
+		t.Fatal("freed slot was not reused:", slot, err)
+	}
+
+	// Growing the bitmap adds free slots, and shrinking it drops slots.
+	sf.resizeSlots(130)
+	slot, err = sf.allocateSlot()
+	if err != nil || slot != 65 {
+		t.Fatal("wrong slot after growing:", slot, err)
+	}
+	slot, err = sf.allocateSlot()
+	if err != nil || slot != 70 {
+		t.Fatal("wrong slot after growing:", slot, err)
+	}
+	sf.resizeSlots(66)
+	if sf.slotUsed(70) {
+		t.Fatal("slot beyond the end of the bitmap is used")
+	}
+	if highest, ok := sf.highestSlot(); !ok || highest != 65 {
+		t.Fatal("wrong highest slot after shrinking:", highest, ok)
+	}
+	sf.resizeSlots(0)
+	if _, ok := sf.highestSlot(); ok {
+		t.Fatal("empty bitmap has a used slot")
+	}
+}
+
+// TestSlotTx checks that slots are only freed when a transaction succeeds,
+// and that slots allocated by a failed transaction are freed.
+func TestSlotTx(t *testing.T) {
+	sf := new(storageFolder)
+	sf.resizeSlots(4)
+	sf.setSlot(0)
+
+	// A failed transaction keeps the slots that it tried to free, and frees
+	// the slots that it allocated.
+	var st slotTx
+	slot, err := sf.allocateSlot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.allocate(sf, slot)
+	st.free(sf, 0)
+	if !sf.slotUsed(0) {
+		t.Fatal("slot was freed before the transaction finished")
+	}
+	st.finish(errors.New("tx failed"))
+	if !sf.slotUsed(0) || sf.slotUsed(slot) {
+		t.Fatal("failed transaction changed the slots:", sf.slotUsed(0), sf.slotUsed(slot))
+	}
+
+	// A successful transaction frees the slots, and keeps the ones that it
+	// allocated.
+	st = slotTx{}
+	slot, err = sf.allocateSlot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.allocate(sf, slot)
+	st.free(sf, 0)
+	st.finish(nil)
+	if sf.slotUsed(0) || !sf.slotUsed(slot) {
+		t.Fatal("successful transaction did not change the slots:", sf.slotUsed(0), sf.slotUsed(slot))
+	}
+}
+
+// TestResizeFile checks that resizeFile grows files with zeros and shrinks
+// them.
+func TestResizeFile(t *testing.T) {
+	dir := build.TempDir(modules.StorageManagerDir, "TestResizeFile")
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "slab")
+	var deps productionDependencies
+	err = deps.resizeFile(path, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = deps.writeFileAt(path, []byte{1, 2, 3}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(modules.SectorSize + 100)
+	err = deps.resizeFile(path, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != size || !bytes.Equal(data[:3], []byte{1, 2, 3}) || !bytes.Equal(data[3:], make([]byte, size-3)) {
+		t.Fatal("file was not grown with zeros")
+	}
+	err = deps.resizeFile(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{1, 2}) {
+		t.Fatal("file was not shrunk:", data)
+	}
+}
+
+// waitForLegacyMove waits for the storage manager to finish moving the sectors
+// stored in files of their own into the slabs.
+func waitForLegacyMove(sm *StorageManager) error {
+	for i := 0; i < 100; i++ {
+		sm.mu.RLock()
+		active := sm.legacyActive
+		sm.mu.RUnlock()
+		if !active {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return errors.New("sectors were not moved into the slabs")
+}
+
+// TestSlabMigration checks that sectors stored in files of their own are moved
+// into the slab of their storage folder when the storage manager is loaded.
+func TestSlabMigration(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestSlabMigration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := smt.sm.storageFolders[0]
+	folderDir := filepath.Join(smt.sm.persistDir, sf.uidString())
+
+	// Add three sectors, and move the last two into files of their own.
+	var roots []crypto.Hash
+	var datas [][]byte
+	for i := 0; i < 3; i++ {
+		root, data, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(root, 10, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		datas = append(datas, data)
+	}
+	for _, root := range roots[1:] {
+		err = smt.makeLegacy(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Restart the storage manager while the file of the last sector cannot
+	// be read. Only the second sector should be moved into the slab.
+	err = smt.sm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ffs := &faultyFS{brokenSubstrings: []string{string(smt.sm.sectorID(roots[2][:]))}}
+	smt.sm, err = newStorageManager(ffs, filepath.Join(smt.persistDir, modules.StorageManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = waitForLegacyMove(smt.sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(folderDir, string(smt.sm.sectorID(roots[1][:])))); !os.IsNotExist(err) {
+		t.Fatal("file of a moved sector was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(folderDir, string(smt.sm.sectorID(roots[2][:])))); err != nil {
+		t.Fatal("file of a sector that could not be moved was removed")
+	}
+	if smt.sm.storageFolders[0].FailedReads != 1 {
+		t.Error("failed move was not counted as a failed read")
+	}
+
+	// The sector that was not moved is still read from its own file.
+	ffs.brokenSubstrings = nil
+	for i, root := range roots {
+		data, err := smt.sm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector data changed during the move", i)
+		}
+	}
+
+	// Restart the storage manager again, now that the disk is working. Every
+	// sector should be in the slab.
+	err = smt.sm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	smt.sm, err = New(filepath.Join(smt.persistDir, modules.StorageManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = waitForLegacyMove(smt.sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := ioutil.ReadDir(folderDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != slabFilename {
+		t.Fatal("storage folder should only hold the slab:", infos)
+	}
+	sectors, err := smt.folderSectors(smt.sm.storageFolders[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sectors != 3 {
+		t.Fatal("wrong number of sectors after the move:", sectors)
+	}
+	for i, root := range roots {
+		data, err := smt.sm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector data changed during the move", i)
+		}
+	}
+}
+
+// resizeRecorder records the sizes that files are resized to.
+type resizeRecorder struct {
+	productionDependencies
+	sizes []int64
+}
+
+// resizeFile records the size before resizing the file.
+func (rr *resizeRecorder) resizeFile(s string, size int64) error {
+	rr.sizes = append(rr.sizes, size)
+	return rr.productionDependencies.resizeFile(s, size)
+}
+
+// TestSlabMigrationGrowth checks that the slab of a storage folder grows one
+// slot at a time while sectors are moved into it from files of their own, so
+// that the move does not need twice the size of the storage folder on disk.
+func TestSlabMigrationGrowth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestSlabMigrationGrowth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := smt.sm.storageFolders[0]
+
+	// Add three sectors, move them into files of their own, and remove the
+	// slab, as older versions of the storage manager did not have one.
+	var roots []crypto.Hash
+	var datas [][]byte
+	for i := 0; i < 3; i++ {
+		root, data, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(root, 10, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.makeLegacy(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		datas = append(datas, data)
+	}
+	err = os.Remove(smt.sm.slabPath(sf.UID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the storage manager. The slab should be created empty, grow by
+	// one slot per sector moved, and only then grow to its full size.
+	err = smt.sm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := new(resizeRecorder)
+	smt.sm, err = newStorageManager(rr, filepath.Join(smt.persistDir, modules.StorageManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = waitForLegacyMove(smt.sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss := int64(modules.SectorSize)
+	expected := []int64{0, ss, 2 * ss, 3 * ss, int64(minimumStorageFolderSize/modules.SectorSize) * ss}
+	if len(rr.sizes) != len(expected) {
+		t.Fatal("wrong slab resizes during the move:", rr.sizes)
+	}
+	for i := range expected {
+		if rr.sizes[i] != expected[i] {
+			t.Fatal("wrong slab resizes during the move:", rr.sizes)
+		}
+	}
+	for i, root := range roots {
+		data, err := smt.sm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector data changed during the move", i)
+		}
+	}
+}
+
+// blockingFS blocks reads of the files whose path contains a substring until
+// it is released.
+type blockingFS struct {
+	productionDependencies
+	substring string
+	release   chan struct{}
+}
+
+// readFile blocks until the blockingFS is released if the path of the file
+// contains the substring.
+func (bfs blockingFS) readFile(s string) ([]byte, error) {
+	if strings.Contains(s, bfs.substring) {
+		<-bfs.release
+	}
+	return bfs.productionDependencies.readFile(s)
+}
+
+// TestSlabMigrationBackground checks that the storage manager does not wait
+// for sectors stored in files of their own to be moved into the slabs before
+// it is ready, and that such sectors are read from their files until they
+// have moved.
+func TestSlabMigrationBackground(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestSlabMigrationBackground")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folderDir := filepath.Join(smt.sm.persistDir, smt.sm.storageFolders[0].uidString())
+
+	// Add two sectors and move them into files of their own.
+	var roots []crypto.Hash
+	var datas [][]byte
+	for i := 0; i < 2; i++ {
+		root, data, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(root, 10, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.makeLegacy(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		datas = append(datas, data)
+	}
+
+	// Restart the storage manager while reads of the first sector block. The
+	// storage manager should still load, and serve the second sector.
+	err = smt.sm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bfs := blockingFS{
+		substring: string(smt.sm.sectorID(roots[0][:])),
+		release:   make(chan struct{}),
+	}
+	smt.sm, err = newStorageManager(bfs, filepath.Join(smt.persistDir, modules.StorageManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := smt.sm.ReadSector(roots[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, datas[1]) {
+		t.Fatal("sector data changed during the move")
+	}
+	if _, err := os.Stat(filepath.Join(folderDir, string(smt.sm.sectorID(roots[0][:])))); err != nil {
+		t.Fatal("file of a sector was removed before the sector was moved")
+	}
+	smt.sm.mu.RLock()
+	active := smt.sm.legacyActive
+	smt.sm.mu.RUnlock()
+	if !active {
+		t.Fatal("storage manager finished moving sectors while a sector could not be read")
+	}
+
+	// Release the first sector. Both sectors should be moved into the slab.
+	close(bfs.release)
+	err = waitForLegacyMove(smt.sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := ioutil.ReadDir(folderDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != slabFilename {
+		t.Fatal("storage folder should only hold the slab:", infos)
+	}
+	for i, root := range roots {
+		data, err := smt.sm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector data changed during the move", i)
+		}
+	}
+}
+
+// TestShrinkCompactsSlab checks that shrinking a storage folder moves sectors
+// from the end of the slab into free slots, so that the slab can be shrunk.
+func TestShrinkCompactsSlab(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestShrinkCompactsSlab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize * 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := smt.sm.storageFolders[0]
+	slabPath := smt.sm.slabPath(sf.UID)
+	info, err := os.Stat(slabPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(info.Size()) != minimumStorageFolderSize*2 {
+		t.Fatal("slab was not sized to the storage folder:", info.Size())
+	}
+
+	// Fill the storage folder, then remove all but the last four sectors.
+	slots := minimumStorageFolderSize * 2 / modules.SectorSize
+	var roots []crypto.Hash
+	var datas [][]byte
+	for i := uint64(0); i < slots; i++ {
+		root, data, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(root, 10, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		datas = append(datas, data)
+	}
+	for _, root := range roots[:slots-4] {
+		err = smt.sm.RemoveSector(root, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Shrink the storage folder. The remaining sectors fit, so nothing is
+	// offloaded, but they need to be moved to the start of the slab.
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = os.Stat(slabPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(info.Size()) != minimumStorageFolderSize {
+		t.Fatal("slab was not shrunk:", info.Size())
+	}
+	sectors, err := smt.folderSectors(sf.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sectors != 4 {
+		t.Fatal("wrong number of sectors after shrinking:", sectors)
+	}
+	for i := slots - 4; i < slots; i++ {
+		data, err := smt.sm.ReadSector(roots[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector data changed while compacting the slab")
+		}
+	}
+
+	// Grow the storage folder again, which grows the slab.
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = os.Stat(slabPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(info.Size()) != minimumStorageFolderSize*3 {
+		t.Fatal("slab was not grown:", info.Size())
+	}
+}
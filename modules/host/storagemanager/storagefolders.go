@@ -9,14 +9,12 @@ package storagemanager
 // be a power of 2.
 //
 // Though storage folders each contain a bunch of sectors, there is no mapping
-// from a storage folder to the sectors that it contains. Instead, one must
-// either look at the filesystem or go through the sector usage database.
-// There is a mapping from a sector to the storage folder that it is in, so a
-// list of sectors for each storage folder can be obtained, though the
-// operation is expensive. It is not recommended that you try to look at the
-// filesystem to see all of the sectors in a storage folder, because all of the
-// golang implementations that let you do this load the whole directory into
-// memory at once, and these directories may contain millions of sectors.
+// from a storage folder to the sectors that it contains. Instead, one must go
+// through the sector usage database. There is a mapping from a sector to the
+// storage folder and the slot in the slab of that storage folder that it is
+// in, so a list of sectors for each storage folder can be obtained, though the
+// operation is expensive. The slab itself only records which slots are in use
+// through an in-memory bitmap, see slab.go.
 //
 // Strict resource limits are maintained, to make sure that any user behavior
 // that would strain the host will return an error instead of causing the user
@@ -98,6 +96,11 @@ var (
 	// matches will trigger the error.
 	ErrRepeatFolder = errors.New("selected path is already in use as a storage folder, please use 'resize'")
 
+	// errAllocationInProgress is returned if a storage folder is added,
+	// removed, resized or migrated while the slab of a storage folder is being
+	// allocated.
+	errAllocationInProgress = errors.New("disk space for a storage folder is being allocated, try again later")
+
 	// errBadStorageFolderIndex is returned if a storage folder is requested
 	// that does not have the correct index.
 	errBadStorageFolderIndex = errors.New("no storage folder exists at that index")
@@ -128,11 +131,10 @@ var (
 //
 // 'Size' is set by the user, indicating how much data can be placed into that
 // folder before the host should consider it full. Size is measured in bytes,
-// but only accounts for the actual raw data. Sia also places a nontrivial
-// amount of load on the filesystem, potentially to the tune of millions of
-// files. These files have long, cryptographic names and may take up as much as
-// a gigabyte of space in filesystem overhead, depending on how the filesystem
-// is architected. The host is programmed to gracefully handle full disks, so
+// and the slab of the storage folder is sized to match. Sectors stored by
+// older versions of Sia are in files of their own until they are moved into
+// the slab, and these files have some filesystem overhead on top of the raw
+// data. The host is programmed to gracefully handle full disks, so
 // while it might cause the user surprise that the host can't break past 99%
 // utilization, there should not be any issues if the user overestimates how
 // much storage is available in the folder they have offered to Sia. The host
@@ -169,6 +171,13 @@ type storageFolder struct {
 	FailedWrites     uint64
 	SuccessfulReads  uint64
 	SuccessfulWrites uint64
+
+	// slots is a bitmap of the slots in the slab of the storage folder that
+	// hold a sector, and slabSlots is the number of slots in the slab. Both
+	// are rebuilt from the sector usage database when the storage manager is
+	// loaded.
+	slabSlots uint64
+	slots     []uint64
 }
 
 // emptiestStorageFolder takes a set of storage folders and returns the storage
@@ -206,8 +215,10 @@ func emptiestStorageFolder(sfs []*storageFolder) (*storageFolder, int) {
 }
 
 // offloadStorageFolder takes sectors in a storage folder and moves them to
-// another storage folder.
-func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dataToOffload uint64) error {
+// another storage folder. Only sectors in slots at or beyond 'minSlot' are
+// moved, so that a slab can be shrunk after sectors have been offloaded.
+// Sectors that have not been moved into the slab yet are always eligible.
+func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dataToOffload uint64, minSlot uint64) error {
 	// The host is going to check every sector, using a different database tx
 	// for each sector. To be able to track progress, a starting point needs to
 	// be grabbed. This read grabs the starting point.
@@ -228,7 +239,7 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 	// will be pruned. Once all folders are full, the offload loop will quit
 	// and return with ErrIncompleteOffload.
 	availableFolders := make([]*storageFolder, 0)
-	for _, sf := range sm.storageFolders {
+	for _, sf := range sm.placementFolders() {
 		if sf == offloadFolder {
 			// The offload folder is not an available folder.
 			continue
@@ -247,6 +258,7 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 	// after 'dataToOffload' data has been moved from the storage folder.
 	dataOffloaded := uint64(0)
 	for currentSectorID != nil && dataOffloaded < dataToOffload && len(availableFolders) > 0 {
+		var st slotTx
 		err = sm.db.Update(func(tx *bolt.Tx) error {
 			// Defer seeking to the next sector.
 			defer func() {
@@ -261,10 +273,11 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 			if err != nil {
 				return err
 			}
-			if !bytes.Equal(usage.StorageFolder, offloadFolder.UID) {
+			if !bytes.Equal(usage.StorageFolder, offloadFolder.UID) || (usage.HasSlot && usage.Slot < minSlot) {
 				// The current sector is not in the offloading storage folder,
-				// try the next sector. Returning nil will advance to the next
-				// iteration of the loop.
+				// or is in a slot that can stay, try the next sector.
+				// Returning nil will advance to the next iteration of the
+				// loop.
 				return nil
 			}
 
@@ -272,10 +285,10 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 			// be moved to the next folder.
 			success := false
 			emptiestFolder, emptiestIndex := emptiestStorageFolder(availableFolders)
+			var newSlot uint64
 			for emptiestFolder != nil {
-				oldSectorPath := filepath.Join(sm.persistDir, offloadFolder.uidString(), string(currentSectorID))
 				// Try reading the sector from disk.
-				sectorData, err := sm.dependencies.readFile(oldSectorPath)
+				sectorData, err := sm.readSector(currentSectorID, usage)
 				if err != nil {
 					// Inidicate that the storage folder is having read
 					// troubles.
@@ -290,17 +303,14 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 				offloadFolder.SuccessfulReads++
 
 				// Try writing the sector to the emptiest storage folder.
-				newSectorPath := filepath.Join(sm.persistDir, emptiestFolder.uidString(), string(currentSectorID))
-				err = sm.dependencies.writeFile(newSectorPath, sectorData, 0700)
+				newSlot, err = sm.writeSector(emptiestFolder, sectorData)
 				if err != nil {
 					// Indicate that the storage folder is having write
-					// troubles.
-					emptiestFolder.FailedWrites++
-
-					// After the failed write, try removing any garbage that
-					// may have gotten left behind. The error is not checked,
-					// as it is known that the disk is having write troubles.
-					_ = sm.dependencies.removeFile(newSectorPath)
+					// troubles. A failed write only leaves garbage in a free
+					// slot, so there is nothing to clean up.
+					if err != errNoFreeSlot {
+						emptiestFolder.FailedWrites++
+					}
 
 					// Because the write failed, we should move on to the next
 					// storage folder, and remove the current storage folder
@@ -313,13 +323,21 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 				}
 				// Indicate that the storage folder is doing successful writes.
 				emptiestFolder.SuccessfulWrites++
-				err = sm.dependencies.removeFile(oldSectorPath)
-				if err != nil {
-					// Indicate that the storage folder is having write
-					// troubles.
-					offloadFolder.FailedWrites++
+				st.allocate(emptiestFolder, newSlot)
+
+				// Free the old slot of the sector. A sector that had not been
+				// moved into the slab yet has its file removed instead.
+				if usage.HasSlot {
+					st.free(offloadFolder, usage.Slot)
 				} else {
-					offloadFolder.SuccessfulWrites++
+					err = sm.dependencies.removeFile(sm.legacySectorPath(offloadFolder.UID, currentSectorID))
+					if err != nil {
+						// Indicate that the storage folder is having write
+						// troubles.
+						offloadFolder.FailedWrites++
+					} else {
+						offloadFolder.SuccessfulWrites++
+					}
 				}
 
 				success = true
@@ -340,6 +358,8 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 			// required to deal with outlier cases where the swap is fine but
 			// the database update is not.
 			usage.StorageFolder = emptiestFolder.UID
+			usage.HasSlot = true
+			usage.Slot = newSlot
 			newUsageBytes, err := json.Marshal(usage)
 			if err != nil {
 				return err
@@ -352,6 +372,7 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 			// Seek to the next sector.
 			return nil
 		})
+		st.finish(err)
 		if err != nil {
 			return err
 		}
@@ -383,53 +404,37 @@ func (sf *storageFolder) uidString() string {
 	return hex.EncodeToString(sf.UID)
 }
 
-// AddStorageFolder adds a storage folder to the host.
-func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
-	// Lock the host for the duration of the add operation - it is important
-	// that the host not be manipulated while sectors are being moved around.
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	// The resource lock is required as the sector movements require access to
-	// the logger.
-	sm.resourceLock.RLock()
-	defer sm.resourceLock.RUnlock()
-	if sm.closed {
-		return errStorageManagerClosed
-	}
-
+// createStorageFolder creates a storage folder of 'size' bytes at 'path',
+// including its symlink. The slab of the storage folder is left for the caller
+// to allocate, and the storage folder is not added to the list of storage
+// folders of the host.
+func (sm *StorageManager) createStorageFolder(path string, size uint64) (*storageFolder, error) {
 	// Check that the maximum number of allowed storage folders has not been
 	// exceeded.
 	if len(sm.storageFolders) >= maximumStorageFolders {
-		return errMaxStorageFolders
-	}
-	// Check that the storage folder being added meets the size requirements.
-	if size > maximumStorageFolderSize {
-		return ErrLargeStorageFolder
-	}
-	if size < minimumStorageFolderSize {
-		return ErrSmallStorageFolder
+		return nil, errMaxStorageFolders
 	}
 	// Check that the path is an absolute path.
 	if !filepath.IsAbs(path) {
 		if path == "" {
-			return ErrEmptyPath
+			return nil, ErrEmptyPath
 		}
-		return ErrRelativePath
+		return nil, ErrRelativePath
 	}
 	// Check that the folder being linked to is not already in use.
 	for _, sf := range sm.storageFolders {
 		if sf.Path == path {
-			return ErrRepeatFolder
+			return nil, ErrRepeatFolder
 		}
 	}
 
 	// Check that the folder being linked to both exists and is a folder.
 	pathInfo, err := os.Stat(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !pathInfo.Mode().IsDir() {
-		return errStorageFolderNotFolder
+		return nil, errStorageFolderNotFolder
 	}
 
 	// Create a storage folder object.
@@ -446,7 +451,7 @@ func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
 		// Generate an attempt UID for the storage folder.
 		_, err = sm.dependencies.randRead(newSF.UID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// Check for collsions. Check should be relatively inexpensive at all
@@ -468,10 +473,73 @@ func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
 	symPath := filepath.Join(sm.persistDir, newSF.uidString())
 	err = sm.dependencies.symlink(path, symPath)
 	if err != nil {
+		return nil, err
+	}
+	return newSF, nil
+}
+
+// removeStorageFolderFiles removes the slab and the symlink of a storage
+// folder that could not be added to the host.
+func (sm *StorageManager) removeStorageFolderFiles(sf *storageFolder) {
+	_ = sm.dependencies.removeFile(sm.slabPath(sf.UID))
+	_ = sm.dependencies.removeFile(filepath.Join(sm.persistDir, sf.uidString()))
+}
+
+// managedCreateStorageFolder creates a storage folder of 'size' bytes at
+// 'path' and marks the storage manager as allocating, so that the slab of the
+// storage folder can be allocated without holding the lock.
+func (sm *StorageManager) managedCreateStorageFolder(path string, size uint64) (*storageFolder, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return nil, errStorageManagerClosed
+	}
+	if sm.allocating {
+		return nil, errAllocationInProgress
+	}
+
+	// Check that the storage folder being added meets the size requirements.
+	if size > maximumStorageFolderSize {
+		return nil, ErrLargeStorageFolder
+	}
+	if size < minimumStorageFolderSize {
+		return nil, ErrSmallStorageFolder
+	}
+	newSF, err := sm.createStorageFolder(path, size)
+	if err != nil {
+		return nil, err
+	}
+	sm.allocating = true
+	return newSF, nil
+}
+
+// AddStorageFolder adds a storage folder to the host. The slab of the storage
+// folder is allocated without holding the lock, so that the host keeps
+// serving sectors while the disk space is claimed.
+func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
+	newSF, err := sm.managedCreateStorageFolder(path, size)
+	if err != nil {
+		return err
+	}
+	err = sm.allocateSlab(newSF, size/modules.SectorSize)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	sm.allocating = false
+	if err == nil && sm.closed {
+		err = errStorageManagerClosed
+	}
+	if err != nil {
+		sm.removeStorageFolderFiles(newSF)
 		return err
 	}
 
 	// Add the storage folder to the list of folders for the host.
+	newSF.resizeSlots(size / modules.SectorSize)
 	sm.storageFolders = append(sm.storageFolders, newSF)
 	return sm.saveSync()
 }
@@ -515,10 +583,16 @@ func (sm *StorageManager) RemoveStorageFolder(removalIndex int, force bool) erro
 		return errBadStorageFolderIndex
 	}
 	removalFolder := sm.storageFolders[removalIndex]
+	if sm.migratingFolder(removalFolder) {
+		return errMigrationInProgress
+	}
+	if sm.allocating {
+		return errAllocationInProgress
+	}
 
 	// Move all of the sectors in the storage folder to other storage folders.
 	usedSize := removalFolder.Size - removalFolder.SizeRemaining
-	offloadErr := sm.offloadStorageFolder(removalFolder, usedSize)
+	offloadErr := sm.offloadStorageFolder(removalFolder, usedSize, 0)
 	// If 'force' is set, we want to ignore 'ErrIncompleteOffload' and try to
 	// remove the storage folder anyway. For any other error, we want to halt
 	// and return the error.
@@ -529,16 +603,54 @@ func (sm *StorageManager) RemoveStorageFolder(removalIndex int, force bool) erro
 		return offloadErr
 	}
 
-	// Remove the storage folder from the host and then save the host.
+	// Remove the storage folder and its slab from the host and then save the
+	// host. The slab is already gone if the disk holding the storage folder
+	// has been removed.
 	sm.storageFolders = append(sm.storageFolders[0:removalIndex], sm.storageFolders[removalIndex+1:]...)
+	slabErr := sm.dependencies.removeFile(sm.slabPath(removalFolder.UID))
+	if os.IsNotExist(slabErr) {
+		slabErr = nil
+	}
 	removeErr := sm.dependencies.removeFile(filepath.Join(sm.persistDir, removalFolder.uidString()))
 	saveErr := sm.saveSync()
-	return composeErrors(saveErr, removeErr)
+	return composeErrors(saveErr, slabErr, removeErr)
 }
 
 // ResizeStorageFolder changes the amount of disk space that is going to be
-// allocated to a storage folder.
+// allocated to a storage folder. A growing slab is allocated without holding
+// the lock, so that the host keeps serving sectors while the disk space is
+// claimed. The new space is only offered once the slab has grown.
 func (sm *StorageManager) ResizeStorageFolder(storageFolderIndex int, newSize uint64) error {
+	growFolder, err := sm.managedResizeStorageFolder(storageFolderIndex, newSize)
+	if err != nil || growFolder == nil {
+		return err
+	}
+	newSlots := newSize / modules.SectorSize
+	err = sm.allocateSlab(growFolder, newSlots)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	sm.allocating = false
+	if err != nil {
+		growFolder.FailedWrites++
+		return err
+	}
+	if sm.closed {
+		return errStorageManagerClosed
+	}
+	growFolder.resizeSlots(newSlots)
+	growFolder.SizeRemaining += newSize - growFolder.Size
+	growFolder.Size = newSize
+	return sm.saveSync()
+}
+
+// managedResizeStorageFolder shrinks a storage folder, moving sectors as
+// needed. If the storage folder is growing instead, the storage manager is
+// marked as allocating and the storage folder is returned, so that the caller
+// can allocate the slab without holding the lock.
+func (sm *StorageManager) managedResizeStorageFolder(storageFolderIndex int, newSize uint64) (*storageFolder, error) {
 	// Lock the host for the duration of the resize operation - it is important
 	// that the host not be manipulated while sectors are being moved around.
 	sm.mu.Lock()
@@ -548,38 +660,58 @@ func (sm *StorageManager) ResizeStorageFolder(storageFolderIndex int, newSize ui
 	sm.resourceLock.RLock()
 	defer sm.resourceLock.RUnlock()
 	if sm.closed {
-		return errStorageManagerClosed
+		return nil, errStorageManagerClosed
 	}
 
 	// Check that the inputs are valid.
 	if storageFolderIndex >= len(sm.storageFolders) || storageFolderIndex < 0 {
-		return errBadStorageFolderIndex
+		return nil, errBadStorageFolderIndex
 	}
 	resizeFolder := sm.storageFolders[storageFolderIndex]
+	if sm.migratingFolder(resizeFolder) {
+		return nil, errMigrationInProgress
+	}
+	if sm.allocating {
+		return nil, errAllocationInProgress
+	}
 	if newSize > maximumStorageFolderSize {
-		return ErrLargeStorageFolder
+		return nil, ErrLargeStorageFolder
 	}
 	if newSize < minimumStorageFolderSize {
-		return ErrSmallStorageFolder
+		return nil, ErrSmallStorageFolder
 	}
 	if resizeFolder.Size == newSize {
-		return ErrNoResize
+		return nil, ErrNoResize
 	}
 
-	// Sectors do not need to be moved onto or away from the resize folder if
-	// the folder is growing, or if after being shrunk the folder still has
-	// enough storage to house all of the sectors it currently tracks.
+	// A growing folder is handed back to the caller, who grows the slab before
+	// the new space is offered.
+	if newSize > resizeFolder.Size {
+		sm.allocating = true
+		return resizeFolder, nil
+	}
+
+	// Sectors do not need to be moved away from the resize folder if after
+	// being shrunk the folder still has enough storage to house all of the
+	// sectors it currently tracks. Sectors are moved within the slab before it
+	// is shrunk.
+	newSlots := newSize / modules.SectorSize
 	resizeFolderSizeConsumed := resizeFolder.Size - resizeFolder.SizeRemaining
 	if resizeFolderSizeConsumed <= newSize {
+		err := sm.shrinkSlab(resizeFolder, newSlots)
+		if err != nil {
+			return nil, err
+		}
 		resizeFolder.SizeRemaining = newSize - resizeFolderSizeConsumed
 		resizeFolder.Size = newSize
-		return sm.saveSync()
+		return nil, sm.saveSync()
 	}
 
 	// Calculate the number of sectors that need to be offloaded from the
-	// storage folder.
+	// storage folder. Sectors are offloaded from the slots that are cut off
+	// the end of the slab.
 	offloadSize := resizeFolderSizeConsumed - newSize
-	offloadErr := sm.offloadStorageFolder(resizeFolder, offloadSize)
+	offloadErr := sm.offloadStorageFolder(resizeFolder, offloadSize, newSlots)
 	if offloadErr == ErrIncompleteOffload {
 		// Offloading has not fully succeeded, but may have partially
 		// succeeded. To prevent new sectors from being added to the storage
@@ -587,13 +719,21 @@ func (sm *StorageManager) ResizeStorageFolder(storageFolderIndex int, newSize ui
 		// of storage in use.
 		resizeFolder.Size -= resizeFolder.SizeRemaining
 		resizeFolder.SizeRemaining = 0
-		return offloadErr
+		err := sm.shrinkSlab(resizeFolder, resizeFolder.Size/modules.SectorSize)
+		if err != nil {
+			return nil, err
+		}
+		return nil, offloadErr
 	} else if offloadErr != nil {
-		return offloadErr
+		return nil, offloadErr
 	}
 	resizeFolder.Size = newSize
 	resizeFolder.SizeRemaining = 0
-	return sm.saveSync()
+	err := sm.shrinkSlab(resizeFolder, newSlots)
+	if err != nil {
+		return nil, err
+	}
+	return nil, sm.saveSync()
 }
 
 // StorageFolders provides information about all of the storage folders in the
@@ -33,6 +33,18 @@ func (ffs faultyFS) readFile(s string) ([]byte, error) {
 	return ffs.productionDependencies.readFile(s)
 }
 
+// readFileAt reads part of a file from the filesystem. The call will fail if
+// reading from a file that has a substring which matches the ffs list of
+// broken substrings.
+func (ffs faultyFS) readFileAt(s string, b []byte, off int64) error {
+	for _, bs := range ffs.brokenSubstrings {
+		if strings.Contains(s, bs) {
+			return mockErrReadFile
+		}
+	}
+	return ffs.productionDependencies.readFileAt(s, b, off)
+}
+
 // symlink creates a symlink between a source and a destination file, but will
 // fail if either filename contains a substring found in the set of broken
 // substrings.
@@ -71,15 +83,29 @@ func (ffs faultyFS) writeFile(s string, b []byte, fm os.FileMode) error {
 	return ioutil.WriteFile(s, b, fm)
 }
 
-// faultyRemove is a mocked set of dependencies that operates as normal except
-// that removeFile will fail.
-type faultyRemove struct {
-	productionDependencies
-}
+// writeFileAt writes part of a file to the filesystem. The call will fail if
+// writing to a file that has a substring which matches the ffs list of broken
+// substrings.
+func (ffs faultyFS) writeFileAt(s string, b []byte, off int64) error {
+	// The partial write reqires that there be at least a few bytes, so that a
+	// partial write can be properly simulated.
+	if len(b) < 2 {
+		panic("mocked writeFileAt requires file data that's at least 2 bytes in length")
+	}
 
-// removeFile fails to remove a file from the filesystem.
-func (faultyRemove) removeFile(s string) error {
-	return mockErrRemoveFile
+	for _, bs := range ffs.brokenSubstrings {
+		if strings.Contains(s, bs) {
+			// Do a partial write, so that garbage is left in the file.
+			err := ffs.productionDependencies.writeFileAt(s, b[:len(b)/2], off)
+			if err != nil {
+				return err
+			}
+
+			// Return a simulated failure, as the full slice was not written.
+			return mockErrWriteFile
+		}
+	}
+	return ffs.productionDependencies.writeFileAt(s, b, off)
 }
 
 // TestStorageFolderTolerance tests the tolerance of storage folders in the
@@ -143,19 +169,19 @@ func TestStorageFolderTolerance(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check the filesystem - there should be one sector in the storage folder.
-	infos, err := ioutil.ReadDir(storageFolderOne)
+	sectors, err := smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 1 {
+	if sectors != 1 {
 		t.Fatal("expecting at least one sector in storage folder one")
 	}
 
-	// Replace the storage manager dependencies with the faulty remove, and
-	// then try to remove the sector.
-	smt.sm.dependencies = faultyRemove{}
-	err = smt.sm.RemoveSector(sectorRoot, 10)
-	if err != mockErrRemoveFile {
+	// Break writes to the storage folder, and then try to delete the sector,
+	// which needs to overwrite the slot of the sector.
+	ffs.brokenSubstrings = []string{filepath.Join(smt.persistDir, modules.StorageManagerDir, smt.sm.storageFolders[0].uidString())}
+	err = smt.sm.DeleteSector(sectorRoot)
+	if err != mockErrWriteFile {
 		t.Fatal(err)
 	}
 	// Check that the failed write count was incremented for the storage
@@ -164,15 +190,14 @@ func TestStorageFolderTolerance(t *testing.T) {
 		t.Fatal("failed writes counter is not incrementing properly")
 	}
 	// Check the filesystem - sector should still be in the storage folder.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	sectors, err = smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 1 {
+	if sectors != 1 {
 		t.Fatal("expecting at least one sector in storage folder one")
 	}
-	// Put 'ffs' back as the set of dependencies.
-	smt.sm.dependencies = ffs
+	ffs.brokenSubstrings = nil
 
 	// Add a second storage folder, which can receive the sector when the first
 	// storage folder is deleted.
@@ -209,18 +234,18 @@ func TestStorageFolderTolerance(t *testing.T) {
 	}
 	// Check the filesystem - there should be one sector in the storage folder,
 	// and none in storage folder two.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	sectors, err = smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 1 {
+	if sectors != 1 {
 		t.Fatal("expecting at least one sector in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 0 {
+	if sectors != 0 {
 		t.Fatal("expecting zero sectors in storage folder two")
 	}
 
@@ -247,18 +272,18 @@ func TestStorageFolderTolerance(t *testing.T) {
 	}
 	// Check the filesystem - there should be one sector in the storage folder,
 	// and none in storage folder two.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	sectors, err = smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 1 {
+	if sectors != 1 {
 		t.Fatal("expecting at least one sector in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 0 {
+	if sectors != 0 {
 		t.Fatal("expecting zero sectors in storage folder two")
 	}
 
@@ -284,11 +309,11 @@ func TestStorageFolderTolerance(t *testing.T) {
 		t.Fatal("storage folder was not removed correctly")
 	}
 	// Check the filesystem - there should be no sectors in storage folder two.
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 0 {
+	if sectors != 0 {
 		t.Fatal("expecting zero sectors in storage folder two")
 	}
 
@@ -335,18 +360,18 @@ func TestStorageFolderTolerance(t *testing.T) {
 	}
 	// Check the filesystem - storage folder one is having disk issues and
 	// should have no sectors. Storage folder two should be full.
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	sectors, err = smt.folderSectors(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 0 {
+	if sectors != 0 {
 		t.Fatal("expecting zero sectors in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != int(numSectors) {
+	if sectors != int(numSectors) {
 		t.Fatal("expecting", numSectors, "sectors in storage folder two")
 	}
 	// Try adding another sector, there should be an error because the one disk
@@ -367,18 +392,18 @@ func TestStorageFolderTolerance(t *testing.T) {
 	}
 	// Check the filesystem - storage folder one is having disk issues and
 	// should have no sectors. Storage folder two should be full.
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	sectors, err = smt.folderSectors(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 0 {
+	if sectors != 0 {
 		t.Fatal("expecting zero sectors in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != int(numSectors) {
+	if sectors != int(numSectors) {
 		t.Fatal("expecting", numSectors, "sectors in storage folder two")
 	}
 
@@ -413,25 +438,25 @@ func TestStorageFolderTolerance(t *testing.T) {
 	// Check the filesystem - storageFolderTwo should have
 	// minimumStorageFolderSize*2 worth of sectors, and storageFolderFour
 	// should have minimumStorageFolderSize worth of sectors.
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	sectors, err = smt.folderSectors(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 0 {
+	if sectors != 0 {
 		t.Fatal("expecting zero sectors in storage folder three")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != int(numSectors)-int(minimumStorageFolderSize/modules.SectorSize) {
+	if sectors != int(numSectors)-int(minimumStorageFolderSize/modules.SectorSize) {
 		t.Fatal("expecting", numSectors, "sectors in storage folder two")
 	}
-	infos, err = ioutil.ReadDir(storageFolderFour)
+	sectors, err = smt.folderSectors(storageFolderFour)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != int(minimumStorageFolderSize/modules.SectorSize) {
+	if sectors != int(minimumStorageFolderSize/modules.SectorSize) {
 		t.Fatal("expecting to have 8 sectors in storageFolderFour")
 	}
 
@@ -463,25 +488,25 @@ func TestStorageFolderTolerance(t *testing.T) {
 	// Check the filesystem - there should be one less sector in
 	// storageFolderTwo from the previous check, and one more sector in
 	// storageFolderFour.
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	sectors, err = smt.folderSectors(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 0 {
+	if sectors != 0 {
 		t.Fatal("expecting zero sectors in storage folder three")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != int(numSectors)-int(minimumStorageFolderSize/modules.SectorSize)-1 {
+	if sectors != int(numSectors)-int(minimumStorageFolderSize/modules.SectorSize)-1 {
 		t.Fatal("expecting", numSectors, "sectors in storage folder two")
 	}
-	infos, err = ioutil.ReadDir(storageFolderFour)
+	sectors, err = smt.folderSectors(storageFolderFour)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != int(minimumStorageFolderSize/modules.SectorSize)+1 {
+	if sectors != int(minimumStorageFolderSize/modules.SectorSize)+1 {
 		t.Fatal("filesystem consistency error")
 	}
 }
@@ -221,20 +221,24 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Error("manager capacity has not been correctly updated after adding a sector", totalStorage, remainingStorage)
 	}
 	// Check that the sector has been added to the filesystem correctly - the
-	// file should exist in storageFolderOne, and the data in the file should
-	// match the data of the sector.
-	sectorPath := filepath.Join(storageFolderOne, string(smt.sm.sectorID(sectorRoot[:])))
+	// sector should be in the first slot of the slab in storageFolderOne, and
+	// the data in the slot should match the data of the sector.
+	slabPath := filepath.Join(storageFolderOne, slabFilename)
 	err = func() error {
-		sectorFile, err := os.Open(sectorPath)
-		defer sectorFile.Close()
-		fileInfo, err := sectorFile.Stat()
+		slabFile, err := os.Open(slabPath)
 		if err != nil {
 			return err
 		}
-		if uint64(fileInfo.Size()) != modules.SectorSize {
-			return errors.New("scanned sector is not the right size")
+		defer slabFile.Close()
+		fileInfo, err := slabFile.Stat()
+		if err != nil {
+			return err
+		}
+		if uint64(fileInfo.Size()) != minimumStorageFolderSize {
+			return errors.New("slab is not the size of the storage folder")
 		}
-		readSectorData, err := ioutil.ReadAll(sectorFile)
+		readSectorData := make([]byte, modules.SectorSize)
+		_, err = slabFile.ReadAt(readSectorData, 0)
 		if err != nil {
 			return err
 		}
@@ -401,18 +405,18 @@ func TestStorageFolderUsage(t *testing.T) {
 	}
 	// Check the filesystem. The folder for storage folder 1 should have 10
 	// files, and the folder for storage folder 2 should have 1 file.
-	infos, err := ioutil.ReadDir(storageFolderOne)
+	sectors, err := smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 10 {
+	if sectors != 10 {
 		t.Fatal("storage folder one should have 10 sectors in it")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 1 {
+	if sectors != 1 {
 		t.Fatal("storage folder two should have 1 sector in it")
 	}
 
@@ -435,15 +439,15 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Error("total storage was not adjusted correctly after removing a storage folder")
 	}
 	// Check the filesystem.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	sectors, err = smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 8 {
+	if sectors != 8 {
 		t.Fatal("wrong number of sectors in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
-	if len(infos) != 3 {
+	sectors, err = smt.folderSectors(storageFolderTwo)
+	if sectors != 3 {
 		t.Fatal("wrong number of sectors in storage folder two")
 	}
 
@@ -466,11 +470,11 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Error("total storage was not adjusted after removing a storage folder")
 	}
 	// Check that the filesystem seems correct.
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 11 {
+	if sectors != 11 {
 		t.Fatal("wrong number of sectors in folder")
 	}
 	_, err = os.Stat(symPath)
@@ -496,18 +500,18 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check the filesystem.
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 8 {
+	if sectors != 8 {
 		t.Fatal("wrong number of sectors")
 	}
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	sectors, err = smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 3 {
+	if sectors != 3 {
 		t.Fatal("wrong number of sectors")
 	}
 
@@ -570,18 +574,18 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check the filesystem.
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 16 {
+	if sectors != 16 {
 		t.Fatal("there should be 16 sectors in storage folder two")
 	}
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	sectors, err = smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 5 {
+	if sectors != 5 {
 		t.Fatal("there should be 5 sectors in storage folder one")
 	}
 	// Try removing a non-repeat sector.
@@ -601,7 +605,6 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Fatal("wrong error when removing illegal sector:", err)
 	}
 	// Now try the legal sector removal.
-	sectorPath = filepath.Join(storageFolderOne, string(smt.sm.sectorID(sectorRoot[:])))
 	err = smt.sm.RemoveSector(sectorRoot, 81)
 	if err != nil {
 		t.Fatal(err)
@@ -625,21 +628,16 @@ func TestStorageFolderUsage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Check that the sector on-disk has been deleted.
-	_, err = os.Stat(sectorPath)
-	if !os.IsNotExist(err) {
-		t.Fatal(err)
-	}
 	// Check that the total number of sectors seen on disk is 20.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	sectors, err = smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	infos2, err := ioutil.ReadDir(storageFolderTwo)
+	sectors2, err := smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos)+len(infos2) != 20 {
+	if sectors+sectors2 != 20 {
 		t.Fatal("there should be 20 sectors total on disk at this point")
 	}
 
@@ -687,11 +685,11 @@ func TestStorageFolderUsage(t *testing.T) {
 
 			// Check that the filesystem is housing the correct number of
 			// sectors.
-			infos, err = ioutil.ReadDir(storageFolderOne)
+			sectors, err = smt.folderSectors(storageFolderOne)
 			if err != nil {
 				t.Fatal(err)
 			}
-			infos2, err = ioutil.ReadDir(storageFolderTwo)
+			sectors2, err = smt.folderSectors(storageFolderTwo)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -702,7 +700,7 @@ func TestStorageFolderUsage(t *testing.T) {
 				// subtract it from the expected total number of sectors.
 				bonus++
 			}
-			if len(infos)+len(infos2) != 20-i-bonus {
+			if sectors+sectors2 != 20-i-bonus {
 				t.Fatal("sector count is incorrect while managing virtual sectors")
 			}
 
@@ -787,25 +785,25 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check the filesystem.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	sectors, err = smt.folderSectors(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 8 {
+	if sectors != 8 {
 		t.Fatal("expecting 8 sectors in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	sectors, err = smt.folderSectors(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 24 {
+	if sectors != 24 {
 		t.Fatal("expecting 24 sectors in storage folder two")
 	}
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	sectors, err = smt.folderSectors(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(infos) != 16 {
+	if sectors != 16 {
 		t.Fatal("expecting 16 sectors in storage folder three")
 	}
 
@@ -862,15 +860,15 @@ func TestStorageFolderUsage(t *testing.T) {
 
 			// Check that the filesystem is housing the correct number of
 			// sectors.
-			infos, err := ioutil.ReadDir(storageFolderOne)
+			sectors, err := smt.folderSectors(storageFolderOne)
 			if err != nil {
 				t.Fatal(err)
 			}
-			infos2, err := ioutil.ReadDir(storageFolderTwo)
+			sectors2, err := smt.folderSectors(storageFolderTwo)
 			if err != nil {
 				t.Fatal(err)
 			}
-			infos3, err := ioutil.ReadDir(storageFolderThree)
+			sectors3, err := smt.folderSectors(storageFolderThree)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -881,8 +879,8 @@ func TestStorageFolderUsage(t *testing.T) {
 				// subtract it from the expected total number of sectors.
 				bonus++
 			}
-			if len(infos)+len(infos2)+len(infos3) != 48-i-bonus {
-				t.Error(len(infos)+len(infos2)+len(infos3), i, bonus)
+			if sectors+sectors2+sectors3 != 48-i-bonus {
+				t.Error(sectors+sectors2+sectors3, i, bonus)
 				t.Fatal("sector count is incorrect while managing virtual sectors")
 			}
 		}
@@ -917,7 +915,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	// Check the filesystem, there should be 3 files in the manager folder
 	// (storagemanager.db, storagemanager.json, storagemanager.log).
 	// NOTE: on Windows, a lock file for the db will also be present.
-	infos, err = ioutil.ReadDir(smt.sm.persistDir)
+	infos, err := ioutil.ReadDir(smt.sm.persistDir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -925,3 +923,66 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Error("unexpected number of files in the manager directory")
 	}
 }
+
+// TestAllocationInProgress checks that storage folders cannot be added,
+// removed, resized or migrated while the slab of a storage folder is being
+// allocated.
+func TestAllocationInProgress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestAllocationInProgress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	smt.sm.mu.Lock()
+	smt.sm.allocating = true
+	smt.sm.mu.Unlock()
+	err = smt.addRandFolder(minimumStorageFolderSize)
+	if err != errAllocationInProgress {
+		t.Error("expected errAllocationInProgress when adding a folder, got", err)
+	}
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*2)
+	if err != errAllocationInProgress {
+		t.Error("expected errAllocationInProgress when resizing a folder, got", err)
+	}
+	err = smt.sm.MigrateStorageFolder(0, filepath.Join(smt.persistDir, "migrate"), 0)
+	if err != errAllocationInProgress {
+		t.Error("expected errAllocationInProgress when migrating a folder, got", err)
+	}
+	err = smt.sm.RemoveStorageFolder(0, false)
+	if err != errAllocationInProgress {
+		t.Error("expected errAllocationInProgress when removing a folder, got", err)
+	}
+
+	// Once the allocation has finished, the folder can be grown, and the new
+	// space is offered.
+	smt.sm.mu.Lock()
+	smt.sm.allocating = false
+	smt.sm.mu.Unlock()
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := smt.sm.storageFolders[0]
+	if sf.Size != minimumStorageFolderSize*2 || sf.SizeRemaining != minimumStorageFolderSize*2 || sf.slabSlots != sf.Size/modules.SectorSize {
+		t.Fatal("storage folder was not grown:", sf.Size, sf.SizeRemaining, sf.slabSlots)
+	}
+	info, err := os.Stat(smt.sm.slabPath(sf.UID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(info.Size()) != sf.Size {
+		t.Fatal("slab was not grown:", info.Size())
+	}
+	if smt.sm.allocating {
+		t.Fatal("storage manager is still allocating")
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"errors"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 )
 
@@ -50,6 +52,38 @@ type StorageManager struct {
 	sectorSalt     crypto.Hash
 	storageFolders []*storageFolder
 
+	// allocating is set while the slab of a storage folder is being allocated,
+	// which is done without holding the lock because it can take a long time.
+	// Storage folders cannot be added, removed, resized or migrated until the
+	// allocation has finished.
+	allocating bool
+
+	// Sector scrubbing. The scrubber is woken when its settings change, and
+	// stopped when the storage manager is closed.
+	scrubCursor    []byte
+	scrubPeriod    time.Duration
+	scrubRateLimit uint64
+	scrubStatus    modules.StorageScrubStatus
+	scrubStop      chan struct{}
+	scrubWake      chan struct{}
+
+	// Storage folder migration. The migration thread is woken when a
+	// migration starts, and stopped when the storage manager is closed.
+	migration       *storageFolderMigration
+	migrationCursor []byte
+	migrationStatus modules.StorageFolderMigrationStatus
+	migrateStop     chan struct{}
+	migrateWake     chan struct{}
+
+	// Sectors stored in their own files by older versions of the storage
+	// manager are moved into the slabs by a background thread, which is
+	// stopped when the storage manager is closed. The thread is active until
+	// it has been through every sector once.
+	legacyActive bool
+	legacyCursor []byte
+	legacyMoved  int
+	legacyStop   chan struct{}
+
 	// Utilities.
 	db         *persist.BoltDatabase
 	log        *persist.Logger
@@ -79,6 +113,9 @@ func (sm *StorageManager) Close() (composedError error) {
 	if closed {
 		return nil
 	}
+	close(sm.scrubStop)
+	close(sm.migrateStop)
+	close(sm.legacyStop)
 
 	// Close the bolt database.
 	err := sm.db.Close()
@@ -109,6 +146,14 @@ func newStorageManager(dependencies dependencies, persistDir string) (*StorageMa
 		dependencies: dependencies,
 
 		persistDir: persistDir,
+
+		scrubStop: make(chan struct{}),
+		scrubWake: make(chan struct{}, 1),
+
+		migrateStop: make(chan struct{}),
+		migrateWake: make(chan struct{}, 1),
+
+		legacyStop: make(chan struct{}),
 	}
 
 	// Create the perist directory if it does not yet exist.
@@ -150,6 +195,22 @@ func newStorageManager(dependencies dependencies, persistDir string) (*StorageMa
 		_ = sm.db.Close()
 		return nil, err
 	}
+
+	// Rebuild the slot bitmaps of the storage folders. Any sectors stored by
+	// older versions of the storage manager are moved into the slabs in the
+	// background.
+	err = sm.loadSlabs()
+	if err != nil {
+		_ = sm.log.Close()
+		_ = sm.db.Close()
+		return nil, err
+	}
+
+	go sm.threadedScrub()
+	go sm.threadedMigrate()
+	if sm.legacyActive {
+		go sm.threadedMoveLegacySectors()
+	}
 	return sm, nil
 }
 
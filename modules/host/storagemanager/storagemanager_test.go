@@ -1,6 +1,10 @@
 package storagemanager
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -8,6 +12,8 @@ import (
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/bolt"
 )
 
 // storageManagerTester holds a testing-initialized storage manager and any
@@ -53,6 +59,99 @@ func createSector() (sectorRoot crypto.Hash, sectorData []byte, err error) {
 	return sectorRoot, sectorData, nil
 }
 
+// folderSectors returns the number of sectors stored in the storage folder at
+// the given path. It also checks that the slot bitmap of the storage folder
+// agrees with the sector usage database, and that the slab on disk is large
+// enough to hold every slot. Zero is returned if no storage folder uses the
+// path.
+func (smt *storageManagerTester) folderSectors(path string) (int, error) {
+	var sf *storageFolder
+	for _, folder := range smt.sm.storageFolders {
+		if folder.Path == path {
+			sf = folder
+		}
+	}
+	if sf == nil {
+		return 0, nil
+	}
+
+	var sectors, slotted int
+	err := smt.sm.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSectorUsage).ForEach(func(_, usageBytes []byte) error {
+			var usage sectorUsage
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(usage.StorageFolder, sf.UID) {
+				return nil
+			}
+			sectors++
+			if usage.HasSlot {
+				slotted++
+				if !sf.slotUsed(usage.Slot) {
+					return errors.New("sector is in a slot that is marked as free")
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	var used int
+	for slot := uint64(0); slot < sf.slabSlots; slot++ {
+		if sf.slotUsed(slot) {
+			used++
+		}
+	}
+	if used != slotted {
+		return 0, errors.New("slot bitmap does not match the sector usage database")
+	}
+	info, err := os.Stat(filepath.Join(path, slabFilename))
+	if err != nil {
+		return 0, err
+	}
+	if uint64(info.Size()) < sf.slabSlots*modules.SectorSize {
+		return 0, errors.New("slab is smaller than its number of slots")
+	}
+	return sectors, nil
+}
+
+// makeLegacy moves a sector out of the slab of its storage folder and into a
+// file of its own, the way that older versions of the storage manager stored
+// sectors.
+func (smt *storageManagerTester) makeLegacy(root crypto.Hash) error {
+	sm := smt.sm
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.db.Update(func(tx *bolt.Tx) error {
+		bsu := tx.Bucket(bucketSectorUsage)
+		sectorKey := sm.sectorID(root[:])
+		var usage sectorUsage
+		err := json.Unmarshal(bsu.Get(sectorKey), &usage)
+		if err != nil {
+			return err
+		}
+		data, err := sm.readSector(sectorKey, usage)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(sm.legacySectorPath(usage.StorageFolder, sectorKey), data, 0700)
+		if err != nil {
+			return err
+		}
+		sm.storageFolder(usage.StorageFolder).clearSlot(usage.Slot)
+		usage.HasSlot = false
+		usage.Slot = 0
+		usageBytes, err := json.Marshal(usage)
+		if err != nil {
+			return err
+		}
+		return bsu.Put(sectorKey, usageBytes)
+	})
+}
+
 // newStorageManagerTester creates a storage tester ready for use.
 func newStorageManagerTester(name string) (*storageManagerTester, error) {
 	testdir := build.TempDir(modules.StorageManagerDir, name)
@@ -32,6 +32,7 @@ import (
 	"encoding/json"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -106,6 +107,21 @@ var (
 
 type storageObligationStatus uint64
 
+// String returns the name of the status, as reported by the host API.
+func (sos storageObligationStatus) String() string {
+	switch sos {
+	case obligationUnresolved:
+		return "unresolved"
+	case obligationRejected:
+		return "rejected"
+	case obligationSucceeded:
+		return "succeeded"
+	case obligationFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
 // storageObligation contains all of the metadata related to a file contract
 // and the storage contained by the file contract.
 type storageObligation struct {
@@ -128,6 +144,11 @@ type storageObligation struct {
 	RiskedCollateral         types.Currency
 	TransactionFeesAdded     types.Currency
 
+	// NegotiationHeight is the height at which the file contract was
+	// negotiated. It is zero for obligations created by older versions of the
+	// host.
+	NegotiationHeight types.BlockHeight
+
 	OriginTransactionSet   []types.Transaction
 	RevisionTransactionSet []types.Transaction
 
@@ -137,6 +158,15 @@ type storageObligation struct {
 	RevisionConfirmed bool
 	ProofConfirmed    bool
 	ObligationStatus  storageObligationStatus
+
+	// Variables tracking the attempts to submit a storage proof. ProofFee is
+	// the fee paid by the most recent proof that was accepted by the
+	// transaction pool.
+	ProofStatus   storageProofStatus
+	ProofAttempts uint64
+	ProofHeight   types.BlockHeight
+	ProofFee      types.Currency
+	ProofError    string
 }
 
 // getStorageObligation fetches a storage obligation from the database tx.
@@ -373,6 +403,7 @@ func (h *Host) addStorageObligation(so storageObligation) error {
 	h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
 	h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Add(so.RiskedCollateral)
 	h.financialMetrics.TransactionFeeExpenses = h.financialMetrics.TransactionFeeExpenses.Add(so.TransactionFeesAdded)
+	h.addRenterUsage(so)
 
 	// Set an action item that will have the host verify that the file contract
 	// has been submitted to the blockchain, then another to submit the file
@@ -508,6 +539,13 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 	h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
 	h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Add(so.RiskedCollateral)
 	h.financialMetrics.TransactionFeeExpenses = h.financialMetrics.TransactionFeeExpenses.Add(so.TransactionFeesAdded)
+
+	// Update the usage of the renter. Unlike the financial metrics, the usage
+	// is read by other connections, so it is updated under lock.
+	h.mu.Lock()
+	h.subtractRenterUsage(oldSO)
+	h.addRenterUsage(so)
+	h.mu.Unlock()
 	return nil
 }
 
@@ -573,9 +611,16 @@ func (h *Host) removeStorageObligation(so storageObligation, sos storageObligati
 	// ended up, and the sector roots are removed because they are large
 	// objects with little purpose once storage proofs are no longer needed.
 	h.financialMetrics.ContractCount--
+	h.subtractRenterUsage(so)
 	so.ObligationStatus = sos
+	h.addRenterFailure(so)
+	entry := so.ledgerEntry(h.blockHeight, time.Now())
 	so.SectorRoots = nil
 	return h.db.Update(func(tx *bolt.Tx) error {
+		err := putLedgerEntry(tx, entry)
+		if err != nil {
+			return err
+		}
 		return putStorageObligation(tx, so)
 	})
 }
@@ -591,6 +636,8 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 	defer func() {
 		h.managedUnlockStorageObligation(soid)
 	}()
+	h.managedBeginOperation()
+	defer h.managedEndOperation()
 
 	// Convert the storage obligation id into a storage obligation.
 	var err error
@@ -728,7 +775,12 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 		// If the window has closed, the host has failed and the obligation can
 		// be removed.
 		if so.proofDeadline() < blockHeight || len(so.SectorRoots) == 0 {
-			h.log.Debugln("storage proof not confirmed by deadline, id", so.id())
+			if len(so.SectorRoots) > 0 {
+				h.log.Printf("WARN: storage proof for contract %v was not confirmed before the proof window closed at height %v", so.id(), so.proofDeadline())
+				so.ProofStatus = proofFailed
+			} else {
+				h.log.Debugln("storage proof not confirmed by deadline, id", so.id())
+			}
 			h.mu.Lock()
 			err := h.removeStorageObligation(so, obligationFailed)
 			h.mu.Unlock()
@@ -738,75 +790,33 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 			return
 		}
 
-		// Get the index of the segment, and the index of the sector containing
-		// the segment.
-		segmentIndex, err := h.cs.StorageProofSegment(so.id())
-		if err != nil {
-			h.log.Debugln("Host got an error when fetching a storage proof segment:", err)
-			return
-		}
-		sectorIndex := segmentIndex / (modules.SectorSize / crypto.SegmentSize)
-		// Pull the corresponding sector into memory.
-		sectorRoot := so.SectorRoots[sectorIndex]
-		sectorBytes, err := h.ReadSector(sectorRoot)
-		if err != nil {
-			h.log.Debugln(err)
-			return
-		}
-
-		// Build the storage proof for just the sector.
-		sectorSegment := segmentIndex % (modules.SectorSize / crypto.SegmentSize)
-		base, cachedHashSet := crypto.MerkleProof(sectorBytes, sectorSegment)
-
-		// Using the sector, build a cached root.
-		log2SectorSize := uint64(0)
-		for 1<<log2SectorSize < (modules.SectorSize / crypto.SegmentSize) {
-			log2SectorSize++
-		}
-		ct := crypto.NewCachedTree(log2SectorSize)
-		ct.SetIndex(segmentIndex)
-		for _, root := range so.SectorRoots {
-			ct.Push(root)
+		// Warn the operator when the proof window is about to close, because
+		// a missed proof costs the host its collateral.
+		if so.proofDeadline() <= blockHeight+storageProofWarningBuffer {
+			h.log.Printf("WARN: storage proof for contract %v is not confirmed, %v blocks before the proof window closes (status: %v)", so.id(), so.proofDeadline()-blockHeight, so.ProofStatus)
 		}
-		hashSet := ct.Prove(base, cachedHashSet)
-		sp := types.StorageProof{
-			ParentID: so.id(),
-			HashSet:  hashSet,
+		// A proof that was broadcast recently is given time to be confirmed
+		// before checking whether it needs to be resubmitted.
+		if so.ProofStatus != proofBroadcast || blockHeight >= so.ProofHeight+resubmissionTimeout {
+			h.submitStorageProof(&so, blockHeight)
 		}
-		copy(sp.Segment[:], base)
 
-		// Create and build the transaction with the storage proof.
-		builder := h.wallet.StartTransaction()
-		_, feeRecommendation := h.tpool.FeeEstimation()
-		if so.value().Cmp(feeRecommendation) < 0 {
-			// There's no sense submitting the storage proof if the fee is more
-			// than the anticipated revenue.
-			h.log.Debugln("Host not submitting storage proof due to a value that does not sufficiently exceed the fee cost")
-			return
-		}
-		txnSize := uint64(len(encoding.Marshal(sp)) + 300)
-		requiredFee := feeRecommendation.Mul64(txnSize)
-		err = builder.FundSiacoins(requiredFee)
-		if err != nil {
-			h.log.Println("Host error when funding a storage proof transaction fee:", err)
-			return
-		}
-		builder.AddMinerFee(requiredFee)
-		builder.AddStorageProof(sp)
-		storageProofSet, err := builder.Sign(true)
-		if err != nil {
-			h.log.Println("Host error when signing the storage proof transaction:", err)
-			return
+		// Queue another action item to check whether the storage proof got
+		// confirmed, resubmitting it if it did not. The final check is made
+		// after the proof window has closed.
+		next := blockHeight + resubmissionTimeout
+		if next > so.proofDeadline() {
+			next = so.proofDeadline() + 1
 		}
-		err = h.tpool.AcceptTransactionSet(storageProofSet)
+		h.mu.Lock()
+		err = h.queueActionItem(next, so.id())
+		h.mu.Unlock()
 		if err != nil {
-			h.log.Println("Host unable to submit storage proof transaction to transaction pool:", err)
-			return
+			h.log.Println("Error queuing action item:", err)
 		}
-		so.TransactionFeesAdded = so.TransactionFeesAdded.Add(requiredFee)
-
-		// Queue another action item to check whether there the storage proof
-		// got confirmed.
+	} else if so.ProofConfirmed && blockHeight < so.proofDeadline() {
+		// The storage proof was confirmed early, check on the obligation
+		// again at the end of the proof window.
 		h.mu.Lock()
 		err = h.queueActionItem(so.proofDeadline(), so.id())
 		h.mu.Unlock()
@@ -815,8 +825,16 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 		}
 	}
 
-	// Save the storage obligation to account for any fee changes.
+	// Save the storage obligation to account for any fee changes. The
+	// confirmation flags are set by the consensus updates, which may have
+	// confirmed a transaction while the action item was being handled.
 	err = h.db.Update(func(tx *bolt.Tx) error {
+		current, err := getStorageObligation(tx, soid)
+		if err == nil {
+			so.OriginConfirmed = current.OriginConfirmed
+			so.RevisionConfirmed = current.RevisionConfirmed
+			so.ProofConfirmed = current.ProofConfirmed
+		}
 		soBytes, err := json.Marshal(so)
 		if err != nil {
 			return err
@@ -0,0 +1,262 @@
+package host
+
+// storageproofs.go builds and submits the storage proofs of the host, and lets
+// the host operator follow them. A missed storage proof costs the host its
+// collateral, so the host keeps track of every attempt, resubmits proofs that
+// were dropped from the transaction pool before being confirmed, and warns the
+// operator when the end of a proof window is close.
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	proofPending   storageProofStatus = iota // Indicates that no storage proof has been attempted yet.
+	proofBuilt                               // Indicates that a storage proof was built, but was not accepted by the transaction pool.
+	proofBroadcast                           // Indicates that a storage proof is in the transaction pool, waiting to be confirmed.
+	proofConfirmed                           // Indicates that a storage proof was confirmed on the blockchain.
+	proofFailed                              // Indicates that a storage proof could not be built, or was not confirmed before the window closed.
+)
+
+var (
+	// errProofFeeTooHigh is recorded when the fee of a storage proof is more
+	// than the value of the storage obligation.
+	errProofFeeTooHigh = errors.New("storage proof fee is higher than the value of the contract")
+)
+
+type storageProofStatus uint64
+
+// String returns the name of the status, as reported by the host API.
+func (sps storageProofStatus) String() string {
+	switch sps {
+	case proofPending:
+		return "pending"
+	case proofBuilt:
+		return "built"
+	case proofBroadcast:
+		return "broadcast"
+	case proofConfirmed:
+		return "confirmed"
+	case proofFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// byWindowEnd sorts storage proofs by the height at which their window closes.
+type byWindowEnd []modules.HostStorageProof
+
+func (s byWindowEnd) Len() int           { return len(s) }
+func (s byWindowEnd) Less(i, j int) bool { return s[i].WindowEnd < s[j].WindowEnd }
+func (s byWindowEnd) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// proofStatus returns the status of the storage proof of the obligation. The
+// confirmation of a proof is tracked by the consensus updates, which only set
+// ProofConfirmed, and a proof that was still in the transaction pool when the
+// obligation failed was never confirmed.
+func (so storageObligation) proofStatus() storageProofStatus {
+	if so.ProofConfirmed {
+		return proofConfirmed
+	}
+	if so.ObligationStatus == obligationFailed {
+		return proofFailed
+	}
+	return so.ProofStatus
+}
+
+// proofSummary returns the information about the storage proof of the
+// obligation that is reported by the host API.
+func (so storageObligation) proofSummary(height types.BlockHeight) modules.HostStorageProof {
+	status := so.proofStatus()
+	return modules.HostStorageProof{
+		ContractID:  so.id(),
+		WindowStart: so.expiration(),
+		WindowEnd:   so.proofDeadline(),
+		Status:      status.String(),
+		Attempts:    so.ProofAttempts,
+		LastAttempt: so.ProofHeight,
+		Fee:         so.ProofFee,
+		Error:       so.ProofError,
+		Warning:     status != proofConfirmed && so.ObligationStatus == obligationUnresolved && so.proofDeadline() <= height+storageProofWarningBuffer,
+	}
+}
+
+// buildStorageProof builds the storage proof of the obligation for the
+// segment that was selected by the consensus set.
+func (h *Host) buildStorageProof(so storageObligation) (types.StorageProof, error) {
+	// Get the index of the segment, and the index of the sector containing
+	// the segment.
+	segmentIndex, err := h.cs.StorageProofSegment(so.id())
+	if err != nil {
+		return types.StorageProof{}, err
+	}
+	sectorIndex := segmentIndex / (modules.SectorSize / crypto.SegmentSize)
+	// Pull the corresponding sector into memory.
+	sectorRoot := so.SectorRoots[sectorIndex]
+	sectorBytes, err := h.ReadSector(sectorRoot)
+	if err != nil {
+		return types.StorageProof{}, err
+	}
+
+	// Build the storage proof for just the sector.
+	sectorSegment := segmentIndex % (modules.SectorSize / crypto.SegmentSize)
+	base, cachedHashSet := crypto.MerkleProof(sectorBytes, sectorSegment)
+
+	// Using the sector, build a cached root.
+	log2SectorSize := uint64(0)
+	for 1<<log2SectorSize < (modules.SectorSize / crypto.SegmentSize) {
+		log2SectorSize++
+	}
+	ct := crypto.NewCachedTree(log2SectorSize)
+	ct.SetIndex(segmentIndex)
+	for _, root := range so.SectorRoots {
+		ct.Push(root)
+	}
+	hashSet := ct.Prove(base, cachedHashSet)
+	sp := types.StorageProof{
+		ParentID: so.id(),
+		HashSet:  hashSet,
+	}
+	copy(sp.Segment[:], base)
+	return sp, nil
+}
+
+// proofInPool returns whether a storage proof for the obligation is in the
+// transaction pool.
+func (h *Host) proofInPool(id types.FileContractID) bool {
+	for _, txn := range h.tpool.TransactionList() {
+		for _, sp := range txn.StorageProofs {
+			if sp.ParentID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// submitStorageProof builds a storage proof for the obligation and submits it
+// to the transaction pool, recording the attempt in the obligation. A proof
+// that is still in the transaction pool is left to be confirmed, because the
+// transaction pool rejects a second proof for the same contract; the proof is
+// only resubmitted once it has been dropped from the pool, with at least
+// storageProofFeeIncrease times the fee of the dropped proof. The obligation
+// should be under lock.
+func (h *Host) submitStorageProof(so *storageObligation, blockHeight types.BlockHeight) {
+	resubmit := so.ProofStatus == proofBroadcast
+	if resubmit && h.proofInPool(so.id()) {
+		return
+	}
+	so.ProofAttempts++
+	so.ProofHeight = blockHeight
+	fail := func(status storageProofStatus, err error) {
+		so.ProofStatus = status
+		so.ProofError = err.Error()
+	}
+
+	sp, err := h.buildStorageProof(*so)
+	if err != nil {
+		h.log.Println("Host unable to build a storage proof:", err)
+		fail(proofFailed, err)
+		return
+	}
+
+	// Create and build the transaction with the storage proof.
+	_, feeRecommendation := h.tpool.FeeEstimation()
+	if so.value().Cmp(feeRecommendation) < 0 {
+		// There's no sense submitting the storage proof if the fee is more
+		// than the anticipated revenue.
+		h.log.Debugln("Host not submitting storage proof due to a value that does not sufficiently exceed the fee cost")
+		fail(proofFailed, errProofFeeTooHigh)
+		return
+	}
+	txnSize := uint64(len(encoding.Marshal(sp)) + 300)
+	requiredFee := feeRecommendation.Mul64(txnSize)
+	// A dropped proof may have been priced too low for the miners, so its
+	// fee is raised, but never beyond the value of the obligation.
+	if resubmit {
+		minFee := so.ProofFee.Mul64(storageProofFeeIncrease)
+		if minFee.Cmp(so.value()) > 0 {
+			minFee = so.value()
+		}
+		if requiredFee.Cmp(minFee) < 0 {
+			requiredFee = minFee
+		}
+	}
+	builder := h.wallet.StartTransaction()
+	err = builder.FundSiacoins(requiredFee)
+	if err != nil {
+		h.log.Println("Host error when funding a storage proof transaction fee:", err)
+		builder.Drop()
+		fail(proofFailed, err)
+		return
+	}
+	builder.AddMinerFee(requiredFee)
+	builder.AddStorageProof(sp)
+	storageProofSet, err := builder.Sign(true)
+	if err != nil {
+		h.log.Println("Host error when signing the storage proof transaction:", err)
+		builder.Drop()
+		fail(proofFailed, err)
+		return
+	}
+	err = h.tpool.AcceptTransactionSet(storageProofSet)
+	if err != nil {
+		h.log.Println("Host unable to submit storage proof transaction to transaction pool:", err)
+		builder.Drop()
+		fail(proofBuilt, err)
+		return
+	}
+
+	// The dropped proof was never confirmed, so only the fee of the new proof
+	// is an expense of the obligation.
+	if resubmit {
+		so.TransactionFeesAdded = so.TransactionFeesAdded.Sub(so.ProofFee)
+	}
+	so.TransactionFeesAdded = so.TransactionFeesAdded.Add(requiredFee)
+	so.ProofStatus = proofBroadcast
+	so.ProofFee = requiredFee
+	so.ProofError = ""
+}
+
+// StorageProofs returns the storage proofs of the unresolved storage
+// obligations of the host, along with the proofs that failed within the last
+// failedProofRetention blocks, ordered by the end of their proof window.
+func (h *Host) StorageProofs() ([]modules.HostStorageProof, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to StorageProofs after close")
+	}
+	defer h.tg.Done()
+
+	var proofs []modules.HostStorageProof
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			if err := json.Unmarshal(soBytes, &so); err != nil {
+				return err
+			}
+			recentFailure := so.ObligationStatus == obligationFailed && h.blockHeight <= so.proofDeadline()+failedProofRetention
+			if so.ObligationStatus == obligationUnresolved || recentFailure {
+				proofs = append(proofs, so.proofSummary(h.blockHeight))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byWindowEnd(proofs))
+	return proofs, nil
+}
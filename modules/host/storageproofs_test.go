@@ -0,0 +1,265 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestStorageProofs checks that the host tracks the state of the storage
+// proof of an obligation from the start of the proof window until the
+// obligation is resolved.
+func TestStorageProofs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestStorageProofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Add a storage obligation holding one sector, and confirm it.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.addStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+	sectorRoot, sectorData, err := randSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so.SectorRoots = []crypto.Hash{sectorRoot}
+	sectorCost := types.SiacoinPrecision.Mul64(550)
+	so.PotentialStorageRevenue = so.PotentialStorageRevenue.Add(sectorCost)
+	validPayouts, missedPayouts := so.payouts()
+	validPayouts[0].Value = validPayouts[0].Value.Sub(sectorCost)
+	validPayouts[1].Value = validPayouts[1].Value.Add(sectorCost)
+	missedPayouts[0].Value = missedPayouts[0].Value.Sub(sectorCost)
+	missedPayouts[1].Value = missedPayouts[1].Value.Add(sectorCost)
+	revisionSet := []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:          so.id(),
+			UnlockConditions:  types.UnlockConditions{},
+			NewRevisionNumber: 1,
+
+			NewFileSize:           uint64(len(sectorData)),
+			NewFileMerkleRoot:     sectorRoot,
+			NewWindowStart:        so.expiration(),
+			NewWindowEnd:          so.proofDeadline(),
+			NewValidProofOutputs:  validPayouts,
+			NewMissedProofOutputs: missedPayouts,
+			NewUnlockHash:         types.UnlockConditions{}.UnlockHash(),
+		}},
+	}}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.modifyStorageObligation(so, nil, []crypto.Hash{sectorRoot}, [][]byte{sectorData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+	err = ht.tpool.AcceptTransactionSet(revisionSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The proof window has not opened yet.
+	proofs, err := ht.host.StorageProofs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs) != 1 || proofs[0].ContractID != so.id() || proofs[0].Status != "pending" || proofs[0].WindowStart != so.expiration() || proofs[0].WindowEnd != so.proofDeadline() || proofs[0].Warning {
+		t.Fatal("wrong storage proofs before the proof window:", proofs)
+	}
+
+	// Mine until the host submits the storage proof.
+	for ht.host.blockHeight < so.expiration()+resubmissionTimeout {
+		_, err := ht.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = ht.host.tg.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs, err = ht.host.StorageProofs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs) != 1 || proofs[0].Status != "broadcast" || proofs[0].Attempts != 1 || proofs[0].Fee.IsZero() || proofs[0].Error != "" {
+		t.Fatal("storage proof was not broadcast:", proofs)
+	}
+
+	// A proof that is not confirmed close to the end of the window should
+	// raise a warning.
+	err = ht.host.db.View(func(tx *bolt.Tx) error {
+		so, err = getStorageObligation(tx, so.id())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if so.proofSummary(so.proofDeadline() - storageProofWarningBuffer - 1).Warning {
+		t.Error("warning raised too early")
+	}
+	if !so.proofSummary(so.proofDeadline() - storageProofWarningBuffer).Warning {
+		t.Error("no warning raised close to the end of the proof window")
+	}
+
+	// The proof is in the transaction pool, so submitting it again does
+	// nothing: the transaction pool would reject a second proof for the same
+	// contract.
+	countProofs := func() (n int) {
+		for _, txn := range ht.tpool.TransactionList() {
+			for _, sp := range txn.StorageProofs {
+				if sp.ParentID == so.id() {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	if countProofs() != 1 {
+		t.Fatal("storage proof is not in the transaction pool")
+	}
+	resubmitted := so
+	ht.host.submitStorageProof(&resubmitted, ht.host.blockHeight)
+	if resubmitted.ProofAttempts != so.ProofAttempts || resubmitted.ProofStatus != proofBroadcast || countProofs() != 1 {
+		t.Error("proof in the transaction pool was resubmitted:", resubmitted.ProofAttempts, resubmitted.ProofStatus, countProofs())
+	}
+
+	// Once the proof is dropped from the transaction pool, it is resubmitted.
+	ht.tpool.PurgeTransactionPool()
+	if countProofs() != 0 {
+		t.Fatal("storage proof was not purged")
+	}
+	ht.host.submitStorageProof(&resubmitted, ht.host.blockHeight)
+	if resubmitted.ProofAttempts != so.ProofAttempts+1 || resubmitted.ProofStatus != proofBroadcast || resubmitted.ProofError != "" || countProofs() != 1 {
+		t.Fatal("dropped proof was not resubmitted:", resubmitted.ProofAttempts, resubmitted.ProofStatus, resubmitted.ProofError, countProofs())
+	}
+	if resubmitted.ProofFee.Cmp(so.ProofFee.Mul64(storageProofFeeIncrease)) < 0 {
+		t.Error("fee of the resubmitted proof was not raised:", so.ProofFee, resubmitted.ProofFee)
+	}
+	if resubmitted.TransactionFeesAdded.Cmp(so.TransactionFeesAdded.Sub(so.ProofFee).Add(resubmitted.ProofFee)) != 0 {
+		t.Error("fee of the dropped proof was counted as an expense")
+	}
+
+	// Mine a block to confirm the storage proof.
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs, err = ht.host.StorageProofs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs) != 1 || proofs[0].Status != "confirmed" || proofs[0].Warning {
+		t.Fatal("storage proof was not confirmed:", proofs)
+	}
+
+	// Once the window closes, the obligation is resolved and the proof is no
+	// longer listed.
+	for ht.host.blockHeight <= so.proofDeadline() {
+		_, err := ht.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ht.host.tg.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	proofs, err = ht.host.StorageProofs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs) != 0 {
+		t.Fatal("resolved obligation is still listed:", proofs)
+	}
+	err = ht.host.db.View(func(tx *bolt.Tx) error {
+		so, err = getStorageObligation(tx, so.id())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if so.ObligationStatus != obligationSucceeded {
+		t.Fatal("obligation did not succeed:", so.ObligationStatus)
+	}
+}
+
+// TestFailedStorageProofs checks that failed storage proofs are reported for
+// failedProofRetention blocks after the end of their proof window.
+func TestFailedStorageProofs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestFailedStorageProofs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Store a failed obligation whose proof was still in the transaction pool
+	// when the window closed.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so.ObligationStatus = obligationFailed
+	so.ProofStatus = proofBroadcast
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		height types.BlockHeight
+		listed bool
+	}{
+		{so.proofDeadline() + 1, true},
+		{so.proofDeadline() + failedProofRetention, true},
+		{so.proofDeadline() + failedProofRetention + 1, false},
+	}
+	ht.host.mu.Lock()
+	oldHeight := ht.host.blockHeight
+	ht.host.mu.Unlock()
+	for _, test := range tests {
+		ht.host.mu.Lock()
+		ht.host.blockHeight = test.height
+		ht.host.mu.Unlock()
+		proofs, err := ht.host.StorageProofs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !test.listed {
+			if len(proofs) != 0 {
+				t.Errorf("failed proof is still listed at height %v: %v", test.height, proofs)
+			}
+			continue
+		}
+		if len(proofs) != 1 || proofs[0].ContractID != so.id() || proofs[0].Status != "failed" || proofs[0].Warning {
+			t.Errorf("failed proof is not listed at height %v: %v", test.height, proofs)
+		}
+	}
+	ht.host.mu.Lock()
+	ht.host.blockHeight = oldHeight
+	ht.host.mu.Unlock()
+}
@@ -22,6 +22,18 @@ const (
 	// not due to an error.
 	StopResponse = "stop"
 
+	// LostSectorsVersion is the earliest version of siad whose host accepts
+	// RPCLostSectors. Older hosts close the connection when asked. The RPC
+	// ships in the next release, so the renter does not use it until hosts
+	// report that release's version.
+	LostSectorsVersion = "1.0.2"
+
+	// PartialSectorVersion is the earliest version of siad whose host sends
+	// Merkle range proofs with partial sector downloads. Older hosts send the
+	// data without a proof. Like RPCLostSectors, partial downloads take effect
+	// once hosts report the next release's version.
+	PartialSectorVersion = "1.0.2"
+
 	// NegotiateDownloadTime defines the amount of time that the renter and
 	// host have to negotiate a download request batch. The time is set high
 	// enough that two nodes behind Tor have a reasonable chance of completing
@@ -40,6 +52,12 @@ const (
 	// connection that is running over Tor.
 	NegotiateFileContractRevisionTime = 600 * time.Second
 
+	// NegotiateLostSectorsTime defines the amount of time that the renter and
+	// host have to exchange the list of sectors that the host has lost. The
+	// host needs time to check every sector of the contract, and the list can
+	// be large for a large contract.
+	NegotiateLostSectorsTime = 300 * time.Second
+
 	// NegotiateRecentRevisionTime establishes the minimum amount of time that
 	// the connection deadline is expected to be set to when a recent file
 	// contract revision is being requested from the host. The deadline is long
@@ -100,6 +118,10 @@ const (
 	// transaction signature slice is allowed to be when being sent over the
 	// wire during negoitation.
 	NegotiateMaxTransactionSignaturesSize = 5e3
+
+	// MaxAnnouncedAddresses is the maximum number of addresses, including the
+	// primary address, that a host can list in a single announcement.
+	MaxAnnouncedAddresses = 8
 )
 
 var (
@@ -120,6 +142,14 @@ var (
 	// announcement or it's not a recognized version of a host announcement.
 	ErrAnnNotAnnouncement = errors.New("provided data does not form a recognized host announcement")
 
+	// ErrAnnNoAddresses is returned when creating a host announcement without
+	// any addresses.
+	ErrAnnNoAddresses = errors.New("a host announcement needs at least one address")
+
+	// ErrAnnTooManyAddresses is returned when creating a host announcement that
+	// lists more than MaxAnnouncedAddresses addresses.
+	ErrAnnTooManyAddresses = errors.New("host announcement lists too many addresses")
+
 	// ErrAnnUnrecognizedSignature is returned when the signature in a host
 	// announcement is not a type of signature that is recognized.
 	ErrAnnUnrecognizedSignature = errors.New("the signature provided in the host announcement is not recognized")
@@ -138,6 +168,11 @@ var (
 	// it reads the StopResponse string.
 	ErrStopResponse = errors.New("sender wishes to stop communicating")
 
+	// ErrHostMaintenance is sent by a host that refuses a renewal or revision
+	// because it is in maintenance mode. The refusal is temporary, and the
+	// renter can retry once the host has left maintenance mode.
+	ErrHostMaintenance = errors.New("host is in maintenance mode, try again later")
+
 	// PrefixHostAnnouncement is used to indicate that a transaction's
 	// Arbitrary Data field contains a host announcement. The encoded
 	// announcement will follow this prefix.
@@ -156,6 +191,10 @@ var (
 	// contract.
 	RPCReviseContract = types.Specifier{'R', 'e', 'v', 'i', 's', 'e', 'C', 'o', 'n', 't', 'r', 'a', 'c', 't', 2}
 
+	// RPCLostSectors is the specifier for requesting the Merkle roots of the
+	// sectors under a file contract that the host has lost.
+	RPCLostSectors = types.Specifier{'L', 'o', 's', 't', 'S', 'e', 'c', 't', 'o', 'r', 's'}
+
 	// RPCRecentRevision is the specifier for getting the most recent file
 	// contract revision for a given file contract.
 	RPCRecentRevision = types.Specifier{'R', 'e', 'c', 'e', 'n', 't', 'R', 'e', 'v', 'i', 's', 'i', 'o', 'n', 2}
@@ -204,6 +243,16 @@ type (
 		PublicKey  types.SiaPublicKey
 	}
 
+	// HostAnnouncementAddresses lists the additional addresses of a host that
+	// announces more than one address. It follows the signature of the
+	// HostAnnouncement, and is itself followed by a signature that covers
+	// both the announcement and the additional addresses. Nodes that do not
+	// recognize the additional addresses ignore them, and only use the
+	// NetAddress of the announcement.
+	HostAnnouncementAddresses struct {
+		Addresses []NetAddress
+	}
+
 	// HostExternalSettings are the parameters advertised by the host. These
 	// are the values that the renter will request from the host in order to
 	// build its database.
@@ -285,10 +334,17 @@ type (
 	}
 )
 
+// Partial returns whether the download action requests only part of a sector.
+// The host sends a Merkle range proof with the data of partial requests.
+func (da DownloadAction) Partial() bool {
+	return da.Offset != 0 || da.Length != SectorSize
+}
+
 // ReadNegotiationAcceptance reads an accept/reject response from r (usually a
 // net.Conn). If the response is not AcceptResponse, ReadNegotiationAcceptance
 // returns the response as an error. If the response is StopResponse,
-// ErrStopResponse is returned, allowing for direct error comparison.
+// ErrStopResponse is returned, allowing for direct error comparison. The same
+// is true for ErrHostMaintenance.
 //
 // Note that since errors returned by ReadNegotiationAcceptance are newly
 // allocated, they cannot be compared to other errors in the traditional
@@ -304,6 +360,8 @@ func ReadNegotiationAcceptance(r io.Reader) error {
 		return nil
 	case StopResponse:
 		return ErrStopResponse
+	case ErrHostMaintenance.Error():
+		return ErrHostMaintenance
 	default:
 		return errors.New(resp)
 	}
@@ -336,16 +394,34 @@ func WriteNegotiationStop(w io.Writer) error {
 // the exact []byte that should be added to the arbitrary data of a
 // transaction.
 func CreateAnnouncement(addr NetAddress, pk types.SiaPublicKey, sk crypto.SecretKey) (signedAnnouncement []byte, err error) {
-	if err := addr.IsValid(); err != nil {
-		return nil, err
+	return CreateAnnouncementAddresses([]NetAddress{addr}, pk, sk)
+}
+
+// CreateAnnouncementAddresses encodes a host announcement that lists several
+// addresses, in the order that renters should try them. The first address is
+// the primary address of the host, which is the only address seen by nodes
+// that do not recognize additional addresses. An announcement with a single
+// address is identical to one created by CreateAnnouncement.
+func CreateAnnouncementAddresses(addrs []NetAddress, pk types.SiaPublicKey, sk crypto.SecretKey) (signedAnnouncement []byte, err error) {
+	if len(addrs) == 0 {
+		return nil, ErrAnnNoAddresses
+	}
+	if len(addrs) > MaxAnnouncedAddresses {
+		return nil, ErrAnnTooManyAddresses
+	}
+	for _, addr := range addrs {
+		if err := addr.IsValid(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create the HostAnnouncement and marshal it.
-	annBytes := encoding.Marshal(HostAnnouncement{
+	ha := HostAnnouncement{
 		Specifier:  PrefixHostAnnouncement,
-		NetAddress: addr,
+		NetAddress: addrs[0],
 		PublicKey:  pk,
-	})
+	}
+	annBytes := encoding.Marshal(ha)
 
 	// Create a signature for the announcement.
 	annHash := crypto.HashBytes(annBytes)
@@ -353,36 +429,65 @@ func CreateAnnouncement(addr NetAddress, pk types.SiaPublicKey, sk crypto.Secret
 	if err != nil {
 		return nil, err
 	}
-	// Return the signed announcement.
-	return append(annBytes, sig[:]...), nil
+	signedAnnouncement = append(annBytes, sig[:]...)
+	if len(addrs) == 1 {
+		return signedAnnouncement, nil
+	}
+
+	// Append the additional addresses, and a signature that covers both the
+	// announcement and the additional addresses.
+	haa := HostAnnouncementAddresses{
+		Addresses: addrs[1:],
+	}
+	sig, err = crypto.SignHash(crypto.HashAll(ha, haa), sk)
+	if err != nil {
+		return nil, err
+	}
+	signedAnnouncement = append(signedAnnouncement, encoding.Marshal(haa)...)
+	return append(signedAnnouncement, sig[:]...), nil
 }
 
 // DecodeAnnouncement decodes announcement bytes into a host announcement,
-// verifying the prefix and the signature.
+// verifying the prefix and the signature. Only the primary address of the
+// announcement is returned.
 func DecodeAnnouncement(fullAnnouncement []byte) (na NetAddress, spk types.SiaPublicKey, err error) {
+	addrs, spk, err := DecodeAnnouncementAddresses(fullAnnouncement)
+	if err != nil {
+		return "", types.SiaPublicKey{}, err
+	}
+	return addrs[0], spk, nil
+}
+
+// DecodeAnnouncementAddresses decodes announcement bytes into a host
+// announcement, verifying the prefix and the signatures. All of the addresses
+// of the announcement are returned, starting with the primary address. Only
+// the primary address is returned if the additional addresses are missing or
+// cannot be verified.
+func DecodeAnnouncementAddresses(fullAnnouncement []byte) (addrs []NetAddress, spk types.SiaPublicKey, err error) {
 	// Read the first part of the announcement to get the intended host
 	// announcement.
 	var ha HostAnnouncement
-	dec := encoding.NewDecoder(bytes.NewReader(fullAnnouncement))
+	r := bytes.NewReader(fullAnnouncement)
+	dec := encoding.NewDecoder(r)
 	err = dec.Decode(&ha)
 	if err != nil {
-		return "", types.SiaPublicKey{}, err
+		return nil, types.SiaPublicKey{}, err
 	}
 
 	// Check that the announcement was registered as a host announcement.
 	if ha.Specifier != PrefixHostAnnouncement {
-		return "", types.SiaPublicKey{}, ErrAnnNotAnnouncement
+		return nil, types.SiaPublicKey{}, ErrAnnNotAnnouncement
 	}
 	// Check that the public key is a recognized type of public key.
 	if ha.PublicKey.Algorithm != types.SignatureEd25519 {
-		return "", types.SiaPublicKey{}, ErrAnnUnrecognizedSignature
+		return nil, types.SiaPublicKey{}, ErrAnnUnrecognizedSignature
 	}
 
 	// Read the signature out of the reader.
 	var sig crypto.Signature
 	err = dec.Decode(&sig)
 	if err != nil {
-		return "", types.SiaPublicKey{}, err
+		return nil, types.SiaPublicKey{}, err
 	}
 	// Verify the signature.
 	var pk crypto.PublicKey
@@ -390,9 +495,21 @@ func DecodeAnnouncement(fullAnnouncement []byte) (na NetAddress, spk types.SiaPu
 	annHash := crypto.HashObject(ha)
 	err = crypto.VerifyHash(annHash, pk, sig)
 	if err != nil {
-		return "", types.SiaPublicKey{}, err
+		return nil, types.SiaPublicKey{}, err
+	}
+	addrs = []NetAddress{ha.NetAddress}
+
+	// Read and verify the additional addresses. Announcements made before
+	// additional addresses existed may be followed by arbitrary data, which
+	// is ignored unless it is a valid list of additional addresses.
+	var haa HostAnnouncementAddresses
+	if r.Len() == 0 || dec.Decode(&haa) != nil || dec.Decode(&sig) != nil {
+		return addrs, ha.PublicKey, nil
+	}
+	if len(haa.Addresses)+1 > MaxAnnouncedAddresses || crypto.VerifyHash(crypto.HashAll(ha, haa), pk, sig) != nil {
+		return addrs, ha.PublicKey, nil
 	}
-	return ha.NetAddress, ha.PublicKey, nil
+	return append(addrs, haa.Addresses...), ha.PublicKey, nil
 }
 
 // VerifyFileContractRevisionTransactionSignatures checks that the signatures
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -91,6 +92,102 @@ func TestAnnouncementHandling(t *testing.T) {
 	}
 }
 
+// TestAnnouncementAddresses checks that announcements of several addresses
+// can be decoded, both by DecodeAnnouncementAddresses and by nodes that only
+// know about the primary address.
+func TestAnnouncementAddresses(t *testing.T) {
+	t.Parallel()
+
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	addrs := []NetAddress{"f.o:1234", "1.2.3.4:1234", "[2001:db8::1]:1234"}
+
+	// An announcement of a single address is a regular announcement.
+	single, err := CreateAnnouncementAddresses(addrs[:1], spk, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regular, err := CreateAnnouncement(addrs[0], spk, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(single, regular) {
+		t.Error("announcement of a single address differs from a regular announcement")
+	}
+
+	annBytes, err := CreateAnnouncementAddresses(addrs, spk, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decAddrs, decPubKey, err := DecodeAnnouncementAddresses(annBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decAddrs) != len(addrs) || decAddrs[0] != addrs[0] || decAddrs[1] != addrs[1] || decAddrs[2] != addrs[2] {
+		t.Error("decoded announcement has the wrong addresses:", decAddrs)
+	}
+	if !bytes.Equal(decPubKey.Key, spk.Key) {
+		t.Error("decoded announcement has the wrong public key")
+	}
+	decAddr, _, err := DecodeAnnouncement(annBytes)
+	if err != nil || decAddr != addrs[0] {
+		t.Error("DecodeAnnouncement should return the primary address:", decAddr, err)
+	}
+
+	// Nodes that do not know about additional addresses only read the
+	// announcement and its first signature.
+	var ha HostAnnouncement
+	var sig crypto.Signature
+	dec := encoding.NewDecoder(bytes.NewReader(annBytes))
+	if err := dec.Decode(&ha); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&sig); err != nil {
+		t.Fatal(err)
+	}
+	if ha.NetAddress != addrs[0] || crypto.VerifyHash(crypto.HashObject(ha), pk, sig) != nil {
+		t.Error("announcement cannot be verified without its additional addresses")
+	}
+
+	// Additional addresses that were tampered with are ignored, and so is
+	// trailing data that does not list additional addresses, which older
+	// announcements may contain.
+	annBytes[len(regular)+20]++
+	decAddrs, _, err = DecodeAnnouncementAddresses(annBytes)
+	if err != nil || len(decAddrs) != 1 || decAddrs[0] != addrs[0] {
+		t.Error("tampered additional addresses were not ignored:", decAddrs, err)
+	}
+	annBytes[len(regular)+20]--
+	decAddrs, _, err = DecodeAnnouncementAddresses(append(regular, 1, 2, 3))
+	if err != nil || len(decAddrs) != 1 || decAddrs[0] != addrs[0] {
+		t.Error("trailing data was not ignored:", decAddrs, err)
+	}
+	decAddr, _, err = DecodeAnnouncement(append(regular, 1, 2, 3))
+	if err != nil || decAddr != addrs[0] {
+		t.Error("trailing data was not ignored:", decAddr, err)
+	}
+
+	// The number of addresses is limited.
+	_, err = CreateAnnouncementAddresses(nil, spk, sk)
+	if err != ErrAnnNoAddresses {
+		t.Error("expected ErrAnnNoAddresses, got", err)
+	}
+	tooMany := make([]NetAddress, MaxAnnouncedAddresses+1)
+	for i := range tooMany {
+		tooMany[i] = addrs[0]
+	}
+	_, err = CreateAnnouncementAddresses(tooMany, spk, sk)
+	if err != ErrAnnTooManyAddresses {
+		t.Error("expected ErrAnnTooManyAddresses, got", err)
+	}
+}
+
 // TestNegotiationResponses tests the WriteNegotiationAcceptance,
 // WriteNegotiationRejection, and ReadNegotiationAcceptance functions.
 func TestNegotiationResponses(t *testing.T) {
@@ -168,3 +168,17 @@ func (na NetAddress) IsStdValid() error {
 
 	return nil
 }
+
+// SameAddresses returns true if a and b list the same addresses in the same
+// order.
+func SameAddresses(a, b []NetAddress) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
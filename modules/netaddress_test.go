@@ -317,3 +317,25 @@ func TestIsLocal(t *testing.T) {
 		}
 	}
 }
+
+// TestSameAddresses checks that SameAddresses compares address lists in
+// order.
+func TestSameAddresses(t *testing.T) {
+	testSet := []struct {
+		a, b            []NetAddress
+		desiredResponse bool
+	}{
+		{nil, nil, true},
+		{nil, []NetAddress{}, true},
+		{[]NetAddress{"a.b:1"}, []NetAddress{"a.b:1"}, true},
+		{[]NetAddress{"a.b:1", "c.d:2"}, []NetAddress{"a.b:1", "c.d:2"}, true},
+		{[]NetAddress{"a.b:1"}, nil, false},
+		{[]NetAddress{"a.b:1"}, []NetAddress{"a.b:2"}, false},
+		{[]NetAddress{"a.b:1", "c.d:2"}, []NetAddress{"c.d:2", "a.b:1"}, false},
+	}
+	for _, test := range testSet {
+		if SameAddresses(test.a, test.b) != test.desiredResponse {
+			t.Error("test failed:", test.a, test.b, SameAddresses(test.a, test.b))
+		}
+	}
+}
@@ -14,6 +14,13 @@ const (
 	RenterDir = "renter"
 )
 
+// These are the actions that an AllowancePlan may call for on a contract.
+const (
+	PlanActionForm  = "form"
+	PlanActionRenew = "renew"
+	PlanActionKeep  = "keep"
+)
+
 // An ErasureCoder is an error-correcting encoder and decoder.
 type ErasureCoder interface {
 	// NumPieces is the number of pieces returned by Encode.
@@ -74,9 +81,69 @@ type Allowance struct {
 	RenewWindow types.BlockHeight `json:"renewwindow"`
 }
 
+// A ContractPlan describes what setting an allowance would do to a single
+// contract. ID is only set for existing contracts. Cost is the estimated
+// amount that forming or renewing the contract would take from the wallet,
+// including fees, and is zero for contracts that are kept as they are.
+type ContractPlan struct {
+	Action     string               `json:"action"`
+	ID         types.FileContractID `json:"id"`
+	NetAddress NetAddress           `json:"netaddress"`
+	PublicKey  types.SiaPublicKey   `json:"publickey"`
+	Sectors    uint64               `json:"sectors"`
+	EndHeight  types.BlockHeight    `json:"endheight"`
+	Cost       types.Currency       `json:"cost"`
+}
+
+// An AllowancePlan describes the contracts that would be formed, renewed, or
+// left alone if an allowance were set. Plans are estimates: new hosts are
+// chosen randomly, and host prices may change before the allowance is set.
+type AllowancePlan struct {
+	Allowance Allowance      `json:"allowance"`
+	Contracts []ContractPlan `json:"contracts"`
+	TotalCost types.Currency `json:"totalcost"`
+}
+
+// A ContractFailure records why a contract could not be formed or renewed
+// with a host.
+type ContractFailure struct {
+	NetAddress NetAddress `json:"netaddress"`
+	Error      string     `json:"error"`
+}
+
+// A ContractAttempt summarizes a single attempt to form or renew a set of
+// contracts. Wanted is the number of contracts that were needed, and Formed
+// is the number that were actually formed or renewed. Error is set if the
+// attempt as a whole failed.
+type ContractAttempt struct {
+	StartTime time.Time         `json:"starttime"`
+	EndTime   time.Time         `json:"endtime"`
+	Height    types.BlockHeight `json:"height"`
+	Wanted    int               `json:"wanted"`
+	Formed    int               `json:"formed"`
+	Cost      types.Currency    `json:"cost"`
+	Failures  []ContractFailure `json:"failures"`
+	Error     string            `json:"error"`
+}
+
+// A RenewalPolicy controls which contracts the renter renews. When a contract
+// is not renewed, a contract is formed with a new host in its place, and the
+// renter migrates the data stored on the old host.
+type RenewalPolicy struct {
+	// MaxPriceIncrease is the percentage by which a host's storage price may
+	// exceed the price of the contract being renewed.
+	MaxPriceIncrease uint64 `json:"maxpriceincrease"`
+
+	// MaxFailureRate is the percentage of uploads, downloads, and connection
+	// attempts that may fail before a host is considered unreliable. Hosts
+	// that are offline are never renewed.
+	MaxFailureRate uint64 `json:"maxfailurerate"`
+}
+
 // RenterSettings control the behavior of the Renter.
 type RenterSettings struct {
-	Allowance Allowance `json:"allowance"`
+	Allowance     Allowance     `json:"allowance"`
+	RenewalPolicy RenewalPolicy `json:"renewalpolicy"`
 }
 
 // RenterFinancialMetrics contains metrics about how much the Renter has
@@ -94,11 +161,195 @@ type RenterFinancialMetrics struct {
 	UploadSpending   types.Currency `json:"uploadspending"`
 }
 
+// RenterPeriodSpending reports how the allowance was spent during a single
+// contract period. A period begins when a new contract set is formed and
+// closes when that set is renewed or expires. Closed periods are persisted
+// and never modified again.
+type RenterPeriodSpending struct {
+	// Period is the index of the period, starting at 0 for the first
+	// contract set formed by the renter.
+	Period      uint64            `json:"period"`
+	StartHeight types.BlockHeight `json:"startheight"`
+	EndHeight   types.BlockHeight `json:"endheight"`
+	Closed      bool              `json:"closed"`
+
+	// Allocated is the allowance that was in effect during the period.
+	// Unspent is the portion of Allocated that was never paid into a
+	// contract.
+	Allocated types.Currency `json:"allocated"`
+	Unspent   types.Currency `json:"unspent"`
+
+	// ContractSpending is the total amount paid to form or renew the
+	// period's contracts, including fees. The remaining categories are
+	// spent out of the contracts' renter funds.
+	ContractSpending types.Currency `json:"contractspending"`
+	DownloadSpending types.Currency `json:"downloadspending"`
+	StorageSpending  types.Currency `json:"storagespending"`
+	UploadSpending   types.Currency `json:"uploadspending"`
+
+	// Fees that were paid when forming or renewing the period's contracts.
+	// None of these fees are refunded.
+	ContractFees types.Currency `json:"contractfees"`
+	SiafundFees  types.Currency `json:"siafundfees"`
+	TxnFees      types.Currency `json:"txnfees"`
+
+	// LockedFunds is the amount of money that was placed in the period's
+	// contracts as renter funds. ExpectedRefund is the portion of those
+	// funds that has not been spent, and will be returned to the renter
+	// when the contracts end.
+	LockedFunds    types.Currency `json:"lockedfunds"`
+	ExpectedRefund types.Currency `json:"expectedrefund"`
+}
+
 // A HostDBEntry represents one host entry in the Renter's host DB. It
 // aggregates the host's external settings with its public key.
 type HostDBEntry struct {
 	HostExternalSettings
 	PublicKey types.SiaPublicKey `json:"publickey"`
+
+	// AdditionalAddresses are the addresses that the host announced besides
+	// its NetAddress, in the order that they are tried. ReachableAddress is
+	// the address at which the host was last reached, and is empty if the
+	// host has not been reached.
+	AdditionalAddresses []NetAddress `json:"additionaladdresses"`
+	ReachableAddress    NetAddress   `json:"reachableaddress"`
+}
+
+// DialAddresses returns the addresses of the host in the order that they
+// should be tried: the address at which the host was last reached, followed
+// by the NetAddress and the additional addresses of the host.
+func (he HostDBEntry) DialAddresses() []NetAddress {
+	addrs := make([]NetAddress, 0, len(he.AdditionalAddresses)+2)
+	seen := make(map[NetAddress]struct{})
+	for _, addr := range append([]NetAddress{he.ReachableAddress, he.NetAddress}, he.AdditionalAddresses...) {
+		if _, exists := seen[addr]; exists || addr == "" {
+			continue
+		}
+		seen[addr] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// A HostDBScan records the outcome of a single scan of a host. ErrorType is
+// empty if the scan succeeded. Latency is the time taken to connect to the
+// host and receive its settings.
+type HostDBScan struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Success   bool          `json:"success"`
+	ErrorType string        `json:"errortype,omitempty"`
+	Latency   time.Duration `json:"latency"`
+}
+
+// A HostAddressChange records an address that a host announced, and the
+// height at which the address was first seen.
+type HostAddressChange struct {
+	NetAddress NetAddress        `json:"netaddress"`
+	Height     types.BlockHeight `json:"height"`
+}
+
+// A HostBenchmark records the result of a single benchmark of a host. Latency
+// benchmarks measure the round-trip time of a settings request, and download
+// benchmarks measure the throughput of a paid 1 MiB download, in bytes per
+// second. Only the field of the benchmark that was run is set.
+type HostBenchmark struct {
+	Timestamp  time.Time     `json:"timestamp"`
+	Latency    time.Duration `json:"latency,omitempty"`
+	Throughput uint64        `json:"throughput,omitempty"`
+}
+
+// HostBenchmarkSettings control the periodic benchmarking of hosts. If
+// Enabled, the latency of each active host is measured every Interval. If
+// Downloads is also set, 1 MiB of a sector is downloaded from each host that
+// the renter has a contract with, which costs a small amount of money.
+type HostBenchmarkSettings struct {
+	Enabled   bool          `json:"enabled"`
+	Downloads bool          `json:"downloads"`
+	Interval  time.Duration `json:"interval"`
+}
+
+// HostScoreWeights are the exponents applied to each factor of a host's
+// score. A weight of zero causes a factor to be ignored, and larger weights
+// make a factor more significant.
+type HostScoreWeights struct {
+	Uptime     float64 `json:"uptime"`
+	Age        float64 `json:"age"`
+	Storage    float64 `json:"storage"`
+	Version    float64 `json:"version"`
+	Latency    float64 `json:"latency"`
+	Throughput float64 `json:"throughput"`
+}
+
+// The orders in which hosts can be sorted by HostDBFilter.
+const (
+	HostSortScore = "score"
+	HostSortPrice = "price"
+)
+
+// A HostDBFilter selects and orders hosts in the HostDB. Fields with a zero
+// value do not filter any hosts. Hosts are sorted by descending score, or by
+// ascending storage price, and Offset and Limit select a page of the result.
+// A Limit of zero returns every host after Offset.
+type HostDBFilter struct {
+	MaxStoragePrice     types.Currency
+	MaxUploadPrice      types.Currency
+	MaxDownloadPrice    types.Currency
+	MinRemainingStorage uint64
+	AcceptingContracts  bool
+	Online              bool
+	MinUptime           float64
+	MinVersion          string
+
+	SortBy string
+	Offset int
+	Limit  int
+}
+
+// A HostDBSummary is a host entry along with its availability and score.
+type HostDBSummary struct {
+	HostDBEntry
+	Online bool           `json:"online"`
+	Uptime float64        `json:"uptime"`
+	Score  types.Currency `json:"score"`
+}
+
+// HostSubnetSizes are the prefix lengths, in bits, of the subnets that hosts
+// are grouped by. At most one host per subnet is selected for new contracts.
+// A size of zero disables the restriction for that address family.
+type HostSubnetSizes struct {
+	IPv4 int `json:"ipv4"`
+	IPv6 int `json:"ipv6"`
+}
+
+// HostScoreBreakdown explains the score of a host. Price is the score given
+// to the host's prices and collateral. Each of the other factors is a
+// multiplier between 0 and 1 with its weight already applied, and Score is
+// the product of Price and the multipliers.
+type HostScoreBreakdown struct {
+	Score      types.Currency `json:"score"`
+	Price      types.Currency `json:"price"`
+	Uptime     float64        `json:"uptime"`
+	Age        float64        `json:"age"`
+	Storage    float64        `json:"storage"`
+	Version    float64        `json:"version"`
+	Latency    float64        `json:"latency"`
+	Throughput float64        `json:"throughput"`
+}
+
+// HostDBEntryDetails contains a host's entry along with the HostDB's record
+// of its availability. Uptime and Downtime are percentages of the time
+// covered by the host's scans. AddressHistory, ScanHistory and Benchmarks
+// hold the host's most recent addresses, scans and benchmarks, oldest first.
+type HostDBEntryDetails struct {
+	Entry          HostDBEntry         `json:"entry"`
+	Online         bool                `json:"online"`
+	FirstSeen      types.BlockHeight   `json:"firstseen"`
+	AddressHistory []HostAddressChange `json:"addresshistory"`
+	Uptime         float64             `json:"uptime"`
+	Downtime       float64             `json:"downtime"`
+	ScanHistory    []HostDBScan        `json:"scanhistory"`
+	Benchmarks     []HostBenchmark     `json:"benchmarks"`
+	ScoreBreakdown HostScoreBreakdown  `json:"scorebreakdown"`
 }
 
 // A RenterContract contains all the metadata necessary to revise or renew a
@@ -111,6 +362,16 @@ type RenterContract struct {
 	MerkleRoots     []crypto.Hash              `json:"merkleroots"`
 	NetAddress      NetAddress                 `json:"netaddress"`
 	SecretKey       crypto.SecretKey           `json:"secretkey"`
+
+	// The costs of forming the contract. TotalCost is the full amount that
+	// was taken from the wallet, which includes the fees.
+	TotalCost   types.Currency `json:"totalcost"`
+	ContractFee types.Currency `json:"contractfee"`
+	SiafundFee  types.Currency `json:"siafundfee"`
+	TxnFee      types.Currency `json:"txnfee"`
+
+	// StoragePrice is the host's storage price when the contract was formed.
+	StoragePrice types.Currency `json:"storageprice"`
 }
 
 // EndHeight returns the height at which the host is no longer obligated to
@@ -135,12 +396,39 @@ type Renter interface {
 	// AllHosts returns the full list of hosts known to the renter.
 	AllHosts() []HostDBEntry
 
+	// HostDetails returns the HostDB's record of the host with the given
+	// public key.
+	HostDetails(types.SiaPublicKey) (HostDBEntryDetails, bool)
+
+	// HostBenchmarkSettings returns the settings that control the
+	// benchmarking of hosts.
+	HostBenchmarkSettings() HostBenchmarkSettings
+
+	// HostPruneDowntime returns how long a host can be offline before it is
+	// removed from the hostdb.
+	HostPruneDowntime() time.Duration
+
+	// HostScoreWeights returns the weights used to score hosts.
+	HostScoreWeights() HostScoreWeights
+
+	// HostSubnetSizes returns the sizes of the subnets that hosts are
+	// grouped by.
+	HostSubnetSizes() HostSubnetSizes
+
+	// SearchHosts returns a page of the hosts that match the filter, along
+	// with the total number of matching hosts.
+	SearchHosts(HostDBFilter) (hosts []HostDBSummary, total int)
+
 	// Close closes the Renter.
 	Close() error
 
 	// Contracts returns the contracts formed by the renter.
 	Contracts() []RenterContract
 
+	// ContractAttempts returns the results of the most recent attempts to
+	// form and to renew contracts.
+	ContractAttempts() (formation, renewal ContractAttempt)
+
 	// DeleteFile deletes a file entry from the renter.
 	DeleteFile(path string) error
 
@@ -156,6 +444,19 @@ type Renter interface {
 	// FinancialMetrics returns the financial metrics of the Renter.
 	FinancialMetrics() RenterFinancialMetrics
 
+	// PeriodSpending returns the spending report of the specified contract
+	// period.
+	PeriodSpending(period uint64) (RenterPeriodSpending, error)
+
+	// PlanAllowance returns the changes that setting the renter's settings
+	// would make to the renter's contracts, without forming any contracts or
+	// spending any money.
+	PlanAllowance(RenterSettings) (AllowancePlan, error)
+
+	// CurrentPeriodSpending returns the spending report of the current
+	// contract period.
+	CurrentPeriodSpending() RenterPeriodSpending
+
 	// LoadSharedFiles loads a '.sia' file into the renter. A .sia file may
 	// contain multiple files. The paths of the added files are returned.
 	LoadSharedFiles(source string) ([]string, error)
@@ -170,6 +471,25 @@ type Renter interface {
 	// Settings returns the Renter's current settings.
 	Settings() RenterSettings
 
+	// SubnetConflicts returns the IDs of contracts whose hosts share a subnet
+	// with the host of another contract.
+	SubnetConflicts() []types.FileContractID
+
+	// SetHostBenchmarkSettings sets the settings that control the
+	// benchmarking of hosts.
+	SetHostBenchmarkSettings(HostBenchmarkSettings) error
+
+	// SetHostPruneDowntime sets how long a host can be offline before it is
+	// removed from the hostdb.
+	SetHostPruneDowntime(time.Duration) error
+
+	// SetHostScoreWeights sets the weights used to score hosts.
+	SetHostScoreWeights(HostScoreWeights) error
+
+	// SetHostSubnetSizes sets the sizes of the subnets that hosts are
+	// grouped by.
+	SetHostSubnetSizes(HostSubnetSizes) error
+
 	// SetSettings sets the Renter's settings.
 	SetSettings(RenterSettings) error
 
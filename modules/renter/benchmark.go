@@ -0,0 +1,104 @@
+package renter
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+)
+
+const (
+	// benchmarkCheckInterval is how often the benchmark loop checks whether
+	// download benchmarks are due.
+	benchmarkCheckInterval = time.Minute
+
+	// benchmarkDownloadSize is the number of bytes downloaded by a download
+	// benchmark. Enough data is downloaded that the throughput is not
+	// dominated by the latency of the host, while costing much less than a
+	// full sector.
+	benchmarkDownloadSize = 1 << 20 // 1 MiB
+)
+
+var (
+	errNoSectors = errors.New("contract has no sectors to download")
+)
+
+// managedBenchmarkDownload downloads benchmarkDownloadSize bytes from a random
+// sector of a contract, or the whole sector if sectors are smaller or the
+// host does not support partial downloads, and reports the throughput of the
+// download to the hostdb. Contracts that are being revised or downloaded from
+// are skipped.
+func (r *Renter) managedBenchmarkDownload(contract modules.RenterContract) error {
+	if len(contract.MerkleRoots) == 0 {
+		return errNoSectors
+	}
+	i, err := crypto.RandIntn(len(contract.MerkleRoots))
+	if err != nil {
+		return err
+	}
+	length := uint64(benchmarkDownloadSize)
+	if length > modules.SectorSize || !r.supportsPartialSector(contract) {
+		length = modules.SectorSize
+	}
+	chunk, err := crypto.RandIntn(int(modules.SectorSize / length))
+	if err != nil {
+		return err
+	}
+
+	// The time taken to connect to the host is not counted, as it is
+	// measured by the latency benchmarks.
+	d, err := r.hostContractor.BenchmarkDownloader(contract)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	start := time.Now()
+	var data []byte
+	if length == modules.SectorSize {
+		data, err = d.Sector(contract.MerkleRoots[i])
+	} else {
+		data, err = d.PartialSector(contract.MerkleRoots[i], uint64(chunk)*length, length)
+	}
+	if err != nil {
+		return err
+	}
+	r.hostDB.RecordThroughput(r.hostAddress(contract), uint64(len(data)), time.Since(start))
+	return nil
+}
+
+// supportsPartialSector returns whether the host of a contract supports
+// partial sector downloads. Hosts that the hostdb does not know are assumed
+// not to.
+func (r *Renter) supportsPartialSector(contract modules.RenterContract) bool {
+	pks := contract.LastRevision.UnlockConditions.PublicKeys
+	if len(pks) < 2 {
+		return false
+	}
+	details, ok := r.hostDB.HostDetails(pks[1])
+	return ok && build.VersionCmp(details.Entry.Version, modules.PartialSectorVersion) >= 0
+}
+
+// threadedBenchmarkLoop periodically measures the download throughput of the
+// hosts that the renter has contracts with, if download benchmarks are
+// enabled.
+func (r *Renter) threadedBenchmarkLoop() {
+	var lastBenchmark time.Time
+	for {
+		time.Sleep(benchmarkCheckInterval)
+
+		settings := r.hostDB.BenchmarkSettings()
+		if !settings.Enabled || !settings.Downloads || time.Since(lastBenchmark) < settings.Interval {
+			continue
+		}
+		for _, c := range r.hostContractor.Contracts() {
+			err := r.managedBenchmarkDownload(c)
+			if err != nil && err != errNoSectors && !contractor.IsContractInUse(err) {
+				r.log.Printf("WARN: could not benchmark downloads from %v: %v", c.NetAddress, err)
+			}
+		}
+		lastBenchmark = time.Now()
+	}
+}
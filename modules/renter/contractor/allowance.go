@@ -39,6 +39,39 @@ func (c *Contractor) contractEndHeight() types.BlockHeight {
 	return endHeight
 }
 
+// checkAllowance returns an error if the allowance is malformed.
+func checkAllowance(a modules.Allowance) error {
+	if a.Hosts == 0 {
+		return errAllowanceNoHosts
+	} else if a.Period == 0 {
+		return errAllowanceZeroPeriod
+	} else if a.RenewWindow == 0 {
+		return ErrAllowanceZeroWindow
+	} else if a.RenewWindow >= a.Period {
+		return errAllowanceWindowSize
+	}
+	return nil
+}
+
+// managedValidateAllowance returns an error if the allowance is malformed or
+// is not sufficient to store at least one sector. Otherwise it returns the
+// number of sectors that the allowance can store.
+func (c *Contractor) managedValidateAllowance(a modules.Allowance) (uint64, error) {
+	// sanity checks
+	if err := checkAllowance(a); err != nil {
+		return 0, err
+	}
+
+	// check that allowance is sufficient to store at least one sector
+	numSectors, err := maxSectors(a, c.hdb)
+	if err != nil {
+		return 0, err
+	} else if numSectors == 0 {
+		return 0, ErrInsufficientAllowance
+	}
+	return numSectors, nil
+}
+
 // SetAllowance sets the amount of money the Contractor is allowed to spend on
 // contracts over a given time period, divided among the number of hosts
 // specified. Note that Contractor can start forming contracts as soon as
@@ -56,23 +89,9 @@ func (c *Contractor) contractEndHeight() types.BlockHeight {
 // NOTE: At this time, transaction fees are not counted towards the allowance.
 // This means the contractor may spend more than allowance.Funds.
 func (c *Contractor) SetAllowance(a modules.Allowance) error {
-	// sanity checks
-	if a.Hosts == 0 {
-		return errAllowanceNoHosts
-	} else if a.Period == 0 {
-		return errAllowanceZeroPeriod
-	} else if a.RenewWindow == 0 {
-		return ErrAllowanceZeroWindow
-	} else if a.RenewWindow >= a.Period {
-		return errAllowanceWindowSize
-	}
-
-	// check that allowance is sufficient to store at least one sector
-	numSectors, err := maxSectors(a, c.hdb)
+	numSectors, err := c.managedValidateAllowance(a)
 	if err != nil {
 		return err
-	} else if numSectors == 0 {
-		return ErrInsufficientAllowance
 	}
 
 	c.mu.RLock()
@@ -101,6 +120,12 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 	for _, contract := range c.contracts {
 		renewSet = append(renewSet, contract)
 	}
+	// at most a.Hosts contracts are renewed
+	wanted := len(renewSet)
+	if wanted > int(a.Hosts) {
+		wanted = int(a.Hosts)
+	}
+	attempt := c.newAttempt(wanted)
 
 	// calculate new endHeight; if the period has not changed, the endHeight
 	// should not change either
@@ -114,13 +139,22 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 	// renew existing contracts with new allowance parameters
 	newContracts := make(map[types.FileContractID]modules.RenterContract)
 	for _, contract := range renewSet {
+		if err := c.managedCheckRenewal(contract); err != nil {
+			c.log.Printf("INFO: not renewing contract with %v: %v; a new contract will be formed in its place", contract.NetAddress, err)
+			c.managedAddFailure(&attempt, contract.NetAddress, err)
+			remaining++
+			continue
+		}
 		newContract, err := c.managedRenew(contract, numSectors, endHeight)
 		if err != nil {
 			c.log.Printf("WARN: failed to renew contract with %v; a new contract will be formed in its place", contract.NetAddress)
+			c.managedAddFailure(&attempt, contract.NetAddress, err)
 			remaining++
 			continue
 		}
 		newContracts[newContract.ID] = newContract
+		attempt.Formed++
+		attempt.Cost = attempt.Cost.Add(newContract.TotalCost)
 		if len(newContracts) >= int(a.Hosts) {
 			break
 		}
@@ -129,10 +163,13 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 			time.Sleep(60 * time.Second)
 		}
 	}
+	if len(renewSet) > 0 {
+		c.managedFinishAttempt(attempt, true)
+	}
 
 	// if we did not renew enough contracts, form new ones
 	if remaining > 0 {
-		formed, err := c.managedFormContracts(remaining, numSectors, endHeight)
+		formed, err := c.managedFormContracts(remaining, numSectors, endHeight, a)
 		if err != nil {
 			return err
 		}
@@ -146,10 +183,16 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 		return errors.New("unable to form or renew any contracts")
 	}
 
-	// Set the allowance and replace the contract set
+	// Set the allowance and replace the contract set. The renewed contracts
+	// begin a new spending period.
 	c.mu.Lock()
 	c.allowance = a
+	c.closePeriod()
 	c.contracts = newContracts
+	for _, contract := range c.contracts {
+		c.addContractSpending(contract)
+		delete(c.interactions, contract.NetAddress)
+	}
 	// update metrics
 	var spending types.Currency
 	for _, contract := range c.contracts {
@@ -166,7 +209,12 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 // need to be renewed when setting the allowance.
 func (c *Contractor) managedFormAllowanceContracts(n int, numSectors uint64, a modules.Allowance) error {
 	if n <= 0 {
-		return nil
+		// no contracts are needed, but the renew window may have changed
+		c.mu.Lock()
+		c.allowance = a
+		err := c.saveSync()
+		c.mu.Unlock()
+		return err
 	}
 
 	// if we're forming contracts but not renewing, the new contracts should
@@ -180,7 +228,7 @@ func (c *Contractor) managedFormAllowanceContracts(n int, numSectors uint64, a m
 	c.mu.RUnlock()
 
 	// form the contracts
-	formed, err := c.managedFormContracts(n, numSectors, endHeight)
+	formed, err := c.managedFormContracts(n, numSectors, endHeight, a)
 	if err != nil {
 		return err
 	}
@@ -188,8 +236,10 @@ func (c *Contractor) managedFormAllowanceContracts(n int, numSectors uint64, a m
 	// Set the allowance and replace the contract set
 	c.mu.Lock()
 	c.allowance = a
+	c.currentPeriod.Allocated = a.Funds
 	for _, contract := range formed {
 		c.contracts[contract.ID] = contract
+		c.addContractSpending(contract)
 	}
 	// update metrics
 	var spending types.Currency
@@ -0,0 +1,83 @@
+package contractor
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// failedHostCooldown is the number of blocks for which a host that failed
+	// to form or renew a contract is avoided when choosing new hosts.
+	failedHostCooldown = types.BlockHeight(6)
+)
+
+// newAttempt returns a ContractAttempt for the specified number of contracts,
+// beginning now. The caller must hold the lock.
+func (c *Contractor) newAttempt(wanted int) modules.ContractAttempt {
+	return modules.ContractAttempt{
+		StartTime: time.Now(),
+		Height:    c.blockHeight,
+		Wanted:    wanted,
+	}
+}
+
+// recentlyFailed returns the hosts that failed to form or renew a contract
+// within the last failedHostCooldown blocks. The caller must hold the lock.
+func (c *Contractor) recentlyFailed() []modules.NetAddress {
+	var failed []modules.NetAddress
+	for addr, height := range c.failedHosts {
+		if height+failedHostCooldown > c.blockHeight {
+			failed = append(failed, addr)
+		}
+	}
+	return failed
+}
+
+// managedAddFailure records in attempt that a contract could not be formed or
+// renewed with the specified host. Unless the failure was caused by the
+// renter's own budget, the host is avoided by subsequent formation attempts.
+func (c *Contractor) managedAddFailure(attempt *modules.ContractAttempt, addr modules.NetAddress, err error) {
+	attempt.Failures = append(attempt.Failures, modules.ContractFailure{
+		NetAddress: addr,
+		Error:      err.Error(),
+	})
+	if err == errBudgetExceeded {
+		return
+	}
+	c.mu.Lock()
+	c.failedHosts[addr] = c.blockHeight
+	c.mu.Unlock()
+}
+
+// managedFinishAttempt stores attempt as the most recent formation or renewal
+// attempt and saves it, so that it is reported across restarts even when the
+// attempt did not change the contract set. Hosts whose failures are older than
+// failedHostCooldown are forgotten.
+func (c *Contractor) managedFinishAttempt(attempt modules.ContractAttempt, renewal bool) {
+	attempt.EndTime = time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if renewal {
+		c.lastRenewal = attempt
+	} else {
+		c.lastFormation = attempt
+	}
+	for addr, height := range c.failedHosts {
+		if height+failedHostCooldown <= c.blockHeight {
+			delete(c.failedHosts, addr)
+		}
+	}
+	if err := c.save(); err != nil {
+		c.log.Println("WARN: failed to save contract attempt:", err)
+	}
+}
+
+// ContractAttempts returns the results of the most recent attempts to form
+// and to renew contracts.
+func (c *Contractor) ContractAttempts() (formation, renewal modules.ContractAttempt) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastFormation, c.lastRenewal
+}
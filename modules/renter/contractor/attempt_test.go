@@ -0,0 +1,162 @@
+package contractor
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// listHostDB is a hostDB that returns a fixed list of hosts, honoring the
+// exclude list passed to RandomHosts.
+type listHostDB []modules.HostDBEntry
+
+func (hdb listHostDB) Host(addr modules.NetAddress) (modules.HostDBEntry, bool) {
+	for _, h := range hdb {
+		if h.NetAddress == addr {
+			return h, true
+		}
+	}
+	return modules.HostDBEntry{}, false
+}
+
+func (hdb listHostDB) HostByKey(pk types.SiaPublicKey) (modules.HostDBEntry, bool) {
+	for _, h := range hdb {
+		if h.PublicKey.String() == pk.String() {
+			return h, true
+		}
+	}
+	return modules.HostDBEntry{}, false
+}
+
+func (hdb listHostDB) IsOffline(modules.NetAddress) bool { return false }
+
+func (hdb listHostDB) RandomHosts(n int, exclude []modules.NetAddress) (hosts []modules.HostDBEntry) {
+	excluded := make(map[modules.NetAddress]bool)
+	for _, addr := range exclude {
+		excluded[addr] = true
+	}
+	for _, h := range hdb {
+		if len(hosts) < n && !excluded[h.NetAddress] {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// TestFormContractsFailures tests that managedFormContracts records why each
+// host failed, and that failed hosts are avoided for failedHostCooldown
+// blocks.
+func TestFormContractsFailures(t *testing.T) {
+	expensive := maxStoragePrice.Add(types.NewCurrency64(1))
+	hdb := listHostDB{
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "foo", StoragePrice: expensive}},
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "bar", StoragePrice: expensive}},
+	}
+	c := &Contractor{
+		hdb:         hdb,
+		blockHeight: 10,
+		contracts:   make(map[types.FileContractID]modules.RenterContract),
+		failedHosts: make(map[modules.NetAddress]types.BlockHeight),
+		persist:     new(memPersist),
+		log:         persist.NewLogger(ioutil.Discard),
+	}
+
+	// both hosts are too expensive
+	a := modules.Allowance{
+		Funds:  expensive.Mul64(modules.SectorSize).Mul64(10),
+		Hosts:  1,
+		Period: 10,
+	}
+	_, err := c.managedFormContracts(1, 1, 20, a)
+	if err == nil {
+		t.Fatal("expected formation to fail")
+	}
+	formation, _ := c.ContractAttempts()
+	if formation.Wanted != 1 || formation.Formed != 0 || formation.Error == "" {
+		t.Fatal("wrong attempt summary:", formation)
+	} else if len(formation.Failures) != 2 {
+		t.Fatal("expected 2 failures, got", len(formation.Failures))
+	}
+	for _, f := range formation.Failures {
+		if f.Error != errTooExpensive.Error() {
+			t.Fatal("wrong failure reason:", f.Error)
+		}
+	}
+	// the failed attempt is saved, although the contract set did not change
+	if saved := c.persist.(*memPersist).LastFormation; saved.Wanted != 1 || len(saved.Failures) != 2 {
+		t.Fatal("failed attempt was not saved:", saved)
+	}
+	if len(c.recentlyFailed()) != 2 {
+		t.Fatal("expected both hosts to be avoided, got", c.recentlyFailed())
+	}
+
+	// budget failures are not the host's fault
+	c.failedHosts = make(map[modules.NetAddress]types.BlockHeight)
+	a.Funds = types.NewCurrency64(1)
+	_, err = c.managedFormContracts(1, 1, 20, a)
+	if err == nil {
+		t.Fatal("expected formation to fail")
+	}
+	formation, _ = c.ContractAttempts()
+	for _, f := range formation.Failures {
+		if f.Error != errBudgetExceeded.Error() {
+			t.Fatal("wrong failure reason:", f.Error)
+		}
+	}
+	if len(c.recentlyFailed()) != 0 {
+		t.Fatal("hosts should not be avoided after a budget failure")
+	}
+
+	// failed hosts are forgotten after the cooldown
+	c.managedAddFailure(&formation, "foo", errTooExpensive)
+	c.blockHeight += failedHostCooldown
+	if len(c.recentlyFailed()) != 0 {
+		t.Fatal("failure should have expired")
+	}
+	c.managedFinishAttempt(formation, false)
+	if len(c.failedHosts) != 0 {
+		t.Fatal("expired failure was not pruned")
+	}
+}
+
+// TestSetAllowanceRenewalAttempt tests that the renewal attempt of
+// SetAllowance only wants as many contracts as the allowance has hosts.
+func TestSetAllowanceRenewalAttempt(t *testing.T) {
+	expensive := maxStoragePrice.Add(types.NewCurrency64(1))
+	hdb := listHostDB{
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "baz", StoragePrice: expensive}},
+	}
+	var foo, bar modules.RenterContract
+	foo.ID, foo.NetAddress = types.FileContractID{1}, "foo"
+	bar.ID, bar.NetAddress = types.FileContractID{2}, "bar"
+	c := &Contractor{
+		hdb:          hdb,
+		blockHeight:  10,
+		contracts:    map[types.FileContractID]modules.RenterContract{foo.ID: foo, bar.ID: bar},
+		failedHosts:  make(map[modules.NetAddress]types.BlockHeight),
+		interactions: make(map[modules.NetAddress]hostInteractions),
+		persist:      new(memPersist),
+		log:          persist.NewLogger(ioutil.Discard),
+	}
+
+	// neither contract can be renewed, and the replacement host is too
+	// expensive
+	a := modules.Allowance{
+		Funds:       expensive.Mul64(modules.SectorSize).Mul64(10 * 10),
+		Hosts:       1,
+		Period:      10,
+		RenewWindow: 5,
+	}
+	if err := c.SetAllowance(a); err == nil {
+		t.Fatal("expected SetAllowance to fail")
+	}
+	_, renewal := c.ContractAttempts()
+	if renewal.Wanted != 1 {
+		t.Fatal("renewal attempt should want 1 contract, got", renewal.Wanted)
+	} else if renewal.Formed != 0 || len(renewal.Failures) != 2 {
+		t.Fatal("wrong renewal attempt:", renewal)
+	}
+}
@@ -0,0 +1,98 @@
+package contractor
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// errContractInUse is returned by BenchmarkDownloader if an Editor or
+// Downloader is open for the contract.
+var errContractInUse = errors.New("contract is in use")
+
+// IsContractInUse returns whether err is the error returned by
+// BenchmarkDownloader when the contract is in use.
+func IsContractInUse(err error) bool { return err == errContractInUse }
+
+// managedAcquireContract marks a contract as in use by an Editor or a
+// Downloader. If the contract is being benchmarked, managedAcquireContract
+// waits for the benchmark to finish. Benchmarks download at most one sector,
+// so the wait is short.
+func (c *Contractor) managedAcquireContract(id types.FileContractID) {
+	for {
+		c.mu.Lock()
+		done, ok := c.benchmarks[id]
+		if !ok {
+			if c.sessions == nil {
+				c.sessions = make(map[types.FileContractID]int)
+			}
+			c.sessions[id]++
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+		<-done
+	}
+}
+
+// managedReleaseContract releases a contract acquired by
+// managedAcquireContract.
+func (c *Contractor) managedReleaseContract(id types.FileContractID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions[id]--
+	if c.sessions[id] <= 0 {
+		delete(c.sessions, id)
+	}
+}
+
+// managedAcquireBenchmark marks a contract as being benchmarked. It returns
+// errContractInUse instead of waiting if an Editor or a Downloader is open for
+// the contract, so that benchmarks never delay uploads and downloads.
+func (c *Contractor) managedAcquireBenchmark(id types.FileContractID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.benchmarks[id]; ok || c.sessions[id] > 0 {
+		return errContractInUse
+	}
+	if c.benchmarks == nil {
+		c.benchmarks = make(map[types.FileContractID]chan struct{})
+	}
+	c.benchmarks[id] = make(chan struct{})
+	return nil
+}
+
+// managedReleaseBenchmark releases a contract acquired by
+// managedAcquireBenchmark, waking any Editors and Downloaders that are
+// waiting for it.
+func (c *Contractor) managedReleaseBenchmark(id types.FileContractID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.benchmarks[id])
+	delete(c.benchmarks, id)
+}
+
+// BenchmarkDownloader returns a Downloader for measuring the download
+// throughput of a host. Unlike Downloader, it does not share the contract:
+// if an Editor or a Downloader is open for the contract, errContractInUse is
+// returned, and new Editors and Downloaders wait until the benchmark
+// Downloader is closed. The latest revision of the contract is used.
+func (c *Contractor) BenchmarkDownloader(contract modules.RenterContract) (Downloader, error) {
+	if err := c.managedAcquireBenchmark(contract.ID); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	if latest, ok := c.contracts[contract.ID]; ok {
+		contract = latest
+	}
+	c.mu.RUnlock()
+
+	d, err := c.newDownloader(contract)
+	if err != nil {
+		c.managedReleaseBenchmark(contract.ID)
+		return nil, err
+	}
+	d.release = func() { c.managedReleaseBenchmark(contract.ID) }
+	return d, nil
+}
@@ -0,0 +1,58 @@
+package contractor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestBenchmarkContractUse checks that benchmarks do not share a contract
+// with Editors and Downloaders.
+func TestBenchmarkContractUse(t *testing.T) {
+	c := &Contractor{}
+	id := types.FileContractID{1}
+
+	// a contract with an open Editor or Downloader cannot be benchmarked
+	c.managedAcquireContract(id)
+	if err := c.managedAcquireBenchmark(id); !IsContractInUse(err) {
+		t.Fatal("expected errContractInUse, got", err)
+	}
+	c.managedReleaseContract(id)
+
+	// other contracts are not affected
+	if err := c.managedAcquireBenchmark(types.FileContractID{2}); err != nil {
+		t.Fatal(err)
+	}
+	c.managedReleaseBenchmark(types.FileContractID{2})
+
+	// a contract can only be benchmarked once at a time
+	if err := c.managedAcquireBenchmark(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.managedAcquireBenchmark(id); !IsContractInUse(err) {
+		t.Fatal("expected errContractInUse, got", err)
+	}
+
+	// Editors and Downloaders wait for the benchmark to finish
+	acquired := make(chan struct{})
+	go func() {
+		c.managedAcquireContract(id)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("contract was acquired during a benchmark")
+	case <-time.After(100 * time.Millisecond):
+	}
+	c.managedReleaseBenchmark(id)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("contract was not acquired after the benchmark")
+	}
+	c.managedReleaseContract(id)
+	if len(c.sessions) != 0 || len(c.benchmarks) != 0 {
+		t.Fatal("contract was not released:", c.sessions, c.benchmarks)
+	}
+}
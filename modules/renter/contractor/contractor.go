@@ -42,10 +42,24 @@ type Contractor struct {
 	blockHeight     types.BlockHeight
 	cachedRevisions map[types.FileContractID]cachedRevision
 	contracts       map[types.FileContractID]modules.RenterContract
+	failedHosts     map[modules.NetAddress]types.BlockHeight // height of each host's last failure
+	interactions    map[modules.NetAddress]hostInteractions
 	lastChange      modules.ConsensusChangeID
 	renewHeight     types.BlockHeight // height at which to renew contracts
+	renewalPolicy   modules.RenewalPolicy
 
 	financialMetrics modules.RenterFinancialMetrics
+	currentPeriod    modules.RenterPeriodSpending
+	spendingHistory  []modules.RenterPeriodSpending
+
+	lastFormation modules.ContractAttempt
+	lastRenewal   modules.ContractAttempt
+
+	// benchmarks holds the contracts that are being benchmarked; the channel
+	// is closed when the benchmark ends. sessions counts the Editors and
+	// Downloaders that are open for each contract.
+	benchmarks map[types.FileContractID]chan struct{}
+	sessions   map[types.FileContractID]int
 
 	mu sync.RWMutex
 }
@@ -76,6 +90,32 @@ func (c *Contractor) Contract(hostAddr modules.NetAddress) (modules.RenterContra
 	return modules.RenterContract{}, false
 }
 
+// contractHost returns the hostdb entry of the host of a contract. The host is
+// looked up by the public key in the contract's unlock conditions, so that a
+// host that announced a new address is still found.
+func (c *Contractor) contractHost(contract modules.RenterContract) (modules.HostDBEntry, bool) {
+	pks := contract.LastRevision.UnlockConditions.PublicKeys
+	if len(pks) < 2 {
+		return c.hdb.Host(contract.NetAddress)
+	}
+	return c.hdb.HostByKey(pks[1])
+}
+
+// contractAddresses returns the addresses that should be excluded when
+// choosing new hosts for the contractor: the address of each contract, and
+// the current address of its host, in case the host has moved since the
+// contract was formed.
+func (c *Contractor) contractAddresses(contracts []modules.RenterContract) []modules.NetAddress {
+	var addrs []modules.NetAddress
+	for _, contract := range contracts {
+		addrs = append(addrs, contract.NetAddress)
+		if host, ok := c.contractHost(contract); ok && host.NetAddress != contract.NetAddress {
+			addrs = append(addrs, host.NetAddress)
+		}
+	}
+	return addrs
+}
+
 // Contracts returns the contracts formed by the contractor.
 func (c *Contractor) Contracts() (cs []modules.RenterContract) {
 	c.mu.RLock()
@@ -127,6 +167,9 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p
 
 		cachedRevisions: make(map[types.FileContractID]cachedRevision),
 		contracts:       make(map[types.FileContractID]modules.RenterContract),
+		failedHosts:     make(map[modules.NetAddress]types.BlockHeight),
+		interactions:    make(map[modules.NetAddress]hostInteractions),
+		renewalPolicy:   defaultRenewalPolicy,
 	}
 
 	// Load the prior persistence structures.
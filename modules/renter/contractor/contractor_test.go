@@ -32,8 +32,10 @@ func (newStub) AcceptTransactionSet([]types.Transaction) error      { return nil
 func (newStub) FeeEstimation() (a types.Currency, b types.Currency) { return }
 
 // hdb stubs
-func (newStub) Host(modules.NetAddress) (settings modules.HostDBEntry, ok bool) { return }
-func (newStub) RandomHosts(int, []modules.NetAddress) []modules.HostDBEntry     { return nil }
+func (newStub) Host(modules.NetAddress) (settings modules.HostDBEntry, ok bool)      { return }
+func (newStub) HostByKey(types.SiaPublicKey) (settings modules.HostDBEntry, ok bool) { return }
+func (newStub) IsOffline(modules.NetAddress) bool                                    { return false }
+func (newStub) RandomHosts(int, []modules.NetAddress) []modules.HostDBEntry          { return nil }
 
 // TestNew tests the New function.
 func TestNew(t *testing.T) {
@@ -157,6 +159,8 @@ func TestAllowance(t *testing.T) {
 type stubHostDB struct{}
 
 func (stubHostDB) Host(modules.NetAddress) (h modules.HostDBEntry, ok bool)         { return }
+func (stubHostDB) HostByKey(types.SiaPublicKey) (h modules.HostDBEntry, ok bool)    { return }
+func (stubHostDB) IsOffline(modules.NetAddress) bool                                { return false }
 func (stubHostDB) RandomHosts(int, []modules.NetAddress) (hs []modules.HostDBEntry) { return }
 
 // TestIntegrationSetAllowance tests the SetAllowance method.
@@ -165,7 +169,7 @@ func TestIntegrationSetAllowance(t *testing.T) {
 		t.SkipNow()
 	}
 	// create testing trio
-	h, c, m, err := newTestingTrio("TestIntegrationSetAllowance")
+	_, c, m, err := newTestingTrio("TestIntegrationSetAllowance")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -212,12 +216,18 @@ func TestIntegrationSetAllowance(t *testing.T) {
 		t.Fatal("expected 1 contract, got", len(c.contracts))
 	}
 
-	// reannounce host on different IP (easier than creating a new host)
-	addr := modules.NetAddress("127.0.0.1:" + c.Contracts()[0].NetAddress.Port())
-	err = h.AnnounceAddress(addr)
+	// create and announce a second host. The hostdb identifies hosts by
+	// their public key, so reannouncing the first host on a different IP
+	// would not add a host.
+	h2, err := newTestingHost(build.TempDir("contractor", "TestIntegrationSetAllowance", "Host2"), c.cs.(modules.ConsensusSet), c.tpool.(modules.TransactionPool))
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = h2.Announce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := h2.ExternalSettings().NetAddress
 	m.AddBlock()
 
 	// wait for hostdb to scan host
@@ -47,6 +47,8 @@ type (
 
 	hostDB interface {
 		Host(modules.NetAddress) (modules.HostDBEntry, bool)
+		HostByKey(types.SiaPublicKey) (modules.HostDBEntry, bool)
+		IsOffline(modules.NetAddress) bool
 		RandomHosts(n int, exclude []modules.NetAddress) []modules.HostDBEntry
 	}
 
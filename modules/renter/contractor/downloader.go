@@ -17,6 +17,13 @@ type Downloader interface {
 	// retrieve.
 	Sector(root crypto.Hash) ([]byte, error)
 
+	// PartialSector retrieves 'length' bytes starting at 'offset' from the
+	// sector with the specified Merkle root, and revises the underlying
+	// contract to pay the host for the data retrieved. The range must be
+	// aligned to crypto.SegmentSize, and the host must be at least
+	// modules.PartialSectorVersion.
+	PartialSector(root crypto.Hash, offset, length uint64) ([]byte, error)
+
 	// Close terminates the connection to the host.
 	Close() error
 }
@@ -25,16 +32,26 @@ type Downloader interface {
 // It implements the Downloader interface. hostDownloaders are NOT thread-
 // safe; calls to Sector must be serialized.
 type hostDownloader struct {
+	addr       modules.NetAddress
 	downloader *proto.Downloader
 	contractor *Contractor
+	release    func() // releases the contract when the downloader is closed
 }
 
 // Sector retrieves the sector with the specified Merkle root, and revises
 // the underlying contract to pay the host proportionally to the data
 // retrieve.
 func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
+	return hd.PartialSector(root, 0, modules.SectorSize)
+}
+
+// PartialSector retrieves 'length' bytes starting at 'offset' from the sector
+// with the specified Merkle root, and revises the underlying contract to pay
+// the host for the data retrieved.
+func (hd *hostDownloader) PartialSector(root crypto.Hash, offset, length uint64) ([]byte, error) {
 	oldSpending := hd.downloader.DownloadSpending
-	contract, sector, err := hd.downloader.Sector(root)
+	contract, sector, err := hd.downloader.PartialSector(root, offset, length)
+	hd.contractor.managedRecordInteraction(hd.addr, err)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +59,7 @@ func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
 
 	hd.contractor.mu.Lock()
 	hd.contractor.financialMetrics.DownloadSpending = hd.contractor.financialMetrics.DownloadSpending.Add(delta)
+	hd.contractor.currentPeriod.DownloadSpending = hd.contractor.currentPeriod.DownloadSpending.Add(delta)
 	hd.contractor.contracts[contract.ID] = contract
 	hd.contractor.saveSync()
 	hd.contractor.mu.Unlock()
@@ -51,18 +69,38 @@ func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
 
 // Close cleanly terminates the download loop with the host and closes the
 // connection.
-func (hd *hostDownloader) Close() error { return hd.downloader.Close() }
+func (hd *hostDownloader) Close() error {
+	err := hd.downloader.Close()
+	if hd.release != nil {
+		hd.release()
+		hd.release = nil
+	}
+	return err
+}
 
 // Downloader initiates the download request loop with a host, and returns a
 // Downloader.
 func (c *Contractor) Downloader(contract modules.RenterContract) (Downloader, error) {
+	c.managedAcquireContract(contract.ID)
+	d, err := c.newDownloader(contract)
+	if err != nil {
+		c.managedReleaseContract(contract.ID)
+		return nil, err
+	}
+	d.release = func() { c.managedReleaseContract(contract.ID) }
+	return d, nil
+}
+
+// newDownloader initiates the download request loop with the host of a
+// contract.
+func (c *Contractor) newDownloader(contract modules.RenterContract) (*hostDownloader, error) {
 	c.mu.RLock()
 	height := c.blockHeight
 	c.mu.RUnlock()
 	if height > contract.EndHeight() {
 		return nil, errors.New("contract has already ended")
 	}
-	host, ok := c.hdb.Host(contract.NetAddress)
+	host, ok := c.contractHost(contract)
 	if !ok {
 		return nil, errors.New("no record of that host")
 	}
@@ -85,6 +123,7 @@ func (c *Contractor) Downloader(contract modules.RenterContract) (Downloader, er
 		contract.LastRevision = cached.revision
 		d, err = proto.NewDownloader(host, contract)
 	}
+	c.managedRecordInteraction(contract.NetAddress, err)
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +132,7 @@ func (c *Contractor) Downloader(contract modules.RenterContract) (Downloader, er
 	d.SaveFn = c.saveRevision(contract.ID)
 
 	return &hostDownloader{
+		addr:       contract.NetAddress,
 		downloader: d,
 		contractor: c,
 	}, nil
@@ -49,6 +49,7 @@ type hostEditor struct {
 	editor     *proto.Editor
 	contract   modules.RenterContract
 	contractor *Contractor
+	release    func() // releases the contract when the editor is closed
 }
 
 // Address returns the NetAddress of the host.
@@ -63,13 +64,21 @@ func (he *hostEditor) EndHeight() types.BlockHeight { return he.contract.EndHeig
 
 // Close cleanly terminates the revision loop with the host and closes the
 // connection.
-func (he *hostEditor) Close() error { return he.editor.Close() }
+func (he *hostEditor) Close() error {
+	err := he.editor.Close()
+	if he.release != nil {
+		he.release()
+		he.release = nil
+	}
+	return err
+}
 
 // Upload negotiates a revision that adds a sector to a file contract.
 func (he *hostEditor) Upload(data []byte) (crypto.Hash, error) {
 	oldUploadSpending := he.editor.UploadSpending
 	oldStorageSpending := he.editor.StorageSpending
 	contract, sectorRoot, err := he.editor.Upload(data)
+	he.contractor.managedRecordInteraction(he.contract.NetAddress, err)
 	if err != nil {
 		return crypto.Hash{}, err
 	}
@@ -79,6 +88,8 @@ func (he *hostEditor) Upload(data []byte) (crypto.Hash, error) {
 	he.contractor.mu.Lock()
 	he.contractor.financialMetrics.UploadSpending = he.contractor.financialMetrics.UploadSpending.Add(uploadDelta)
 	he.contractor.financialMetrics.StorageSpending = he.contractor.financialMetrics.StorageSpending.Add(storageDelta)
+	he.contractor.currentPeriod.UploadSpending = he.contractor.currentPeriod.UploadSpending.Add(uploadDelta)
+	he.contractor.currentPeriod.StorageSpending = he.contractor.currentPeriod.StorageSpending.Add(storageDelta)
 	he.contractor.contracts[contract.ID] = contract
 	he.contractor.saveSync()
 	he.contractor.mu.Unlock()
@@ -90,6 +101,7 @@ func (he *hostEditor) Upload(data []byte) (crypto.Hash, error) {
 // Delete negotiates a revision that removes a sector from a file contract.
 func (he *hostEditor) Delete(root crypto.Hash) error {
 	contract, err := he.editor.Delete(root)
+	he.contractor.managedRecordInteraction(he.contract.NetAddress, err)
 	if err != nil {
 		return err
 	}
@@ -107,6 +119,7 @@ func (he *hostEditor) Delete(root crypto.Hash) error {
 func (he *hostEditor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newData []byte) error {
 	oldUploadSpending := he.editor.UploadSpending
 	contract, err := he.editor.Modify(oldRoot, newRoot, offset, newData)
+	he.contractor.managedRecordInteraction(he.contract.NetAddress, err)
 	if err != nil {
 		return err
 	}
@@ -114,6 +127,7 @@ func (he *hostEditor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newDat
 
 	he.contractor.mu.Lock()
 	he.contractor.financialMetrics.UploadSpending = he.contractor.financialMetrics.UploadSpending.Add(uploadDelta)
+	he.contractor.currentPeriod.UploadSpending = he.contractor.currentPeriod.UploadSpending.Add(uploadDelta)
 	he.contractor.contracts[contract.ID] = contract
 	he.contractor.saveSync()
 	he.contractor.mu.Unlock()
@@ -125,13 +139,26 @@ func (he *hostEditor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newDat
 // Editor initiates the contract revision process with a host, and returns
 // an Editor.
 func (c *Contractor) Editor(contract modules.RenterContract) (Editor, error) {
+	c.managedAcquireContract(contract.ID)
+	e, err := c.newEditor(contract)
+	if err != nil {
+		c.managedReleaseContract(contract.ID)
+		return nil, err
+	}
+	e.release = func() { c.managedReleaseContract(contract.ID) }
+	return e, nil
+}
+
+// newEditor initiates the contract revision process with the host of a
+// contract.
+func (c *Contractor) newEditor(contract modules.RenterContract) (*hostEditor, error) {
 	c.mu.RLock()
 	height := c.blockHeight
 	c.mu.RUnlock()
 	if height > contract.EndHeight() {
 		return nil, errors.New("contract has already ended")
 	}
-	host, ok := c.hdb.Host(contract.NetAddress)
+	host, ok := c.contractHost(contract)
 	if !ok {
 		return nil, errors.New("no record of that host")
 	}
@@ -161,6 +188,7 @@ func (c *Contractor) Editor(contract modules.RenterContract) (Editor, error) {
 		contract.MerkleRoots = cached.merkleRoots
 		e, err = proto.NewEditor(host, contract, height)
 	}
+	c.managedRecordInteraction(contract.NetAddress, err)
 	if err != nil {
 		return nil, err
 	}
@@ -20,10 +20,23 @@ var (
 	// the contractor will cap host's MaxCollateral setting to this value
 	maxCollateral = types.SiacoinPrecision.Mul64(1e3) // 1k SC
 
+	// formationTimeout is the maximum amount of time that will be spent
+	// trying to reach the desired number of contracts
+	formationTimeout = func() time.Duration {
+		if build.Release == "testing" {
+			return time.Minute
+		}
+		return 3 * time.Hour
+	}()
+	// maxFormationRounds is the number of times the hostdb will be sampled
+	// for new hosts while forming contracts
+	maxFormationRounds = 3
+
 	// ErrInsufficientAllowance indicates that the renter's allowance is less
 	// than the amount necessary to store at least one sector
 	ErrInsufficientAllowance = errors.New("allowance is not large enough to perform contract creation")
 	errTooExpensive          = errors.New("host price was too high")
+	errBudgetExceeded        = errors.New("contract would exceed the allowance")
 )
 
 // maxSectors is the estimated maximum number of sectors that the allowance
@@ -60,17 +73,27 @@ func maxSectors(a modules.Allowance, hdb hostDB) (uint64, error) {
 	return numSectors, nil
 }
 
-// managedNewContract negotiates an initial file contract with the specified
-// host, saves it, and returns it.
-func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uint64, endHeight types.BlockHeight) (modules.RenterContract, error) {
-	// reject hosts that are too expensive
+// checkHost rejects hosts that are too expensive to form contracts with, and
+// caps the collateral that will be requested from the host. The capped entry
+// is returned.
+func checkHost(host modules.HostDBEntry) (modules.HostDBEntry, error) {
 	if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
-		return modules.RenterContract{}, errTooExpensive
+		return modules.HostDBEntry{}, errTooExpensive
 	}
-	// cap host.MaxCollateral
 	if host.MaxCollateral.Cmp(maxCollateral) > 0 {
 		host.MaxCollateral = maxCollateral
 	}
+	return host, nil
+}
+
+// managedNewContract negotiates an initial file contract with the specified
+// host, saves it, and returns it.
+func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uint64, endHeight types.BlockHeight) (modules.RenterContract, error) {
+	// reject hosts that are too expensive, and cap host.MaxCollateral
+	host, err := checkHost(host)
+	if err != nil {
+		return modules.RenterContract{}, err
+	}
 
 	// get an address to use for negotiation
 	uc, err := c.wallet.NextAddress()
@@ -105,56 +128,105 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uin
 }
 
 // managedFormContracts forms contracts with n hosts using the allowance
-// parameters.
-func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight types.BlockHeight) ([]modules.RenterContract, error) {
+// parameters. Hosts that fail to form a contract are replaced with new hosts
+// until n contracts have been formed, the hostdb runs out of hosts,
+// formationTimeout elapses, or another contract would exceed the budget. The
+// budget is the share of the allowance belonging to n hosts. It is checked
+// against the funds that the formed contracts actually locked up, plus the
+// storage cost of the next host. Like the rest of the allowance, it does not
+// count the fees paid to form the contracts, which are reported as spending.
+// The outcome is recorded as the most recent formation attempt.
+func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight types.BlockHeight, a modules.Allowance) ([]modules.RenterContract, error) {
 	if n <= 0 {
 		return nil, nil
 	}
+	budget := a.Funds.Mul64(uint64(n)).Div64(a.Hosts)
 
-	// Sample at least 10 hosts.
-	nRandomHosts := 2 * n
-	if nRandomHosts < 10 {
-		nRandomHosts = 10
-	}
-	// Don't select from hosts we've already formed contracts with
 	c.mu.RLock()
-	var exclude []modules.NetAddress
+	attempt := c.newAttempt(n)
+	var duration types.BlockHeight
+	if endHeight > c.blockHeight {
+		duration = endHeight - c.blockHeight
+	}
+	var existing []modules.RenterContract
 	for _, contract := range c.contracts {
-		exclude = append(exclude, contract.NetAddress)
+		existing = append(existing, contract)
 	}
+	failed := c.recentlyFailed()
 	c.mu.RUnlock()
-	hosts := c.hdb.RandomHosts(nRandomHosts, exclude)
-	if len(hosts) < n {
-		return nil, fmt.Errorf("not enough hosts in hostdb for contract formation, got %v but needed %v", len(hosts), n)
-	}
+
+	// Don't select from hosts we've already formed contracts with
+	exclude := c.contractAddresses(existing)
 
 	var contracts []modules.RenterContract
-	var errs []string
-	for _, h := range hosts {
-		contract, err := c.managedNewContract(h, numSectors, endHeight)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("\t%v: %v", h.NetAddress, err))
-			continue
+	var spent types.Currency
+	for round := 0; round < maxFormationRounds && len(contracts) < n; round++ {
+		// Sample at least 10 hosts. Hosts that recently failed are avoided,
+		// unless there are not enough other hosts.
+		needed := n - len(contracts)
+		nRandomHosts := 2 * needed
+		if nRandomHosts < 10 {
+			nRandomHosts = 10
 		}
-		contracts = append(contracts, contract)
-		if len(contracts) >= n {
+		hosts := c.hdb.RandomHosts(nRandomHosts, append(append([]modules.NetAddress(nil), exclude...), failed...))
+		if len(hosts) < needed {
+			hosts = c.hdb.RandomHosts(nRandomHosts, exclude)
+		}
+		if round == 0 && len(hosts) < n {
+			err := fmt.Errorf("not enough hosts in hostdb for contract formation, got %v but needed %v", len(hosts), n)
+			attempt.Error = err.Error()
+			c.managedFinishAttempt(attempt, false)
+			return nil, err
+		} else if len(hosts) == 0 {
 			break
 		}
-		if build.Release != "testing" {
-			// sleep for 1 minute to alleviate potential block propagation issues
-			time.Sleep(60 * time.Second)
+
+		for _, h := range hosts {
+			if len(contracts) >= n || time.Since(attempt.StartTime) > formationTimeout {
+				break
+			}
+			// each host is only tried once per attempt
+			exclude = append(exclude, h.NetAddress)
+
+			cost := h.StoragePrice.Mul64(numSectors * modules.SectorSize).Mul64(uint64(duration))
+			if spent.Add(cost).Cmp(budget) > 0 {
+				c.managedAddFailure(&attempt, h.NetAddress, errBudgetExceeded)
+				continue
+			}
+			contract, err := c.managedNewContract(h, numSectors, endHeight)
+			if err != nil {
+				c.managedAddFailure(&attempt, h.NetAddress, err)
+				continue
+			}
+			contracts = append(contracts, contract)
+			spent = spent.Add(contractLockedFunds(contract))
+			attempt.Cost = attempt.Cost.Add(contract.TotalCost)
+			if len(contracts) >= n {
+				break
+			}
+			if build.Release != "testing" {
+				// sleep for 1 minute to alleviate potential block propagation issues
+				time.Sleep(60 * time.Second)
+			}
 		}
 	}
+	attempt.Formed = len(contracts)
+
+	var errs []string
+	for _, f := range attempt.Failures {
+		errs = append(errs, fmt.Sprintf("\t%v: %v", f.NetAddress, f.Error))
+	}
 	// If we couldn't form any contracts, return an error. Otherwise, just log
-	// the failures.
-	// TODO: is there a better way to handle failure here? Should we prefer an
-	// all-or-nothing approach? We can't pick new hosts to negotiate with
-	// because they'll probably be more expensive than we can afford.
+	// the failures; they can be inspected through ContractAttempts.
 	if len(contracts) == 0 {
-		return nil, errors.New("could not form any contracts:\n" + strings.Join(errs, "\n"))
+		err := errors.New("could not form any contracts:\n" + strings.Join(errs, "\n"))
+		attempt.Error = err.Error()
+		c.managedFinishAttempt(attempt, false)
+		return nil, err
 	} else if len(contracts) < n {
 		c.log.Printf("WARN: failed to form desired number of contracts (wanted %v, got %v):\n%v", n, len(contracts), strings.Join(errs, "\n"))
 	}
+	c.managedFinishAttempt(attempt, false)
 
 	return contracts, nil
 }
@@ -3,8 +3,10 @@ package contractor
 import (
 	"bytes"
 	"errors"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -310,6 +312,226 @@ func TestIntegrationUploadDownload(t *testing.T) {
 	}
 }
 
+// TestIntegrationPartialDownload tests that the renter can download and verify
+// parts of a sector using the Merkle range proofs sent by the host.
+func TestIntegrationPartialDownload(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, _, err := newTestingTrio("TestIntegrationPartialDownload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get the host's entry from the db
+	hostEntry, ok := c.hdb.Host(h.ExternalSettings().NetAddress)
+	if !ok {
+		t.Fatal("no entry for host in db")
+	}
+
+	// form a contract with the host and upload a sector
+	contract, err := c.managedNewContract(hostEntry, 10, c.blockHeight+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor, err := c.Editor(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := crypto.RandBytes(int(modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := editor.Upload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = editor.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hosts that predate partial downloads are only asked for whole sectors
+	realHDB := c.hdb
+	c.hdb = versionHostDB{realHDB, "1.0.1"}
+	oldDownloader, err := c.Downloader(c.contracts[contract.ID])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oldDownloader.PartialSector(root, 0, crypto.SegmentSize); err == nil {
+		t.Fatal("partial download from an old host succeeded")
+	}
+	retrieved, err := oldDownloader.Sector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Fatal("downloaded sector does not match original")
+	}
+	err = oldDownloader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.hdb = realHDB
+
+	// download several ranges of the sector, followed by the whole sector.
+	// The testing host supports partial downloads before they are released.
+	contract = c.contracts[contract.ID]
+	hostEntry.Version = modules.PartialSectorVersion
+	downloader, err := proto.NewDownloader(hostEntry, contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges := []struct {
+		offset, length uint64
+	}{
+		{0, crypto.SegmentSize},
+		{crypto.SegmentSize * 3, crypto.SegmentSize * 5},
+		{modules.SectorSize - crypto.SegmentSize*2, crypto.SegmentSize * 2},
+		{0, modules.SectorSize},
+	}
+	var downloaded uint64
+	for _, r := range ranges {
+		downloaded += r.length
+		_, retrieved, err := downloader.PartialSector(root, r.offset, r.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data[r.offset:r.offset+r.length], retrieved) {
+			t.Fatal("downloaded data does not match original", r.offset, r.length)
+		}
+	}
+
+	// ranges that are not segment-aligned or out of bounds are refused
+	if _, _, err := downloader.PartialSector(root, 1, crypto.SegmentSize); err == nil {
+		t.Fatal("unaligned download succeeded")
+	}
+	if _, _, err := downloader.PartialSector(root, modules.SectorSize, crypto.SegmentSize); err == nil {
+		t.Fatal("out of bounds download succeeded")
+	}
+
+	// the host should only have been paid for the data that was downloaded
+	if downloader.DownloadSpending.Cmp(hostEntry.DownloadBandwidthPrice.Mul64(downloaded)) != 0 {
+		t.Fatal("wrong download spending:", downloader.DownloadSpending)
+	}
+	err = downloader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestIntegrationMaintenance tests that a host in maintenance mode finishes
+// the current revision, refuses new revisions without being penalized, and
+// keeps serving downloads.
+func TestIntegrationMaintenance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, _, err := newTestingTrio("TestIntegrationMaintenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get the host's entry from the db
+	hostEntry, ok := c.hdb.Host(h.ExternalSettings().NetAddress)
+	if !ok {
+		t.Fatal("no entry for host in db")
+	}
+
+	// form a contract with the host and open an editor
+	contract, err := c.managedNewContract(hostEntry, 10, c.blockHeight+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor, err := c.Editor(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// enter maintenance mode while the editor is open
+	err = h.SetMaintenance(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := h.MaintenanceStatus(); !status.Enabled || !status.SafeToStop {
+		t.Fatal("idle host in maintenance mode should be safe to stop:", status)
+	}
+	if h.ExternalSettings().AcceptingContracts {
+		t.Fatal("host in maintenance mode should not advertise accepting contracts")
+	}
+
+	// the open editor can finish one more revision, after which the host
+	// ends the revision loop
+	data, err := crypto.RandBytes(int(modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := editor.Upload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := editor.Upload(data); err == nil {
+		t.Fatal("host did not end the revision loop")
+	}
+	editor.Close()
+
+	// new revisions are refused with a retryable error, which does not count
+	// as a failed interaction
+	c.mu.RLock()
+	failures := c.interactions[contract.NetAddress].Failures
+	c.mu.RUnlock()
+	contract = c.contracts[contract.ID]
+	_, err = c.Editor(contract)
+	if err != modules.ErrHostMaintenance {
+		t.Fatal("expected ErrHostMaintenance, got", err)
+	}
+	c.mu.RLock()
+	newFailures := c.interactions[contract.NetAddress].Failures
+	c.mu.RUnlock()
+	if newFailures != failures {
+		t.Fatal("refused revision was counted as a failure")
+	}
+
+	// downloads are still served
+	downloader, err := c.Downloader(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieved, err := downloader.Sector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Fatal("downloaded data does not match original")
+	}
+	err = downloader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// leaving maintenance mode allows revisions again
+	err = h.SetMaintenance(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.MaintenanceStatus().SafeToStop {
+		t.Fatal("host out of maintenance mode should not be safe to stop")
+	}
+	contract = c.contracts[contract.ID]
+	editor, err = c.Editor(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = editor.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestIntegrationDelete tests that the contractor can delete a sector from a
 // contract previously formed with a host.
 func TestIntegrationDelete(t *testing.T) {
@@ -369,6 +591,108 @@ func TestIntegrationDelete(t *testing.T) {
 	}
 }
 
+// TestIntegrationLostSectors tests that the host reports the sectors it has
+// lost to the renter.
+func TestIntegrationLostSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, _, err := newTestingTrio("TestIntegrationLostSectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get the host's entry from the db
+	hostEntry, ok := c.hdb.Host(h.ExternalSettings().NetAddress)
+	if !ok {
+		t.Fatal("no entry for host in db")
+	}
+
+	// form a contract with the host
+	contract, err := c.managedNewContract(hostEntry, 10, c.blockHeight+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// upload two sectors
+	editor, err := c.Editor(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		data, err := crypto.RandBytes(int(modules.SectorSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = editor.Upload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = editor.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the host has not lost anything yet. The testing host supports the RPC
+	// before it is released.
+	realHDB := c.hdb
+	c.hdb = versionHostDB{realHDB, modules.LostSectorsVersion}
+	contract = c.contracts[contract.ID]
+	lost, err := c.LostSectors(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lost) != 0 {
+		t.Fatal("host reported lost sectors:", lost)
+	}
+
+	// lose the first sector
+	err = h.DeleteSector(contract.MerkleRoots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost, err = c.LostSectors(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lost) != 1 || lost[0] != contract.MerkleRoots[0] {
+		t.Fatal("host did not report the lost sector:", lost)
+	}
+
+	// hosts that predate the RPC are not asked, and the query is not
+	// recorded as a failed interaction
+	interactions := c.interactions[contract.NetAddress]
+	c.hdb = versionHostDB{realHDB, "1.0.1"}
+	_, err = c.LostSectors(contract)
+	if err != errLostSectorsUnsupported {
+		t.Fatal("expected errLostSectorsUnsupported, got", err)
+	}
+	if hi := c.interactions[contract.NetAddress]; hi != interactions {
+		t.Fatal("lost sector queries were recorded as interactions:", hi, interactions)
+	}
+}
+
+// versionHostDB reports every host as running the given version.
+type versionHostDB struct {
+	hostDB
+	version string
+}
+
+func (hdb versionHostDB) Host(addr modules.NetAddress) (modules.HostDBEntry, bool) {
+	host, ok := hdb.hostDB.Host(addr)
+	host.Version = hdb.version
+	return host, ok
+}
+
+func (hdb versionHostDB) HostByKey(spk types.SiaPublicKey) (modules.HostDBEntry, bool) {
+	host, ok := hdb.hostDB.HostByKey(spk)
+	host.Version = hdb.version
+	return host, ok
+}
+
 // TestIntegrationInsertDelete tests that the contractor can insert and delete
 // a sector during the same revision.
 func TestIntegrationInsertDelete(t *testing.T) {
@@ -606,6 +930,177 @@ func TestIntegrationRenew(t *testing.T) {
 	}
 }
 
+// TestIntegrationHostMoved tests that the contractor can keep revising,
+// downloading from, and renewing a contract after the host has announced a
+// new address.
+func TestIntegrationHostMoved(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, m, err := newTestingTrio("TestIntegrationHostMoved")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get the host's entry from the db
+	oldAddr := h.ExternalSettings().NetAddress
+	hostEntry, ok := c.hdb.Host(oldAddr)
+	if !ok {
+		t.Fatal("no entry for host in db")
+	}
+
+	// form a contract with the host and upload a sector
+	contract, err := c.managedNewContract(hostEntry, 10, c.blockHeight+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor, err := c.Editor(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := crypto.RandBytes(int(modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := editor.Upload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = editor.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// announce a different address that reaches the same host
+	newAddr := modules.NetAddress(net.JoinHostPort("127.0.0.1", oldAddr.Port()))
+	if oldAddr.Host() == "127.0.0.1" {
+		newAddr = modules.NetAddress(net.JoinHostPort("localhost", oldAddr.Port()))
+	}
+	err = h.AnnounceAddress(newAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddBlock()
+	for i := 0; i < 100; i++ {
+		if _, ok := c.hdb.Host(newAddr); ok {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, ok := c.hdb.Host(newAddr); !ok {
+		t.Fatal("hostdb did not learn the new address of the host")
+	}
+	if _, ok := c.hdb.Host(oldAddr); ok {
+		t.Fatal("hostdb should no longer know the host by its old address")
+	}
+
+	// the contract can still be revised and downloaded from
+	contract = c.contracts[contract.ID]
+	editor, err = c.Editor(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = editor.Upload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = editor.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract = c.contracts[contract.ID]
+	downloader, err := c.Downloader(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieved, err := downloader.Sector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Fatal("downloaded data does not match original")
+	}
+	err = downloader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the contract can be renewed, and keeps its original address
+	if err := c.managedCheckRenewal(contract); err != nil {
+		t.Fatal(err)
+	}
+	contract = c.contracts[contract.ID]
+	renewed, err := c.managedRenew(contract, modules.SectorSize*10, c.blockHeight+200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.NetAddress != oldAddr {
+		t.Fatal("renewed contract should keep the original address, got", renewed.NetAddress)
+	}
+	if len(renewed.MerkleRoots) != 2 {
+		t.Fatal("renewed contract should have 2 sectors, got", len(renewed.MerkleRoots))
+	}
+}
+
+// TestIntegrationContractPolicy tests that the host applies its contract
+// policy to new and renewed contracts, and that the renter learns why a
+// contract was rejected.
+func TestIntegrationContractPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, _, err := newTestingTrio("TestIntegrationContractPolicy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get the host's entry from the db
+	hostEntry, ok := c.hdb.Host(h.ExternalSettings().NetAddress)
+	if !ok {
+		t.Fatal("no entry for host in db")
+	}
+
+	// a contract that is shorter than the minimum duration is rejected
+	err = h.SetContractPolicy(modules.HostContractPolicy{MinDuration: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.managedNewContract(hostEntry, 10, c.blockHeight+100)
+	if err == nil || !strings.Contains(err.Error(), "contract duration is shorter than the host allows") {
+		t.Fatal("expected the short contract to be rejected, got", err)
+	}
+	contract, err := c.managedNewContract(hostEntry, 10, c.blockHeight+200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the renter of the contract is on the allowlist, so a new contract
+	// with a new renter key is rejected while the renewal is accepted
+	renterPK := contract.SecretKey.PublicKey()
+	err = h.SetContractPolicy(modules.HostContractPolicy{
+		RequireAllowlist: true,
+		AllowedRenters: []types.SiaPublicKey{{
+			Algorithm: types.SignatureEd25519,
+			Key:       renterPK[:],
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.managedNewContract(hostEntry, 10, c.blockHeight+200)
+	if err == nil || !strings.Contains(err.Error(), "renter is not on the allowlist of the host") {
+		t.Fatal("expected the unknown renter to be rejected, got", err)
+	}
+	_, err = c.managedRenew(contract, 10, c.blockHeight+300)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestResync tests that the contractor can resync with a host after being
 // interrupted during contract revision.
 func TestResync(t *testing.T) {
@@ -0,0 +1,56 @@
+package contractor
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
+)
+
+var (
+	// errLostSectorsUnsupported is returned when asking a host that predates
+	// RPCLostSectors for its lost sectors.
+	errLostSectorsUnsupported = errors.New("host does not support lost sector queries")
+)
+
+// LostSectors asks the host of a contract which of the contract's sectors it
+// has lost, returning their Merkle roots. Hosts that predate RPCLostSectors
+// are not asked. The queries are not recorded as interactions with the host,
+// because a query that fails says little about whether the host can store
+// and serve data.
+func (c *Contractor) LostSectors(contract modules.RenterContract) ([]crypto.Hash, error) {
+	c.mu.RLock()
+	height := c.blockHeight
+	c.mu.RUnlock()
+	if height > contract.EndHeight() {
+		return nil, errors.New("contract has already ended")
+	}
+
+	// The hostdb entry is needed to know whether the host supports the RPC.
+	host, ok := c.contractHost(contract)
+	if !ok {
+		return nil, errors.New("no record of that host")
+	}
+	if build.VersionCmp(host.Version, modules.LostSectorsVersion) < 0 {
+		return nil, errLostSectorsUnsupported
+	}
+
+	lost, err := proto.LostSectors(host, contract)
+	if proto.IsRevisionMismatch(err) {
+		// try again with the cached revision
+		c.mu.RLock()
+		cached, ok := c.cachedRevisions[contract.ID]
+		c.mu.RUnlock()
+		if !ok {
+			// nothing we can do; return original error
+			return nil, err
+		}
+		c.log.Printf("host %v has different revision for %v; retrying with cached revision", contract.NetAddress, contract.ID)
+		contract.LastRevision = cached.revision
+		contract.MerkleRoots = cached.merkleRoots
+		lost, err = proto.LostSectors(host, contract)
+	}
+	return lost, err
+}
@@ -15,6 +15,12 @@ type contractorPersist struct {
 	LastChange       modules.ConsensusChangeID
 	RenewHeight      types.BlockHeight
 	FinancialMetrics modules.RenterFinancialMetrics
+	CurrentPeriod    modules.RenterPeriodSpending
+	SpendingHistory  []modules.RenterPeriodSpending
+	LastFormation    modules.ContractAttempt
+	LastRenewal      modules.ContractAttempt
+	RenewalPolicy    modules.RenewalPolicy
+	Interactions     map[modules.NetAddress]hostInteractions
 }
 
 // persistData returns the data in the Contractor that will be saved to disk.
@@ -25,6 +31,12 @@ func (c *Contractor) persistData() contractorPersist {
 		LastChange:       c.lastChange,
 		RenewHeight:      c.renewHeight,
 		FinancialMetrics: c.financialMetrics,
+		CurrentPeriod:    c.currentPeriod,
+		SpendingHistory:  c.spendingHistory,
+		LastFormation:    c.lastFormation,
+		LastRenewal:      c.lastRenewal,
+		RenewalPolicy:    c.renewalPolicy,
+		Interactions:     c.interactions,
 	}
 	for _, rev := range c.cachedRevisions {
 		data.CachedRevisions = append(data.CachedRevisions, rev)
@@ -37,7 +49,8 @@ func (c *Contractor) persistData() contractorPersist {
 
 // load loads the Contractor persistence data from disk.
 func (c *Contractor) load() error {
-	var data contractorPersist
+	// older persist files do not contain a renewal policy
+	data := contractorPersist{RenewalPolicy: defaultRenewalPolicy}
 	err := c.persist.load(&data)
 	if err != nil {
 		return err
@@ -53,6 +66,14 @@ func (c *Contractor) load() error {
 	c.lastChange = data.LastChange
 	c.renewHeight = data.RenewHeight
 	c.financialMetrics = data.FinancialMetrics
+	c.currentPeriod = data.CurrentPeriod
+	c.spendingHistory = data.SpendingHistory
+	c.lastFormation = data.LastFormation
+	c.lastRenewal = data.LastRenewal
+	c.renewalPolicy = data.RenewalPolicy
+	for addr, hi := range data.Interactions {
+		c.interactions[addr] = hi
+	}
 	return nil
 }
 
@@ -0,0 +1,150 @@
+package contractor
+
+import (
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// planContract returns a ContractPlan for forming or renewing a contract with
+// host. The host is expected to have passed checkHost.
+func (c *Contractor) planContract(action string, host modules.HostDBEntry, numSectors uint64, startHeight, endHeight types.BlockHeight) modules.ContractPlan {
+	params := proto.ContractParams{
+		Host:        host,
+		Filesize:    numSectors * modules.SectorSize,
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+	}
+	return modules.ContractPlan{
+		Action:     action,
+		NetAddress: host.NetAddress,
+		PublicKey:  host.PublicKey,
+		Sectors:    numSectors,
+		EndHeight:  endHeight,
+		Cost:       proto.ContractCost(params, c.tpool),
+	}
+}
+
+// PlanAllowance returns the changes that SetSettings would make to the
+// contract set if it were called with a and p. The same decisions are made as
+// in SetAllowance, with p as the renewal policy, but no contracts are
+// negotiated and the wallet is not used.
+//
+// Contracts that would be renewed are reported with the cost of renewal. If a
+// contract could not be renewed because its host is unknown, too expensive,
+// or forbidden by the renewal policy, a new contract is planned in its place.
+// Kept contracts report the number of sectors they currently store.
+func (c *Contractor) PlanAllowance(a modules.Allowance, p modules.RenewalPolicy) (modules.AllowancePlan, error) {
+	if err := checkRenewalPolicy(p); err != nil {
+		return modules.AllowancePlan{}, err
+	}
+	numSectors, err := c.managedValidateAllowance(a)
+	if err != nil {
+		return modules.AllowancePlan{}, err
+	}
+
+	c.mu.RLock()
+	shouldRenew := a.Period != c.allowance.Period || a.Funds.Cmp(c.allowance.Funds) != 0
+	shouldWait := c.blockHeight+a.Period < c.contractEndHeight()
+	remaining := int(a.Hosts) - len(c.contracts)
+	height := c.blockHeight
+	var existing []modules.RenterContract
+	for _, contract := range c.contracts {
+		existing = append(existing, contract)
+	}
+	// mirror the end heights chosen by SetAllowance
+	endHeight := height + a.Period
+	if len(c.contracts) > 0 {
+		if !shouldRenew {
+			endHeight = c.contractEndHeight()
+		} else if a.Period == c.allowance.Period {
+			endHeight = c.contractEndHeight() + 1
+		}
+	}
+	c.mu.RUnlock()
+
+	plan := modules.AllowancePlan{Allowance: a}
+	exclude := c.contractAddresses(existing)
+
+	// keep reports the existing contracts as kept
+	keep := func() {
+		for _, contract := range existing {
+			host, _ := c.contractHost(contract)
+			plan.Contracts = append(plan.Contracts, modules.ContractPlan{
+				Action:     modules.PlanActionKeep,
+				ID:         contract.ID,
+				NetAddress: contract.NetAddress,
+				PublicKey:  host.PublicKey,
+				Sectors:    uint64(len(contract.MerkleRoots)),
+				EndHeight:  contract.EndHeight(),
+			})
+		}
+	}
+
+	switch {
+	case !shouldRenew:
+		// the remaining contracts are formed below, even if the existing
+		// contracts end after the new period
+		keep()
+	case shouldWait:
+		// the new allowance takes effect when the contracts are renewed
+		keep()
+		return plan, nil
+	default:
+		renewed := 0
+		for _, contract := range existing {
+			if renewed >= int(a.Hosts) {
+				break
+			}
+			if err := c.managedCheckRenewalPolicy(contract, p); err != nil {
+				remaining++
+				continue
+			}
+			host, ok := c.contractHost(contract)
+			if !ok {
+				remaining++
+				continue
+			}
+			host, err := checkHost(host)
+			if err != nil {
+				remaining++
+				continue
+			}
+			cp := c.planContract(modules.PlanActionRenew, host, numSectors, height, endHeight)
+			cp.ID = contract.ID
+			plan.Contracts = append(plan.Contracts, cp)
+			renewed++
+		}
+	}
+
+	// choose hosts for the new contracts
+	if remaining > 0 {
+		nRandomHosts := 2 * remaining
+		if nRandomHosts < 10 {
+			nRandomHosts = 10
+		}
+		hosts := c.hdb.RandomHosts(nRandomHosts, exclude)
+		if len(hosts) < remaining {
+			return modules.AllowancePlan{}, fmt.Errorf("not enough hosts in hostdb for contract formation, got %v but needed %v", len(hosts), remaining)
+		}
+		formed := 0
+		for _, h := range hosts {
+			if formed >= remaining {
+				break
+			}
+			host, err := checkHost(h)
+			if err != nil {
+				continue
+			}
+			plan.Contracts = append(plan.Contracts, c.planContract(modules.PlanActionForm, host, numSectors, height, endHeight))
+			formed++
+		}
+	}
+
+	for _, cp := range plan.Contracts {
+		plan.TotalCost = plan.TotalCost.Add(cp.Cost)
+	}
+	return plan, nil
+}
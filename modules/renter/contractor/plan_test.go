@@ -0,0 +1,149 @@
+package contractor
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestPlanAllowanceWait tests that PlanAllowance forms the remaining
+// contracts when the allowance only adds hosts, even if the existing
+// contracts end after the new period, as SetAllowance does.
+func TestPlanAllowanceWait(t *testing.T) {
+	hdb := listHostDB{
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "foo", StoragePrice: types.NewCurrency64(1)}},
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "bar", StoragePrice: types.NewCurrency64(1)}},
+	}
+	var rc modules.RenterContract
+	rc.NetAddress = "foo"
+	rc.LastRevision.NewWindowStart = 100
+	a := modules.Allowance{
+		Funds:       types.SiacoinPrecision,
+		Hosts:       1,
+		Period:      10,
+		RenewWindow: 5,
+	}
+	c := &Contractor{
+		hdb:         hdb,
+		tpool:       newStub{},
+		blockHeight: 10,
+		allowance:   a,
+		contracts:   map[types.FileContractID]modules.RenterContract{rc.ID: rc},
+	}
+
+	// the funds and period are unchanged, so the contracts are not renewed,
+	// and the contracts end after blockHeight+Period
+	a.Hosts = 2
+	plan, err := c.PlanAllowance(a, defaultRenewalPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Contracts) != 2 {
+		t.Fatal("expected 2 planned contracts, got", plan.Contracts)
+	}
+	if plan.Contracts[0].Action != modules.PlanActionKeep || plan.Contracts[0].NetAddress != "foo" {
+		t.Fatal("existing contract should be kept:", plan.Contracts[0])
+	}
+	if plan.Contracts[1].Action != modules.PlanActionForm || plan.Contracts[1].NetAddress != "bar" || plan.Contracts[1].EndHeight != rc.EndHeight() {
+		t.Fatal("new contract should be formed with the end height of the existing contract:", plan.Contracts[1])
+	}
+
+	// a changed period that would end earlier waits for the renewal
+	a.Period = 20
+	plan, err = c.PlanAllowance(a, defaultRenewalPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Contracts) != 1 || plan.Contracts[0].Action != modules.PlanActionKeep {
+		t.Fatal("expected only the existing contract to be kept, got", plan.Contracts)
+	}
+}
+
+// TestPlanAllowanceRenewalPolicy tests that PlanAllowance replaces the
+// contracts that the renewal policy forbids renewing, as SetAllowance does.
+func TestPlanAllowanceRenewalPolicy(t *testing.T) {
+	hdb := listHostDB{
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "foo", StoragePrice: types.NewCurrency64(130)}},
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "bar", StoragePrice: types.NewCurrency64(100)}},
+	}
+	var rc modules.RenterContract
+	rc.NetAddress = "foo"
+	rc.StoragePrice = types.NewCurrency64(100)
+	rc.LastRevision.NewWindowStart = 20
+	a := modules.Allowance{
+		Funds:       types.SiacoinPrecision,
+		Hosts:       1,
+		Period:      10,
+		RenewWindow: 5,
+	}
+	c := &Contractor{
+		hdb:          hdb,
+		tpool:        newStub{},
+		blockHeight:  10,
+		allowance:    a,
+		contracts:    map[types.FileContractID]modules.RenterContract{rc.ID: rc},
+		interactions: make(map[modules.NetAddress]hostInteractions),
+	}
+
+	// a changed period renews the contract if the price increase is allowed
+	a.Period = 20
+	plan, err := c.PlanAllowance(a, modules.RenewalPolicy{MaxPriceIncrease: 30, MaxFailureRate: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Contracts) != 1 || plan.Contracts[0].Action != modules.PlanActionRenew || plan.Contracts[0].NetAddress != "foo" {
+		t.Fatal("expected the contract to be renewed, got", plan.Contracts)
+	}
+
+	// otherwise a new contract is formed in its place
+	plan, err = c.PlanAllowance(a, modules.RenewalPolicy{MaxPriceIncrease: 20, MaxFailureRate: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Contracts) != 1 || plan.Contracts[0].Action != modules.PlanActionForm || plan.Contracts[0].NetAddress != "bar" {
+		t.Fatal("expected a new contract to replace the contract, got", plan.Contracts)
+	}
+	if _, err := c.PlanAllowance(a, modules.RenewalPolicy{MaxFailureRate: 101}); err != errFailureRateRange {
+		t.Fatal("expected errFailureRateRange, got", err)
+	}
+}
+
+// TestPlanAllowanceMovedHost tests that PlanAllowance does not plan a new
+// contract with a host that has moved since its contract was formed.
+func TestPlanAllowanceMovedHost(t *testing.T) {
+	pk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte("foo")}
+	hdb := listHostDB{
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "moved", StoragePrice: types.NewCurrency64(1)}, PublicKey: pk},
+		{HostExternalSettings: modules.HostExternalSettings{NetAddress: "bar", StoragePrice: types.NewCurrency64(1)}},
+	}
+	var rc modules.RenterContract
+	rc.NetAddress = "foo"
+	rc.LastRevision.NewWindowStart = 100
+	rc.LastRevision.UnlockConditions.PublicKeys = []types.SiaPublicKey{{}, pk}
+	a := modules.Allowance{
+		Funds:       types.SiacoinPrecision,
+		Hosts:       1,
+		Period:      10,
+		RenewWindow: 5,
+	}
+	c := &Contractor{
+		hdb:         hdb,
+		tpool:       newStub{},
+		blockHeight: 10,
+		allowance:   a,
+		contracts:   map[types.FileContractID]modules.RenterContract{rc.ID: rc},
+	}
+
+	a.Hosts = 2
+	plan, err := c.PlanAllowance(a, defaultRenewalPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Contracts) != 2 {
+		t.Fatal("expected 2 planned contracts, got", plan.Contracts)
+	}
+	if plan.Contracts[1].Action != modules.PlanActionForm || plan.Contracts[1].NetAddress != "bar" {
+		t.Fatal("new contract should be formed with bar, not the moved host:", plan.Contracts[1])
+	}
+}
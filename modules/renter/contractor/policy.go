@@ -0,0 +1,130 @@
+package contractor
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// defaultRenewalPolicy is the renewal policy used until the renter
+	// specifies one.
+	defaultRenewalPolicy = modules.RenewalPolicy{
+		MaxPriceIncrease: 25,
+		MaxFailureRate:   50,
+	}
+
+	// minInteractions is the number of interactions with a host that must be
+	// recorded before its failure rate is considered.
+	minInteractions = uint64(10)
+
+	errFailureRateRange = errors.New("max failure rate must be at most 100 percent")
+	errHostOffline      = errors.New("host is offline")
+	errHostUnreliable   = errors.New("host failure rate is too high")
+	errPriceIncrease    = errors.New("host price increased too much")
+)
+
+// hostInteractions counts the successful and failed interactions with a
+// host, such as uploads, downloads, and connection attempts.
+type hostInteractions struct {
+	Successes uint64
+	Failures  uint64
+}
+
+// managedRecordInteraction records the outcome of an interaction with a host.
+// Hosts that gracefully end a revision loop, or that refuse a revision because
+// they are in maintenance mode, are not penalized.
+func (c *Contractor) managedRecordInteraction(addr modules.NetAddress, err error) {
+	if err == modules.ErrStopResponse || err == modules.ErrHostMaintenance {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hi := c.interactions[addr]
+	if err != nil {
+		hi.Failures++
+	} else {
+		hi.Successes++
+	}
+	c.interactions[addr] = hi
+}
+
+// managedCheckRenewal returns an error if the renewal policy forbids renewing
+// contract. Hosts that are unknown to the hostdb are left for managedRenew to
+// reject.
+func (c *Contractor) managedCheckRenewal(contract modules.RenterContract) error {
+	c.mu.RLock()
+	p := c.renewalPolicy
+	c.mu.RUnlock()
+	return c.managedCheckRenewalPolicy(contract, p)
+}
+
+// managedCheckRenewalPolicy returns an error if the renewal policy p forbids
+// renewing contract.
+func (c *Contractor) managedCheckRenewalPolicy(contract modules.RenterContract, p modules.RenewalPolicy) error {
+	host, ok := c.contractHost(contract)
+	if !ok {
+		return nil
+	}
+	if c.hdb.IsOffline(host.NetAddress) {
+		return errHostOffline
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	// contracts formed before prices were recorded are not checked
+	if !contract.StoragePrice.IsZero() {
+		maxPrice := contract.StoragePrice.Mul64(100 + p.MaxPriceIncrease).Div64(100)
+		if host.StoragePrice.Cmp(maxPrice) > 0 {
+			return errPriceIncrease
+		}
+	}
+	hi := c.interactions[contract.NetAddress]
+	if total := hi.Successes + hi.Failures; total >= minInteractions && hi.Failures*100 > p.MaxFailureRate*total {
+		return errHostUnreliable
+	}
+	return nil
+}
+
+// RenewalPolicy returns the current renewal policy.
+func (c *Contractor) RenewalPolicy() modules.RenewalPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.renewalPolicy
+}
+
+// checkRenewalPolicy returns an error if the renewal policy is malformed.
+func checkRenewalPolicy(p modules.RenewalPolicy) error {
+	if p.MaxFailureRate > 100 {
+		return errFailureRateRange
+	}
+	return nil
+}
+
+// SetRenewalPolicy sets the policy that decides which contracts are renewed.
+func (c *Contractor) SetRenewalPolicy(p modules.RenewalPolicy) error {
+	if err := checkRenewalPolicy(p); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.renewalPolicy = p
+	return c.saveSync()
+}
+
+// SetSettings sets the renewal policy and the allowance. Both are validated
+// before either is applied, so a rejected allowance does not change the
+// renewal policy. The policy is applied first, because SetAllowance uses it
+// to decide which contracts are renewed.
+func (c *Contractor) SetSettings(a modules.Allowance, p modules.RenewalPolicy) error {
+	if err := checkRenewalPolicy(p); err != nil {
+		return err
+	}
+	if _, err := c.managedValidateAllowance(a); err != nil {
+		return err
+	}
+	if err := c.SetRenewalPolicy(p); err != nil {
+		return err
+	}
+	return c.SetAllowance(a)
+}
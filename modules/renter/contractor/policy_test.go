@@ -0,0 +1,133 @@
+package contractor
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// offlineHostDB is a listHostDB in which every host is offline.
+type offlineHostDB struct {
+	listHostDB
+}
+
+func (offlineHostDB) IsOffline(modules.NetAddress) bool { return true }
+
+// TestCheckRenewal tests that managedCheckRenewal enforces the renewal
+// policy.
+func TestCheckRenewal(t *testing.T) {
+	host := modules.HostDBEntry{HostExternalSettings: modules.HostExternalSettings{
+		NetAddress:   "foo",
+		StoragePrice: types.NewCurrency64(120),
+	}}
+	c := &Contractor{
+		hdb:           listHostDB{host},
+		interactions:  make(map[modules.NetAddress]hostInteractions),
+		renewalPolicy: defaultRenewalPolicy,
+	}
+	contract := modules.RenterContract{
+		NetAddress:   "foo",
+		StoragePrice: types.NewCurrency64(100),
+	}
+
+	// a 20% price increase is allowed by the default policy
+	if err := c.managedCheckRenewal(contract); err != nil {
+		t.Fatal(err)
+	}
+	c.renewalPolicy.MaxPriceIncrease = 10
+	if err := c.managedCheckRenewal(contract); err != errPriceIncrease {
+		t.Fatal("expected errPriceIncrease, got", err)
+	}
+	c.renewalPolicy = defaultRenewalPolicy
+
+	// failure rates are only considered after minInteractions
+	for i := uint64(0); i < minInteractions-1; i++ {
+		c.managedRecordInteraction("foo", errHostOffline)
+	}
+	if err := c.managedCheckRenewal(contract); err != nil {
+		t.Fatal(err)
+	}
+	c.managedRecordInteraction("foo", errHostOffline)
+	if err := c.managedCheckRenewal(contract); err != errHostUnreliable {
+		t.Fatal("expected errHostUnreliable, got", err)
+	}
+	// graceful stops are not failures
+	c.interactions = make(map[modules.NetAddress]hostInteractions)
+	c.managedRecordInteraction("foo", modules.ErrStopResponse)
+	if len(c.interactions) != 0 {
+		t.Fatal("graceful stop should not be recorded")
+	}
+
+	c.hdb = offlineHostDB{listHostDB{host}}
+	if err := c.managedCheckRenewal(contract); err != errHostOffline {
+		t.Fatal("expected errHostOffline, got", err)
+	}
+}
+
+// TestRenewContractsReplace tests that contracts which the renewal policy
+// forbids renewing are removed from the contract set.
+func TestRenewContractsReplace(t *testing.T) {
+	host := modules.HostDBEntry{HostExternalSettings: modules.HostExternalSettings{
+		NetAddress:   "foo",
+		StoragePrice: types.NewCurrency64(200),
+	}}
+	var rc modules.RenterContract
+	rc.NetAddress = "foo"
+	rc.StoragePrice = types.NewCurrency64(100)
+	rc.LastRevision.NewWindowStart = 20
+	c := &Contractor{
+		hdb:         listHostDB{host},
+		blockHeight: 15,
+		allowance: modules.Allowance{
+			Funds:       types.SiacoinPrecision,
+			Hosts:       1,
+			Period:      20,
+			RenewWindow: 10,
+		},
+		contracts:     map[types.FileContractID]modules.RenterContract{rc.ID: rc},
+		failedHosts:   make(map[modules.NetAddress]types.BlockHeight),
+		interactions:  make(map[modules.NetAddress]hostInteractions),
+		renewalPolicy: defaultRenewalPolicy,
+		persist:       new(memPersist),
+		log:           persist.NewLogger(ioutil.Discard),
+	}
+
+	if err := c.managedRenewContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.contracts) != 0 {
+		t.Fatal("contract should have been replaced")
+	}
+	_, renewal := c.ContractAttempts()
+	if renewal.Wanted != 1 || renewal.Formed != 0 {
+		t.Fatal("wrong renewal attempt:", renewal)
+	} else if len(renewal.Failures) != 1 || renewal.Failures[0].Error != errPriceIncrease.Error() {
+		t.Fatal("expected price increase failure, got", renewal.Failures)
+	}
+	if len(c.recentlyFailed()) != 1 {
+		t.Fatal("replaced host should be avoided when forming new contracts")
+	}
+}
+
+// TestSetSettings checks that SetSettings does not change the renewal policy
+// if the allowance is rejected.
+func TestSetSettings(t *testing.T) {
+	c := &Contractor{
+		hdb:           stubHostDB{},
+		renewalPolicy: defaultRenewalPolicy,
+	}
+	p := modules.RenewalPolicy{MaxPriceIncrease: 50, MaxFailureRate: 10}
+	if err := c.SetSettings(modules.Allowance{}, p); err != errAllowanceNoHosts {
+		t.Fatal("expected errAllowanceNoHosts, got", err)
+	}
+	if c.RenewalPolicy() != defaultRenewalPolicy {
+		t.Fatal("renewal policy was changed by a rejected allowance:", c.RenewalPolicy())
+	}
+	p.MaxFailureRate = 101
+	if err := c.SetSettings(modules.Allowance{}, p); err != errFailureRateRange {
+		t.Fatal("expected errFailureRateRange, got", err)
+	}
+}
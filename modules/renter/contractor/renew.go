@@ -14,15 +14,14 @@ import (
 // It returns the new contract. This is a blocking call that
 // performs network I/O.
 func (c *Contractor) managedRenew(contract modules.RenterContract, numSectors uint64, newEndHeight types.BlockHeight) (modules.RenterContract, error) {
-	host, ok := c.hdb.Host(contract.NetAddress)
+	host, ok := c.contractHost(contract)
 	if !ok {
 		return modules.RenterContract{}, errors.New("no record of that host")
-	} else if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
-		return modules.RenterContract{}, errTooExpensive
 	}
-	// cap host.MaxCollateral
-	if host.MaxCollateral.Cmp(maxCollateral) > 0 {
-		host.MaxCollateral = maxCollateral
+	// reject hosts that are too expensive, and cap host.MaxCollateral
+	host, err := checkHost(host)
+	if err != nil {
+		return modules.RenterContract{}, err
 	}
 
 	// get an address to use for negotiation
@@ -50,12 +49,19 @@ func (c *Contractor) managedRenew(contract modules.RenterContract, numSectors ui
 		txnBuilder.Drop() // return unused outputs to wallet
 		return modules.RenterContract{}, err
 	}
+	// The renewed contract keeps the address of the original contract, even
+	// if the host has announced a new address since. The renter finds the
+	// contract of each piece by the address recorded when it was uploaded, and
+	// the host itself is always looked up by its public key.
+	newContract.NetAddress = contract.NetAddress
 
 	return newContract, nil
 }
 
 // managedRenewContracts renews any contracts that are up for renewal, using
-// the current allowance.
+// the current allowance. Contracts that the renewal policy forbids renewing
+// are removed from the contract set instead; new contracts will be formed in
+// their place, and the renter will migrate their data to the new hosts.
 func (c *Contractor) managedRenewContracts() error {
 	c.mu.RLock()
 	// Renew contracts when they enter the renew window.
@@ -72,8 +78,8 @@ func (c *Contractor) managedRenewContracts() error {
 	}
 
 	c.mu.RLock()
-	endHeight := c.blockHeight + c.allowance.Period
 	numSectors, err := maxSectors(c.allowance, c.hdb)
+	attempt := c.newAttempt(len(renewSet))
 	c.mu.RUnlock()
 	if err != nil {
 		return err
@@ -83,26 +89,82 @@ func (c *Contractor) managedRenewContracts() error {
 
 	// map old ID to new contract, for easy replacement later
 	newContracts := make(map[types.FileContractID]modules.RenterContract)
+	var replaced []types.FileContractID
 	for _, contract := range renewSet {
+		if err := c.managedCheckRenewal(contract); err != nil {
+			c.log.Printf("INFO: not renewing contract with %v: %v", contract.NetAddress, err)
+			c.managedAddFailure(&attempt, contract.NetAddress, err)
+			replaced = append(replaced, contract.ID)
+			continue
+		}
+		c.mu.RLock()
+		endHeight := c.renewEndHeight(contract)
+		c.mu.RUnlock()
 		newContract, err := c.managedRenew(contract, numSectors, endHeight)
 		if err != nil {
 			c.log.Printf("WARN: failed to renew contract with %v: %v", contract.NetAddress, err)
+			c.managedAddFailure(&attempt, contract.NetAddress, err)
 		} else {
 			newContracts[contract.ID] = newContract
+			attempt.Formed++
+			attempt.Cost = attempt.Cost.Add(newContract.TotalCost)
 		}
 		if build.Release != "testing" {
 			// sleep for 1 minute to alleviate potential block propagation issues
 			time.Sleep(60 * time.Second)
 		}
 	}
+	if len(newContracts) == 0 {
+		attempt.Error = "could not renew any contracts"
+	}
+	c.managedFinishAttempt(attempt, true)
 
 	// replace old contracts with renewed ones
 	c.mu.Lock()
+	c.applyRenewals(newContracts, replaced)
+	err = c.saveSync()
+	c.mu.Unlock()
+	return err
+}
+
+// renewsCurrentPeriod returns whether a contract belongs to the current
+// spending period, meaning that renewing it begins a new period. A contract
+// that could not be renewed with the rest of its period belongs to the
+// period that was closed by the earlier renewals. c.mu must be held.
+func (c *Contractor) renewsCurrentPeriod(contract modules.RenterContract) bool {
+	return contract.EndHeight() >= c.currentPeriod.EndHeight
+}
+
+// renewEndHeight returns the end height that a contract should be renewed
+// to. Contracts of the current period are renewed for a full period, and
+// contracts that are renewed late are renewed to the end of the current
+// period, so that the contracts of a period end together. c.mu must be held.
+func (c *Contractor) renewEndHeight(contract modules.RenterContract) types.BlockHeight {
+	if c.renewsCurrentPeriod(contract) {
+		return c.blockHeight + c.allowance.Period
+	}
+	return c.currentPeriod.EndHeight
+}
+
+// applyRenewals replaces the renewed contracts in the contract set with their
+// renewals, and removes the contracts in 'replaced'. The current period is
+// closed once, by the renewals of its contracts; renewals of contracts whose
+// period has already been closed are added to the current period. c.mu must
+// be held.
+func (c *Contractor) applyRenewals(newContracts map[types.FileContractID]modules.RenterContract, replaced []types.FileContractID) {
+	for id := range newContracts {
+		if c.renewsCurrentPeriod(c.contracts[id]) {
+			c.closePeriod()
+			break
+		}
+	}
 	for id, contract := range newContracts {
 		delete(c.contracts, id)
 		c.contracts[contract.ID] = contract
+		c.addContractSpending(contract)
+		delete(c.interactions, contract.NetAddress)
+	}
+	for _, id := range replaced {
+		delete(c.contracts, id)
 	}
-	err = c.saveSync()
-	c.mu.Unlock()
-	return err
 }
@@ -0,0 +1,105 @@
+package contractor
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errUnknownPeriod = errors.New("no spending record exists for that period")
+)
+
+// contractLockedFunds returns the renter funds that were placed in a contract
+// when it was formed. Contracts formed before costs were recorded report
+// their current renter funds instead.
+func contractLockedFunds(contract modules.RenterContract) types.Currency {
+	fees := contract.ContractFee.Add(contract.SiafundFee).Add(contract.TxnFee)
+	if contract.TotalCost.Cmp(fees) <= 0 {
+		return contract.RenterFunds()
+	}
+	return contract.TotalCost.Sub(fees)
+}
+
+// addContractSpending records the cost of a newly formed or renewed contract
+// in the current period. If the contract is the first one of the period, the
+// period starts at the current height and ends with the contract.
+func (c *Contractor) addContractSpending(contract modules.RenterContract) {
+	p := &c.currentPeriod
+	if p.ContractSpending.IsZero() {
+		p.StartHeight = c.blockHeight
+		p.EndHeight = contract.EndHeight()
+	}
+	p.ContractSpending = p.ContractSpending.Add(contract.TotalCost)
+	p.ContractFees = p.ContractFees.Add(contract.ContractFee)
+	p.SiafundFees = p.SiafundFees.Add(contract.SiafundFee)
+	p.TxnFees = p.TxnFees.Add(contract.TxnFee)
+	p.LockedFunds = p.LockedFunds.Add(contractLockedFunds(contract))
+}
+
+// periodSpending returns the spending report of the current period. The
+// fields that depend on the state of the active contracts are filled in at
+// call time.
+func (c *Contractor) periodSpending() modules.RenterPeriodSpending {
+	p := c.currentPeriod
+	if p.EndHeight == 0 {
+		// periods recorded by older versions do not store their end height
+		p.EndHeight = c.contractEndHeight()
+	}
+	p.ExpectedRefund = types.ZeroCurrency
+	for _, contract := range c.contracts {
+		p.ExpectedRefund = p.ExpectedRefund.Add(contract.RenterFunds())
+	}
+	p.Unspent = types.ZeroCurrency
+	if p.Allocated.Cmp(p.ContractSpending) > 0 {
+		p.Unspent = p.Allocated.Sub(p.ContractSpending)
+	}
+	return p
+}
+
+// closePeriod moves the current period into the spending history and begins
+// a new period using the current allowance. It should be called before the
+// contracts of the closing period are removed from the contract set.
+func (c *Contractor) closePeriod() {
+	// A period in which no contracts were formed has nothing to report, so
+	// it is reused rather than recorded.
+	if c.currentPeriod.ContractSpending.IsZero() {
+		c.currentPeriod.StartHeight = c.blockHeight
+		c.currentPeriod.Allocated = c.allowance.Funds
+		return
+	}
+	closed := c.periodSpending()
+	closed.Closed = true
+	c.spendingHistory = append(c.spendingHistory, closed)
+	c.currentPeriod = modules.RenterPeriodSpending{
+		Period:      closed.Period + 1,
+		StartHeight: c.blockHeight,
+		Allocated:   c.allowance.Funds,
+	}
+	c.log.Printf("INFO: closed spending period %v; %v hastings spent on contracts, %v hastings expected as refund", closed.Period, closed.ContractSpending, closed.ExpectedRefund)
+}
+
+// CurrentPeriodSpending returns the spending report of the current contract
+// period.
+func (c *Contractor) CurrentPeriodSpending() modules.RenterPeriodSpending {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.periodSpending()
+}
+
+// PeriodSpending returns the spending report of the specified contract
+// period. Closed periods are returned as they were when they closed.
+func (c *Contractor) PeriodSpending(period uint64) (modules.RenterPeriodSpending, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if period == c.currentPeriod.Period {
+		return c.periodSpending(), nil
+	}
+	for _, p := range c.spendingHistory {
+		if p.Period == period {
+			return p, nil
+		}
+	}
+	return modules.RenterPeriodSpending{}, errUnknownPeriod
+}
@@ -0,0 +1,165 @@
+package contractor
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// newSpendingContract returns a contract with the specified costs and renter
+// funds.
+func newSpendingContract(id byte, totalCost, fee, renterFunds uint64) modules.RenterContract {
+	var rc modules.RenterContract
+	rc.ID = types.FileContractID{id}
+	rc.LastRevision.NewWindowStart = 100
+	rc.LastRevision.NewValidProofOutputs = []types.SiacoinOutput{{Value: types.NewCurrency64(renterFunds)}}
+	rc.TotalCost = types.NewCurrency64(totalCost)
+	rc.ContractFee = types.NewCurrency64(fee)
+	return rc
+}
+
+// TestPeriodSpending tests that spending is attributed to the correct period,
+// and that closed periods are preserved across a save and load.
+func TestPeriodSpending(t *testing.T) {
+	c := &Contractor{
+		allowance:   modules.Allowance{Funds: types.NewCurrency64(1000)},
+		blockHeight: 10,
+		contracts:   make(map[types.FileContractID]modules.RenterContract),
+		persist:     new(memPersist),
+		log:         persist.NewLogger(ioutil.Discard),
+	}
+	c.currentPeriod.Allocated = c.allowance.Funds
+
+	// form two contracts in the first period
+	for _, rc := range []modules.RenterContract{
+		newSpendingContract(1, 300, 10, 250),
+		newSpendingContract(2, 300, 10, 290),
+	} {
+		c.contracts[rc.ID] = rc
+		c.addContractSpending(rc)
+	}
+	c.currentPeriod.UploadSpending = types.NewCurrency64(40)
+
+	p := c.CurrentPeriodSpending()
+	if p.Period != 0 || p.Closed {
+		t.Fatal("expected open period 0, got", p.Period, p.Closed)
+	} else if p.StartHeight != 10 || p.EndHeight != 100 {
+		t.Fatal("wrong period bounds:", p.StartHeight, p.EndHeight)
+	} else if p.ContractSpending.Cmp(types.NewCurrency64(600)) != 0 {
+		t.Fatal("wrong contract spending:", p.ContractSpending)
+	} else if p.ContractFees.Cmp(types.NewCurrency64(20)) != 0 {
+		t.Fatal("wrong contract fees:", p.ContractFees)
+	} else if p.LockedFunds.Cmp(types.NewCurrency64(580)) != 0 {
+		t.Fatal("wrong locked funds:", p.LockedFunds)
+	} else if p.ExpectedRefund.Cmp(types.NewCurrency64(540)) != 0 {
+		t.Fatal("wrong expected refund:", p.ExpectedRefund)
+	} else if p.Unspent.Cmp(types.NewCurrency64(400)) != 0 {
+		t.Fatal("wrong unspent funds:", p.Unspent)
+	}
+
+	// renew the contracts, closing the first period
+	c.blockHeight = 90
+	c.closePeriod()
+	c.contracts = make(map[types.FileContractID]modules.RenterContract)
+	rc := newSpendingContract(3, 500, 10, 490)
+	c.contracts[rc.ID] = rc
+	c.addContractSpending(rc)
+
+	p = c.CurrentPeriodSpending()
+	if p.Period != 1 || p.StartHeight != 90 {
+		t.Fatal("expected period 1 starting at 90, got", p.Period, p.StartHeight)
+	} else if !p.UploadSpending.IsZero() {
+		t.Fatal("upload spending leaked into the new period")
+	}
+
+	// the closed period should be unaffected by the new contracts
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	c.spendingHistory = nil
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	closed, err := c.PeriodSpending(0)
+	if err != nil {
+		t.Fatal(err)
+	} else if !closed.Closed {
+		t.Fatal("first period should be closed")
+	} else if closed.UploadSpending.Cmp(types.NewCurrency64(40)) != 0 {
+		t.Fatal("wrong upload spending:", closed.UploadSpending)
+	} else if closed.ExpectedRefund.Cmp(types.NewCurrency64(540)) != 0 {
+		t.Fatal("wrong expected refund:", closed.ExpectedRefund)
+	}
+	if _, err := c.PeriodSpending(2); err != errUnknownPeriod {
+		t.Fatal("expected errUnknownPeriod, got", err)
+	}
+}
+
+// TestRenewInBatches tests that renewing the contracts of a period in two
+// batches closes the period once, and that the late renewals join the new
+// period.
+func TestRenewInBatches(t *testing.T) {
+	c := &Contractor{
+		allowance:    modules.Allowance{Funds: types.NewCurrency64(1000), Period: 100},
+		blockHeight:  10,
+		contracts:    make(map[types.FileContractID]modules.RenterContract),
+		interactions: make(map[modules.NetAddress]hostInteractions),
+		persist:      new(memPersist),
+		log:          persist.NewLogger(ioutil.Discard),
+	}
+	c.currentPeriod.Allocated = c.allowance.Funds
+	for _, rc := range []modules.RenterContract{
+		newSpendingContract(1, 300, 10, 290),
+		newSpendingContract(2, 300, 10, 290),
+	} {
+		c.contracts[rc.ID] = rc
+		c.addContractSpending(rc)
+	}
+
+	// the first batch renews one of the contracts for a full period
+	c.blockHeight = 90
+	old := c.contracts[types.FileContractID{1}]
+	if !c.renewsCurrentPeriod(old) {
+		t.Fatal("contract of the current period should begin a new period")
+	} else if h := c.renewEndHeight(old); h != 190 {
+		t.Fatal("expected the first batch to be renewed to 190, got", h)
+	}
+	renewed := newSpendingContract(3, 400, 10, 390)
+	renewed.LastRevision.NewWindowStart = 190
+	c.applyRenewals(map[types.FileContractID]modules.RenterContract{old.ID: renewed}, nil)
+	if len(c.spendingHistory) != 1 || c.currentPeriod.Period != 1 {
+		t.Fatal("first batch should close the period:", len(c.spendingHistory), c.currentPeriod.Period)
+	}
+
+	// the second batch is renewed later, to the end of the new period
+	c.blockHeight = 92
+	old = c.contracts[types.FileContractID{2}]
+	if c.renewsCurrentPeriod(old) {
+		t.Fatal("contract of the closed period should not begin a new period")
+	} else if h := c.renewEndHeight(old); h != 190 {
+		t.Fatal("expected the second batch to be renewed to 190, got", h)
+	}
+	renewed = newSpendingContract(4, 400, 10, 390)
+	renewed.LastRevision.NewWindowStart = 190
+	c.applyRenewals(map[types.FileContractID]modules.RenterContract{old.ID: renewed}, nil)
+	if len(c.spendingHistory) != 1 || c.currentPeriod.Period != 1 {
+		t.Fatal("second batch should not close the period:", len(c.spendingHistory), c.currentPeriod.Period)
+	}
+
+	p := c.CurrentPeriodSpending()
+	if p.StartHeight != 90 || p.EndHeight != 190 {
+		t.Fatal("wrong period bounds:", p.StartHeight, p.EndHeight)
+	} else if p.ContractSpending.Cmp(types.NewCurrency64(800)) != 0 {
+		t.Fatal("both batches should be counted in the new period:", p.ContractSpending)
+	}
+	closed := c.spendingHistory[0]
+	if closed.ContractSpending.Cmp(types.NewCurrency64(600)) != 0 || closed.EndHeight != 100 {
+		t.Fatal("closed period was changed by the renewals:", closed.ContractSpending, closed.EndHeight)
+	}
+	if len(c.contracts) != 2 {
+		t.Fatal("expected the two renewed contracts, got", len(c.contracts))
+	}
+}
@@ -30,6 +30,11 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 			expired = append(expired, id)
 		}
 	}
+	// if every contract of the period expired without being renewed, the
+	// period is over
+	if len(expired) > 0 && len(expired) == len(c.contracts) {
+		c.closePeriod()
+	}
 	for _, id := range expired {
 		delete(c.contracts, id)
 		c.log.Debugln("INFO: deleted expired contract", id)
@@ -163,7 +163,7 @@ func TestDownloadContracts(t *testing.T) {
 	}
 
 	var hc downloadContractor
-	rt, err := newContractorTester("TestDownloadContracts", nil, &hc)
+	rt, err := newContractorTester("TestDownloadContracts", stubHostDB{}, &hc)
 	if err != nil {
 		t.Fatal(err)
 	}
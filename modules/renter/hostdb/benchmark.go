@@ -0,0 +1,188 @@
+package hostdb
+
+// benchmark.go measures the performance of hosts. Unlike scans, which only
+// check whether a host is online, benchmarks are optional and are used to
+// score hosts on their latency and throughput. The hostdb measures the latency
+// of every active host itself, while throughput is measured by the renter,
+// which downloads sectors from the hosts it has contracts with and reports the
+// results through RecordThroughput.
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// maxBenchmarkHistory is the number of benchmarks that are kept for each
+	// host.
+	maxBenchmarkHistory = 20
+
+	// benchmarkPings is the number of settings requests made when measuring
+	// the latency of a host. The fastest request is recorded, as it is the
+	// least affected by congestion.
+	benchmarkPings = 3
+
+	// benchmarkCheckInterval is how often the benchmark loop checks whether
+	// benchmarks are due.
+	benchmarkCheckInterval = time.Minute
+
+	// minBenchmarkInterval is the shortest interval that hosts can be
+	// benchmarked at.
+	minBenchmarkInterval = 10 * time.Minute
+)
+
+var (
+	// defaultBenchmarkSettings leave benchmarking disabled, as download
+	// benchmarks cost money.
+	defaultBenchmarkSettings = modules.HostBenchmarkSettings{
+		Interval: 6 * time.Hour,
+	}
+
+	errBenchmarkInterval = errors.New("benchmark interval must be at least 10 minutes")
+)
+
+// recordBenchmark adds a benchmark to the history of a host.
+func (hdb *HostDB) recordBenchmark(entry *hostEntry, b modules.HostBenchmark) {
+	entry.Benchmarks = append(entry.Benchmarks, b)
+	if len(entry.Benchmarks) > maxBenchmarkHistory {
+		entry.Benchmarks = entry.Benchmarks[len(entry.Benchmarks)-maxBenchmarkHistory:]
+	}
+}
+
+// reweightHost recalculates the weight of a host. If the host is active, it
+// is reinserted into the host tree, as the weight of a node cannot change
+// while it is in the tree.
+func (hdb *HostDB) reweightHost(entry *hostEntry) {
+	key := entry.key()
+	node, active := hdb.activeHosts[key]
+	if !active {
+		entry.Weight = hdb.scoreBreakdown(*entry).Score
+		return
+	}
+	node.removeNode()
+	delete(hdb.activeHosts, key)
+	entry.Weight = hdb.scoreBreakdown(*entry).Score
+	hdb.insertNode(entry)
+}
+
+// managedBenchmarkLatency measures the round-trip latency of a host. Failed
+// requests are not recorded, as scans already account for hosts that are
+// offline.
+func (hdb *HostDB) managedBenchmarkLatency(entry *hostEntry) {
+	hdb.mu.RLock()
+	netAddr := entry.ReachableAddress
+	if netAddr == "" {
+		netAddr = entry.NetAddress
+	}
+	pubKey := entry.PublicKey
+	hdb.mu.RUnlock()
+
+	var best time.Duration
+	for i := 0; i < benchmarkPings; i++ {
+		start := time.Now()
+		if _, err := hdb.managedRequestSettings(netAddr, pubKey); err != nil {
+			hdb.log.Debugln("Benchmarking", netAddr, "failed:", err)
+			continue
+		}
+		if latency := time.Since(start); best == 0 || latency < best {
+			best = latency
+		}
+	}
+	if best == 0 {
+		return
+	}
+
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	hdb.recordBenchmark(entry, modules.HostBenchmark{
+		Timestamp: time.Now(),
+		Latency:   best,
+	})
+	hdb.reweightHost(entry)
+}
+
+// RecordThroughput records the throughput of a download of n bytes from a
+// host that took the given amount of time.
+func (hdb *HostDB) RecordThroughput(addr modules.NetAddress, n uint64, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	entry, exists := hdb.lookupHost(addr)
+	if !exists {
+		return
+	}
+	hdb.recordBenchmark(entry, modules.HostBenchmark{
+		Timestamp:  time.Now(),
+		Throughput: uint64(float64(n) / elapsed.Seconds()),
+	})
+	hdb.reweightHost(entry)
+	hdb.save()
+}
+
+// BenchmarkSettings returns the settings that control the benchmarking of
+// hosts.
+func (hdb *HostDB) BenchmarkSettings() modules.HostBenchmarkSettings {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	return hdb.benchmarkSettings
+}
+
+// SetBenchmarkSettings sets the settings that control the benchmarking of
+// hosts.
+func (hdb *HostDB) SetBenchmarkSettings(s modules.HostBenchmarkSettings) error {
+	if s.Interval < minBenchmarkInterval {
+		return errBenchmarkInterval
+	}
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	hdb.benchmarkSettings = s
+	return hdb.saveSync()
+}
+
+// threadedBenchmark periodically measures the latency of the active hosts,
+// if benchmarking is enabled.
+func (hdb *HostDB) threadedBenchmark() {
+	err := hdb.tg.Add()
+	if err != nil {
+		return
+	}
+	defer hdb.tg.Done()
+
+	var lastBenchmark time.Time
+	for {
+		select {
+		case <-hdb.tg.StopChan():
+			return
+		case <-time.After(benchmarkCheckInterval):
+		}
+
+		hdb.mu.RLock()
+		settings := hdb.benchmarkSettings
+		var entries []*hostEntry
+		for _, node := range hdb.activeHosts {
+			entries = append(entries, node.hostEntry)
+		}
+		hdb.mu.RUnlock()
+		if !settings.Enabled || time.Since(lastBenchmark) < settings.Interval {
+			continue
+		}
+
+		for _, entry := range entries {
+			select {
+			case <-hdb.tg.StopChan():
+				return
+			default:
+			}
+			hdb.managedBenchmarkLatency(entry)
+		}
+		lastBenchmark = time.Now()
+
+		hdb.mu.Lock()
+		hdb.save()
+		hdb.mu.Unlock()
+	}
+}
@@ -0,0 +1,96 @@
+package hostdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestBenchmarkFactors checks that benchmarks are used to score hosts on
+// their latency and throughput.
+func TestBenchmarkFactors(t *testing.T) {
+	var entry hostEntry
+	if f := throughputFactor(entry); f != 1 {
+		t.Error("host without download benchmarks should not be penalized, got", f)
+	}
+
+	// latency benchmarks take precedence over scans
+	entry.ScanHistory = []modules.HostDBScan{{Success: true, Latency: 4 * targetLatency}}
+	entry.Benchmarks = []modules.HostBenchmark{
+		{Latency: 2 * targetLatency},
+		{Throughput: targetThroughput / 4},
+		{Throughput: targetThroughput * 3 / 4},
+	}
+	if f := latencyFactor(entry); f != 0.5 {
+		t.Error("host with twice the target latency should receive 0.5, got", f)
+	}
+	if f := throughputFactor(entry); f != 0.5 {
+		t.Error("host with half the target throughput should receive 0.5, got", f)
+	}
+	entry.Benchmarks = []modules.HostBenchmark{{Throughput: 2 * targetThroughput}}
+	if f := throughputFactor(entry); f != 1 {
+		t.Error("fast host should not be penalized, got", f)
+	}
+}
+
+// TestRecordThroughput checks that recording a download benchmark updates the
+// host's history and weight.
+func TestRecordThroughput(t *testing.T) {
+	hdb := bareHostDB()
+	hdb.persist = &memPersist{}
+	hdb.scoreWeights = defaultScoreWeights
+
+	entry := new(hostEntry)
+	entry.NetAddress = "foo"
+	entry.PublicKey = fakePubKey("foo")
+	entry.StoragePrice = types.NewCurrency64(1)
+	entry.Weight = hdb.scoreBreakdown(*entry).Score
+	hdb.addHost(entry)
+	hdb.insertNode(entry)
+	oldWeight := entry.Weight
+
+	// unknown hosts and zero durations are ignored
+	hdb.RecordThroughput("bar", 1e6, time.Second)
+	hdb.RecordThroughput("foo", 1e6, 0)
+	if len(entry.Benchmarks) != 0 {
+		t.Fatal("benchmark should not have been recorded")
+	}
+
+	hdb.RecordThroughput("foo", targetThroughput, 2*time.Second)
+	if len(entry.Benchmarks) != 1 || entry.Benchmarks[0].Throughput != targetThroughput/2 {
+		t.Fatal("wrong benchmarks:", entry.Benchmarks)
+	}
+	if entry.Weight.Cmp(oldWeight) >= 0 {
+		t.Fatal("slow host should have lost weight")
+	}
+	if hdb.hostTree.weight.Cmp(entry.Weight) != 0 {
+		t.Fatal("host tree was not reweighted")
+	}
+
+	// the history is bounded
+	for i := 0; i < maxBenchmarkHistory; i++ {
+		hdb.RecordThroughput("foo", targetThroughput, time.Second)
+	}
+	if len(entry.Benchmarks) != maxBenchmarkHistory || entry.Benchmarks[0].Throughput != targetThroughput {
+		t.Fatal("benchmark history was not trimmed")
+	}
+}
+
+// TestSetBenchmarkSettings checks that the benchmark interval is validated.
+func TestSetBenchmarkSettings(t *testing.T) {
+	hdb := bareHostDB()
+	hdb.persist = &memPersist{}
+	s := modules.HostBenchmarkSettings{Enabled: true, Interval: time.Minute}
+	if err := hdb.SetBenchmarkSettings(s); err != errBenchmarkInterval {
+		t.Fatal("expected errBenchmarkInterval, got", err)
+	}
+	s.Interval = time.Hour
+	if err := hdb.SetBenchmarkSettings(s); err != nil {
+		t.Fatal(err)
+	}
+	if hdb.BenchmarkSettings() != s {
+		t.Fatal("benchmark settings were not updated")
+	}
+}
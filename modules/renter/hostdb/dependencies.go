@@ -24,6 +24,10 @@ type (
 		Sleep(time.Duration)
 	}
 
+	resolver interface {
+		LookupIP(host string) ([]net.IP, error)
+	}
+
 	persister interface {
 		save(hdbPersist) error
 		saveSync(hdbPersist) error
@@ -43,6 +47,11 @@ type stdSleeper struct{}
 
 func (s stdSleeper) Sleep(d time.Duration) { time.Sleep(d) }
 
+// stdResolver implements the resolver interface via net.LookupIP.
+type stdResolver struct{}
+
+func (stdResolver) LookupIP(host string) ([]net.IP, error) { return net.LookupIP(host) }
+
 // stdPersist implements the persister interface via persist.SaveFile and
 // persist.LoadFile. The metadata and filename required by these functions is
 // internal to stdPersist.
@@ -0,0 +1,119 @@
+package hostdb
+
+// history.go records the outcome of each host scan, so that the uptime of a
+// host can be measured over a longer period than the most recent scan.
+
+import (
+	"io"
+	"net"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// maxScanHistory is the number of scans that are kept for each host. The
+	// total uptime and downtime of a host are tracked separately, so older
+	// scans still count towards the uptime of a host after they are dropped.
+	maxScanHistory = 100
+)
+
+// The error types that are reported for failed scans.
+const (
+	scanErrBadSignature = "badsignature"
+	scanErrClosed       = "closed"
+	scanErrConnection   = "connection"
+	scanErrOther        = "other"
+	scanErrTimeout      = "timeout"
+)
+
+// scanErrorType returns the error type of a failed scan, or the empty string
+// if the scan succeeded.
+func scanErrorType(err error) string {
+	if err == nil {
+		return ""
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return scanErrTimeout
+	}
+	switch err.(type) {
+	case *net.OpError, net.UnknownNetworkError:
+		return scanErrConnection
+	}
+	switch err {
+	case crypto.ErrInvalidSignature:
+		return scanErrBadSignature
+	case io.EOF, io.ErrUnexpectedEOF:
+		return scanErrClosed
+	}
+	return scanErrOther
+}
+
+// recordScan adds a scan to the history of a host. The time since the
+// previous scan is counted as uptime or downtime according to the result of
+// the previous scan.
+func (hdb *HostDB) recordScan(entry *hostEntry, scan modules.HostDBScan) {
+	if n := len(entry.ScanHistory); n > 0 {
+		prev := entry.ScanHistory[n-1]
+		if elapsed := scan.Timestamp.Sub(prev.Timestamp); elapsed > 0 {
+			if prev.Success {
+				entry.Uptime += elapsed
+			} else {
+				entry.Downtime += elapsed
+			}
+		}
+	}
+	entry.ScanHistory = append(entry.ScanHistory, scan)
+	if len(entry.ScanHistory) > maxScanHistory {
+		entry.ScanHistory = entry.ScanHistory[len(entry.ScanHistory)-maxScanHistory:]
+	}
+}
+
+// uptimePercentages returns the percentage of time that the host has been
+// online and offline. If no time has passed between the host's scans, the
+// fraction of successful scans is used instead.
+func (e *hostEntry) uptimePercentages() (uptime, downtime float64) {
+	if total := e.Uptime + e.Downtime; total > 0 {
+		uptime = 100 * float64(e.Uptime) / float64(total)
+		return uptime, 100 - uptime
+	}
+	if len(e.ScanHistory) == 0 {
+		return 0, 0
+	}
+	var successes int
+	for _, scan := range e.ScanHistory {
+		if scan.Success {
+			successes++
+		}
+	}
+	uptime = 100 * float64(successes) / float64(len(e.ScanHistory))
+	return uptime, 100 - uptime
+}
+
+// HostDetails returns the entry, scan history, and score breakdown of the host
+// with the given public key. If no matching host is found, HostDetails returns
+// false.
+func (hdb *HostDB) HostDetails(pk types.SiaPublicKey) (modules.HostDBEntryDetails, bool) {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+
+	key := pk.String()
+	entry, exists := hdb.allHosts[key]
+	if !exists {
+		return modules.HostDBEntryDetails{}, false
+	}
+	_, active := hdb.activeHosts[key]
+	uptime, downtime := entry.uptimePercentages()
+	return modules.HostDBEntryDetails{
+		Entry:          entry.HostDBEntry,
+		Online:         active || entry.Online,
+		FirstSeen:      entry.FirstSeen,
+		AddressHistory: append([]modules.HostAddressChange(nil), entry.AddressHistory...),
+		Uptime:         uptime,
+		Downtime:       downtime,
+		ScanHistory:    append([]modules.HostDBScan(nil), entry.ScanHistory...),
+		Benchmarks:     append([]modules.HostBenchmark(nil), entry.Benchmarks...),
+		ScoreBreakdown: hdb.scoreBreakdown(*entry),
+	}, true
+}
@@ -0,0 +1,116 @@
+package hostdb
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestScanErrorType checks that scan errors are classified correctly.
+func TestScanErrorType(t *testing.T) {
+	tests := []struct {
+		err     error
+		errType string
+	}{
+		{nil, ""},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, scanErrConnection},
+		{&net.DNSError{IsTimeout: true}, scanErrTimeout},
+		{crypto.ErrInvalidSignature, scanErrBadSignature},
+		{io.ErrUnexpectedEOF, scanErrClosed},
+		{errors.New("foo"), scanErrOther},
+	}
+	for _, test := range tests {
+		if errType := scanErrorType(test.err); errType != test.errType {
+			t.Errorf("expected %q for %v, got %q", test.errType, test.err, errType)
+		}
+	}
+}
+
+// TestRecordScan checks that the scan history is bounded and that uptime is
+// calculated from the time between scans.
+func TestRecordScan(t *testing.T) {
+	hdb := bareHostDB()
+	entry := new(hostEntry)
+
+	// with no scans, the host has no uptime or downtime
+	if up, down := entry.uptimePercentages(); up != 0 || down != 0 {
+		t.Fatal("expected no uptime or downtime, got", up, down)
+	}
+
+	// the host is online for 3 hours, then offline for 1 hour
+	start := time.Now()
+	for i, success := range []bool{true, true, true, false, true} {
+		hdb.recordScan(entry, modules.HostDBScan{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Success:   success,
+		})
+	}
+	if entry.Uptime != 3*time.Hour || entry.Downtime != time.Hour {
+		t.Fatal("wrong uptime or downtime:", entry.Uptime, entry.Downtime)
+	}
+	if up, down := entry.uptimePercentages(); up != 75 || down != 25 {
+		t.Fatal("expected 75% uptime, got", up, down)
+	}
+
+	// old scans are dropped, but still count towards uptime
+	last := start.Add(4 * time.Hour)
+	for i := 1; i <= maxScanHistory; i++ {
+		hdb.recordScan(entry, modules.HostDBScan{
+			Timestamp: last.Add(time.Duration(i) * time.Minute),
+			Success:   true,
+		})
+	}
+	if len(entry.ScanHistory) != maxScanHistory {
+		t.Fatal("scan history was not bounded:", len(entry.ScanHistory))
+	}
+	if !entry.ScanHistory[0].Timestamp.Equal(last.Add(time.Minute)) {
+		t.Fatal("oldest scans should have been dropped")
+	}
+	if entry.Downtime != time.Hour {
+		t.Fatal("downtime should be unchanged, got", entry.Downtime)
+	}
+}
+
+// TestHostDetails checks that HostDetails finds hosts by public key and
+// reports their first-seen height.
+func TestHostDetails(t *testing.T) {
+	hdb := bareHostDB()
+	hdb.blockHeight = 12
+
+	var dbe modules.HostDBEntry
+	dbe.NetAddress = "foo.com:1234"
+	dbe.PublicKey = types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       []byte{1, 2, 3},
+	}
+	hdb.insertHost(dbe)
+	hdb.recordScan(hdb.allHosts[dbe.PublicKey.String()], modules.HostDBScan{
+		Timestamp: time.Now(),
+		ErrorType: scanErrTimeout,
+	})
+
+	details, ok := hdb.HostDetails(dbe.PublicKey)
+	if !ok {
+		t.Fatal("host was not found")
+	}
+	if details.Entry.NetAddress != dbe.NetAddress || details.FirstSeen != 12 {
+		t.Fatal("wrong host details:", details.Entry.NetAddress, details.FirstSeen)
+	}
+	if details.Online || details.Downtime != 100 {
+		t.Fatal("host should be offline, got", details.Online, details.Downtime)
+	}
+	if len(details.ScanHistory) != 1 || details.ScanHistory[0].ErrorType != scanErrTimeout {
+		t.Fatal("wrong scan history:", details.ScanHistory)
+	}
+
+	dbe.PublicKey.Key = []byte{4, 5, 6}
+	if _, ok := hdb.HostDetails(dbe.PublicKey); ok {
+		t.Fatal("unknown host should not be found")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
@@ -42,25 +43,35 @@ var (
 // for uploading files.
 type HostDB struct {
 	// dependencies
-	dialer  dialer
-	log     *persist.Logger
-	mu      sync.RWMutex
-	persist persister
-	sleeper sleeper
-	tg      siasync.ThreadGroup
+	dialer   dialer
+	log      *persist.Logger
+	mu       sync.RWMutex
+	persist  persister
+	resolver resolver
+	sleeper  sleeper
+	tg       siasync.ThreadGroup
 
 	// The hostTree is the root node of the tree that organizes hosts by
 	// weight. The tree is necessary for selecting weighted hosts at
-	// random. 'activeHosts' provides a lookup from hostname to the the
+	// random. 'activeHosts' provides a lookup from a host's key to the
 	// corresponding node, as the hostTree is unsorted. A host is active if
 	// it is currently responding to queries about price and other
 	// settings.
 	hostTree    *hostNode
-	activeHosts map[modules.NetAddress]*hostNode
+	activeHosts map[string]*hostNode
 
-	// allHosts is a simple list of all known hosts by their network address,
-	// including hosts that are currently offline.
-	allHosts map[modules.NetAddress]*hostEntry
+	// allHosts is a simple list of all known hosts, including hosts that are
+	// currently offline. Hosts are keyed by their public key, so that a host
+	// keeps its history when it announces a new address. hostKeys maps the
+	// current address of each host to its key.
+	allHosts map[string]*hostEntry
+	hostKeys map[modules.NetAddress]string
+
+	// contracted holds the keys of the hosts that the renter has contracts
+	// with. These hosts are never removed from the hostdb. pruneDowntime is
+	// how long any other host can be offline before it is removed.
+	contracted    map[string]struct{}
+	pruneDowntime time.Duration
 
 	// the scanPool is a set of hosts that need to be scanned. There are a
 	// handful of goroutines constantly waiting on the channel for hosts to
@@ -69,6 +80,14 @@ type HostDB struct {
 	scanPool chan *hostEntry
 	scanWait bool
 
+	// scoreWeights are the weights applied to each factor of a host's score.
+	// subnetSizes are the sizes of the subnets that hosts are grouped by
+	// when they are selected. benchmarkSettings control the benchmarking of
+	// hosts.
+	scoreWeights      modules.HostScoreWeights
+	subnetSizes       modules.HostSubnetSizes
+	benchmarkSettings modules.HostBenchmarkSettings
+
 	blockHeight types.BlockHeight
 	lastChange  modules.ConsensusChangeID
 }
@@ -92,24 +111,31 @@ func New(cs consensusSet, persistDir string) (*HostDB, error) {
 	}
 
 	// Create HostDB using production dependencies.
-	return newHostDB(cs, stdDialer{}, stdSleeper{}, newPersist(persistDir), logger)
+	return newHostDB(cs, stdDialer{}, stdSleeper{}, stdResolver{}, newPersist(persistDir), logger)
 }
 
 // newHostDB creates a HostDB using the provided dependencies. It loads the old
 // persistence data, spawns the HostDB's scanning threads, and subscribes it to
 // the consensusSet.
-func newHostDB(cs consensusSet, d dialer, s sleeper, p persister, l *persist.Logger) (*HostDB, error) {
+func newHostDB(cs consensusSet, d dialer, s sleeper, r resolver, p persister, l *persist.Logger) (*HostDB, error) {
 	// Create the HostDB object.
 	hdb := &HostDB{
-		dialer:  d,
-		sleeper: s,
-		persist: p,
-		log:     l,
-
-		// TODO: should index by pubkey, not ip
-		activeHosts: make(map[modules.NetAddress]*hostNode),
-		allHosts:    make(map[modules.NetAddress]*hostEntry),
+		dialer:   d,
+		sleeper:  s,
+		resolver: r,
+		persist:  p,
+		log:      l,
+
+		activeHosts: make(map[string]*hostNode),
+		allHosts:    make(map[string]*hostEntry),
+		hostKeys:    make(map[modules.NetAddress]string),
+		contracted:  make(map[string]struct{}),
 		scanPool:    make(chan *hostEntry, scanPoolSize),
+
+		scoreWeights:      defaultScoreWeights,
+		subnetSizes:       defaultSubnetSizes,
+		benchmarkSettings: defaultBenchmarkSettings,
+		pruneDowntime:     defaultPruneDowntime,
 	}
 
 	// Load the prior persistence structures.
@@ -122,8 +148,10 @@ func newHostDB(cs consensusSet, d dialer, s sleeper, p persister, l *persist.Log
 	if err == modules.ErrInvalidConsensusChangeID {
 		hdb.lastChange = modules.ConsensusChangeBeginning
 		// clear the host sets
-		hdb.activeHosts = make(map[modules.NetAddress]*hostNode)
-		hdb.allHosts = make(map[modules.NetAddress]*hostEntry)
+		hdb.hostTree = nil
+		hdb.activeHosts = make(map[string]*hostNode)
+		hdb.allHosts = make(map[string]*hostEntry)
+		hdb.hostKeys = make(map[modules.NetAddress]string)
 		// subscribe again using the new ID
 		err = cs.ConsensusSetSubscribe(hdb, hdb.lastChange)
 	}
@@ -136,6 +164,7 @@ func newHostDB(cs consensusSet, d dialer, s sleeper, p persister, l *persist.Log
 		go hdb.threadedProbeHosts()
 	}
 	go hdb.threadedScan()
+	go hdb.threadedBenchmark()
 
 	return hdb, nil
 }
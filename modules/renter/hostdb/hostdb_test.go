@@ -8,6 +8,7 @@ import (
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // bareHostDB returns a HostDB with its fields initialized, but without any
@@ -16,12 +17,29 @@ func bareHostDB() *HostDB {
 	return &HostDB{
 		log: persist.NewLogger(ioutil.Discard),
 
-		activeHosts: make(map[modules.NetAddress]*hostNode),
-		allHosts:    make(map[modules.NetAddress]*hostEntry),
+		activeHosts: make(map[string]*hostNode),
+		allHosts:    make(map[string]*hostEntry),
+		hostKeys:    make(map[modules.NetAddress]string),
+		contracted:  make(map[string]struct{}),
 		scanPool:    make(chan *hostEntry, scanPoolSize),
 	}
 }
 
+// fakePubKey returns a public key derived from s. Hosts are keyed by their
+// public key, so every host in a test needs a distinct one.
+func fakePubKey(s string) types.SiaPublicKey {
+	return types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       []byte(s),
+	}
+}
+
+// addHost adds an entry to the set of all hosts, as insertHost would.
+func (hdb *HostDB) addHost(entry *hostEntry) {
+	hdb.allHosts[entry.key()] = entry
+	hdb.hostKeys[entry.NetAddress] = entry.key()
+}
+
 // newStub is used to test the New function. It implements all of the hostdb's
 // dependencies.
 type newStub struct{}
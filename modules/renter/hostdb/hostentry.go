@@ -1,7 +1,8 @@
 package hostdb
 
 import (
-	"bytes"
+	"net"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -14,6 +15,59 @@ type hostEntry struct {
 	Weight      types.Currency
 	Reliability types.Currency
 	Online      bool
+
+	// FirstSeen is the height at which the host was first announced.
+	// ScanHistory holds the most recent scans of the host, and Uptime and
+	// Downtime are the total time covered by all of its scans.
+	FirstSeen   types.BlockHeight
+	ScanHistory []modules.HostDBScan
+	Uptime      time.Duration
+	Downtime    time.Duration
+
+	// Benchmarks holds the most recent latency and download benchmarks of
+	// the host.
+	Benchmarks []modules.HostBenchmark
+
+	// IPs are the addresses that the host's NetAddress resolved to when the
+	// host was last scanned. They are used to group hosts by subnet.
+	IPs []net.IP
+
+	// AddressHistory holds the most recent addresses announced by the host,
+	// including its current address.
+	AddressHistory []modules.HostAddressChange
+}
+
+const (
+	// maxAddressHistory is the number of addresses that are kept for each
+	// host.
+	maxAddressHistory = 20
+)
+
+// key returns the key of the host in the hostdb, which is the string form of
+// its public key.
+func (e *hostEntry) key() string {
+	return e.PublicKey.String()
+}
+
+// recordAddress adds an address to the address history of a host.
+func (e *hostEntry) recordAddress(addr modules.NetAddress, height types.BlockHeight) {
+	e.AddressHistory = append(e.AddressHistory, modules.HostAddressChange{
+		NetAddress: addr,
+		Height:     height,
+	})
+	if len(e.AddressHistory) > maxAddressHistory {
+		e.AddressHistory = e.AddressHistory[len(e.AddressHistory)-maxAddressHistory:]
+	}
+}
+
+// lookupHost returns the host whose current address is addr.
+func (hdb *HostDB) lookupHost(addr modules.NetAddress) (*hostEntry, bool) {
+	key, exists := hdb.hostKeys[addr]
+	if !exists {
+		return nil, false
+	}
+	entry, exists := hdb.allHosts[key]
+	return entry, exists
 }
 
 // insertHost adds a host entry to the state. The host will be inserted into
@@ -27,9 +81,39 @@ func (hdb *HostDB) insertHost(host modules.HostDBEntry) {
 		hdb.log.Debugf("WARN: host '%v' has an invalid NetAddress: %v", host.NetAddress, err)
 		return
 	}
-	// Don't do anything if we've already seen this host and the public key is
-	// the same.
-	if knownHost, exists := hdb.allHosts[host.NetAddress]; exists && bytes.Equal(host.PublicKey.Key, knownHost.PublicKey.Key) {
+	// Invalid additional addresses are dropped, the host remains reachable
+	// at its other addresses.
+	var additional []modules.NetAddress
+	for _, addr := range host.AdditionalAddresses {
+		if err := addr.IsValid(); err != nil {
+			hdb.log.Debugf("WARN: host '%v' announced an invalid additional address '%v': %v", host.NetAddress, addr, err)
+			continue
+		}
+		additional = append(additional, addr)
+	}
+	host.AdditionalAddresses = additional
+
+	// If the host is already known, only its addresses can have changed. A
+	// new NetAddress is recorded, and the host is scanned at its new
+	// addresses.
+	key := host.PublicKey.String()
+	if knownHost, exists := hdb.allHosts[key]; exists {
+		if knownHost.NetAddress == host.NetAddress && modules.SameAddresses(knownHost.AdditionalAddresses, host.AdditionalAddresses) {
+			return
+		}
+		knownHost.AdditionalAddresses = host.AdditionalAddresses
+		knownHost.ReachableAddress = ""
+		knownHost.IPs = nil
+		if knownHost.NetAddress != host.NetAddress {
+			hdb.log.Debugln("Host", key, "moved from", knownHost.NetAddress, "to", host.NetAddress)
+			if hdb.hostKeys[knownHost.NetAddress] == key {
+				delete(hdb.hostKeys, knownHost.NetAddress)
+			}
+			knownHost.NetAddress = host.NetAddress
+			knownHost.recordAddress(host.NetAddress, hdb.blockHeight)
+			hdb.hostKeys[host.NetAddress] = key
+		}
+		hdb.queueHostEntry(knownHost)
 		return
 	}
 
@@ -37,26 +121,33 @@ func (hdb *HostDB) insertHost(host modules.HostDBEntry) {
 	h := &hostEntry{
 		HostDBEntry: host,
 		Reliability: DefaultReliability,
+		FirstSeen:   hdb.blockHeight,
 	}
-	hdb.allHosts[host.NetAddress] = h
+	h.recordAddress(host.NetAddress, hdb.blockHeight)
+	hdb.allHosts[key] = h
+	hdb.hostKeys[host.NetAddress] = key
 
 	// Add the host to the scan queue. If the scan is successful, the host
 	// will be placed in activeHosts.
 	hdb.queueHostEntry(h)
 }
 
-// Remove deletes an entry from the hostdb.
-func (hdb *HostDB) removeHost(addr modules.NetAddress) error {
+// removeHost deletes the host with the given key from the hostdb.
+func (hdb *HostDB) removeHost(key string) error {
 	// See if the node is in the set of active hosts.
-	node, exists := hdb.activeHosts[addr]
+	node, exists := hdb.activeHosts[key]
 	if exists {
 		node.removeNode()
-		delete(hdb.activeHosts, addr)
+		delete(hdb.activeHosts, key)
 	}
 
 	// Remove the node from all hosts.
-	delete(hdb.allHosts, addr)
-
+	if entry, exists := hdb.allHosts[key]; exists {
+		if hdb.hostKeys[entry.NetAddress] == key {
+			delete(hdb.hostKeys, entry.NetAddress)
+		}
+		delete(hdb.allHosts, key)
+	}
 	return nil
 }
 
@@ -65,13 +156,26 @@ func (hdb *HostDB) removeHost(addr modules.NetAddress) error {
 func (hdb *HostDB) Host(addr modules.NetAddress) (modules.HostDBEntry, bool) {
 	hdb.mu.Lock()
 	defer hdb.mu.Unlock()
-	entry, ok := hdb.allHosts[addr]
+	entry, ok := hdb.lookupHost(addr)
 	if !ok || entry == nil {
 		return modules.HostDBEntry{}, false
 	}
 	return entry.HostDBEntry, true
 }
 
+// HostByKey returns the HostDBEntry of the host with the specified public
+// key. Unlike Host, it finds hosts that have announced a new address. If no
+// matching host is found, HostByKey returns false.
+func (hdb *HostDB) HostByKey(pk types.SiaPublicKey) (modules.HostDBEntry, bool) {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	entry, ok := hdb.allHosts[pk.String()]
+	if !ok {
+		return modules.HostDBEntry{}, false
+	}
+	return entry.HostDBEntry, true
+}
+
 // ActiveHosts returns the hosts that can be randomly selected out of the
 // hostdb, sorted by preference.
 func (hdb *HostDB) ActiveHosts() (activeHosts []modules.HostDBEntry) {
@@ -79,8 +183,9 @@ func (hdb *HostDB) ActiveHosts() (activeHosts []modules.HostDBEntry) {
 	numHosts := len(hdb.activeHosts)
 	hdb.mu.RUnlock()
 
-	// Get the hosts using RandomHosts so that they are in sorted order.
-	sortedHosts := hdb.RandomHosts(numHosts, nil)
+	// Get the hosts using randomHosts so that they are in sorted order. Hosts
+	// that share a subnet are all included.
+	sortedHosts := hdb.randomHosts(numHosts, nil, false)
 	return sortedHosts
 }
 
@@ -101,7 +206,7 @@ func (hdb *HostDB) AverageContractPrice() types.Currency {
 	// maybe a more sophisticated way of doing this
 	var totalPrice types.Currency
 	sampleSize := 18
-	hosts := hdb.RandomHosts(sampleSize, nil)
+	hosts := hdb.randomHosts(sampleSize, nil, false)
 	if len(hosts) == 0 {
 		return totalPrice
 	}
@@ -119,11 +224,12 @@ func (hdb *HostDB) IsOffline(addr modules.NetAddress) bool {
 	hdb.mu.RLock()
 	defer hdb.mu.RUnlock()
 
-	if _, ok := hdb.activeHosts[addr]; ok {
+	h, ok := hdb.lookupHost(addr)
+	if !ok {
 		return false
 	}
-	if h, ok := hdb.allHosts[addr]; ok {
-		return !h.Online
+	if _, ok := hdb.activeHosts[h.key()]; ok {
+		return false
 	}
-	return false
+	return !h.Online
 }
@@ -39,6 +39,22 @@ func TestInsertHost(t *testing.T) {
 		t.Error("duplicate host was added to scan pool")
 	case <-time.After(100 * time.Millisecond):
 	}
+
+	// host that announces new additional addresses should be scanned again,
+	// without dropping its NetAddress or keeping invalid addresses
+	dbe.AdditionalAddresses = []modules.NetAddress{"1.2.3.4:1234", "foo", "[2001:db8::1]:1234"}
+	hdb.insertHost(dbe)
+	select {
+	case entry := <-hdb.scanPool:
+		if entry.NetAddress != dbe.NetAddress || len(entry.AdditionalAddresses) != 2 || entry.AdditionalAddresses[1] != "[2001:db8::1]:1234" {
+			t.Error("wrong addresses after announcement:", entry.NetAddress, entry.AdditionalAddresses)
+		}
+		if len(entry.AddressHistory) != 1 {
+			t.Error("new additional addresses should not be recorded as a move:", entry.AddressHistory)
+		}
+	case <-time.After(time.Second):
+		t.Error("host was not scanned after announcing new addresses")
+	}
 }
 
 // TestActiveHosts tests the ActiveHosts method.
@@ -53,6 +69,7 @@ func TestActiveHosts(t *testing.T) {
 	// with one host
 	h1 := new(hostEntry)
 	h1.NetAddress = "foo"
+	h1.PublicKey = fakePubKey("foo")
 	h1.Weight = types.NewCurrency64(1)
 	h1.AcceptingContracts = true
 	hdb.insertNode(h1)
@@ -65,6 +82,7 @@ func TestActiveHosts(t *testing.T) {
 	// with multiple hosts
 	h2 := new(hostEntry)
 	h2.NetAddress = "bar"
+	h2.PublicKey = fakePubKey("bar")
 	h2.Weight = types.NewCurrency64(1)
 	h2.AcceptingContracts = true
 	hdb.insertNode(h2)
@@ -90,6 +108,7 @@ func TestAverageContractPrice(t *testing.T) {
 	// with one host
 	h1 := new(hostEntry)
 	h1.NetAddress = "foo"
+	h1.PublicKey = fakePubKey("foo")
 	h1.ContractPrice = types.NewCurrency64(100)
 	h1.Weight = baseWeight
 	h1.AcceptingContracts = true
@@ -101,6 +120,7 @@ func TestAverageContractPrice(t *testing.T) {
 	// with two hosts
 	h2 := new(hostEntry)
 	h2.NetAddress = "bar"
+	h2.PublicKey = fakePubKey("bar")
 	h2.ContractPrice = types.NewCurrency64(300)
 	h2.Weight = baseWeight
 	h2.AcceptingContracts = true
@@ -115,17 +135,16 @@ func TestAverageContractPrice(t *testing.T) {
 
 // TestIsOffline tests the IsOffline method.
 func TestIsOffline(t *testing.T) {
-	hdb := &HostDB{
-		allHosts: map[modules.NetAddress]*hostEntry{
-			"foo.com:1234": {Online: true},
-			"bar.com:1234": {Online: false},
-			"baz.com:1234": {Online: true},
-		},
-		activeHosts: map[modules.NetAddress]*hostNode{
-			"foo.com:1234": nil,
-		},
-		scanPool: make(chan *hostEntry),
+	hdb := bareHostDB()
+	for _, addr := range []modules.NetAddress{"foo.com:1234", "bar.com:1234", "baz.com:1234"} {
+		entry := new(hostEntry)
+		entry.NetAddress = addr
+		entry.PublicKey = fakePubKey(string(addr))
+		entry.Online = addr != "bar.com:1234"
+		hdb.addHost(entry)
 	}
+	foo, _ := hdb.lookupHost("foo.com:1234")
+	hdb.activeHosts[foo.key()] = nil
 
 	tests := []struct {
 		addr    modules.NetAddress
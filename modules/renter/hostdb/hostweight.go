@@ -1,20 +1,213 @@
 package hostdb
 
 import (
+	"errors"
+	"math"
 	"math/big"
+	"time"
 
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
+const (
+	// minFactor is the smallest multiplier that a single factor can give a
+	// host before its weight is applied. It prevents a host's score from
+	// being reduced to zero by any one factor.
+	minFactor = 0.01
+
+	// fullAge is the age in blocks at which a host no longer receives a
+	// penalty for being new. Hosts that were just announced receive a
+	// multiplier of newHostFactor.
+	fullAge       = 4320 // 30 days
+	newHostFactor = 0.1
+
+	// fullStorage is the amount of remaining storage at which a host no
+	// longer receives a penalty for being nearly full.
+	fullStorage = 100e9 // 100 GB
+
+	// outdatedVersionFactor and invalidVersionFactor are the multipliers
+	// given to hosts running an older version than the renter, and to hosts
+	// that do not report a valid version.
+	outdatedVersionFactor = 0.5
+	invalidVersionFactor  = 0.1
+
+	// targetLatency is the average latency at which a host no longer
+	// receives a penalty for being slow.
+	targetLatency = 500 * time.Millisecond
+
+	// targetThroughput is the average download throughput, in bytes per
+	// second, at which a host no longer receives a penalty for being slow.
+	// Download benchmarks fetch 1 MiB, so the target is roughly 1 MiB in
+	// 500 ms.
+	targetThroughput = 2e6
+
+	// maxScoreWeight is the largest weight that can be given to a factor.
+	maxScoreWeight = 10
+)
+
 var (
 	// Because most weights would otherwise be fractional, we set the base
 	// weight to 10^150 to give ourselves lots of precision when determing the
 	// weight of a host
 	baseWeight = types.NewCurrency(new(big.Int).Exp(big.NewInt(10), big.NewInt(150), nil))
+
+	// defaultScoreWeights are the weights used to score hosts until the
+	// renter sets its own. Uptime is weighted most heavily, as hosts that are
+	// frequently offline put the renter's data at risk.
+	defaultScoreWeights = modules.HostScoreWeights{
+		Uptime:     3,
+		Age:        1,
+		Storage:    1,
+		Version:    1,
+		Latency:    1,
+		Throughput: 1,
+	}
+
+	errScoreWeightRange = errors.New("score weights must be between 0 and 10")
 )
 
-// calculateHostWeight returns the weight of a host according to the settings of
-// the host database entry. Currently, only the price is considered.
+// checkScoreWeights returns an error if any of the weights are out of range.
+func checkScoreWeights(w modules.HostScoreWeights) error {
+	for _, weight := range []float64{w.Uptime, w.Age, w.Storage, w.Version, w.Latency, w.Throughput} {
+		if !(weight >= 0 && weight <= maxScoreWeight) {
+			return errScoreWeightRange
+		}
+	}
+	return nil
+}
+
+// uptimeFactor returns the multiplier given to a host for its uptime. Hosts
+// that have not been scanned are treated as having been online half of the
+// time.
+func uptimeFactor(entry hostEntry) float64 {
+	if len(entry.ScanHistory) == 0 {
+		return 0.5
+	}
+	uptime, _ := entry.uptimePercentages()
+	return math.Max(uptime/100, minFactor)
+}
+
+// ageFactor returns the multiplier given to a host for the length of time
+// since it was first announced.
+func ageFactor(entry hostEntry, height types.BlockHeight) float64 {
+	if entry.FirstSeen >= height {
+		return newHostFactor
+	}
+	age := float64(height - entry.FirstSeen)
+	return math.Min(newHostFactor+(1-newHostFactor)*age/fullAge, 1)
+}
+
+// storageFactor returns the multiplier given to a host for the amount of
+// storage it has remaining.
+func storageFactor(entry hostEntry) float64 {
+	return math.Max(math.Min(float64(entry.RemainingStorage)/fullStorage, 1), minFactor)
+}
+
+// versionFactor returns the multiplier given to a host for the version of
+// siad it reports.
+func versionFactor(entry hostEntry) float64 {
+	switch {
+	case entry.Version == "" || !build.IsVersion(entry.Version):
+		return invalidVersionFactor
+	case build.VersionCmp(entry.Version, build.Version) < 0:
+		return outdatedVersionFactor
+	}
+	return 1
+}
+
+// latencyFactor returns the multiplier given to a host for its average
+// latency. Latency benchmarks are used if the host has any, as they are more
+// accurate than the latency of its successful scans. Hosts without either are
+// not penalized, as their uptime already accounts for it.
+func latencyFactor(entry hostEntry) float64 {
+	var total time.Duration
+	var n int
+	for _, b := range entry.Benchmarks {
+		if b.Latency > 0 {
+			total += b.Latency
+			n++
+		}
+	}
+	if n == 0 {
+		for _, scan := range entry.ScanHistory {
+			if scan.Success {
+				total += scan.Latency
+				n++
+			}
+		}
+	}
+	if n == 0 || total/time.Duration(n) <= targetLatency {
+		return 1
+	}
+	avg := total / time.Duration(n)
+	return math.Max(float64(targetLatency)/float64(avg), minFactor)
+}
+
+// throughputFactor returns the multiplier given to a host for the average
+// throughput of its download benchmarks. Hosts that have not been benchmarked
+// are not penalized.
+func throughputFactor(entry hostEntry) float64 {
+	var total float64
+	var n int
+	for _, b := range entry.Benchmarks {
+		if b.Throughput > 0 {
+			total += float64(b.Throughput)
+			n++
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return math.Max(math.Min(total/float64(n)/targetThroughput, 1), minFactor)
+}
+
+// scoreBreakdown returns the score of a host along with the contribution of
+// each factor. The score is the weight given to the host in the host tree.
+func (hdb *HostDB) scoreBreakdown(entry hostEntry) modules.HostScoreBreakdown {
+	w := hdb.scoreWeights
+	sb := modules.HostScoreBreakdown{
+		Price:      calculateHostWeight(entry),
+		Uptime:     math.Pow(uptimeFactor(entry), w.Uptime),
+		Age:        math.Pow(ageFactor(entry, hdb.blockHeight), w.Age),
+		Storage:    math.Pow(storageFactor(entry), w.Storage),
+		Version:    math.Pow(versionFactor(entry), w.Version),
+		Latency:    math.Pow(latencyFactor(entry), w.Latency),
+		Throughput: math.Pow(throughputFactor(entry), w.Throughput),
+	}
+	sb.Score = sb.Price.MulFloat(sb.Uptime * sb.Age * sb.Storage * sb.Version * sb.Latency * sb.Throughput)
+	return sb
+}
+
+// ScoreWeights returns the weights used to score hosts.
+func (hdb *HostDB) ScoreWeights() modules.HostScoreWeights {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	return hdb.scoreWeights
+}
+
+// SetScoreWeights sets the weights used to score hosts. The weights of all
+// active hosts are recalculated.
+func (hdb *HostDB) SetScoreWeights(w modules.HostScoreWeights) error {
+	if err := checkScoreWeights(w); err != nil {
+		return err
+	}
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	hdb.scoreWeights = w
+
+	// The weight of a host cannot change while it is in the tree, so the
+	// tree is rebuilt once the weights of all active hosts are updated.
+	for _, node := range hdb.activeHosts {
+		node.hostEntry.Weight = hdb.scoreBreakdown(*node.hostEntry).Score
+	}
+	hdb.rebuildTree()
+	return hdb.saveSync()
+}
+
+// calculateHostWeight returns the price component of a host's score,
+// according to the prices and collateral of the host database entry.
 func calculateHostWeight(entry hostEntry) (weight types.Currency) {
 	// Prices tiered as follows:
 	//    - the storage price is presented as 'per block per byte'
@@ -3,6 +3,8 @@ package hostdb
 import (
 	"testing"
 
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -47,3 +49,92 @@ func TestHostWeightWithBothPricesZero(t *testing.T) {
 		t.Error("Weight of two zero-priced hosts should be equal.")
 	}
 }
+
+// TestScoreFactors checks the multipliers given to hosts for each factor of
+// their score.
+func TestScoreFactors(t *testing.T) {
+	var entry hostEntry
+	entry.FirstSeen = 100
+	entry.RemainingStorage = fullStorage / 2
+	entry.Version = build.Version
+
+	if f := ageFactor(entry, 100); f != newHostFactor {
+		t.Error("new host should receive the new host factor, got", f)
+	}
+	if f := ageFactor(entry, 100+fullAge); f != 1 {
+		t.Error("old host should not be penalized, got", f)
+	}
+	if f := storageFactor(entry); f != 0.5 {
+		t.Error("half-full host should receive 0.5, got", f)
+	}
+	if f := versionFactor(entry); f != 1 {
+		t.Error("up-to-date host should not be penalized, got", f)
+	}
+	entry.Version = "0.6.0"
+	if f := versionFactor(entry); f != outdatedVersionFactor {
+		t.Error("outdated host should be penalized, got", f)
+	}
+	entry.Version = "foo"
+	if f := versionFactor(entry); f != invalidVersionFactor {
+		t.Error("host with invalid version should be penalized, got", f)
+	}
+
+	// a host with no scans is not penalized for latency
+	if f := latencyFactor(entry); f != 1 {
+		t.Error("unscanned host should not be penalized for latency, got", f)
+	}
+	entry.ScanHistory = []modules.HostDBScan{
+		{Success: true, Latency: 2 * targetLatency},
+		{Success: false, Latency: 100 * targetLatency},
+	}
+	if f := latencyFactor(entry); f != 0.5 {
+		t.Error("host with twice the target latency should receive 0.5, got", f)
+	}
+	if f := uptimeFactor(entry); f != 0.5 {
+		t.Error("host with one successful scan of two should receive 0.5, got", f)
+	}
+}
+
+// TestSetScoreWeights checks that the score breakdown reflects the weights,
+// and that changing the weights reweights the active hosts.
+func TestSetScoreWeights(t *testing.T) {
+	hdb := bareHostDB()
+	hdb.persist = &memPersist{}
+	hdb.scoreWeights = defaultScoreWeights
+
+	entry := new(hostEntry)
+	entry.NetAddress = "foo"
+	entry.StoragePrice = types.NewCurrency64(1)
+	entry.RemainingStorage = fullStorage / 2
+	entry.Version = build.Version
+	entry.Weight = hdb.scoreBreakdown(*entry).Score
+	hdb.insertNode(entry)
+
+	sb := hdb.scoreBreakdown(*entry)
+	if sb.Storage != 0.5 || sb.Age != newHostFactor {
+		t.Fatal("wrong score breakdown:", sb)
+	}
+	if sb.Score.Cmp(sb.Price.MulFloat(sb.Uptime*sb.Age*sb.Storage*sb.Version*sb.Latency)) != 0 {
+		t.Fatal("score is not the product of the factors")
+	}
+
+	// ignoring every factor but storage should leave only the storage penalty
+	err := hdb.SetScoreWeights(modules.HostScoreWeights{Storage: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb = hdb.scoreBreakdown(*entry)
+	if sb.Storage != 0.25 || sb.Uptime != 1 || sb.Age != 1 {
+		t.Fatal("weights were not applied:", sb)
+	}
+	if entry.Weight.Cmp(sb.Score) != 0 || hdb.hostTree.weight.Cmp(sb.Score) != 0 {
+		t.Fatal("active host was not reweighted")
+	}
+
+	// invalid weights should be rejected
+	for _, w := range []modules.HostScoreWeights{{Uptime: -1}, {Latency: maxScoreWeight + 1}} {
+		if err := hdb.SetScoreWeights(w); err != errScoreWeightRange {
+			t.Error("expected errScoreWeightRange, got", err)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package hostdb
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/modules"
 )
 
 // hdbPersist defines what HostDB data persists across sessions.
 type hdbPersist struct {
-	AllHosts    []hostEntry
-	ActiveHosts []hostEntry
-	LastChange  modules.ConsensusChangeID
+	AllHosts          []hostEntry
+	ActiveHosts       []hostEntry
+	LastChange        modules.ConsensusChangeID
+	ScoreWeights      modules.HostScoreWeights
+	SubnetSizes       modules.HostSubnetSizes
+	BenchmarkSettings modules.HostBenchmarkSettings
+	PruneDowntime     time.Duration
 }
 
 // persistData returns the data in the hostdb that will be saved to disk.
@@ -21,6 +27,10 @@ func (hdb *HostDB) persistData() hdbPersist {
 		data.ActiveHosts = append(data.ActiveHosts, *node.hostEntry)
 	}
 	data.LastChange = hdb.lastChange
+	data.ScoreWeights = hdb.scoreWeights
+	data.SubnetSizes = hdb.subnetSizes
+	data.BenchmarkSettings = hdb.benchmarkSettings
+	data.PruneDowntime = hdb.pruneDowntime
 	return data
 }
 
@@ -36,17 +46,44 @@ func (hdb *HostDB) saveSync() error {
 
 // load loads the hostdb persistence data from disk.
 func (hdb *HostDB) load() error {
-	var data hdbPersist
+	data := hdbPersist{
+		ScoreWeights:      defaultScoreWeights,
+		SubnetSizes:       defaultSubnetSizes,
+		BenchmarkSettings: defaultBenchmarkSettings,
+		PruneDowntime:     defaultPruneDowntime,
+	}
 	err := hdb.persist.load(&data)
 	if err != nil {
 		return err
 	}
+
+	// Older versions of the hostdb keyed hosts by address, so the same host
+	// may appear more than once. The entry that was seen last is kept.
+	hdb.allHosts = make(map[string]*hostEntry)
+	hdb.hostKeys = make(map[modules.NetAddress]string)
 	for i := range data.AllHosts {
-		hdb.allHosts[data.AllHosts[i].NetAddress] = &data.AllHosts[i]
+		entry := &data.AllHosts[i]
+		if len(entry.AddressHistory) == 0 {
+			entry.recordAddress(entry.NetAddress, entry.FirstSeen)
+		}
+		key := entry.key()
+		if prior, exists := hdb.allHosts[key]; exists && prior.FirstSeen > entry.FirstSeen {
+			continue
+		}
+		hdb.allHosts[key] = entry
+		hdb.hostKeys[entry.NetAddress] = key
 	}
+	hdb.activeHosts = make(map[string]*hostNode)
 	for i := range data.ActiveHosts {
-		hdb.insertNode(hdb.allHosts[data.ActiveHosts[i].NetAddress])
+		if entry, exists := hdb.allHosts[data.ActiveHosts[i].key()]; exists {
+			hdb.activeHosts[entry.key()] = &hostNode{hostEntry: entry}
+		}
 	}
+	hdb.rebuildTree()
 	hdb.lastChange = data.LastChange
+	hdb.scoreWeights = data.ScoreWeights
+	hdb.subnetSizes = data.SubnetSizes
+	hdb.benchmarkSettings = data.BenchmarkSettings
+	hdb.pruneDowntime = data.PruneDowntime
 	return nil
 }
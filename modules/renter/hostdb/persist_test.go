@@ -26,15 +26,12 @@ func TestSaveLoad(t *testing.T) {
 	host1.NetAddress = "foo"
 	host2.NetAddress = "bar"
 	host3.NetAddress = "baz"
-	hdb.allHosts = map[modules.NetAddress]*hostEntry{
-		host1.NetAddress: &host1,
-		host2.NetAddress: &host2,
-		host3.NetAddress: &host3,
-	}
-	hdb.activeHosts = map[modules.NetAddress]*hostNode{
-		host1.NetAddress: {hostEntry: &host1},
-		host2.NetAddress: {hostEntry: &host2},
-		host3.NetAddress: {hostEntry: &host3},
+	host1.PublicKey = fakePubKey("foo")
+	host2.PublicKey = fakePubKey("bar")
+	host3.PublicKey = fakePubKey("baz")
+	for _, h := range []*hostEntry{&host1, &host2, &host3} {
+		hdb.addHost(h)
+		hdb.activeHosts[h.key()] = &hostNode{hostEntry: h}
 	}
 	hdb.lastChange = modules.ConsensusChangeID{1, 2, 3}
 
@@ -54,17 +51,17 @@ func TestSaveLoad(t *testing.T) {
 	}
 
 	// check that AllHosts was loaded
-	_, ok0 := hdb.allHosts[host1.NetAddress]
-	_, ok1 := hdb.allHosts[host2.NetAddress]
-	_, ok2 := hdb.allHosts[host3.NetAddress]
+	_, ok0 := hdb.lookupHost(host1.NetAddress)
+	_, ok1 := hdb.lookupHost(host2.NetAddress)
+	_, ok2 := hdb.lookupHost(host3.NetAddress)
 	if !ok0 || !ok1 || !ok2 || len(hdb.allHosts) != 3 {
 		t.Fatal("allHosts was not restored properly:", hdb.allHosts)
 	}
 
 	// check that ActiveHosts was loaded
-	_, ok0 = hdb.activeHosts[host1.NetAddress]
-	_, ok1 = hdb.activeHosts[host2.NetAddress]
-	_, ok2 = hdb.activeHosts[host3.NetAddress]
+	_, ok0 = hdb.activeHosts[host1.key()]
+	_, ok1 = hdb.activeHosts[host2.key()]
+	_, ok2 = hdb.activeHosts[host3.key()]
 	if !ok0 || !ok1 || !ok2 || len(hdb.activeHosts) != 3 {
 		t.Fatal("active was not restored properly:", hdb.activeHosts)
 	}
@@ -115,15 +112,12 @@ func TestRescan(t *testing.T) {
 	host1.NetAddress = "foo"
 	host2.NetAddress = "bar"
 	host3.NetAddress = "baz"
-	hdb.allHosts = map[modules.NetAddress]*hostEntry{
-		host1.NetAddress: &host1,
-		host2.NetAddress: &host2,
-		host3.NetAddress: &host3,
-	}
-	hdb.activeHosts = map[modules.NetAddress]*hostNode{
-		host1.NetAddress: {hostEntry: &host1},
-		host2.NetAddress: {hostEntry: &host2},
-		host3.NetAddress: {hostEntry: &host3},
+	host1.PublicKey = fakePubKey("foo")
+	host2.PublicKey = fakePubKey("bar")
+	host3.PublicKey = fakePubKey("baz")
+	for _, h := range []*hostEntry{&host1, &host2, &host3} {
+		hdb.addHost(h)
+		hdb.activeHosts[h.key()] = &hostNode{hostEntry: h}
 	}
 
 	// use a bogus change ID
@@ -151,14 +145,14 @@ func TestRescan(t *testing.T) {
 	// Reload the hostdb using the same persist and the mocked consensus set.
 	// The old change ID will be rejected, causing a rescan, which should
 	// discover the new announcement.
-	hdb, err = newHostDB(cs, stdDialer{}, stdSleeper{}, hdb.persist, hdb.log)
+	hdb, err = newHostDB(cs, stdDialer{}, stdSleeper{}, stdResolver{}, hdb.persist, hdb.log)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(hdb.allHosts) != 1 {
 		t.Fatal("hostdb rescan resulted in wrong host set:", hdb.allHosts)
 	}
-	if _, exists := hdb.allHosts["quux.com:1234"]; !exists {
+	if _, exists := hdb.lookupHost("quux.com:1234"); !exists {
 		t.Fatal("hostdb rescan resulted in wrong host set:", hdb.allHosts)
 	}
 }
@@ -0,0 +1,112 @@
+package hostdb
+
+// prune.go removes hosts that have been offline for a long time. Hosts that
+// stop announcing would otherwise stay in the hostdb forever, making every
+// scan cycle and every search slower as the network grows. Hosts that the
+// renter has contracts with are never removed, as the contractor needs their
+// entries to renew and download from their contracts.
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// defaultPruneDowntime is how long a host can be offline before it is
+	// removed, unless the renter sets a different limit.
+	defaultPruneDowntime = 30 * 24 * time.Hour
+
+	// minPruneDowntime is the shortest downtime that can be set, as hosts
+	// that are briefly offline for maintenance should not be forgotten.
+	minPruneDowntime = 24 * time.Hour
+)
+
+var (
+	errPruneDowntime = errors.New("prune downtime must be 0 or at least 24 hours")
+)
+
+// offlineDuration returns how long the host has been offline, according to
+// its scan history. A host that has never been scanned is not considered
+// offline.
+func (e *hostEntry) offlineDuration(now time.Time) time.Duration {
+	if len(e.ScanHistory) == 0 {
+		return 0
+	}
+	for i := len(e.ScanHistory) - 1; i >= 0; i-- {
+		if e.ScanHistory[i].Success {
+			if i == len(e.ScanHistory)-1 {
+				return 0
+			}
+			return now.Sub(e.ScanHistory[i].Timestamp)
+		}
+	}
+	return now.Sub(e.ScanHistory[0].Timestamp)
+}
+
+// pruneHosts removes the hosts that have been offline for longer than the
+// prune downtime, except for active and contracted hosts. The host tree is
+// rebuilt afterwards so that it does not keep the empty nodes of hosts that
+// went offline. pruneHosts returns the number of hosts that were removed.
+func (hdb *HostDB) pruneHosts() int {
+	if hdb.pruneDowntime == 0 {
+		return 0
+	}
+	now := time.Now()
+	var pruned int
+	for key, entry := range hdb.allHosts {
+		if _, active := hdb.activeHosts[key]; active {
+			continue
+		}
+		if _, contracted := hdb.contracted[key]; contracted {
+			continue
+		}
+		if entry.offlineDuration(now) > hdb.pruneDowntime {
+			hdb.removeHost(key)
+			pruned++
+		}
+	}
+	if hdb.hostTree != nil && hdb.hostTree.count > len(hdb.activeHosts) {
+		hdb.rebuildTree()
+	}
+	if pruned > 0 {
+		hdb.log.Println("Pruned", pruned, "offline hosts from the hostdb")
+		hdb.save()
+	}
+	return pruned
+}
+
+// SetContractedHosts sets the hosts that the renter has contracts with. These
+// hosts are never removed from the hostdb.
+func (hdb *HostDB) SetContractedHosts(pks []types.SiaPublicKey) {
+	contracted := make(map[string]struct{}, len(pks))
+	for i := range pks {
+		contracted[pks[i].String()] = struct{}{}
+	}
+	hdb.mu.Lock()
+	hdb.contracted = contracted
+	hdb.mu.Unlock()
+}
+
+// PruneDowntime returns how long a host can be offline before it is removed
+// from the hostdb. A downtime of 0 means that hosts are never removed.
+func (hdb *HostDB) PruneDowntime() time.Duration {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	return hdb.pruneDowntime
+}
+
+// SetPruneDowntime sets how long a host can be offline before it is removed
+// from the hostdb, and removes the hosts that have already been offline for
+// longer.
+func (hdb *HostDB) SetPruneDowntime(d time.Duration) error {
+	if d < 0 || (d > 0 && d < minPruneDowntime) {
+		return errPruneDowntime
+	}
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	hdb.pruneDowntime = d
+	hdb.pruneHosts()
+	return hdb.saveSync()
+}
@@ -0,0 +1,134 @@
+package hostdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestPruneHosts checks that hosts which have been offline for too long are
+// removed, while active and contracted hosts are kept.
+func TestPruneHosts(t *testing.T) {
+	hdb := bareHostDB()
+	hdb.persist = &memPersist{}
+	hdb.pruneDowntime = defaultPruneDowntime
+
+	offline := []modules.HostDBScan{
+		{Timestamp: time.Now().Add(-2 * defaultPruneDowntime), Success: true},
+		{Timestamp: time.Now().Add(-defaultPruneDowntime), Success: false},
+		{Timestamp: time.Now(), Success: false},
+	}
+	var entries []*hostEntry
+	for i := 0; i < 10; i++ {
+		entry := new(hostEntry)
+		entry.NetAddress = fakeAddr(uint8(i))
+		entry.PublicKey = fakePubKey(string(entry.NetAddress))
+		entry.AcceptingContracts = true
+		entry.Weight = types.NewCurrency64(10)
+		hdb.addHost(entry)
+		hdb.insertNode(entry)
+		entries = append(entries, entry)
+	}
+
+	// Hosts 0-2 go offline, but host 2 has a contract with the renter. Host 3
+	// went offline recently.
+	for _, entry := range entries[:3] {
+		entry.ScanHistory = offline
+		node := hdb.activeHosts[entry.key()]
+		node.removeNode()
+		delete(hdb.activeHosts, entry.key())
+	}
+	entries[3].ScanHistory = offline[1:]
+	hdb.SetContractedHosts([]types.SiaPublicKey{entries[2].PublicKey})
+
+	if n := hdb.pruneHosts(); n != 2 {
+		t.Fatal("expected 2 hosts to be pruned, got", n)
+	}
+	for i, entry := range entries {
+		_, exists := hdb.lookupHost(entry.NetAddress)
+		if exists != (i >= 2) {
+			t.Errorf("host %v: expected exists to be %v", i, i >= 2)
+		}
+	}
+
+	// The host tree should only contain the active hosts.
+	if hdb.hostTree.count != len(hdb.activeHosts) || len(hdb.activeHosts) != 7 {
+		t.Fatal("host tree was not rebuilt:", hdb.hostTree.count, len(hdb.activeHosts))
+	}
+	if err := repeatCheck(hdb.hostTree); err != nil {
+		t.Fatal(err)
+	}
+	if err := uniformTreeVerification(hdb, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	// A downtime of 0 disables pruning.
+	if err := hdb.SetPruneDowntime(0); err != nil {
+		t.Fatal(err)
+	}
+	hdb.SetContractedHosts(nil)
+	if n := hdb.pruneHosts(); n != 0 {
+		t.Fatal("hosts were pruned while pruning was disabled")
+	}
+}
+
+// TestSetPruneDowntime checks that the prune downtime is validated.
+func TestSetPruneDowntime(t *testing.T) {
+	hdb := bareHostDB()
+	hdb.persist = &memPersist{}
+	if err := hdb.SetPruneDowntime(time.Hour); err != errPruneDowntime {
+		t.Fatal("expected errPruneDowntime, got", err)
+	}
+	if err := hdb.SetPruneDowntime(-time.Hour); err != errPruneDowntime {
+		t.Fatal("expected errPruneDowntime, got", err)
+	}
+	if err := hdb.SetPruneDowntime(7 * 24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if hdb.PruneDowntime() != 7*24*time.Hour {
+		t.Fatal("prune downtime was not updated")
+	}
+}
+
+// TestInsertHostAddressChange checks that a host which announces a new
+// address keeps its entry, and that the change is recorded.
+func TestInsertHostAddressChange(t *testing.T) {
+	hdb := bareHostDB()
+
+	var dbe modules.HostDBEntry
+	dbe.NetAddress = "foo.com:1234"
+	dbe.PublicKey = fakePubKey("foo")
+	hdb.mu.Lock()
+	hdb.insertHost(dbe)
+	hdb.mu.Unlock()
+	<-hdb.scanPool
+
+	hdb.mu.Lock()
+	hdb.blockHeight = 10
+	dbe.NetAddress = "bar.com:1234"
+	hdb.insertHost(dbe)
+	hdb.mu.Unlock()
+	select {
+	case <-hdb.scanPool:
+	case <-time.After(time.Second):
+		t.Fatal("host was not scanned at its new address")
+	}
+
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	if len(hdb.allHosts) != 1 {
+		t.Fatal("expected 1 host, got", len(hdb.allHosts))
+	}
+	if _, exists := hdb.lookupHost("foo.com:1234"); exists {
+		t.Error("host can still be found at its old address")
+	}
+	entry, exists := hdb.lookupHost("bar.com:1234")
+	if !exists {
+		t.Fatal("host cannot be found at its new address")
+	}
+	if len(entry.AddressHistory) != 2 || entry.AddressHistory[1] != (modules.HostAddressChange{NetAddress: "bar.com:1234", Height: 10}) {
+		t.Fatal("wrong address history:", entry.AddressHistory)
+	}
+}
@@ -5,7 +5,6 @@ package hostdb
 // settings of the hosts.
 
 import (
-	"bytes"
 	"crypto/rand"
 	"math/big"
 	"net"
@@ -92,12 +91,13 @@ func (hdb *HostDB) queueHostEntry(entry *hostEntry) {
 }
 
 // decrementReliability reduces the reliability of a node, moving it out of the
-// set of active hosts or deleting it entirely if necessary.
-func (hdb *HostDB) decrementReliability(addr modules.NetAddress, penalty types.Currency) {
-	hdb.log.Debugln("reliability decrement issued for", addr)
+// set of active hosts or deleting it entirely if necessary. Hosts that the
+// renter has contracts with are never deleted.
+func (hdb *HostDB) decrementReliability(key string, penalty types.Currency) {
+	hdb.log.Debugln("reliability decrement issued for", key)
 
 	// Look up the entry and decrement the reliability.
-	entry, exists := hdb.allHosts[addr]
+	entry, exists := hdb.allHosts[key]
 	if !exists {
 		// TODO: should panic here
 		return
@@ -107,42 +107,56 @@ func (hdb *HostDB) decrementReliability(addr modules.NetAddress, penalty types.C
 
 	// If the entry is in the active database, remove it from the active
 	// database.
-	node, exists := hdb.activeHosts[addr]
+	node, exists := hdb.activeHosts[key]
 	if exists {
-		hdb.log.Debugln("host is being pulled from list of active hosts", addr)
+		hdb.log.Debugln("host is being pulled from list of active hosts", entry.NetAddress)
 		node.removeNode()
-		delete(hdb.activeHosts, entry.NetAddress)
+		delete(hdb.activeHosts, key)
 	}
 
 	// If the reliability has fallen to 0, remove the host from the
 	// database entirely.
-	if entry.Reliability.IsZero() {
-		hdb.log.Debugln("host is being dropped from hostdb", addr)
-		delete(hdb.allHosts, addr)
+	_, contracted := hdb.contracted[key]
+	if entry.Reliability.IsZero() && !contracted {
+		hdb.log.Debugln("host is being dropped from hostdb", entry.NetAddress)
+		hdb.removeHost(key)
 	}
 }
 
 // managedUpdateEntry updates an entry in the hostdb after a scan has taken
-// place.
-func (hdb *HostDB) managedUpdateEntry(entry *hostEntry, newSettings modules.HostExternalSettings, netErr error) {
+// place. The latency of the scan is recorded in the host's scan history, and
+// the address at which the host was reached is recorded in the entry. The
+// hostdb is saved after every scan, including failed scans, so that the
+// downtime of offline hosts is not lost on restart.
+func (hdb *HostDB) managedUpdateEntry(entry *hostEntry, newSettings modules.HostExternalSettings, reached modules.NetAddress, netErr error, latency time.Duration) {
 	hdb.mu.Lock()
 	defer hdb.mu.Unlock()
+	defer func() {
+		err := hdb.save()
+		if err != nil {
+			hdb.log.Println("Error saving hostdb:", err)
+		}
+	}()
 
-	// Regardless of whether the host responded, add it to allHosts.
-	priorHost, exists := hdb.allHosts[entry.NetAddress]
-	if !exists {
-		hdb.allHosts[entry.NetAddress] = entry
+	hdb.recordScan(entry, modules.HostDBScan{
+		Timestamp: time.Now(),
+		Success:   netErr == nil,
+		ErrorType: scanErrorType(netErr),
+		Latency:   latency,
+	})
+
+	// If the host was removed from the hostdb while it was being scanned,
+	// the result is discarded. Otherwise a pruned host could be restored.
+	key := entry.key()
+	if _, exists := hdb.allHosts[key]; !exists {
+		return
 	}
 
-	// If the scan was unsuccessful, decrement the host's reliability.
+	// If the scan was unsuccessful, decrement the host's reliability. Hosts
+	// are keyed by public key, so a failed signature from a different host
+	// at the same address only affects the entry of that key.
 	if netErr != nil {
-		if exists && bytes.Equal(priorHost.PublicKey.Key, entry.PublicKey.Key) {
-			// Only decrement the reliability if the public key in the
-			// hostdb matches the public key in the host announcement -
-			// the failure may just be a failed signature, indicating
-			// the wrong public key.
-			hdb.decrementReliability(entry.NetAddress, UnreachablePenalty)
-		}
+		hdb.decrementReliability(key, UnreachablePenalty)
 		return
 	}
 
@@ -150,10 +164,10 @@ func (hdb *HostDB) managedUpdateEntry(entry *hostEntry, newSettings modules.Host
 	// properties of the tree require that the weight does not change while the
 	// node is in the tree, so the node must be removed before the settings and
 	// weight are changed.
-	existingNode, exists := hdb.activeHosts[entry.NetAddress]
+	existingNode, exists := hdb.activeHosts[key]
 	if exists {
 		existingNode.removeNode()
-		delete(hdb.activeHosts, entry.NetAddress)
+		delete(hdb.activeHosts, key)
 	} else if len(hdb.activeHosts) > maxActiveHosts {
 		// We already have the maximum number of active hosts, do not add more.
 		return
@@ -163,67 +177,92 @@ func (hdb *HostDB) managedUpdateEntry(entry *hostEntry, newSettings modules.Host
 	// must be preserved.
 	newSettings.NetAddress = entry.HostExternalSettings.NetAddress
 	entry.HostExternalSettings = newSettings
+	entry.ReachableAddress = reached
 	entry.Reliability = MaxReliability
 	entry.Online = true
-	entry.Weight = calculateHostWeight(*entry)
+	entry.Weight = hdb.scoreBreakdown(*entry).Score
 	hdb.insertNode(entry)
 
 	// Sanity check - the node should be in the hostdb now.
-	_, exists = hdb.activeHosts[entry.NetAddress]
+	_, exists = hdb.activeHosts[key]
 	if !exists {
 		hdb.log.Critical("Host was not added to the list of active hosts after the entry was updated.")
 	}
-	hdb.save()
+}
+
+// managedRequestSettings connects to a host and requests its settings,
+// verifying that they are signed by the host's public key.
+func (hdb *HostDB) managedRequestSettings(netAddr modules.NetAddress, pubKey types.SiaPublicKey) (settings modules.HostExternalSettings, err error) {
+	dialer := &net.Dialer{
+		Cancel:  hdb.tg.StopChan(),
+		Timeout: hostRequestTimeout,
+	}
+	conn, err := dialer.Dial("tcp", string(netAddr))
+	if err != nil {
+		return settings, err
+	}
+	connCloseChan := make(chan struct{})
+	go func() {
+		select {
+		case <-hdb.tg.StopChan():
+		case <-connCloseChan:
+		}
+		conn.Close()
+	}()
+	defer close(connCloseChan)
+	conn.SetDeadline(time.Now().Add(hostScanDeadline))
+
+	err = encoding.WriteObject(conn, modules.RPCSettings)
+	if err != nil {
+		return settings, err
+	}
+	var pubkey crypto.PublicKey
+	copy(pubkey[:], pubKey.Key)
+	err = crypto.ReadSignedObject(conn, &settings, maxSettingsLen, pubkey)
+	return settings, err
 }
 
 // managedScanHost will connect to a host and grab the settings, verifying
-// uptime and updating to the host's preferences.
+// uptime and updating to the host's preferences. The announced addresses of
+// the host are tried in order, until one of them responds.
 func (hdb *HostDB) managedScanHost(hostEntry *hostEntry) {
 	// Request settings from the queued host entry.
 	//
 	// A readlock is necessary when viewing the elements of the host entry.
 	hdb.mu.RLock()
-	netAddr := hostEntry.NetAddress
+	addrs := append([]modules.NetAddress{hostEntry.NetAddress}, hostEntry.AdditionalAddresses...)
 	pubKey := hostEntry.PublicKey
 	hdb.mu.RUnlock()
-	hdb.log.Debugln("Scanning", netAddr, pubKey)
-	var settings modules.HostExternalSettings
-	err := func() error {
-		dialer := &net.Dialer{
-			Cancel:  hdb.tg.StopChan(),
-			Timeout: hostRequestTimeout,
-		}
-		conn, err := dialer.Dial("tcp", string(netAddr))
-		if err != nil {
-			return err
-		}
-		connCloseChan := make(chan struct{})
-		go func() {
-			select {
-			case <-hdb.tg.StopChan():
-			case <-connCloseChan:
-			}
-			conn.Close()
-		}()
-		defer close(connCloseChan)
-		conn.SetDeadline(time.Now().Add(hostScanDeadline))
 
-		err = encoding.WriteObject(conn, modules.RPCSettings)
+	var settings modules.HostExternalSettings
+	var reached modules.NetAddress
+	var err error
+	var latency time.Duration
+	for _, netAddr := range addrs {
+		hdb.log.Debugln("Scanning", netAddr, pubKey)
+		start := time.Now()
+		settings, err = hdb.managedRequestSettings(netAddr, pubKey)
+		latency = time.Since(start)
 		if err != nil {
-			return err
+			hdb.log.Debugln("Scanning", netAddr, pubKey, "failed:", err)
+			continue
 		}
-		var pubkey crypto.PublicKey
-		copy(pubkey[:], pubKey.Key)
-		return crypto.ReadSignedObject(conn, &settings, maxSettingsLen, pubkey)
-	}()
-	if err != nil {
-		hdb.log.Debugln("Scanning", netAddr, pubKey, "failed:", err)
-	} else {
 		hdb.log.Debugln("Scanning", netAddr, pubKey, "succeeded")
+		reached = netAddr
+		break
+	}
+
+	// Update the host's IPs, which may have changed since the last scan. The
+	// IPs of the address that responded are used, so that hosts are grouped
+	// by the subnet that renters actually connect to.
+	resolveAddr := reached
+	if resolveAddr == "" {
+		resolveAddr = addrs[0]
 	}
+	hdb.managedResolveHost(hostEntry, resolveAddr)
 
 	// Update the host tree to have a new entry.
-	hdb.managedUpdateEntry(hostEntry, settings, err)
+	hdb.managedUpdateEntry(hostEntry, settings, reached, err, latency)
 }
 
 // threadedProbeHosts tries to fetch the settings of a host. If successful, the
@@ -270,8 +309,8 @@ func (hdb *HostDB) threadedScan() {
 
 			// Assemble all of the inactive hosts into a single array.
 			var entries []*hostEntry
-			for _, entry := range hdb.allHosts {
-				_, exists := hdb.activeHosts[entry.NetAddress]
+			for key, entry := range hdb.allHosts {
+				_, exists := hdb.activeHosts[key]
 				if !exists {
 					entries = append(entries, entry)
 				}
@@ -310,5 +349,11 @@ func (hdb *HostDB) threadedScan() {
 			return
 		case <-time.After(time.Duration(randSleep.Int64()) + minScanSleep):
 		}
+
+		// Remove the hosts that have been offline for too long, now that
+		// the scans of the previous cycle have completed.
+		hdb.mu.Lock()
+		hdb.pruneHosts()
+		hdb.mu.Unlock()
 	}
 }
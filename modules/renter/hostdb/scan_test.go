@@ -24,21 +24,40 @@ func TestDecrementReliability(t *testing.T) {
 	// from activeHosts.
 	h := new(hostEntry)
 	h.NetAddress = "foo"
+	h.PublicKey = fakePubKey("foo")
 	h.Reliability = types.NewCurrency64(1)
-	hdb.allHosts[h.NetAddress] = h
-	hdb.activeHosts[h.NetAddress] = &hostNode{hostEntry: h}
-	hdb.decrementReliability(h.NetAddress, types.NewCurrency64(0))
+	hdb.addHost(h)
+	hdb.activeHosts[h.key()] = &hostNode{hostEntry: h}
+	hdb.decrementReliability(h.key(), types.NewCurrency64(0))
 	if len(hdb.ActiveHosts()) != 0 {
 		t.Error("decrementing did not remove host from activeHosts")
 	}
 
 	// Decrement reliability to 0. This should remove the host from allHosts.
-	hdb.decrementReliability(h.NetAddress, h.Reliability)
+	hdb.decrementReliability(h.key(), h.Reliability)
 	if len(hdb.AllHosts()) != 0 {
 		t.Error("decrementing did not remove host from allHosts")
 	}
 }
 
+// TestUpdateEntrySaves checks that the scan history of a host is saved after
+// a failed scan, so that its downtime survives a restart.
+func TestUpdateEntrySaves(t *testing.T) {
+	hdb := bareHostDB()
+	persist := new(memPersist)
+	hdb.persist = persist
+
+	h := new(hostEntry)
+	h.NetAddress = "foo"
+	h.PublicKey = fakePubKey("foo")
+	h.Reliability = MaxReliability
+	hdb.addHost(h)
+	hdb.managedUpdateEntry(h, modules.HostExternalSettings{}, "", net.UnknownNetworkError("fail"), 0)
+	if len(persist.AllHosts) != 1 || len(persist.AllHosts[0].ScanHistory) != 1 || persist.AllHosts[0].ScanHistory[0].Success {
+		t.Fatal("failed scan was not saved:", persist.AllHosts)
+	}
+}
+
 // probeDialer is used to test the threadedProbeHosts method. A simple type
 // alias is used so that it can easily be redefined during testing, allowing
 // multiple behaviors to be tested.
@@ -237,7 +256,7 @@ func TestThreadedScan(t *testing.T) {
 	h := new(hostEntry)
 	h.NetAddress = "foo"
 	h.Reliability = types.NewCurrency64(1)
-	hdb.activeHosts[h.NetAddress] = &hostNode{hostEntry: h}
+	hdb.activeHosts[h.key()] = &hostNode{hostEntry: h}
 
 	// perform one scan
 	go hdb.threadedScan()
@@ -251,8 +270,8 @@ func TestThreadedScan(t *testing.T) {
 
 	// remove the host from activeHosts and add it to allHosts
 	hdb.mu.Lock()
-	delete(hdb.activeHosts, h.NetAddress)
-	hdb.allHosts[h.NetAddress] = h
+	delete(hdb.activeHosts, h.key())
+	hdb.addHost(h)
 	hdb.mu.Unlock()
 
 	// perform one scan
@@ -265,3 +284,74 @@ func TestThreadedScan(t *testing.T) {
 		t.Error("host was not scanned")
 	}
 }
+
+// TestScanAdditionalAddresses checks that a scan tries the addresses of a host
+// in order, and records the address at which the host was reached.
+func TestScanAdditionalAddresses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	hdb := bareHostDB()
+	hdb.persist = &memPersist{}
+
+	// Serve the settings of the host on one address, and find an address at
+	// which nothing is listening.
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			encoding.ReadObject(conn, new(types.Specifier), types.SpecifierLen)
+			crypto.WriteSignedObject(conn, modules.HostExternalSettings{AcceptingContracts: true}, sk)
+			conn.Close()
+		}
+	}()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := modules.NetAddress(closed.Addr().String())
+	closed.Close()
+	liveAddr := modules.NetAddress(l.Addr().String())
+
+	h := new(hostEntry)
+	h.NetAddress = deadAddr
+	h.AdditionalAddresses = []modules.NetAddress{liveAddr}
+	h.PublicKey = types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	h.Reliability = DefaultReliability
+	hdb.addHost(h)
+
+	// The host should be reached at its additional address, and keep its
+	// NetAddress.
+	hdb.managedScanHost(h)
+	if !h.Online || h.ReachableAddress != liveAddr || h.NetAddress != deadAddr {
+		t.Fatal("host was not reached at its additional address:", h.Online, h.ReachableAddress, h.NetAddress)
+	}
+	if _, ok := hdb.Host(deadAddr); !ok {
+		t.Fatal("host should still be known by its NetAddress")
+	}
+	addrs := h.DialAddresses()
+	if len(addrs) != 2 || addrs[0] != liveAddr || addrs[1] != deadAddr {
+		t.Fatal("reachable address should be dialed first:", addrs)
+	}
+
+	// If none of the addresses respond, the scan fails.
+	l.Close()
+	hdb.managedScanHost(h)
+	if h.Online {
+		t.Fatal("unreachable host is still online")
+	}
+}
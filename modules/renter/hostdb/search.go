@@ -0,0 +1,88 @@
+package hostdb
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// hostSummaries sorts a slice of host summaries by descending score, or by
+// ascending storage price. Ties are broken by address so that pages of the
+// results are stable.
+type hostSummaries struct {
+	hosts  []modules.HostDBSummary
+	byCost bool
+}
+
+func (hs hostSummaries) Len() int      { return len(hs.hosts) }
+func (hs hostSummaries) Swap(i, j int) { hs.hosts[i], hs.hosts[j] = hs.hosts[j], hs.hosts[i] }
+func (hs hostSummaries) Less(i, j int) bool {
+	a, b := hs.hosts[i], hs.hosts[j]
+	var cmp int
+	if hs.byCost {
+		cmp = a.StoragePrice.Cmp(b.StoragePrice)
+	} else {
+		cmp = b.Score.Cmp(a.Score)
+	}
+	if cmp != 0 {
+		return cmp < 0
+	}
+	return a.NetAddress < b.NetAddress
+}
+
+// matchesFilter returns true if the host matches every criterion of the
+// filter.
+func matchesFilter(h modules.HostDBSummary, f modules.HostDBFilter) bool {
+	switch {
+	case !f.MaxStoragePrice.IsZero() && h.StoragePrice.Cmp(f.MaxStoragePrice) > 0:
+		return false
+	case !f.MaxUploadPrice.IsZero() && h.UploadBandwidthPrice.Cmp(f.MaxUploadPrice) > 0:
+		return false
+	case !f.MaxDownloadPrice.IsZero() && h.DownloadBandwidthPrice.Cmp(f.MaxDownloadPrice) > 0:
+		return false
+	case h.RemainingStorage < f.MinRemainingStorage:
+		return false
+	case f.AcceptingContracts && !h.AcceptingContracts:
+		return false
+	case f.Online && !h.Online:
+		return false
+	case h.Uptime < f.MinUptime:
+		return false
+	case f.MinVersion != "" && (!build.IsVersion(h.Version) || build.VersionCmp(h.Version, f.MinVersion) < 0):
+		return false
+	}
+	return true
+}
+
+// SearchHosts returns the hosts that match the filter, sorted and paginated
+// as the filter specifies, along with the total number of matching hosts.
+func (hdb *HostDB) SearchHosts(f modules.HostDBFilter) ([]modules.HostDBSummary, int) {
+	hdb.mu.RLock()
+	var matches []modules.HostDBSummary
+	for key, entry := range hdb.allHosts {
+		_, active := hdb.activeHosts[key]
+		uptime, _ := entry.uptimePercentages()
+		h := modules.HostDBSummary{
+			HostDBEntry: entry.HostDBEntry,
+			Online:      active || entry.Online,
+			Uptime:      uptime,
+			Score:       hdb.scoreBreakdown(*entry).Score,
+		}
+		if matchesFilter(h, f) {
+			matches = append(matches, h)
+		}
+	}
+	hdb.mu.RUnlock()
+
+	sort.Sort(hostSummaries{hosts: matches, byCost: f.SortBy == modules.HostSortPrice})
+	total := len(matches)
+	if f.Offset >= total {
+		return nil, total
+	}
+	matches = matches[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(matches) {
+		matches = matches[:f.Limit]
+	}
+	return matches, total
+}
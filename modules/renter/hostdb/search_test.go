@@ -0,0 +1,69 @@
+package hostdb
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSearchHosts checks that SearchHosts filters, sorts, and paginates hosts.
+func TestSearchHosts(t *testing.T) {
+	hdb := bareHostDB()
+	hosts := []struct {
+		addr      modules.NetAddress
+		price     uint64
+		accepting bool
+		version   string
+	}{
+		{"foo:1", 30, true, "1.0.1"},
+		{"bar:1", 10, true, "0.6.0"},
+		{"baz:1", 20, false, "1.0.0"},
+	}
+	for _, h := range hosts {
+		entry := new(hostEntry)
+		entry.NetAddress = h.addr
+		entry.PublicKey = fakePubKey(string(h.addr))
+		entry.StoragePrice = types.NewCurrency64(h.price)
+		entry.AcceptingContracts = h.accepting
+		entry.Version = h.version
+		hdb.addHost(entry)
+	}
+
+	// no filter returns every host
+	if _, total := hdb.SearchHosts(modules.HostDBFilter{}); total != 3 {
+		t.Fatal("expected 3 hosts, got", total)
+	}
+
+	// sort by price
+	results, _ := hdb.SearchHosts(modules.HostDBFilter{SortBy: modules.HostSortPrice})
+	if results[0].NetAddress != "bar:1" || results[1].NetAddress != "baz:1" || results[2].NetAddress != "foo:1" {
+		t.Fatal("hosts were not sorted by price:", results)
+	}
+
+	// filters
+	results, total := hdb.SearchHosts(modules.HostDBFilter{MaxStoragePrice: types.NewCurrency64(20)})
+	if total != 2 {
+		t.Fatal("expected 2 hosts, got", total)
+	}
+	for _, h := range results {
+		if h.NetAddress == "foo:1" {
+			t.Fatal("host above the max price was listed")
+		}
+	}
+	if results, _ = hdb.SearchHosts(modules.HostDBFilter{AcceptingContracts: true, MinVersion: "1.0.0"}); len(results) != 1 || results[0].NetAddress != "foo:1" {
+		t.Fatal("wrong hosts:", results)
+	}
+	if _, total = hdb.SearchHosts(modules.HostDBFilter{Online: true}); total != 0 {
+		t.Fatal("offline hosts were listed")
+	}
+
+	// pagination
+	results, total = hdb.SearchHosts(modules.HostDBFilter{SortBy: modules.HostSortPrice, Offset: 1, Limit: 1})
+	if total != 3 || len(results) != 1 || results[0].NetAddress != "baz:1" {
+		t.Fatal("wrong page:", total, results)
+	}
+	if results, total = hdb.SearchHosts(modules.HostDBFilter{Offset: 5}); total != 3 || len(results) != 0 {
+		t.Fatal("expected an empty page, got", results)
+	}
+}
@@ -0,0 +1,138 @@
+package hostdb
+
+// subnet.go groups hosts by the subnets of their IP addresses. Hosts in the
+// same subnet are likely to be run by the same operator or to share a network
+// connection, so storing more than one piece of a chunk on them defeats the
+// purpose of erasure coding.
+
+import (
+	"errors"
+	"net"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// defaultSubnetSizes group IPv4 hosts by /24 and IPv6 hosts by /48, which
+	// are the smallest blocks that are commonly assigned to a single site.
+	defaultSubnetSizes = modules.HostSubnetSizes{
+		IPv4: 24,
+		IPv6: 48,
+	}
+
+	errSubnetSizeRange = errors.New("subnet sizes must be between 0 and 32 bits for IPv4, and 0 and 128 bits for IPv6")
+)
+
+// hostSubnets returns the subnets that a host's IPs belong to. Loopback
+// addresses do not belong to any subnet, so that hosts on a local network can
+// all be selected.
+func hostSubnets(ips []net.IP, sizes modules.HostSubnetSizes) []string {
+	var subnets []string
+	for _, ip := range ips {
+		if ip.IsLoopback() {
+			continue
+		}
+		bits, size := 128, sizes.IPv6
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits, size = v4, 32, sizes.IPv4
+		}
+		if size == 0 {
+			continue
+		}
+		mask := net.CIDRMask(size, bits)
+		subnet := (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+		if !containsSubnet(subnets, subnet) {
+			subnets = append(subnets, subnet)
+		}
+	}
+	return subnets
+}
+
+// containsSubnet returns true if subnet is in subnets.
+func containsSubnet(subnets []string, subnet string) bool {
+	for _, s := range subnets {
+		if s == subnet {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesSubnet returns true if any of the subnets are in used.
+func sharesSubnet(subnets []string, used map[string]struct{}) bool {
+	for _, subnet := range subnets {
+		if _, exists := used[subnet]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// managedResolveHost looks up the IPs of a host and stores them in its entry.
+// If the lookup fails, the previous IPs are kept.
+func (hdb *HostDB) managedResolveHost(entry *hostEntry, addr modules.NetAddress) {
+	host := addr.Host()
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		ips, err = hdb.resolver.LookupIP(host)
+		if err != nil {
+			hdb.log.Debugln("Could not resolve", addr, err)
+			return
+		}
+	}
+	hdb.mu.Lock()
+	entry.IPs = ips
+	hdb.mu.Unlock()
+}
+
+// SubnetSizes returns the sizes of the subnets that hosts are grouped by.
+func (hdb *HostDB) SubnetSizes() modules.HostSubnetSizes {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	return hdb.subnetSizes
+}
+
+// SetSubnetSizes sets the sizes of the subnets that hosts are grouped by.
+func (hdb *HostDB) SetSubnetSizes(sizes modules.HostSubnetSizes) error {
+	if sizes.IPv4 < 0 || sizes.IPv4 > 32 || sizes.IPv6 < 0 || sizes.IPv6 > 128 {
+		return errSubnetSizeRange
+	}
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	hdb.subnetSizes = sizes
+	return hdb.saveSync()
+}
+
+// SubnetConflicts returns the addresses in addrs whose hosts share a subnet
+// with the host of another address in addrs. Unknown hosts do not conflict
+// with any other host.
+func (hdb *HostDB) SubnetConflicts(addrs []modules.NetAddress) []modules.NetAddress {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+
+	// count the hosts in each subnet
+	hostsPerSubnet := make(map[string]int)
+	subnets := make(map[modules.NetAddress][]string)
+	for _, addr := range addrs {
+		entry, exists := hdb.lookupHost(addr)
+		if !exists {
+			continue
+		}
+		subnets[addr] = hostSubnets(entry.IPs, hdb.subnetSizes)
+		for _, subnet := range subnets[addr] {
+			hostsPerSubnet[subnet]++
+		}
+	}
+
+	var conflicts []modules.NetAddress
+	for _, addr := range addrs {
+		for _, subnet := range subnets[addr] {
+			if hostsPerSubnet[subnet] > 1 {
+				conflicts = append(conflicts, addr)
+				break
+			}
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,102 @@
+package hostdb
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// mapResolver is a resolver that looks up hosts in a map.
+type mapResolver map[string][]net.IP
+
+func (r mapResolver) LookupIP(host string) ([]net.IP, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return ips, nil
+}
+
+// TestHostSubnets checks that IPs are grouped into subnets of the configured
+// size.
+func TestHostSubnets(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("12.34.56.78"),
+		net.ParseIP("12.34.56.200"), // same /24 as the first IP
+		net.ParseIP("2001:db8:1:2::1"),
+		net.ParseIP("127.0.0.1"),
+	}
+	subnets := hostSubnets(ips, defaultSubnetSizes)
+	if len(subnets) != 2 || subnets[0] != "12.34.56.0/24" || subnets[1] != "2001:db8:1::/48" {
+		t.Fatal("wrong subnets:", subnets)
+	}
+	subnets = hostSubnets(ips, modules.HostSubnetSizes{IPv4: 16})
+	if len(subnets) != 1 || subnets[0] != "12.34.0.0/16" {
+		t.Fatal("wrong subnets:", subnets)
+	}
+	if subnets = hostSubnets(ips, modules.HostSubnetSizes{}); len(subnets) != 0 {
+		t.Fatal("subnets should be disabled, got", subnets)
+	}
+}
+
+// TestRandomHostsSubnets checks that RandomHosts selects at most one host per
+// subnet, while ActiveHosts returns every host.
+func TestRandomHostsSubnets(t *testing.T) {
+	hdb := bareHostDB()
+	hdb.subnetSizes = defaultSubnetSizes
+	hdb.resolver = mapResolver{
+		"foo.com": {net.ParseIP("12.34.56.1")},
+		"bar.com": {net.ParseIP("12.34.56.2")},
+		"baz.com": {net.ParseIP("98.76.54.3")},
+	}
+	for _, addr := range []modules.NetAddress{"foo.com:1", "bar.com:1", "baz.com:1", "127.0.0.1:1", "127.0.0.2:1"} {
+		entry := new(hostEntry)
+		entry.NetAddress = addr
+		entry.PublicKey = fakePubKey(string(addr))
+		entry.AcceptingContracts = true
+		entry.Weight = types.NewCurrency64(10)
+		hdb.managedResolveHost(entry, addr)
+		hdb.addHost(entry)
+		hdb.insertNode(entry)
+	}
+
+	// foo.com and bar.com share a subnet, and the loopback hosts are not in
+	// any subnet.
+	if hosts := hdb.RandomHosts(5, nil); len(hosts) != 4 {
+		t.Fatal("expected 4 hosts, got", len(hosts))
+	}
+	if hosts := hdb.ActiveHosts(); len(hosts) != 5 {
+		t.Fatal("expected 5 active hosts, got", len(hosts))
+	}
+
+	// ignoring foo.com should also exclude bar.com
+	for _, h := range hdb.RandomHosts(5, []modules.NetAddress{"foo.com:1"}) {
+		if h.NetAddress == "bar.com:1" || h.NetAddress == "foo.com:1" {
+			t.Fatal("host in ignored subnet was selected:", h.NetAddress)
+		}
+	}
+
+	// the shared subnet is flagged
+	conflicts := hdb.SubnetConflicts([]modules.NetAddress{"foo.com:1", "bar.com:1", "baz.com:1", "127.0.0.1:1"})
+	if len(conflicts) != 2 || conflicts[0] != "foo.com:1" || conflicts[1] != "bar.com:1" {
+		t.Fatal("wrong subnet conflicts:", conflicts)
+	}
+
+	// disabling IPv4 subnets removes the restriction
+	hdb.persist = &memPersist{}
+	if err := hdb.SetSubnetSizes(modules.HostSubnetSizes{IPv6: 48}); err != nil {
+		t.Fatal(err)
+	}
+	if hosts := hdb.RandomHosts(5, nil); len(hosts) != 5 {
+		t.Fatal("expected 5 hosts, got", len(hosts))
+	}
+	if conflicts := hdb.SubnetConflicts([]modules.NetAddress{"foo.com:1", "bar.com:1"}); len(conflicts) != 0 {
+		t.Fatal("expected no conflicts, got", conflicts)
+	}
+	if err := hdb.SetSubnetSizes(modules.HostSubnetSizes{IPv4: 33}); err != errSubnetSizeRange {
+		t.Fatal("expected errSubnetSizeRange, got", err)
+	}
+}
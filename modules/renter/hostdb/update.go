@@ -13,14 +13,15 @@ func findHostAnnouncements(b types.Block) (announcements []modules.HostDBEntry)
 		// the HostAnnouncement must be prefaced by the standard host
 		// announcement string
 		for _, arb := range t.ArbitraryData {
-			addr, pubKey, err := modules.DecodeAnnouncement(arb)
+			addrs, pubKey, err := modules.DecodeAnnouncementAddresses(arb)
 			if err != nil {
 				continue
 			}
 
 			// Add the announcement to the slice being returned.
 			var host modules.HostDBEntry
-			host.NetAddress = addr
+			host.NetAddress = addrs[0]
+			host.AdditionalAddresses = addrs[1:]
 			host.PublicKey = pubKey
 			announcements = append(announcements, host)
 		}
@@ -55,6 +55,26 @@ func TestFindHostAnnouncements(t *testing.T) {
 	if len(announcements) != 0 {
 		t.Error("host announcement found when there was an invalid encoding of a host announcement")
 	}
+	b.Transactions[0].ArbitraryData[0][17]--
+
+	// Try with an announcement of several addresses.
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	annBytes, err = modules.CreateAnnouncementAddresses([]modules.NetAddress{"foo.com:1234", "1.2.3.4:1234"}, spk, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Transactions[0].ArbitraryData = [][]byte{annBytes}
+	announcements = findHostAnnouncements(b)
+	if len(announcements) != 1 || announcements[0].NetAddress != "foo.com:1234" || len(announcements[0].AdditionalAddresses) != 1 || announcements[0].AdditionalAddresses[0] != "1.2.3.4:1234" {
+		t.Error("wrong addresses in host announcement:", announcements)
+	}
 }
 
 // TestReceiveConsensusSetUpdate probes the ReveiveConsensusSetUpdate method of
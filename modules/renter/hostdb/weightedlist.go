@@ -132,7 +132,8 @@ func (hn *hostNode) removeNode() {
 // with 0 weight will never be selected, they are accepted into the tree.
 func (hdb *HostDB) insertNode(entry *hostEntry) {
 	// If there's already a host of the same id, remove that host.
-	priorEntry, exists := hdb.activeHosts[entry.NetAddress]
+	key := entry.key()
+	priorEntry, exists := hdb.activeHosts[key]
 	if exists {
 		priorEntry.removeNode()
 	}
@@ -140,13 +141,50 @@ func (hdb *HostDB) insertNode(entry *hostEntry) {
 	// Insert the updated entry into the host tree.
 	if hdb.hostTree == nil {
 		hdb.hostTree = createNode(nil, entry)
-		hdb.activeHosts[entry.NetAddress] = hdb.hostTree
+		hdb.activeHosts[key] = hdb.hostTree
 	} else {
 		_, hostNode := hdb.hostTree.recursiveInsert(entry)
-		hdb.activeHosts[entry.NetAddress] = hostNode
+		hdb.activeHosts[key] = hostNode
 	}
 }
 
+// buildTree builds a balanced tree out of the given entries, adding each node
+// to the set of active hosts. Every entry becomes a node, so unlike repeated
+// calls to insertNode, buildTree runs in linear time and leaves no empty
+// nodes behind.
+func (hdb *HostDB) buildTree(parent *hostNode, entries []*hostEntry) *hostNode {
+	if len(entries) == 0 {
+		return nil
+	}
+	node := createNode(parent, entries[0])
+	hdb.activeHosts[entries[0].key()] = node
+
+	// As in recursiveInsert, the left side never has more nodes than the
+	// right side.
+	rest := entries[1:]
+	node.left = hdb.buildTree(node, rest[:len(rest)/2])
+	node.right = hdb.buildTree(node, rest[len(rest)/2:])
+	for _, child := range []*hostNode{node.left, node.right} {
+		if child != nil {
+			node.count += child.count
+			node.weight = node.weight.Add(child.weight)
+		}
+	}
+	return node
+}
+
+// rebuildTree replaces the host tree with a balanced tree containing only the
+// active hosts. The weight of each host is taken from its entry, so the
+// weights of the active hosts can be changed before calling rebuildTree.
+func (hdb *HostDB) rebuildTree() {
+	entries := make([]*hostEntry, 0, len(hdb.activeHosts))
+	for _, node := range hdb.activeHosts {
+		entries = append(entries, node.hostEntry)
+	}
+	hdb.activeHosts = make(map[string]*hostNode, len(entries))
+	hdb.hostTree = hdb.buildTree(nil, entries)
+}
+
 // isEmpty returns whether the hostTree contains no entries.
 func (hdb *HostDB) isEmpty() bool {
 	return hdb.hostTree == nil || hdb.hostTree.weight.IsZero()
@@ -157,7 +195,16 @@ func (hdb *HostDB) isEmpty() bool {
 // may even be 0. The hosts that get returned first have the higher priority.
 // Hosts specified in 'ignore' will not be considered; pass 'nil' if no
 // blacklist is desired.
-func (hdb *HostDB) RandomHosts(n int, ignore []modules.NetAddress) (hosts []modules.HostDBEntry) {
+//
+// At most one host is returned from each subnet, and hosts that share a
+// subnet with an ignored host are not returned either.
+func (hdb *HostDB) RandomHosts(n int, ignore []modules.NetAddress) []modules.HostDBEntry {
+	return hdb.randomHosts(n, ignore, true)
+}
+
+// randomHosts implements RandomHosts. If diverse is false, hosts are returned
+// regardless of their subnet.
+func (hdb *HostDB) randomHosts(n int, ignore []modules.NetAddress, diverse bool) (hosts []modules.HostDBEntry) {
 	hdb.mu.Lock()
 	defer hdb.mu.Unlock()
 	if hdb.isEmpty() {
@@ -167,15 +214,30 @@ func (hdb *HostDB) RandomHosts(n int, ignore []modules.NetAddress) (hosts []modu
 	// These will be restored after selection is finished.
 	var removedEntries []*hostEntry
 
-	// Remove hosts that we want to ignore.
+	// usedSubnets contains the subnets of the hosts that have been selected
+	// or ignored.
+	usedSubnets := make(map[string]struct{})
+
+	// Remove hosts that we want to ignore. Hosts are keyed by public key, so
+	// the active hosts are searched for the ignored addresses.
+	ignored := make(map[modules.NetAddress]struct{}, len(ignore))
 	for _, addr := range ignore {
-		node, exists := hdb.activeHosts[addr]
-		if !exists {
-			continue
+		ignored[addr] = struct{}{}
+		if entry, exists := hdb.lookupHost(addr); exists {
+			for _, subnet := range hostSubnets(entry.IPs, hdb.subnetSizes) {
+				usedSubnets[subnet] = struct{}{}
+			}
+		}
+	}
+	if len(ignored) > 0 {
+		for key, node := range hdb.activeHosts {
+			if _, exists := ignored[node.hostEntry.NetAddress]; !exists {
+				continue
+			}
+			node.removeNode()
+			delete(hdb.activeHosts, key)
+			removedEntries = append(removedEntries, node.hostEntry)
 		}
-		node.removeNode()
-		delete(hdb.activeHosts, addr)
-		removedEntries = append(removedEntries, node.hostEntry)
 	}
 
 	// Pick a host, remove it from the tree, and repeat until we have n hosts
@@ -191,14 +253,21 @@ func (hdb *HostDB) RandomHosts(n int, ignore []modules.NetAddress) (hosts []modu
 			build.Critical("nodeAtWeight is returning and error:", err)
 			break
 		}
-		// Only return the host if they are accepting contracts.
-		if node.hostEntry.HostDBEntry.AcceptingContracts {
+		// Only return the host if they are accepting contracts, and if no
+		// host in the same subnet has been selected.
+		subnets := hostSubnets(node.hostEntry.IPs, hdb.subnetSizes)
+		if diverse && sharesSubnet(subnets, usedSubnets) {
+			hdb.log.Debugln("skipping host in used subnet:", node.hostEntry.NetAddress)
+		} else if node.hostEntry.HostDBEntry.AcceptingContracts {
 			hosts = append(hosts, node.hostEntry.HostDBEntry)
+			for _, subnet := range subnets {
+				usedSubnets[subnet] = struct{}{}
+			}
 		}
 
 		removedEntries = append(removedEntries, node.hostEntry)
 		node.removeNode()
-		delete(hdb.activeHosts, node.hostEntry.NetAddress)
+		delete(hdb.activeHosts, node.hostEntry.key())
 	}
 
 	// Add back all of the entries that got removed.
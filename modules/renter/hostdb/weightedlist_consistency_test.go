@@ -114,7 +114,7 @@ func uniformTreeVerification(hdb *HostDB, numEntries int) error {
 			break
 		}
 		node.removeNode()
-		delete(hdb.activeHosts, node.hostEntry.NetAddress)
+		delete(hdb.activeHosts, node.hostEntry.key())
 
 		// remove the entry from the hostdb so it won't be selected as a
 		// repeat.
@@ -32,6 +32,7 @@ func TestWeightedList(t *testing.T) {
 	firstInsertions := 64
 	for i := 0; i < firstInsertions; i++ {
 		dbe.NetAddress = fakeAddr(uint8(i))
+		dbe.PublicKey = fakePubKey(string(dbe.NetAddress))
 		entry := hostEntry{
 			HostDBEntry: dbe,
 			Weight:      types.NewCurrency64(10),
@@ -63,7 +64,8 @@ func TestWeightedList(t *testing.T) {
 		}
 
 		// Remove the entry and add it to the list of removed entries
-		err := hdb.removeHost(fakeAddr(randInt))
+		pk := fakePubKey(string(fakeAddr(randInt)))
+		err := hdb.removeHost(pk.String())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -78,6 +80,7 @@ func TestWeightedList(t *testing.T) {
 	secondInsertions := 64
 	for i := firstInsertions; i < firstInsertions+secondInsertions; i++ {
 		dbe.NetAddress = fakeAddr(uint8(i))
+		dbe.PublicKey = fakePubKey(string(dbe.NetAddress))
 		entry := hostEntry{
 			HostDBEntry: dbe,
 			Weight:      types.NewCurrency64(10),
@@ -96,11 +99,7 @@ func TestVariedWeights(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
-	hdb := &HostDB{
-		activeHosts: make(map[modules.NetAddress]*hostNode),
-		allHosts:    make(map[modules.NetAddress]*hostEntry),
-		scanPool:    make(chan *hostEntry, scanPoolSize),
-	}
+	hdb := bareHostDB()
 
 	// insert i hosts with the weights 0, 1, ..., i-1. 100e3 selections will be made
 	// per weight added to the tree, the total number of selections necessary
@@ -112,6 +111,7 @@ func TestVariedWeights(t *testing.T) {
 	selections := 0
 	for i := 0; i < hostCount; i++ {
 		dbe.NetAddress = fakeAddr(uint8(i))
+		dbe.PublicKey = fakePubKey(string(dbe.NetAddress))
 		entry := hostEntry{
 			HostDBEntry: dbe,
 			Weight:      types.NewCurrency64(uint64(i)),
@@ -128,7 +128,7 @@ func TestVariedWeights(t *testing.T) {
 		if len(randEntry) == 0 {
 			t.Fatal("no hosts!")
 		}
-		node, exists := hdb.activeHosts[randEntry[0].NetAddress]
+		node, exists := hdb.activeHosts[randEntry[0].PublicKey.String()]
 		if !exists {
 			t.Fatal("can't find randomly selected node in tree")
 		}
@@ -158,14 +158,11 @@ func TestRepeatInsert(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
-	hdb := &HostDB{
-		activeHosts: make(map[modules.NetAddress]*hostNode),
-		allHosts:    make(map[modules.NetAddress]*hostEntry),
-		scanPool:    make(chan *hostEntry, scanPoolSize),
-	}
+	hdb := bareHostDB()
 
 	var dbe modules.HostDBEntry
 	dbe.NetAddress = fakeAddr(0)
+	dbe.PublicKey = fakePubKey(string(dbe.NetAddress))
 	entry1 := hostEntry{
 		HostDBEntry: dbe,
 		Weight:      types.NewCurrency64(1),
@@ -185,6 +182,7 @@ func TestNodeAtWeight(t *testing.T) {
 	// create hostTree
 	h1 := new(hostEntry)
 	h1.NetAddress = "foo"
+	h1.PublicKey = fakePubKey("foo")
 	h1.Weight = baseWeight
 	ht := createNode(nil, h1)
 
@@ -215,17 +213,20 @@ func TestRandomHosts(t *testing.T) {
 	// Insert 3 hosts to be selected.
 	var dbe modules.HostDBEntry
 	dbe.NetAddress = fakeAddr(1)
+	dbe.PublicKey = fakePubKey(string(dbe.NetAddress))
 	dbe.AcceptingContracts = true
 	entry1 := hostEntry{
 		HostDBEntry: dbe,
 		Weight:      types.NewCurrency64(1),
 	}
 	dbe.NetAddress = fakeAddr(2)
+	dbe.PublicKey = fakePubKey(string(dbe.NetAddress))
 	entry2 := hostEntry{
 		HostDBEntry: dbe,
 		Weight:      types.NewCurrency64(2),
 	}
 	dbe.NetAddress = fakeAddr(3)
+	dbe.PublicKey = fakePubKey(string(dbe.NetAddress))
 	entry3 := hostEntry{
 		HostDBEntry: dbe,
 		Weight:      types.NewCurrency64(3),
@@ -298,6 +299,7 @@ func TestRandomHosts(t *testing.T) {
 	// entry4 should not every be returned by RandomHosts because it is not
 	// accepting contracts.
 	dbe.NetAddress = fakeAddr(4)
+	dbe.PublicKey = fakePubKey(string(dbe.NetAddress))
 	dbe.AcceptingContracts = false
 	entry4 := hostEntry{
 		HostDBEntry: dbe,
@@ -5,14 +5,21 @@ import (
 	"net"
 	"time"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
+// maxRangeProofSize is the maximum size of the encoded range proofs for a
+// single partial download. A range proof holds at most two hashes for each
+// level of the sector's Merkle tree.
+const maxRangeProofSize = 2*64*crypto.HashSize + 32
+
 // A Downloader retrieves sectors by calling the download RPC on a host.
-// Downloaders are NOT thread- safe; calls to Sector must be serialized.
+// Downloaders are NOT thread- safe; calls to Sector and PartialSector must be
+// serialized.
 type Downloader struct {
 	host     modules.HostDBEntry
 	contract modules.RenterContract // updated after each revision
@@ -28,11 +35,34 @@ type Downloader struct {
 // the underlying contract to pay the host proportionally to the data
 // retrieve.
 func (hd *Downloader) Sector(root crypto.Hash) (modules.RenterContract, []byte, error) {
+	return hd.PartialSector(root, 0, modules.SectorSize)
+}
+
+// PartialSector retrieves 'length' bytes starting at 'offset' from the sector
+// with the specified Merkle root, and revises the underlying contract to pay
+// the host for the data retrieved. Partial reads must be aligned to
+// crypto.SegmentSize, and are verified against the sector root using a Merkle
+// range proof sent by the host. Hosts older than modules.PartialSectorVersion
+// only support downloading whole sectors.
+func (hd *Downloader) PartialSector(root crypto.Hash, offset, length uint64) (modules.RenterContract, []byte, error) {
 	extendDeadline(hd.conn, modules.NegotiateDownloadTime)
 	defer extendDeadline(hd.conn, time.Hour) // reset deadline when finished
 
+	action := modules.DownloadAction{
+		MerkleRoot: root,
+		Offset:     offset,
+		Length:     length,
+	}
+	if length == 0 || offset+length > modules.SectorSize || offset+length < offset {
+		return modules.RenterContract{}, nil, errors.New("download range is out of sector bounds")
+	} else if action.Partial() && (offset%crypto.SegmentSize != 0 || length%crypto.SegmentSize != 0) {
+		return modules.RenterContract{}, nil, errors.New("partial download range is not segment-aligned")
+	} else if action.Partial() && build.VersionCmp(hd.host.Version, modules.PartialSectorVersion) < 0 {
+		return modules.RenterContract{}, nil, errors.New("host does not support partial sector downloads")
+	}
+
 	// calculate price
-	sectorPrice := hd.host.DownloadBandwidthPrice.Mul64(modules.SectorSize)
+	sectorPrice := hd.host.DownloadBandwidthPrice.Mul64(length)
 	if hd.contract.RenterFunds().Cmp(sectorPrice) < 0 {
 		return modules.RenterContract{}, nil, errors.New("contract has insufficient funds to support download")
 	}
@@ -57,11 +87,7 @@ func (hd *Downloader) Sector(root crypto.Hash) (modules.RenterContract, []byte,
 	}
 
 	// send download action
-	err := encoding.WriteObject(hd.conn, []modules.DownloadAction{{
-		MerkleRoot: root,
-		Offset:     0,
-		Length:     modules.SectorSize,
-	}})
+	err := encoding.WriteObject(hd.conn, []modules.DownloadAction{action})
 	if err != nil {
 		return modules.RenterContract{}, nil, err
 	}
@@ -85,8 +111,21 @@ func (hd *Downloader) Sector(root crypto.Hash) (modules.RenterContract, []byte,
 		return modules.RenterContract{}, nil, errors.New("host did not send enough sectors")
 	}
 	sector := sectors[0]
-	if uint64(len(sector)) != modules.SectorSize {
+	if uint64(len(sector)) != length {
 		return modules.RenterContract{}, nil, errors.New("host did not send enough sector data")
+	}
+	if action.Partial() {
+		// read and verify the range proof of the partial sector data
+		var proofs [][]crypto.Hash
+		if err := encoding.ReadObject(hd.conn, &proofs, maxRangeProofSize); err != nil {
+			return modules.RenterContract{}, nil, err
+		} else if len(proofs) != 1 {
+			return modules.RenterContract{}, nil, errors.New("host did not send enough range proofs")
+		}
+		start, end := offset/crypto.SegmentSize, (offset+length)/crypto.SegmentSize
+		if !crypto.VerifyRangeProof(sector, proofs[0], start, end, modules.SectorSize/crypto.SegmentSize, root) {
+			return modules.RenterContract{}, nil, errors.New("host sent bad sector data")
+		}
 	} else if crypto.MerkleRoot(sector) != root {
 		return modules.RenterContract{}, nil, errors.New("host sent bad sector data")
 	}
@@ -122,7 +161,7 @@ func NewDownloader(host modules.HostDBEntry, contract modules.RenterContract) (*
 	}
 
 	// initiate download loop
-	conn, err := net.DialTimeout("tcp", string(contract.NetAddress), 15*time.Second)
+	conn, err := dialHost(host)
 	if err != nil {
 		return nil, err
 	}
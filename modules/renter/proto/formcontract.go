@@ -2,8 +2,6 @@ package proto
 
 import (
 	"errors"
-	"net"
-	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
@@ -21,7 +19,7 @@ const (
 // transaction to tpool.
 func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool transactionPool) (modules.RenterContract, error) {
 	// extract vars from params, for convenience
-	host, filesize, startHeight, endHeight, refundAddress := params.Host, params.Filesize, params.StartHeight, params.EndHeight, params.RefundAddress
+	host, startHeight, endHeight, refundAddress := params.Host, params.StartHeight, params.EndHeight, params.RefundAddress
 
 	// create our key
 	ourSK, ourPK, err := crypto.GenerateKeyPair()
@@ -39,16 +37,8 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 	}
 
 	// calculate cost to renter and cost to host
-	// TODO: clarify/abstract this math
-	storageAllocation := host.StoragePrice.Mul64(filesize).Mul64(uint64(endHeight - startHeight))
-	hostCollateral := host.Collateral.Mul64(filesize).Mul64(uint64(endHeight - startHeight))
-	if hostCollateral.Cmp(host.MaxCollateral) > 0 {
-		// TODO: if we have to cap the collateral, it probably means we shouldn't be using this host
-		// (ok within a factor of 2)
-		hostCollateral = host.MaxCollateral
-	}
+	payout, hostCollateral := contractPayout(params)
 	hostPayout := hostCollateral.Add(host.ContractPrice)
-	payout := storageAllocation.Add(hostPayout).Mul64(10406).Div64(10000) // renter pays for siafund fee
 	renterCost := payout.Sub(hostCollateral)
 
 	// check for negative currency
@@ -82,8 +72,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 	}
 
 	// calculate transaction fee
-	_, maxFee := tpool.FeeEstimation()
-	fee := maxFee.Mul64(estTxnSize)
+	fee := txnFee(tpool)
 
 	// build transaction containing fc
 	err = txnBuilder.FundSiacoins(renterCost.Add(fee))
@@ -100,7 +89,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 	txnSet := append(parentTxns, txn)
 
 	// initiate connection
-	conn, err := net.DialTimeout("tcp", string(host.NetAddress), 15*time.Second)
+	conn, err := dialHost(host)
 	if err != nil {
 		return modules.RenterContract{}, err
 	}
@@ -260,5 +249,10 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 		LastRevisionTxn: revisionTxn,
 		NetAddress:      host.NetAddress,
 		SecretKey:       ourSK,
+		TotalCost:       renterCost.Add(fee),
+		ContractFee:     host.ContractPrice,
+		SiafundFee:      types.Tax(startHeight, payout),
+		TxnFee:          fee,
+		StoragePrice:    host.StoragePrice,
 	}, nil
 }
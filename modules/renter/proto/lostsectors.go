@@ -0,0 +1,53 @@
+package proto
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// LostSectors asks the host of a contract which of the contract's sectors it
+// has lost, returning their Merkle roots. Only roots that belong to the
+// contract are returned.
+func LostSectors(host modules.HostDBEntry, contract modules.RenterContract) ([]crypto.Hash, error) {
+	conn, err := dialHost(host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// allot time for the RPC request and the revision exchange
+	extendDeadline(conn, modules.NegotiateRecentRevisionTime)
+	if err := encoding.WriteObject(conn, modules.RPCLostSectors); err != nil {
+		return nil, errors.New("couldn't initiate RPC: " + err.Error())
+	}
+	if err := verifyRecentRevision(conn, contract); err != nil {
+		return nil, err
+	}
+
+	// allot time for the host to check the sectors of the contract
+	extendDeadline(conn, modules.NegotiateLostSectorsTime)
+	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+		return nil, errors.New("host could not check sectors: " + err.Error())
+	}
+	var lost []crypto.Hash
+	maxLen := uint64(8 + crypto.HashSize*len(contract.MerkleRoots))
+	if err := encoding.ReadObject(conn, &lost, maxLen); err != nil {
+		return nil, errors.New("couldn't read lost sectors: " + err.Error())
+	}
+
+	// ignore any roots that do not belong to the contract
+	roots := make(map[crypto.Hash]struct{}, len(contract.MerkleRoots))
+	for _, root := range contract.MerkleRoots {
+		roots[root] = struct{}{}
+	}
+	var owned []crypto.Hash
+	for _, root := range lost {
+		if _, ok := roots[root]; ok {
+			owned = append(owned, root)
+		}
+	}
+	return owned, nil
+}
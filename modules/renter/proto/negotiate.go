@@ -86,7 +86,9 @@ func verifyRecentRevision(conn net.Conn, contract modules.RenterContract) error
 		return errors.New("couldn't send challenge response: " + err.Error())
 	}
 	// read acceptance
-	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+	if err := modules.ReadNegotiationAcceptance(conn); err == modules.ErrHostMaintenance {
+		return err
+	} else if err != nil {
 		return errors.New("host did not accept revision request: " + err.Error())
 	}
 	// read last revision and signatures
@@ -1,7 +1,10 @@
 package proto
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
@@ -39,6 +42,55 @@ type ContractParams struct {
 	// TODO: add optional keypair
 }
 
+// errNoHostAddress is returned when dialing a host that has no known address.
+var errNoHostAddress = errors.New("host has no known address")
+
+// dialHost connects to a host, trying its addresses in the order returned by
+// DialAddresses. If none of the addresses can be reached, the error of the
+// last address is returned.
+func dialHost(host modules.HostDBEntry) (net.Conn, error) {
+	err := errNoHostAddress
+	for _, addr := range host.DialAddresses() {
+		var conn net.Conn
+		conn, err = net.DialTimeout("tcp", string(addr), 15*time.Second)
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// contractPayout calculates the payout of a contract formed or renewed with
+// the supplied params, along with the collateral that the host is expected to
+// put into it. The renter pays for the siafund fee.
+func contractPayout(params ContractParams) (payout, hostCollateral types.Currency) {
+	host, duration := params.Host, uint64(params.EndHeight-params.StartHeight)
+	storageAllocation := host.StoragePrice.Mul64(params.Filesize).Mul64(duration)
+	hostCollateral = host.Collateral.Mul64(params.Filesize).Mul64(duration)
+	if hostCollateral.Cmp(host.MaxCollateral) > 0 {
+		// TODO: if we have to cap the collateral, it probably means we shouldn't be using this host
+		// (ok within a factor of 2)
+		hostCollateral = host.MaxCollateral
+	}
+	payout = storageAllocation.Add(hostCollateral).Add(host.ContractPrice).Mul64(10406).Div64(10000)
+	return payout, hostCollateral
+}
+
+// txnFee returns the fee that is added to contract transactions, based on
+// the fee estimation of the transaction pool.
+func txnFee(tpool transactionPool) types.Currency {
+	_, maxFee := tpool.FeeEstimation()
+	return maxFee.Mul64(estTxnSize)
+}
+
+// ContractCost returns the amount of money that FormContract or Renew will
+// take from the renter's wallet when called with the supplied params,
+// including fees. No network I/O is performed.
+func ContractCost(params ContractParams, tpool transactionPool) types.Currency {
+	payout, hostCollateral := contractPayout(params)
+	return payout.Sub(hostCollateral).Add(txnFee(tpool))
+}
+
 // A revisionSaver is called just before we send our revision signature to the host; this
 // allows the revision and Merkle roots to be reloaded later if we desync from the host.
 type revisionSaver func(types.FileContractRevision, []crypto.Hash) error
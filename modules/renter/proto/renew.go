@@ -2,8 +2,6 @@ package proto
 
 import (
 	"errors"
-	"net"
-	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
@@ -15,17 +13,11 @@ import (
 // submits the new contract transaction to tpool.
 func Renew(contract modules.RenterContract, params ContractParams, txnBuilder transactionBuilder, tpool transactionPool) (modules.RenterContract, error) {
 	// extract vars from params, for convenience
-	host, filesize, startHeight, endHeight, refundAddress := params.Host, params.Filesize, params.StartHeight, params.EndHeight, params.RefundAddress
+	host, endHeight, startHeight, refundAddress := params.Host, params.EndHeight, params.StartHeight, params.RefundAddress
 	ourSK := contract.SecretKey
 
 	// calculate cost to renter and cost to host
-	storageAllocation := host.StoragePrice.Mul64(filesize).Mul64(uint64(endHeight - startHeight))
-	hostCollateral := host.Collateral.Mul64(filesize).Mul64(uint64(endHeight - startHeight))
-	if hostCollateral.Cmp(host.MaxCollateral) > 0 {
-		// TODO: if we have to cap the collateral, it probably means we shouldn't be using this host
-		// (ok within a factor of 2)
-		hostCollateral = host.MaxCollateral
-	}
+	payout, hostCollateral := contractPayout(params)
 
 	// Calculate additional basePrice and baseCollateral. If the contract
 	// height did not increase, basePrice and baseCollateral are zero.
@@ -37,7 +29,6 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 	}
 
 	hostPayout := hostCollateral.Add(host.ContractPrice).Add(basePrice)
-	payout := storageAllocation.Add(hostCollateral.Add(host.ContractPrice)).Mul64(10406).Div64(10000) // renter covers siafund fee
 	renterCost := payout.Sub(hostCollateral)
 
 	// check for negative currency
@@ -73,8 +64,7 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 	}
 
 	// calculate transaction fee
-	_, maxFee := tpool.FeeEstimation()
-	fee := maxFee.Mul64(estTxnSize)
+	fee := txnFee(tpool)
 
 	// build transaction containing fc
 	err := txnBuilder.FundSiacoins(renterCost.Add(fee))
@@ -91,7 +81,7 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 	txnSet := append(parentTxns, txn)
 
 	// initiate connection
-	conn, err := net.DialTimeout("tcp", string(host.NetAddress), 15*time.Second)
+	conn, err := dialHost(host)
 	if err != nil {
 		return modules.RenterContract{}, err
 	}
@@ -255,5 +245,10 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 		MerkleRoots:     contract.MerkleRoots,
 		NetAddress:      host.NetAddress,
 		SecretKey:       ourSK,
+		TotalCost:       renterCost.Add(fee),
+		ContractFee:     host.ContractPrice,
+		SiafundFee:      types.Tax(startHeight, payout),
+		TxnFee:          fee,
+		StoragePrice:    host.StoragePrice,
 	}, nil
 }
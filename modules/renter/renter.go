@@ -2,7 +2,9 @@ package renter
 
 import (
 	"errors"
+	"time"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/modules/renter/hostdb"
@@ -31,9 +33,57 @@ type hostDB interface {
 	// AverageContractPrice returns the average contract price of a host.
 	AverageContractPrice() types.Currency
 
+	// BenchmarkSettings returns the settings that control the benchmarking
+	// of hosts.
+	BenchmarkSettings() modules.HostBenchmarkSettings
+
 	// Close closes the hostdb.
 	Close() error
 
+	// HostDetails returns the hostdb's record of the host with the given
+	// public key.
+	HostDetails(types.SiaPublicKey) (modules.HostDBEntryDetails, bool)
+
+	// PruneDowntime returns how long a host can be offline before it is
+	// removed from the hostdb.
+	PruneDowntime() time.Duration
+
+	// RecordThroughput records the throughput of a download from a host.
+	RecordThroughput(addr modules.NetAddress, n uint64, elapsed time.Duration)
+
+	// ScoreWeights returns the weights used to score hosts.
+	ScoreWeights() modules.HostScoreWeights
+
+	// SearchHosts returns a page of the hosts that match the filter, along
+	// with the total number of matching hosts.
+	SearchHosts(modules.HostDBFilter) ([]modules.HostDBSummary, int)
+
+	// SetBenchmarkSettings sets the settings that control the benchmarking
+	// of hosts.
+	SetBenchmarkSettings(modules.HostBenchmarkSettings) error
+
+	// SetContractedHosts sets the hosts that the renter has contracts with,
+	// which are never pruned from the hostdb.
+	SetContractedHosts([]types.SiaPublicKey)
+
+	// SetPruneDowntime sets how long a host can be offline before it is
+	// removed from the hostdb.
+	SetPruneDowntime(time.Duration) error
+
+	// SetScoreWeights sets the weights used to score hosts.
+	SetScoreWeights(modules.HostScoreWeights) error
+
+	// SetSubnetSizes sets the sizes of the subnets that hosts are grouped
+	// by.
+	SetSubnetSizes(modules.HostSubnetSizes) error
+
+	// SubnetConflicts returns the addresses whose hosts share a subnet with
+	// the host of another address in the list.
+	SubnetConflicts([]modules.NetAddress) []modules.NetAddress
+
+	// SubnetSizes returns the sizes of the subnets that hosts are grouped by.
+	SubnetSizes() modules.HostSubnetSizes
+
 	// IsOffline reports whether a host is consider offline.
 	IsOffline(modules.NetAddress) bool
 }
@@ -50,12 +100,27 @@ type hostContractor interface {
 	// Allowance returns the current allowance
 	Allowance() modules.Allowance
 
+	// SetSettings sets the renewal policy and the allowance, validating
+	// both before either is applied.
+	SetSettings(modules.Allowance, modules.RenewalPolicy) error
+
+	// RenewalPolicy returns the current renewal policy.
+	RenewalPolicy() modules.RenewalPolicy
+
+	// PlanAllowance returns the changes that SetSettings would make to the
+	// contract set, without forming any contracts.
+	PlanAllowance(modules.Allowance, modules.RenewalPolicy) (modules.AllowancePlan, error)
+
 	// Contract returns the latest contract formed with the specified host.
 	Contract(modules.NetAddress) (modules.RenterContract, bool)
 
 	// Contracts returns the contracts formed by the contractor.
 	Contracts() []modules.RenterContract
 
+	// ContractAttempts returns the results of the most recent attempts to
+	// form and to renew contracts.
+	ContractAttempts() (formation, renewal modules.ContractAttempt)
+
 	// Editor creates an Editor from the specified contract, allowing it to be
 	// modified.
 	Editor(modules.RenterContract) (contractor.Editor, error)
@@ -63,9 +128,26 @@ type hostContractor interface {
 	// FinancialMetrics returns the financial metrics of the contractor.
 	FinancialMetrics() modules.RenterFinancialMetrics
 
+	// PeriodSpending returns the spending report of the specified contract
+	// period.
+	PeriodSpending(uint64) (modules.RenterPeriodSpending, error)
+
+	// CurrentPeriodSpending returns the spending report of the current
+	// contract period.
+	CurrentPeriodSpending() modules.RenterPeriodSpending
+
 	// Downloader creates a Downloader from the specified contract, allowing
 	// the retrieval of sectors.
 	Downloader(modules.RenterContract) (contractor.Downloader, error)
+
+	// BenchmarkDownloader creates a Downloader for measuring the download
+	// throughput of a host. It fails instead of sharing the contract with an
+	// open Editor or Downloader.
+	BenchmarkDownloader(modules.RenterContract) (contractor.Downloader, error)
+
+	// LostSectors asks the host of a contract which of the contract's
+	// sectors it has lost.
+	LostSectors(modules.RenterContract) ([]crypto.Hash, error)
 }
 
 // A trackedFile contains metadata about files being tracked by the Renter.
@@ -142,6 +224,7 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 	}
 
 	go r.threadedRepairLoop()
+	go r.threadedBenchmarkLoop()
 
 	return r, nil
 }
@@ -154,19 +237,92 @@ func (r *Renter) Close() error {
 // hostdb passthroughs
 func (r *Renter) ActiveHosts() []modules.HostDBEntry { return r.hostDB.ActiveHosts() }
 func (r *Renter) AllHosts() []modules.HostDBEntry    { return r.hostDB.AllHosts() }
+func (r *Renter) HostDetails(pk types.SiaPublicKey) (modules.HostDBEntryDetails, bool) {
+	return r.hostDB.HostDetails(pk)
+}
+func (r *Renter) HostBenchmarkSettings() modules.HostBenchmarkSettings {
+	return r.hostDB.BenchmarkSettings()
+}
+func (r *Renter) SetHostBenchmarkSettings(s modules.HostBenchmarkSettings) error {
+	return r.hostDB.SetBenchmarkSettings(s)
+}
+func (r *Renter) HostPruneDowntime() time.Duration           { return r.hostDB.PruneDowntime() }
+func (r *Renter) SetHostPruneDowntime(d time.Duration) error { return r.hostDB.SetPruneDowntime(d) }
+func (r *Renter) HostScoreWeights() modules.HostScoreWeights { return r.hostDB.ScoreWeights() }
+func (r *Renter) SetHostScoreWeights(w modules.HostScoreWeights) error {
+	return r.hostDB.SetScoreWeights(w)
+}
+func (r *Renter) HostSubnetSizes() modules.HostSubnetSizes { return r.hostDB.SubnetSizes() }
+func (r *Renter) SearchHosts(f modules.HostDBFilter) ([]modules.HostDBSummary, int) {
+	return r.hostDB.SearchHosts(f)
+}
+func (r *Renter) SetHostSubnetSizes(s modules.HostSubnetSizes) error {
+	return r.hostDB.SetSubnetSizes(s)
+}
 
 // contractor passthroughs
 func (r *Renter) Contracts() []modules.RenterContract { return r.hostContractor.Contracts() }
+func (r *Renter) ContractAttempts() (formation, renewal modules.ContractAttempt) {
+	return r.hostContractor.ContractAttempts()
+}
 func (r *Renter) FinancialMetrics() modules.RenterFinancialMetrics {
 	return r.hostContractor.FinancialMetrics()
 }
+func (r *Renter) PeriodSpending(period uint64) (modules.RenterPeriodSpending, error) {
+	return r.hostContractor.PeriodSpending(period)
+}
+func (r *Renter) CurrentPeriodSpending() modules.RenterPeriodSpending {
+	return r.hostContractor.CurrentPeriodSpending()
+}
+func (r *Renter) PlanAllowance(s modules.RenterSettings) (modules.AllowancePlan, error) {
+	return r.hostContractor.PlanAllowance(s.Allowance, s.RenewalPolicy)
+}
 func (r *Renter) Settings() modules.RenterSettings {
 	return modules.RenterSettings{
-		Allowance: r.hostContractor.Allowance(),
+		Allowance:     r.hostContractor.Allowance(),
+		RenewalPolicy: r.hostContractor.RenewalPolicy(),
 	}
 }
 func (r *Renter) SetSettings(s modules.RenterSettings) error {
-	return r.hostContractor.SetAllowance(s.Allowance)
+	return r.hostContractor.SetSettings(s.Allowance, s.RenewalPolicy)
+}
+
+// hostAddress returns the current address of the host of a contract. The host
+// is looked up by its public key, as the address stored in the contract is
+// stale once the host announces a new one. The stored address is used if the
+// hostdb does not know the host.
+func (r *Renter) hostAddress(contract modules.RenterContract) modules.NetAddress {
+	pks := contract.LastRevision.UnlockConditions.PublicKeys
+	if len(pks) < 2 {
+		return contract.NetAddress
+	}
+	details, ok := r.hostDB.HostDetails(pks[1])
+	if !ok {
+		return contract.NetAddress
+	}
+	return details.Entry.NetAddress
+}
+
+// SubnetConflicts returns the IDs of contracts whose hosts share a subnet with
+// the host of another contract. Such contracts should be replaced, as the
+// failure of one subnet could cause the loss of several pieces of a chunk.
+func (r *Renter) SubnetConflicts() []types.FileContractID {
+	contracts := r.hostContractor.Contracts()
+	var addrs []modules.NetAddress
+	for _, c := range contracts {
+		addrs = append(addrs, r.hostAddress(c))
+	}
+	conflicts := make(map[modules.NetAddress]struct{})
+	for _, addr := range r.hostDB.SubnetConflicts(addrs) {
+		conflicts[addr] = struct{}{}
+	}
+	var ids []types.FileContractID
+	for i, c := range contracts {
+		if _, ok := conflicts[addrs[i]]; ok {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids
 }
 
 // enforce that Renter satisfies the modules.Renter interface
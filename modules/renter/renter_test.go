@@ -2,6 +2,7 @@ package renter
 
 import (
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -169,21 +170,56 @@ type stubHostDB struct{}
 func (stubHostDB) ActiveHosts() []modules.HostDBEntry   { return nil }
 func (stubHostDB) AllHosts() []modules.HostDBEntry      { return nil }
 func (stubHostDB) AverageContractPrice() types.Currency { return types.Currency{} }
-func (stubHostDB) Close() error                         { return nil }
+func (stubHostDB) BenchmarkSettings() modules.HostBenchmarkSettings {
+	return modules.HostBenchmarkSettings{}
+}
+func (stubHostDB) Close() error { return nil }
+func (stubHostDB) HostDetails(types.SiaPublicKey) (modules.HostDBEntryDetails, bool) {
+	return modules.HostDBEntryDetails{}, false
+}
+func (stubHostDB) PruneDowntime() time.Duration                               { return 0 }
+func (stubHostDB) RecordThroughput(modules.NetAddress, uint64, time.Duration) {}
+func (stubHostDB) ScoreWeights() modules.HostScoreWeights                     { return modules.HostScoreWeights{} }
+func (stubHostDB) SearchHosts(modules.HostDBFilter) ([]modules.HostDBSummary, int) {
+	return nil, 0
+}
+func (stubHostDB) SetBenchmarkSettings(modules.HostBenchmarkSettings) error { return nil }
+func (stubHostDB) SetContractedHosts([]types.SiaPublicKey)                  {}
+func (stubHostDB) SetPruneDowntime(time.Duration) error                     { return nil }
+func (stubHostDB) SetScoreWeights(modules.HostScoreWeights) error           { return nil }
+func (stubHostDB) SetSubnetSizes(modules.HostSubnetSizes) error             { return nil }
+func (stubHostDB) SubnetConflicts([]modules.NetAddress) []modules.NetAddress {
+	return nil
+}
+func (stubHostDB) SubnetSizes() modules.HostSubnetSizes { return modules.HostSubnetSizes{} }
 func (stubHostDB) IsOffline(modules.NetAddress) bool    { return true }
 
 // stubContractor is the minimal implementation of the hostContractor
 // interface.
 type stubContractor struct{}
 
-func (stubContractor) SetAllowance(modules.Allowance) error { return nil }
-func (stubContractor) Allowance() modules.Allowance         { return modules.Allowance{} }
+func (stubContractor) SetAllowance(modules.Allowance) error                       { return nil }
+func (stubContractor) Allowance() modules.Allowance                               { return modules.Allowance{} }
+func (stubContractor) SetSettings(modules.Allowance, modules.RenewalPolicy) error { return nil }
+func (stubContractor) RenewalPolicy() (p modules.RenewalPolicy)                   { return }
+func (stubContractor) PlanAllowance(modules.Allowance, modules.RenewalPolicy) (modules.AllowancePlan, error) {
+	return modules.AllowancePlan{}, nil
+}
 func (stubContractor) Contract(modules.NetAddress) (modules.RenterContract, bool) {
 	return modules.RenterContract{}, false
 }
-func (stubContractor) Contracts() []modules.RenterContract                      { return nil }
-func (stubContractor) FinancialMetrics() (m modules.RenterFinancialMetrics)     { return }
+func (stubContractor) Contracts() []modules.RenterContract                     { return nil }
+func (stubContractor) ContractAttempts() (f, r modules.ContractAttempt)        { return }
+func (stubContractor) FinancialMetrics() (m modules.RenterFinancialMetrics)    { return }
+func (stubContractor) CurrentPeriodSpending() (s modules.RenterPeriodSpending) { return }
+func (stubContractor) PeriodSpending(uint64) (modules.RenterPeriodSpending, error) {
+	return modules.RenterPeriodSpending{}, nil
+}
 func (stubContractor) Editor(modules.RenterContract) (contractor.Editor, error) { return nil, nil }
 func (stubContractor) Downloader(modules.RenterContract) (contractor.Downloader, error) {
 	return nil, nil
 }
+func (stubContractor) BenchmarkDownloader(modules.RenterContract) (contractor.Downloader, error) {
+	return nil, nil
+}
+func (stubContractor) LostSectors(modules.RenterContract) ([]crypto.Hash, error) { return nil, nil }
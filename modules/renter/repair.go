@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/types"
@@ -30,6 +31,19 @@ var renewThreshold = func() types.BlockHeight {
 	}
 }()
 
+// lostSectorsFrequency is how often the renter asks its hosts which sectors
+// they have lost.
+var lostSectorsFrequency = func() time.Duration {
+	switch build.Release {
+	case "testing":
+		return 5 * time.Second
+	case "dev":
+		return 10 * time.Minute
+	default:
+		return 6 * time.Hour
+	}
+}()
+
 // hostErr and hostErrs are helpers for reporting repair errors. The actual
 // Error implementations aren't that important; we just need to be able to
 // extract the NetAddress of the failed host.
@@ -170,6 +184,103 @@ func (f *file) chunkHosts(chunk uint64) []modules.NetAddress {
 	return old
 }
 
+// uncontractedChunks returns the pieces stored on hosts that the renter no
+// longer has a contract with, e.g. because the contractor chose not to renew
+// the contract. 'contracted' holds every address known for the hosts that the
+// renter has contracts with. Pieces that are also stored on a contracted host
+// are not returned.
+func (f *file) uncontractedChunks(contracted map[modules.NetAddress]struct{}) map[uint64][]uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	stored := f.contractedPieces(contracted)
+	uncontracted := make(map[uint64][]uint64)
+	for _, fc := range f.contracts {
+		if _, ok := contracted[fc.IP]; ok {
+			continue
+		}
+		for _, p := range fc.Pieces {
+			if _, ok := stored[[2]uint64{p.Chunk, p.Piece}]; !ok {
+				uncontracted[p.Chunk] = append(uncontracted[p.Chunk], p.Piece)
+			}
+		}
+	}
+	return uncontracted
+}
+
+// removeMigrated removes the pieces stored on hosts that the renter no longer
+// has a contract with, once the same piece has been uploaded to a host that
+// the renter has a contract with. The number of removed pieces is returned.
+func (f *file) removeMigrated(contracted map[modules.NetAddress]struct{}) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.contractedPieces(contracted)
+	var removed int
+	for id, fc := range f.contracts {
+		if _, ok := contracted[fc.IP]; ok {
+			continue
+		}
+		var kept []pieceData
+		for _, p := range fc.Pieces {
+			if _, ok := stored[[2]uint64{p.Chunk, p.Piece}]; ok {
+				removed++
+			} else {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(f.contracts, id)
+		} else {
+			fc.Pieces = kept
+			f.contracts[id] = fc
+		}
+	}
+	return removed
+}
+
+// contractedPieces returns the chunk and piece index of every piece stored on
+// a contracted host. f.mu must be held.
+func (f *file) contractedPieces(contracted map[modules.NetAddress]struct{}) map[[2]uint64]struct{} {
+	stored := make(map[[2]uint64]struct{})
+	for _, fc := range f.contracts {
+		if _, ok := contracted[fc.IP]; !ok {
+			continue
+		}
+		for _, p := range fc.Pieces {
+			stored[[2]uint64{p.Chunk, p.Piece}] = struct{}{}
+		}
+	}
+	return stored
+}
+
+// removeLost removes the pieces that their hosts have reported as lost. The
+// removed pieces will be uploaded again by the repair loop. The number of
+// removed pieces is returned.
+func (f *file) removeLost(lost map[modules.NetAddress]map[crypto.Hash]struct{}) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var removed int
+	for id, fc := range f.contracts {
+		lostRoots, ok := lost[fc.IP]
+		if !ok {
+			continue
+		}
+		var kept []pieceData
+		for _, p := range fc.Pieces {
+			if _, ok := lostRoots[p.MerkleRoot]; ok {
+				removed++
+			} else {
+				kept = append(kept, p)
+			}
+		}
+		fc.Pieces = kept
+		f.contracts[id] = fc
+	}
+	return removed
+}
+
 // expiringContracts returns the contracts that will expire soon.
 // TODO: what if contract has fully expired?
 func (f *file) expiringContracts(height types.BlockHeight) []fileContract {
@@ -186,16 +297,39 @@ func (f *file) expiringContracts(height types.BlockHeight) []fileContract {
 }
 
 // offlineChunks returns the chunks belonging to "offline" hosts -- hosts that
-// do not meet uptime requirements. Importantly, only chunks missing more than
-// half their redundancy are returned.
-func (f *file) offlineChunks(hdb hostDB) map[uint64][]uint64 {
+// do not meet uptime requirements. The hosts of the given contracts are looked
+// up by their public key, so that a host that announced a new address is still
+// recognized by the addresses recorded in the file. Importantly, only chunks
+// missing more than half their redundancy are returned.
+func (f *file) offlineChunks(hdb hostDB, contracts []modules.RenterContract) map[uint64][]uint64 {
+	online := make(map[modules.NetAddress]bool)
+	for _, c := range contracts {
+		pks := c.LastRevision.UnlockConditions.PublicKeys
+		if len(pks) < 2 {
+			continue
+		}
+		details, ok := hdb.HostDetails(pks[1])
+		online[c.NetAddress] = ok && details.Online
+		if !ok {
+			continue
+		}
+		online[details.Entry.NetAddress] = details.Online
+		for _, change := range details.AddressHistory {
+			online[change.NetAddress] = details.Online
+		}
+	}
+
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
 	// mark all pieces belonging to offline hosts.
 	offline := make(map[uint64][]uint64)
 	for _, fc := range f.contracts {
-		if hdb.IsOffline(fc.IP) {
+		isOffline := hdb.IsOffline(fc.IP)
+		if up, ok := online[fc.IP]; ok {
+			isOffline = !up
+		}
+		if isOffline {
 			for _, p := range fc.Pieces {
 				offline[p.Chunk] = append(offline[p.Chunk], p.Piece)
 			}
@@ -215,13 +349,25 @@ func (f *file) offlineChunks(hdb hostDB) map[uint64][]uint64 {
 // reuploading their missing pieces. Multiple repair attempts may be necessary
 // before the file reaches full redundancy.
 func (r *Renter) threadedRepairLoop() {
+	var lastLostCheck time.Time
 	for {
 		time.Sleep(5 * time.Second)
 
-		if len(r.hostContractor.Contracts()) == 0 {
+		contracts := r.hostContractor.Contracts()
+		if len(contracts) == 0 {
 			// nothing to revise
 			continue
 		}
+		var hostKeys []types.SiaPublicKey
+		for _, c := range contracts {
+			if pks := c.LastRevision.UnlockConditions.PublicKeys; len(pks) > 1 {
+				hostKeys = append(hostKeys, pks[1])
+			}
+		}
+		contracted := r.contractedAddresses(contracts)
+		// hosts that the renter has contracts with must not be pruned from
+		// the hostdb
+		r.hostDB.SetContractedHosts(hostKeys)
 
 		// make copy of repair set under lock
 		repairing := make(map[string]trackedFile)
@@ -231,17 +377,74 @@ func (r *Renter) threadedRepairLoop() {
 		}
 		r.mu.RUnlock(id)
 
+		// periodically ask the hosts which sectors they have lost, so that
+		// the pieces can be uploaded again before a download fails
+		var lost map[modules.NetAddress]map[crypto.Hash]struct{}
+		if len(repairing) > 0 && time.Since(lastLostCheck) >= lostSectorsFrequency {
+			lost = r.managedLostSectors(contracts)
+			lastLostCheck = time.Now()
+		}
+
 		// create host pool
 		pool := r.newHostPool()
 		for name, meta := range repairing {
-			r.threadedRepairFile(name, meta, pool)
+			r.threadedRepairFile(name, meta, pool, contracted, lost)
 		}
 		pool.Close() // heh
 	}
 }
 
-// threadedRepairFile repairs and saves an individual file.
-func (r *Renter) threadedRepairFile(name string, meta trackedFile, pool *hostPool) {
+// contractedAddresses returns every address known for the hosts that the
+// renter has contracts with. Files record the address of the host that stores
+// a piece, so the hosts are matched by their public key, and a host that
+// announced a new address is still recognized by its previous addresses.
+func (r *Renter) contractedAddresses(contracts []modules.RenterContract) map[modules.NetAddress]struct{} {
+	contracted := make(map[modules.NetAddress]struct{})
+	for _, c := range contracts {
+		contracted[c.NetAddress] = struct{}{}
+		pks := c.LastRevision.UnlockConditions.PublicKeys
+		if len(pks) < 2 {
+			continue
+		}
+		details, ok := r.hostDB.HostDetails(pks[1])
+		if !ok {
+			continue
+		}
+		contracted[details.Entry.NetAddress] = struct{}{}
+		for _, change := range details.AddressHistory {
+			contracted[change.NetAddress] = struct{}{}
+		}
+	}
+	return contracted
+}
+
+// managedLostSectors asks the host of each contract which sectors it has
+// lost, returning the lost sectors of each host.
+func (r *Renter) managedLostSectors(contracts []modules.RenterContract) map[modules.NetAddress]map[crypto.Hash]struct{} {
+	lost := make(map[modules.NetAddress]map[crypto.Hash]struct{})
+	for _, c := range contracts {
+		roots, err := r.hostContractor.LostSectors(c)
+		if err != nil {
+			r.log.Debugf("could not ask %v for lost sectors: %v", c.NetAddress, err)
+			continue
+		}
+		if len(roots) == 0 {
+			continue
+		}
+		r.log.Printf("host %v reported %v lost sectors", c.NetAddress, len(roots))
+		lostRoots := make(map[crypto.Hash]struct{})
+		for _, root := range roots {
+			lostRoots[root] = struct{}{}
+		}
+		lost[c.NetAddress] = lostRoots
+	}
+	return lost
+}
+
+// threadedRepairFile repairs and saves an individual file. Pieces stored on
+// hosts that are not in the contracted set are migrated to other hosts, and
+// pieces that their hosts have lost are uploaded again.
+func (r *Renter) threadedRepairFile(name string, meta trackedFile, pool *hostPool, contracted map[modules.NetAddress]struct{}, lost map[modules.NetAddress]map[crypto.Hash]struct{}) {
 	// helper function
 	logAndRemove := func(fmt string, args ...interface{}) {
 		r.log.Printf(fmt, args...)
@@ -259,8 +462,26 @@ func (r *Renter) threadedRepairFile(name string, meta trackedFile, pool *hostPoo
 		return
 	}
 
-	// determine if there is any work to do
+	// pieces on hosts that were replaced whose replacement was uploaded in
+	// an earlier cycle are no longer needed
+	r.managedRemoveMigrated(f, contracted)
+	if n := f.removeLost(lost); n > 0 {
+		r.log.Printf("re-uploading %v pieces of %v that their hosts have lost", n, f.name)
+		f.mu.RLock()
+		err := r.saveFile(f)
+		f.mu.RUnlock()
+		if err != nil {
+			r.log.Printf("failed to save %v after removing lost pieces: %v", f.name, err)
+		}
+	}
+
+	// determine if there is any work to do. Pieces on hosts that were
+	// replaced are uploaded to other hosts, but they are only removed from
+	// the file after the upload succeeded.
 	incChunks := f.incompleteChunks()
+	for chunk, pieces := range f.uncontractedChunks(contracted) {
+		incChunks[chunk] = append(incChunks[chunk], pieces...)
+	}
 	if len(incChunks) == 0 {
 		return
 	}
@@ -277,6 +498,23 @@ func (r *Renter) threadedRepairFile(name string, meta trackedFile, pool *hostPoo
 	if len(incChunks) != 0 {
 		r.log.Printf("repairing %v chunks of %v", len(incChunks), f.name)
 		r.repairChunks(f, handle, incChunks, pool)
+		r.managedRemoveMigrated(f, contracted)
+	}
+}
+
+// managedRemoveMigrated removes the pieces of f that were migrated away from
+// hosts that are no longer under contract, and saves f.
+func (r *Renter) managedRemoveMigrated(f *file, contracted map[modules.NetAddress]struct{}) {
+	n := f.removeMigrated(contracted)
+	if n == 0 {
+		return
+	}
+	r.log.Printf("migrated %v pieces of %v from hosts that are no longer under contract", n, f.name)
+	f.mu.RLock()
+	err := r.saveFile(f)
+	f.mu.RUnlock()
+	if err != nil {
+		r.log.Printf("failed to save %v after removing migrated pieces: %v", f.name, err)
 	}
 }
 
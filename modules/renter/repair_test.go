@@ -156,10 +156,12 @@ func TestRepair(t *testing.T) {
 
 // offlineHostDB is a mocked hostDB, used for testing the offlineChunks method
 // of the file type. It is implemented as a map from NetAddresses to booleans,
-// where the bool indicates whether the host is active.
+// where the bool indicates whether the host is active, and a map from public
+// keys to the details of the hosts that are looked up by key.
 type offlineHostDB struct {
 	stubHostDB
-	hosts map[modules.NetAddress]bool
+	hosts   map[modules.NetAddress]bool
+	details map[string]modules.HostDBEntryDetails
 }
 
 // IsOffline is a stub implementation of the IsOffline method.
@@ -167,6 +169,12 @@ func (hdb *offlineHostDB) IsOffline(addr modules.NetAddress) bool {
 	return !hdb.hosts[addr]
 }
 
+// HostDetails is a stub implementation of the HostDetails method.
+func (hdb *offlineHostDB) HostDetails(pk types.SiaPublicKey) (modules.HostDBEntryDetails, bool) {
+	details, ok := hdb.details[pk.String()]
+	return details, ok
+}
+
 // TestOfflineChunks tests the offlineChunks method of the file type.
 func TestOfflineChunks(t *testing.T) {
 	// Create a mock hostdb.
@@ -192,7 +200,7 @@ func TestOfflineChunks(t *testing.T) {
 	expChunks := map[uint64][]uint64{
 		0: {0, 1},
 	}
-	chunks := f.offlineChunks(hdb)
+	chunks := f.offlineChunks(hdb, nil)
 	if !reflect.DeepEqual(chunks, expChunks) {
 		// pieces may have been in a different order
 		if !reflect.DeepEqual(chunks, map[uint64][]uint64{0: {1, 0}}) {
@@ -200,3 +208,191 @@ func TestOfflineChunks(t *testing.T) {
 		}
 	}
 }
+
+// TestOfflineChunksMovedHost checks that offlineChunks looks up the hosts of
+// contracts by their public key, so that a host that moved away from the
+// address recorded in the file is reported offline when it is offline.
+func TestOfflineChunksMovedHost(t *testing.T) {
+	// The hostdb no longer knows "foo", so it does not report it offline.
+	// The host that used to be at "foo" is now at "bar", and is offline.
+	pk := types.SiaPublicKey{Key: []byte{1}}
+	var details modules.HostDBEntryDetails
+	details.Entry.NetAddress = "bar"
+	details.AddressHistory = []modules.HostAddressChange{{NetAddress: "foo"}, {NetAddress: "bar"}}
+	hdb := &offlineHostDB{
+		hosts: map[modules.NetAddress]bool{
+			"foo": true,
+			"baz": true,
+		},
+		details: map[string]modules.HostDBEntryDetails{
+			pk.String(): details,
+		},
+	}
+	rsc, _ := NewRSCode(1, 1)
+	f := &file{
+		erasureCode: rsc,
+		contracts: map[types.FileContractID]fileContract{
+			{0}: {IP: "foo", Pieces: []pieceData{{0, 0, crypto.Hash{}}, {0, 1, crypto.Hash{}}}},
+			{1}: {IP: "baz", Pieces: []pieceData{{1, 0, crypto.Hash{}}, {1, 1, crypto.Hash{}}}},
+		},
+	}
+	var c modules.RenterContract
+	c.NetAddress = "bar"
+	c.LastRevision.UnlockConditions.PublicKeys = []types.SiaPublicKey{{}, pk}
+	contracts := []modules.RenterContract{c}
+
+	if chunks := f.offlineChunks(hdb, nil); len(chunks) != 0 {
+		t.Fatal("hosts were reported offline without looking them up by key:", chunks)
+	}
+	chunks := f.offlineChunks(hdb, contracts)
+	if len(chunks) != 1 || len(chunks[0]) != 2 {
+		t.Fatal("moved host was not reported offline:", chunks)
+	}
+
+	// Once the host is back online, no chunks are offline.
+	details.Online = true
+	hdb.details[pk.String()] = details
+	if chunks := f.offlineChunks(hdb, contracts); len(chunks) != 0 {
+		t.Fatal("online host was reported offline:", chunks)
+	}
+}
+
+// TestMigrateUncontracted tests the uncontractedChunks and removeMigrated
+// methods of the file type.
+func TestMigrateUncontracted(t *testing.T) {
+	rsc, _ := NewRSCode(1, 1)
+	f := &file{
+		size:        20,
+		pieceSize:   10,
+		erasureCode: rsc,
+		contracts: map[types.FileContractID]fileContract{
+			{0}: {IP: "foo", Pieces: []pieceData{{0, 0, crypto.Hash{}}, {1, 0, crypto.Hash{}}}},
+			{1}: {IP: "bar", Pieces: []pieceData{{0, 1, crypto.Hash{}}, {1, 1, crypto.Hash{}}}},
+		},
+	}
+
+	// the contract with foo was not renewed; its pieces should be migrated,
+	// but they must not be removed before they have been uploaded elsewhere
+	contracted := map[modules.NetAddress]struct{}{"bar": {}}
+	expChunks := map[uint64][]uint64{0: {0}, 1: {0}}
+	if chunks := f.uncontractedChunks(contracted); !reflect.DeepEqual(chunks, expChunks) {
+		t.Fatalf("expected uncontracted chunks %v, got %v", expChunks, chunks)
+	}
+	if n := f.removeMigrated(contracted); n != 0 {
+		t.Fatal("expected no pieces to be removed, got", n)
+	}
+	if len(f.contracts[types.FileContractID{0}].Pieces) != 2 {
+		t.Fatal("pieces on foo should be kept until they are migrated")
+	}
+
+	// migrate the first chunk to baz
+	f.contracts[types.FileContractID{2}] = fileContract{IP: "baz", Pieces: []pieceData{{0, 0, crypto.Hash{}}}}
+	contracted["baz"] = struct{}{}
+	expChunks = map[uint64][]uint64{1: {0}}
+	if chunks := f.uncontractedChunks(contracted); !reflect.DeepEqual(chunks, expChunks) {
+		t.Fatalf("expected uncontracted chunks %v, got %v", expChunks, chunks)
+	}
+	if n := f.removeMigrated(contracted); n != 1 {
+		t.Fatal("expected 1 piece to be removed, got", n)
+	}
+	if pieces := f.contracts[types.FileContractID{0}].Pieces; len(pieces) != 1 || pieces[0].Chunk != 1 {
+		t.Fatal("only the migrated piece should have been removed from foo:", pieces)
+	}
+
+	// migrate the second chunk; the contract with foo should be removed
+	fc := f.contracts[types.FileContractID{2}]
+	fc.Pieces = append(fc.Pieces, pieceData{1, 0, crypto.Hash{}})
+	f.contracts[types.FileContractID{2}] = fc
+	if n := f.removeMigrated(contracted); n != 1 {
+		t.Fatal("expected 1 piece to be removed, got", n)
+	}
+	if _, ok := f.contracts[types.FileContractID{0}]; ok {
+		t.Fatal("contract with foo should have been removed")
+	}
+	if chunks := f.incompleteChunks(); len(chunks) != 0 {
+		t.Fatal("file should be complete, missing", chunks)
+	}
+}
+
+// movedHostDB is a hostDB that knows a single host that has moved from
+// "foo" to "bar".
+type movedHostDB struct {
+	stubHostDB
+}
+
+func (movedHostDB) HostDetails(types.SiaPublicKey) (modules.HostDBEntryDetails, bool) {
+	var details modules.HostDBEntryDetails
+	details.Entry.NetAddress = "bar"
+	details.AddressHistory = []modules.HostAddressChange{{NetAddress: "foo"}, {NetAddress: "bar"}}
+	return details, true
+}
+
+// TestContractedAddresses checks that a host that moved is still recognized
+// by its previous addresses.
+func TestContractedAddresses(t *testing.T) {
+	r := &Renter{hostDB: movedHostDB{}}
+	var c modules.RenterContract
+	c.NetAddress = "bar"
+	c.LastRevision.UnlockConditions.PublicKeys = make([]types.SiaPublicKey, 2)
+	contracted := r.contractedAddresses([]modules.RenterContract{c})
+	for _, addr := range []modules.NetAddress{"foo", "bar"} {
+		if _, ok := contracted[addr]; !ok {
+			t.Error("address of contracted host missing:", addr)
+		}
+	}
+	if len(contracted) != 2 {
+		t.Error("expected 2 addresses, got", contracted)
+	}
+}
+
+// TestHostAddress checks that the address of the host of a contract is looked
+// up by the public key of the host, rather than taken from the contract.
+func TestHostAddress(t *testing.T) {
+	r := &Renter{hostDB: movedHostDB{}}
+	var c modules.RenterContract
+	c.NetAddress = "foo"
+	if addr := r.hostAddress(c); addr != "foo" {
+		t.Error("contract without a host key should keep its address, got", addr)
+	}
+	c.LastRevision.UnlockConditions.PublicKeys = make([]types.SiaPublicKey, 2)
+	if addr := r.hostAddress(c); addr != "bar" {
+		t.Error("expected the current address of the host, got", addr)
+	}
+	r.hostDB = stubHostDB{}
+	if addr := r.hostAddress(c); addr != "foo" {
+		t.Error("unknown host should keep the address of the contract, got", addr)
+	}
+}
+
+// TestRemoveLost tests the removeLost method of the file type.
+func TestRemoveLost(t *testing.T) {
+	rsc, _ := NewRSCode(1, 1)
+	f := &file{
+		size:        20,
+		pieceSize:   10,
+		erasureCode: rsc,
+		contracts: map[types.FileContractID]fileContract{
+			{0}: {IP: "foo", Pieces: []pieceData{{0, 0, crypto.Hash{1}}, {1, 0, crypto.Hash{2}}}},
+			{1}: {IP: "bar", Pieces: []pieceData{{0, 1, crypto.Hash{1}}, {1, 1, crypto.Hash{3}}}},
+		},
+	}
+
+	// foo lost its first piece; bar reports a root that foo lost, but bar
+	// still has its own copy
+	lost := map[modules.NetAddress]map[crypto.Hash]struct{}{
+		"foo": {crypto.Hash{1}: {}},
+		"bar": {crypto.Hash{4}: {}},
+	}
+	if n := f.removeLost(lost); n != 1 {
+		t.Fatal("expected 1 piece to be removed, got", n)
+	}
+	expChunks := map[uint64][]uint64{0: {0}}
+	if chunks := f.incompleteChunks(); !reflect.DeepEqual(chunks, expChunks) {
+		t.Fatalf("expected incomplete chunks %v, got %v", expChunks, chunks)
+	}
+
+	// nothing else should be removed
+	if n := f.removeLost(lost); n != 0 {
+		t.Fatal("expected no pieces to be removed, got", n)
+	}
+}
@@ -59,6 +59,13 @@ func (uc *uploadDownloadContractor) Sector(root crypto.Hash) ([]byte, error) {
 	return uc.sectors[root], nil
 }
 
+// PartialSector simulates a successful partial download.
+func (uc *uploadDownloadContractor) PartialSector(root crypto.Hash, offset, length uint64) ([]byte, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.sectors[root][offset : offset+length], nil
+}
+
 // stub implementations of the contractor.Editor methods
 func (*uploadDownloadContractor) Address() modules.NetAddress                           { return "" }
 func (*uploadDownloadContractor) Delete(crypto.Hash) error                              { return nil }
@@ -78,7 +85,7 @@ func TestUploadDownload(t *testing.T) {
 	hc := &uploadDownloadContractor{
 		sectors: make(map[crypto.Hash][]byte),
 	}
-	rt, err := newContractorTester("TestUploadDownload", nil, hc)
+	rt, err := newContractorTester("TestUploadDownload", stubHostDB{}, hc)
 	if err != nil {
 		t.Fatal(err)
 	}
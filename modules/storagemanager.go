@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -31,6 +33,95 @@ type (
 		SuccessfulWrites uint64 `json:"successfulwrites"`
 	}
 
+	// StorageCheckReport describes the inconsistencies found by a sector
+	// consistency check, which compares the sector roots referenced by the
+	// storage obligations of the host, the sector database of the storage
+	// manager, and the files in the storage folders.
+	StorageCheckReport struct {
+		// SectorsChecked is the number of sectors in the sector database.
+		SectorsChecked uint64 `json:"sectorschecked"`
+
+		// MissingSectors are referenced by a storage obligation, but are
+		// either absent from the sector database or have no file on disk. The
+		// data of these sectors has been lost.
+		MissingSectors []crypto.Hash `json:"missingsectors"`
+
+		// CorruptedSectors are referenced by a storage obligation, but have
+		// been marked as corrupted or are stored in a file of the wrong size.
+		CorruptedSectors []crypto.Hash `json:"corruptedsectors"`
+
+		// MissingFiles is the number of sectors in the sector database that
+		// have no file on disk, whether or not they are referenced.
+		MissingFiles uint64 `json:"missingfiles"`
+
+		// UnreferencedSectors is the number of sectors in the sector database
+		// that are not referenced by any storage obligation.
+		UnreferencedSectors uint64 `json:"unreferencedsectors"`
+
+		// OrphanedFiles are sector files in the storage folders that are not
+		// in the sector database.
+		OrphanedFiles []string `json:"orphanedfiles"`
+
+		// MiscountedFolders are the paths of the storage folders whose
+		// remaining capacity does not match the files stored in them.
+		MiscountedFolders []string `json:"miscountedfolders"`
+
+		// PurgedFiles is the number of orphaned files that were deleted. Files
+		// are only deleted when the check is run in purge mode, which also
+		// corrects the remaining capacity of the miscounted folders.
+		PurgedFiles uint64 `json:"purgedfiles"`
+	}
+
+	// StorageScrubStatus reports the settings and progress of the sector
+	// scrubber, which reads every sector in the background and verifies it
+	// against its Merkle root.
+	StorageScrubStatus struct {
+		// Period is how long a full pass over every sector should take. A
+		// period of 0 disables the scrubber.
+		Period time.Duration `json:"period"`
+
+		// RateLimit is the maximum number of bytes per second that the
+		// scrubber reads from disk. A rate limit of 0 is unlimited.
+		RateLimit uint64 `json:"ratelimit"`
+
+		// Progress of the current pass.
+		PassStarted      time.Time `json:"passstarted"`
+		SectorsScrubbed  uint64    `json:"sectorsscrubbed"`
+		SectorsTotal     uint64    `json:"sectorstotal"`
+		CorruptedSectors uint64    `json:"corruptedsectors"`
+
+		// Results of the most recently completed pass.
+		LastPassCompleted time.Time `json:"lastpasscompleted"`
+		LastPassCorrupted uint64    `json:"lastpasscorrupted"`
+	}
+
+	// StorageFolderMigrationStatus reports the progress of moving the
+	// sectors of a storage folder to a new path. Only one storage folder can
+	// be migrated at a time.
+	StorageFolderMigrationStatus struct {
+		// Active indicates that a migration is in progress.
+		Active bool `json:"active"`
+
+		// The path of the storage folder being migrated, and the path that
+		// the sectors are being moved to.
+		SourcePath      string `json:"sourcepath"`
+		DestinationPath string `json:"destinationpath"`
+
+		// RateLimit is the maximum number of bytes per second that are moved.
+		// A rate limit of 0 is unlimited.
+		RateLimit uint64 `json:"ratelimit"`
+
+		// Progress of the migration. Sectors that could not be moved are
+		// left in the source folder, which is only removed once it is empty.
+		Started       time.Time `json:"started"`
+		SectorsMoved  uint64    `json:"sectorsmoved"`
+		SectorsFailed uint64    `json:"sectorsfailed"`
+		SectorsTotal  uint64    `json:"sectorstotal"`
+
+		// Completed is the time at which the most recent migration finished.
+		Completed time.Time `json:"completed"`
+	}
+
 	// A StorageManager is responsible for managing storage folders and
 	// sectors. Sectors are the base unit of storage that gets moved between
 	// renters and hosts, and primarily is stored on the hosts.
@@ -51,6 +142,13 @@ type (
 		// gracefully handle running out of storage unexpectedly.
 		AddStorageFolder(path string, size uint64) error
 
+		// CheckSectors compares the sector database with the files in the
+		// storage folders and with the provided sector roots, which should be
+		// every sector root referenced by a storage obligation. If purge is
+		// set, orphaned files are deleted and the remaining capacity of each
+		// storage folder is recalculated.
+		CheckSectors(roots []crypto.Hash, purge bool) (StorageCheckReport, error)
+
 		// The storage manager needs to be able to shut down.
 		Close() error
 
@@ -62,6 +160,22 @@ type (
 		// requests to remove data.
 		DeleteSector(sectorRoot crypto.Hash) error
 
+		// LostSectors returns the subset of the given sector roots that the
+		// storage manager can no longer serve, because the sector is missing
+		// or corrupted.
+		LostSectors(roots []crypto.Hash) ([]crypto.Hash, error)
+
+		// MigrateStorageFolder moves all of the sectors of a storage folder to
+		// a new storage folder at 'path', which replaces the old storage
+		// folder once it is empty. The sectors are moved in the background at
+		// no more than 'rateLimit' bytes per second, and the migration resumes
+		// if the manager is restarted.
+		MigrateStorageFolder(index int, path string, rateLimit uint64) error
+
+		// MigrationStatus returns the progress of the storage folder
+		// migration.
+		MigrationStatus() StorageFolderMigrationStatus
+
 		// ReadSector will read a sector from the storage manager, returning the
 		// bytes that match the input sector root.
 		ReadSector(sectorRoot crypto.Hash) ([]byte, error)
@@ -91,6 +205,14 @@ type (
 		// and the operation will be stopped.
 		ResizeStorageFolder(index int, newSize uint64) error
 
+		// ScrubStatus returns the settings and progress of the sector
+		// scrubber.
+		ScrubStatus() StorageScrubStatus
+
+		// SetScrubSettings sets how long a full pass of the sector scrubber
+		// should take, and how many bytes per second it may read.
+		SetScrubSettings(period time.Duration, rateLimit uint64) error
+
 		// StorageFolders will return a list of storage folders tracked by the
 		// manager.
 		StorageFolders() []StorageFolderMetadata
@@ -2,9 +2,14 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"math/big"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/modules"
@@ -32,8 +37,14 @@ Available settings:
      maxdownloadbatchsize: bytes
      maxrevisebatchsize:   bytes
      netaddress:           string
+     additionaladdresses:  comma separated list of addresses
      windowsize:           blocks
 
+     maxconnections:      connections
+     maxconnectionsperip: connections
+     maxdownloadspeed:    bytes / second
+     maxuploadspeed:      bytes / second
+
      collateral:       currency
      collateralbudget: currency
      maxcollateral:    currency
@@ -47,6 +58,15 @@ Currency units can be specified, e.g. 10SC; run 'siac help wallet' for details.
 
 Blocks are approximately 10 minutes each.
 
+Connection and speed limits of 0 are unlimited.
+
+The netaddress and additionaladdresses are announced together, and renters try
+them in order. Announcing a DNS name as netaddress, followed by the IPv4 and
+IPv6 addresses of the host, keeps the host reachable without a new
+announcement each time its IP changes. An empty list of additional addresses
+removes them:
+	siac host config additionaladdresses ""
+
 For a description of each parameter, see doc/API.md.
 
 To configure the host to accept new contracts, set acceptingcontracts to true:
@@ -68,10 +88,214 @@ Doing so will override the standard connectivity checks.`,
 		Run: hostannouncecmd,
 	}
 
+	hostCheckCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Check the host's stored sectors for consistency",
+		Long: `Compare the sectors in the storage manager with the files in the storage
+folders and with the sectors referenced by the host's contracts. Reports
+sectors that have been lost or corrupted, and sector files that are not
+tracked by the storage manager. Use --purge to delete the untracked files and
+correct the remaining capacity of the storage folders.`,
+		Run: wrap(hostcheckcmd),
+	}
+
+	hostContractsCmd = &cobra.Command{
+		Use:   "contracts",
+		Short: "List the host's contracts",
+		Long: `List the storage obligations of the host, ordered by expiration. Contracts
+can be filtered by status, by how soon they expire, and by whether their storage
+proof has been confirmed. For example, the contracts that still need a proof
+this week are listed by:
+
+  siac host contracts --status unresolved --no-proof --expires-within 1008`,
+		Run: wrap(hostcontractscmd),
+	}
+
+	hostLedgerCmd = &cobra.Command{
+		Use:   "ledger",
+		Short: "View or export the host's contract ledger",
+		Long: `View the outcome of every contract the host has resolved: its revenue by
+category, the collateral it locked and lost, and the fees it paid. The ledger
+can be limited to a range of days and exported as CSV or JSON, for example:
+
+  siac host ledger --start 2017-01-01 --end 2017-03-31 --format csv > q1.csv`,
+		Run: wrap(hostledgercmd),
+	}
+
+	hostProofsCmd = &cobra.Command{
+		Use:   "proofs",
+		Short: "View the host's storage proofs",
+		Long: `List the storage proofs of the host's unresolved contracts, ordered by the end
+of their proof window. Each proof is pending, built, broadcast, confirmed, or
+failed. Proofs that are not confirmed close to the end of their window are
+marked with a warning, because a missed proof costs the host its collateral.`,
+		Run: wrap(hostproofscmd),
+	}
+
+	hostRentersCmd = &cobra.Command{
+		Use:   "renters",
+		Short: "View the host's renter policies",
+		Long: `List the renters that the host limits, identified by their public key. A
+renter can be blocked, or limited in the total amount of data it stores on the
+host, its number of contracts, and its number of contract revisions per minute.
+Renters that are not listed are not limited.`,
+		Run: wrap(hostrenterscmd),
+	}
+
+	hostRentersBlockCmd = &cobra.Command{
+		Use:   "block [key]",
+		Short: "Block a renter",
+		Long: `Block the renter with the given public key, e.g. "ed25519:8a1c...". The host
+refuses to form, renew, or revise contracts with a blocked renter.`,
+		Run: wrap(hostrentersblockcmd),
+	}
+
+	hostRentersUnblockCmd = &cobra.Command{
+		Use:   "unblock [key]",
+		Short: "Unblock a renter",
+		Long:  "Unblock the renter with the given public key. Any limits of the renter are kept.",
+		Run:   wrap(hostrentersunblockcmd),
+	}
+
+	hostRentersLimitCmd = &cobra.Command{
+		Use:   "limit [key]",
+		Short: "Limit a renter",
+		Long: `Limit the resources that the renter with the given public key can use on the
+host. Only the limits that are provided are changed, and a limit of 0 removes
+the limit. For example:
+
+  siac host renters limit ed25519:8a1c... --storage 100GB --contracts 10 --revisions 60`,
+		Run: wrap(hostrenterslimitcmd),
+	}
+
+	hostRentersRemoveCmd = &cobra.Command{
+		Use:   "remove [key]",
+		Short: "Remove the policy of a renter",
+		Long:  "Remove the policy of the renter with the given public key, so that it is no longer blocked or limited.",
+		Run:   wrap(hostrentersremovecmd),
+	}
+
+	hostContractsViewCmd = &cobra.Command{
+		Use:   "view [id]",
+		Short: "View the details of a contract",
+		Long:  "View the details of a storage obligation, including the heights at which the host will next act on it.",
+		Run:   wrap(hostcontractsviewcmd),
+	}
+
+	hostMaintenanceCmd = &cobra.Command{
+		Use:   "maintenance",
+		Short: "View the host's maintenance status",
+		Long:  "View whether the host is in maintenance mode, and whether it is safe to stop.",
+		Run:   wrap(hostmaintenancecmd),
+	}
+
+	hostMaintenanceEnableCmd = &cobra.Command{
+		Use:   "enable",
+		Short: "Put the host in maintenance mode",
+		Long: `Put the host in maintenance mode, to prepare for stopping it. The host stops
+accepting contracts, refuses renewals and new uploads with an error that tells
+renters to try again later, and ends upload sessions after their current
+revision. Downloads and storage proofs continue.
+
+Run 'siac host maintenance' to check when the host is safe to stop.`,
+		Run: wrap(hostmaintenanceenablecmd),
+	}
+
+	hostMaintenanceDisableCmd = &cobra.Command{
+		Use:   "disable",
+		Short: "Take the host out of maintenance mode",
+		Long:  "Take the host out of maintenance mode, so that it accepts contracts and uploads again.",
+		Run:   wrap(hostmaintenancedisablecmd),
+	}
+
+	hostPolicyCmd = &cobra.Command{
+		Use:   "policy",
+		Short: "View the host's contract policy",
+		Long: `View the rules that the host applies to the contracts proposed by renters, on
+top of the checks derived from its settings. Contracts that break a rule are
+rejected, and the renter is told why.`,
+		Run: wrap(hostpolicycmd),
+	}
+
+	hostPolicySetCmd = &cobra.Command{
+		Use:   "set [setting] [value]",
+		Short: "Change a rule of the host's contract policy",
+		Long: `Change a rule of the host's contract policy. A value of 0 disables a rule.
+
+Available settings:
+     minduration:           blocks
+     maxduration:           blocks
+     minpayout:             currency
+     requireallowlist:      boolean
+     allowedrenters:        comma separated list of renter keys
+     maxcollateralpercent:  percentage of the collateral budget
+     refusefailedrenewals:  boolean
+
+The duration of a contract is counted from its proposal to the start of its
+proof window. The payout is the total payout of the contract.
+
+If requireallowlist is set, only the renters in allowedrenters can form and
+renew contracts. Renters are identified by the public key in the unlock
+conditions of their contracts, as listed by 'siac host renters'. Setting
+allowedrenters replaces the whole list, and "" clears it.
+
+If refusefailedrenewals is set, the host will not renew the contracts of a
+renter once it has failed to get a storage proof confirmed for an earlier
+contract of that renter.`,
+		Run: wrap(hostpolicysetcmd),
+	}
+
+	hostPricingCmd = &cobra.Command{
+		Use:   "pricing",
+		Short: "View the host's pricing policy",
+		Long:  "View the policy the host uses to set its prices, and the prices it currently advertises.",
+		Run:   wrap(hostpricingcmd),
+	}
+
+	hostPricingSetCmd = &cobra.Command{
+		Use:   "set [fixed|utilisation|network]",
+		Short: "Set the host's pricing policy",
+		Long: `Set the policy the host uses to derive its prices from the minimum prices
+in its settings. The prices are re-evaluated periodically, and the host
+announces itself again when they change.
+
+"fixed" charges exactly the minimum prices.
+
+"utilisation" raises the prices as the host fills up, from the minimum prices
+when the host is empty to --max-multiplier times the minimum prices when it is
+full.
+
+"network" charges the price at --percentile of the prices of the other hosts on
+the network, never going below the minimum prices. A --max-multiplier caps the
+prices. This mode needs the renter module to learn the network prices.`,
+		Run: wrap(hostpricingsetcmd),
+	}
+
+	hostScrubCmd = &cobra.Command{
+		Use:   "scrub",
+		Short: "View the progress of the sector scrubber",
+		Long: `View the settings and progress of the sector scrubber, which reads every sector
+stored by the host in the background and checks it against its Merkle root.
+Sectors that fail the check are marked as corrupted.`,
+		Run: wrap(hostscrubcmd),
+	}
+
+	hostScrubSetCmd = &cobra.Command{
+		Use:   "set [period] [ratelimit]",
+		Short: "Configure the sector scrubber",
+		Long: `Set how long a full pass of the sector scrubber should take, and how much
+data per second the scrubber may read from disk. A period of 0 disables the
+scrubber, and a rate limit of 0 is unlimited. For example, to read every
+sector once a month at no more than 8 MB per second:
+
+  siac host scrub set 720h 8MB`,
+		Run: wrap(hostscrubsetcmd),
+	}
+
 	hostFolderCmd = &cobra.Command{
 		Use:   "folder",
-		Short: "Add, remove, or resize a storage folder",
-		Long:  "Add, remove, or resize a storage folder.",
+		Short: "Add, migrate, remove, or resize a storage folder",
+		Long:  "Add, migrate, remove, or resize a storage folder.",
 	}
 
 	hostFolderAddCmd = &cobra.Command{
@@ -81,6 +305,26 @@ Doing so will override the standard connectivity checks.`,
 		Run:   wrap(hostfolderaddcmd),
 	}
 
+	hostFolderMigrateCmd = &cobra.Command{
+		Use:   "migrate [path] [newpath]",
+		Short: "Move a storage folder to a new path",
+		Long: `Move all of the data in a storage folder to a new path, for example to
+replace a failing disk. The data is moved in the background, and the storage
+folder at the new path replaces the old storage folder once all of the data has
+been moved. The migration does not need space in the other storage folders, and
+resumes if the host is restarted. Use --ratelimit to limit how much data is
+moved per second, for example:
+  siac host folder migrate /mnt/olddisk /mnt/newdisk --ratelimit 50MB`,
+		Run: wrap(hostfoldermigratecmd),
+	}
+
+	hostFolderMigrationCmd = &cobra.Command{
+		Use:   "migration",
+		Short: "View the progress of a storage folder migration",
+		Long:  "View the progress of the current or most recent storage folder migration.",
+		Run:   wrap(hostfoldermigrationcmd),
+	}
+
 	hostFolderRemoveCmd = &cobra.Command{
 		Use:   "remove [path]",
 		Short: "Remove a storage folder from the host",
@@ -176,6 +420,14 @@ func hostcmd() {
 	} else {
 		netaddr += " (manually specified)"
 	}
+	additionalAddrs := "none"
+	if len(is.AdditionalAddresses) > 0 {
+		addrs := make([]string, len(is.AdditionalAddresses))
+		for i, addr := range is.AdditionalAddresses {
+			addrs[i] = string(addr)
+		}
+		additionalAddrs = strings.Join(addrs, ", ")
+	}
 
 	if hostVerbose {
 		// describe net address
@@ -188,8 +440,14 @@ Host Internal Settings:
 	maxdownloadbatchsize: %v
 	maxrevisebatchsize:   %v
 	netaddress:           %v
+	additionaladdresses:  %v
 	windowsize:           %v Hours
 
+	maxconnections:      %v
+	maxconnectionsperip: %v
+	maxdownloadspeed:    %v
+	maxuploadspeed:      %v
+
 	collateral:       %v / TB / Month
 	collateralbudget: %v 
 	maxcollateral:    %v Per Contract
@@ -225,13 +483,22 @@ RPC Stats:
 	Revise Calls:       %v
 	Settings Calls:     %v
 	FormContract Calls: %v
+	LostSectors Calls:  %v
+
+	Connection Limit Rejections: %v
+	IP Limit Rejections:         %v
 `,
 			competitivePrice,
 
 			yesNo(is.AcceptingContracts), periodUnits(is.MaxDuration),
 			filesizeUnits(int64(is.MaxDownloadBatchSize)),
 			filesizeUnits(int64(is.MaxReviseBatchSize)), netaddr,
-			is.WindowSize/6,
+			additionalAddrs, is.WindowSize/6,
+
+			limitUnits(is.MaxConnections, strconv.FormatUint(is.MaxConnections, 10)),
+			limitUnits(is.MaxConnectionsPerIP, strconv.FormatUint(is.MaxConnectionsPerIP, 10)),
+			limitUnits(is.MaxDownloadSpeed, filesizeUnits(int64(is.MaxDownloadSpeed))+" / s"),
+			limitUnits(is.MaxUploadSpeed, filesizeUnits(int64(is.MaxUploadSpeed))+" / s"),
 
 			currencyUnits(is.Collateral.Mul(modules.BlockBytesPerMonthTerabyte)),
 			currencyUnits(is.CollateralBudget),
@@ -260,7 +527,9 @@ RPC Stats:
 
 			nm.ErrorCalls, nm.UnrecognizedCalls, nm.DownloadCalls,
 			nm.RenewCalls, nm.ReviseCalls, nm.SettingsCalls,
-			nm.FormContractCalls)
+			nm.FormContractCalls, nm.LostSectorsCalls,
+
+			nm.ConnectionLimitRejections, nm.IPLimitRejections)
 	} else {
 		fmt.Printf(`Host info:
 	Estimated Competitive Price: %v
@@ -336,7 +605,8 @@ func hostconfigcmd(param, value string) {
 
 	// other valid settings
 	case "acceptingcontracts", "maxdownloadbatchsize", "maxduration",
-		"maxrevisebatchsize", "netaddress", "windowsize":
+		"maxrevisebatchsize", "netaddress", "additionaladdresses", "windowsize", "maxconnections",
+		"maxconnectionsperip", "maxdownloadspeed", "maxuploadspeed":
 
 	// invalid settings
 	default:
@@ -393,6 +663,54 @@ func hostfolderaddcmd(path, size string) {
 	fmt.Println("Added folder", path)
 }
 
+// hostfoldermigratecmd starts moving a folder of the host to a new path.
+func hostfoldermigratecmd(path, newpath string) {
+	values := url.Values{}
+	values.Set("path", abs(path))
+	values.Set("newpath", abs(newpath))
+	if hostFolderMigrateRateLimit != "" {
+		rateLimit, err := parseFilesize(hostFolderMigrateRateLimit)
+		if err != nil {
+			die("Could not parse rate limit:", err)
+		}
+		values.Set("ratelimit", rateLimit)
+	}
+	err := post("/host/storage/folders/migrate", values.Encode())
+	if err != nil {
+		die("Could not migrate folder:", err)
+	}
+	fmt.Printf("Migrating folder %v to %v. Run 'siac host folder migration' to view the progress.\n", path, newpath)
+}
+
+// hostfoldermigrationcmd prints the progress of the storage folder migration.
+func hostfoldermigrationcmd() {
+	var ms api.StorageMigrationGET
+	err := getAPI("/host/storage/folders/migrate", &ms)
+	if err != nil {
+		die("Could not fetch migration status:", err)
+	}
+	if ms.Started.IsZero() {
+		fmt.Println("No storage folder has been migrated.")
+		return
+	}
+	state := "in progress"
+	if !ms.Active {
+		state = "finished " + ms.Completed.Format(time.RFC822)
+	}
+	rateLimit := "unlimited"
+	if ms.RateLimit != 0 {
+		rateLimit = filesizeUnits(int64(ms.RateLimit)) + "/s"
+	}
+	fmt.Printf(`Migration: %v
+	From:       %v
+	To:         %v
+	Started:    %v
+	Rate Limit: %v
+	Moved:      %v of %v sectors
+	Failed:     %v sectors
+`, state, ms.SourcePath, ms.DestinationPath, ms.Started.Format(time.RFC822), rateLimit, ms.SectorsMoved, ms.SectorsTotal, ms.SectorsFailed)
+}
+
 // hostfolderremovecmd removes a folder from the host.
 func hostfolderremovecmd(path string) {
 	err := post("/host/storage/folders/remove", "path="+abs(path))
@@ -423,3 +741,505 @@ func hostsectordeletecmd(root string) {
 	}
 	fmt.Println("Deleted sector", root)
 }
+
+// hostcontractscmd is the handler for the command `siac host contracts`. It
+// lists the storage obligations of the host.
+func hostcontractscmd() {
+	values := url.Values{}
+	if hostContractsStatus != "" {
+		values.Set("status", hostContractsStatus)
+	}
+	if hostContractsNoProof {
+		values.Set("proofconfirmed", "false")
+	}
+	if hostContractsExpiresWithin != "" {
+		within, err := strconv.ParseUint(hostContractsExpiresWithin, 10, 64)
+		if err != nil {
+			die("Could not parse expiration:", err)
+		}
+		var cg api.ConsensusGET
+		if err := getAPI("/consensus", &cg); err != nil {
+			die("Could not fetch block height:", err)
+		}
+		values.Set("maxexpiration", fmt.Sprint(cg.Height+types.BlockHeight(within)))
+	}
+
+	var hcs api.HostContractsGET
+	err := getAPI("/host/contracts?"+values.Encode(), &hcs)
+	if err != nil {
+		die("Could not fetch contracts:", err)
+	}
+	if len(hcs.Contracts) == 0 {
+		fmt.Println("No matching contracts")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tStatus\tSize\tExpiration\tProof Deadline\tProof Confirmed\tPotential Revenue\tRisked Collateral")
+	for _, c := range hcs.Contracts {
+		revenue := c.ContractCost.Add(c.PotentialStorageRevenue).Add(c.PotentialUploadRevenue).Add(c.PotentialDownloadRevenue)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", c.ID, c.Status, filesizeUnits(int64(c.DataSize)),
+			c.Expiration, c.ProofDeadline, yesNo(c.ProofConfirmed), currencyUnits(revenue), currencyUnits(c.RiskedCollateral))
+	}
+	w.Flush()
+}
+
+// hostledgercmd is the handler for the command `siac host ledger`. It prints or
+// exports the ledger of the contracts that the host has resolved.
+func hostledgercmd() {
+	values := url.Values{}
+	if hostLedgerStart != "" {
+		start, err := time.ParseInLocation("2006-01-02", hostLedgerStart, time.Local)
+		if err != nil {
+			die("Could not parse start day:", err)
+		}
+		values.Set("start", fmt.Sprint(start.Unix()))
+	}
+	if hostLedgerEnd != "" {
+		end, err := time.ParseInLocation("2006-01-02", hostLedgerEnd, time.Local)
+		if err != nil {
+			die("Could not parse end day:", err)
+		}
+		// The end day is included in the ledger.
+		values.Set("end", fmt.Sprint(end.AddDate(0, 0, 1).Unix()-1))
+	}
+
+	switch hostLedgerFormat {
+	case "csv", "json":
+		values.Set("format", hostLedgerFormat)
+		resp, err := apiGet("/host/ledger?" + values.Encode())
+		if err != nil {
+			die("Could not fetch ledger:", err)
+		}
+		defer resp.Body.Close()
+		if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+			die("Could not export ledger:", err)
+		}
+		return
+	case "":
+	default:
+		die("Format must be csv or json")
+	}
+
+	var hlg api.HostLedgerGET
+	err := getAPI("/host/ledger?"+values.Encode(), &hlg)
+	if err != nil {
+		die("Could not fetch ledger:", err)
+	}
+	if len(hlg.Entries) == 0 {
+		fmt.Println("No resolved contracts")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tStatus\tStart\tEnd\tResolved\tRevenue\tLost Revenue\tLost Collateral\tFees")
+	for _, e := range hlg.Entries {
+		revenue := e.ContractCompensation.Add(e.StorageRevenue).Add(e.DownloadBandwidthRevenue).Add(e.UploadBandwidthRevenue)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", e.ContractID, e.Status, e.StartHeight, e.EndHeight,
+			e.ResolvedTime.Format("2006-01-02"), currencyUnits(revenue), currencyUnits(e.LostRevenue), currencyUnits(e.LostCollateral), currencyUnits(e.TransactionFeeExpense))
+	}
+	w.Flush()
+}
+
+// hostproofscmd is the handler for the command `siac host proofs`. It prints
+// the storage proofs of the host's unresolved contracts.
+func hostproofscmd() {
+	var hpg api.HostProofsGET
+	err := getAPI("/host/proofs", &hpg)
+	if err != nil {
+		die("Could not fetch storage proofs:", err)
+	}
+	if len(hpg.Proofs) == 0 {
+		fmt.Println("No upcoming storage proofs")
+		return
+	}
+	warnings := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tWindow\tStatus\tAttempts\tFee\tWarning\tError")
+	for _, p := range hpg.Proofs {
+		if p.Warning {
+			warnings++
+		}
+		fmt.Fprintf(w, "%v\t%v-%v\t%v\t%v\t%v\t%v\t%v\n", p.ContractID, p.WindowStart, p.WindowEnd, p.Status,
+			p.Attempts, currencyUnits(p.Fee), yesNo(p.Warning), p.Error)
+	}
+	w.Flush()
+	if warnings > 0 {
+		fmt.Printf("\nWARNING: %v storage proofs are not confirmed close to the end of their window.\n", warnings)
+	}
+}
+
+// hostrenterscmd is the handler for the command `siac host renters`. It prints
+// the policies that limit individual renters.
+func hostrenterscmd() {
+	var hr api.HostRentersGET
+	err := getAPI("/host/renters", &hr)
+	if err != nil {
+		die("Could not fetch renter policies:", err)
+	}
+	if len(hr.Policies) == 0 {
+		fmt.Println("No renters are blocked or limited")
+		return
+	}
+	limit := func(n uint64, s string) string {
+		if n == 0 {
+			return "-"
+		}
+		return s
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Renter\tBlocked\tMax Storage\tMax Contracts\tMax Revisions/Minute")
+	for _, p := range hr.Policies {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", p.RenterKey.String(), yesNo(p.Blocked),
+			limit(p.MaxStorage, filesizeUnits(int64(p.MaxStorage))),
+			limit(p.MaxContracts, fmt.Sprint(p.MaxContracts)),
+			limit(p.MaxRevisionsPerMinute, fmt.Sprint(p.MaxRevisionsPerMinute)))
+	}
+	w.Flush()
+}
+
+// hostrentersblockcmd is the handler for the command
+// `siac host renters block [key]`. It blocks a renter.
+func hostrentersblockcmd(key string) {
+	err := post("/host/renters", "key="+key+"&blocked=true")
+	if err != nil {
+		die("Could not block renter:", err)
+	}
+	fmt.Println("Blocked renter", key)
+}
+
+// hostrentersunblockcmd is the handler for the command
+// `siac host renters unblock [key]`. It unblocks a renter.
+func hostrentersunblockcmd(key string) {
+	err := post("/host/renters", "key="+key+"&blocked=false")
+	if err != nil {
+		die("Could not unblock renter:", err)
+	}
+	fmt.Println("Unblocked renter", key)
+}
+
+// hostrenterslimitcmd is the handler for the command
+// `siac host renters limit [key]`. It changes the limits of a renter.
+func hostrenterslimitcmd(key string) {
+	vals := "key=" + key
+	if hostRentersStorage != "" {
+		storage, err := parseFilesize(hostRentersStorage)
+		if err != nil {
+			die("Could not parse storage limit:", err)
+		}
+		vals += "&maxstorage=" + storage
+	}
+	if hostRentersContracts != "" {
+		vals += "&maxcontracts=" + hostRentersContracts
+	}
+	if hostRentersRevisions != "" {
+		vals += "&maxrevisionsperminute=" + hostRentersRevisions
+	}
+	err := post("/host/renters", vals)
+	if err != nil {
+		die("Could not limit renter:", err)
+	}
+	fmt.Println("Updated the limits of renter", key)
+}
+
+// hostrentersremovecmd is the handler for the command
+// `siac host renters remove [key]`. It removes the policy of a renter.
+func hostrentersremovecmd(key string) {
+	err := post("/host/renters/remove", "key="+key)
+	if err != nil {
+		die("Could not remove renter policy:", err)
+	}
+	fmt.Println("Removed the policy of renter", key)
+}
+
+// hostcontractsviewcmd is the handler for the command
+// `siac host contracts view [id]`. It prints the details of a storage
+// obligation.
+func hostcontractsviewcmd(id string) {
+	var hc api.HostContractGET
+	err := getAPI("/host/contracts/"+id, &hc)
+	if err != nil {
+		die("Could not fetch contract:", err)
+	}
+	fmt.Printf(`Contract:           %v
+Status:             %v
+Revision:           %v
+Merkle Root:        %v
+Sectors:            %v (%v)
+Expiration:         %v
+Proof Deadline:     %v
+
+Origin Confirmed:   %v
+Revision Confirmed: %v
+Proof Confirmed:    %v
+
+Contract Cost:      %v
+Storage Revenue:    %v
+Upload Revenue:     %v
+Download Revenue:   %v
+Locked Collateral:  %v
+Risked Collateral:  %v
+Transaction Fees:   %v
+`, hc.ID, hc.Status, hc.RevisionNumber, hc.MerkleRoot, hc.SectorCount, filesizeUnits(int64(hc.DataSize)),
+		hc.Expiration, hc.ProofDeadline,
+		yesNo(hc.OriginConfirmed), yesNo(hc.RevisionConfirmed), yesNo(hc.ProofConfirmed),
+		currencyUnits(hc.ContractCost), currencyUnits(hc.PotentialStorageRevenue),
+		currencyUnits(hc.PotentialUploadRevenue), currencyUnits(hc.PotentialDownloadRevenue),
+		currencyUnits(hc.LockedCollateral), currencyUnits(hc.RiskedCollateral), currencyUnits(hc.TransactionFeesAdded))
+	if len(hc.ActionItems) == 0 {
+		fmt.Println("\nNo pending action items.")
+		return
+	}
+	fmt.Println("\nPending action items at heights:")
+	for _, height := range hc.ActionItems {
+		fmt.Println(" ", height)
+	}
+}
+
+// hostpolicycmd is the handler for the command `siac host policy`. It prints
+// the contract policy of the host.
+func hostpolicycmd() {
+	var hcp api.HostContractPolicyGET
+	err := getAPI("/host/contractpolicy", &hcp)
+	if err != nil {
+		die("Could not fetch contract policy:", err)
+	}
+	blocks := func(n types.BlockHeight) string {
+		if n == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%v blocks", n)
+	}
+	minPayout := "-"
+	if !hcp.MinPayout.IsZero() {
+		minPayout = currencyUnits(hcp.MinPayout)
+	}
+	maxCollateral := "-"
+	if hcp.MaxCollateralPercent != 0 {
+		maxCollateral = fmt.Sprintf("%v%% of the collateral budget", hcp.MaxCollateralPercent)
+	}
+	fmt.Printf(`Contract Policy:
+	Min Duration:           %v
+	Max Duration:           %v
+	Min Payout:             %v
+	Max Collateral:         %v
+	Refuse Failed Renewals: %v
+	Require Allowlist:      %v
+`, blocks(hcp.MinDuration), blocks(hcp.MaxDuration), minPayout, maxCollateral,
+		yesNo(hcp.RefuseFailedRenewals), yesNo(hcp.RequireAllowlist))
+	if len(hcp.AllowedRenters) == 0 {
+		return
+	}
+	fmt.Println("\nAllowed Renters:")
+	for _, spk := range hcp.AllowedRenters {
+		fmt.Println("\t" + spk.String())
+	}
+}
+
+// hostpolicysetcmd is the handler for the command
+// `siac host policy set [setting] [value]`. It changes a rule of the contract
+// policy of the host.
+func hostpolicysetcmd(param, value string) {
+	switch param {
+	// currency (convert to hastings)
+	case "minpayout":
+		hastings, err := parseCurrency(value)
+		if err != nil {
+			die("Could not parse "+param+":", err)
+		}
+		value = hastings
+
+	// other valid settings
+	case "minduration", "maxduration", "requireallowlist", "allowedrenters",
+		"maxcollateralpercent", "refusefailedrenewals":
+
+	// invalid settings
+	default:
+		die("\"" + param + "\" is not a contract policy setting")
+	}
+	err := post("/host/contractpolicy", param+"="+value)
+	if err != nil {
+		die("Could not update contract policy:", err)
+	}
+	fmt.Println("Contract policy updated.")
+}
+
+// hostpricingcmd is the handler for the command `siac host pricing`. It prints
+// the pricing policy of the host and the prices it currently advertises.
+func hostpricingcmd() {
+	var hp api.HostPricingGET
+	err := getAPI("/host/pricing", &hp)
+	if err != nil {
+		die("Could not fetch pricing policy:", err)
+	}
+	hg := new(api.HostGET)
+	err = getAPI("/host", hg)
+	if err != nil {
+		die("Could not fetch host settings:", err)
+	}
+	mode := hp.Mode
+	if mode == "" {
+		mode = modules.HostPricingFixed
+	}
+	fmt.Println("Pricing Mode:", mode)
+	switch mode {
+	case modules.HostPricingUtilisation:
+		fmt.Println("Max Multiplier:", hp.MaxMultiplier)
+	case modules.HostPricingNetwork:
+		fmt.Println("Percentile:", hp.Percentile)
+		if hp.MaxMultiplier != 0 {
+			fmt.Println("Max Multiplier:", hp.MaxMultiplier)
+		}
+	}
+	es := hg.ExternalSettings
+	fmt.Printf(`
+Advertised Prices:
+	Contract Price:           %v
+	Download Bandwidth Price: %v / TB
+	Storage Price:            %v / TB / Month
+	Upload Bandwidth Price:   %v / TB
+`, currencyUnits(es.ContractPrice),
+		currencyUnits(es.DownloadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
+		currencyUnits(es.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte)),
+		currencyUnits(es.UploadBandwidthPrice.Mul(modules.BytesPerTerabyte)))
+}
+
+// hostmaintenancecmd is the handler for the command `siac host maintenance`.
+// It prints the maintenance status of the host.
+func hostmaintenancecmd() {
+	var hm api.HostMaintenanceGET
+	err := getAPI("/host/maintenance", &hm)
+	if err != nil {
+		die("Could not fetch maintenance status:", err)
+	}
+	fmt.Printf(`Maintenance Mode:  %v
+Active Operations: %v
+Safe To Stop:      %v
+`, yesNo(hm.Enabled), hm.ActiveOperations, yesNo(hm.SafeToStop))
+}
+
+// hostmaintenanceenablecmd is the handler for the command
+// `siac host maintenance enable`. It puts the host in maintenance mode.
+func hostmaintenanceenablecmd() {
+	err := post("/host/maintenance", "enabled=true")
+	if err != nil {
+		die("Could not enable maintenance mode:", err)
+	}
+	fmt.Println("Host is in maintenance mode. Run 'siac host maintenance' to check when it is safe to stop.")
+}
+
+// hostmaintenancedisablecmd is the handler for the command
+// `siac host maintenance disable`. It takes the host out of maintenance mode.
+func hostmaintenancedisablecmd() {
+	err := post("/host/maintenance", "enabled=false")
+	if err != nil {
+		die("Could not disable maintenance mode:", err)
+	}
+	fmt.Println("Host is out of maintenance mode.")
+}
+
+// hostpricingsetcmd is the handler for the command
+// `siac host pricing set [mode]`. It sets the pricing policy of the host.
+func hostpricingsetcmd(mode string) {
+	vals := "mode=" + mode
+	if hostPricingMaxMultiplier != "" {
+		vals += "&maxmultiplier=" + hostPricingMaxMultiplier
+	}
+	if hostPricingPercentile != "" {
+		vals += "&percentile=" + hostPricingPercentile
+	}
+	err := post("/host/pricing", vals)
+	if err != nil {
+		die("Could not set pricing policy:", err)
+	}
+	fmt.Println("Updated host pricing policy.")
+}
+
+// hostcheckcmd is the handler for the command `siac host check`. It checks the
+// host's sectors for consistency and prints the problems that were found.
+func hostcheckcmd() {
+	var sc api.StorageCheckGET
+	var err error
+	if hostCheckPurge {
+		err = postResp("/host/storage/check", "", &sc)
+	} else {
+		err = getAPI("/host/storage/check", &sc)
+	}
+	if err != nil {
+		die("Could not check storage:", err)
+	}
+
+	fmt.Printf(`Sectors Checked:      %v
+Missing Sectors:      %v
+Corrupted Sectors:    %v
+Missing Files:        %v
+Unreferenced Sectors: %v
+Orphaned Files:       %v
+Miscounted Folders:   %v
+`, sc.SectorsChecked, len(sc.MissingSectors), len(sc.CorruptedSectors), sc.MissingFiles,
+		sc.UnreferencedSectors, len(sc.OrphanedFiles), len(sc.MiscountedFolders))
+	if hostCheckPurge {
+		fmt.Println("Purged Files:        ", sc.PurgedFiles)
+	}
+
+	for _, root := range sc.MissingSectors {
+		fmt.Println("missing:  ", root)
+	}
+	for _, root := range sc.CorruptedSectors {
+		fmt.Println("corrupted:", root)
+	}
+	for _, path := range sc.OrphanedFiles {
+		fmt.Println("orphaned: ", path)
+	}
+	for _, path := range sc.MiscountedFolders {
+		fmt.Println("miscounted folder:", path)
+	}
+}
+
+// hostscrubcmd is the handler for the command `siac host scrub`. It prints the
+// settings and progress of the sector scrubber.
+func hostscrubcmd() {
+	var ss api.StorageScrubGET
+	err := getAPI("/host/storage/scrub", &ss)
+	if err != nil {
+		die("Could not fetch scrubber status:", err)
+	}
+	if ss.Period == 0 {
+		fmt.Println("Scrubber: disabled")
+	} else {
+		fmt.Println("Scrub Period:", ss.Period)
+	}
+	if ss.RateLimit == 0 {
+		fmt.Println("Rate Limit:   unlimited")
+	} else {
+		fmt.Printf("Rate Limit:   %v/s\n", filesizeUnits(int64(ss.RateLimit)))
+	}
+	if !ss.PassStarted.IsZero() {
+		fmt.Printf(`
+Current Pass:
+	Started:   %v
+	Scrubbed:  %v of %v sectors
+	Corrupted: %v
+`, ss.PassStarted.Format(time.RFC822), ss.SectorsScrubbed, ss.SectorsTotal, ss.CorruptedSectors)
+	}
+	if !ss.LastPassCompleted.IsZero() {
+		fmt.Printf(`
+Last Pass:
+	Completed: %v
+	Corrupted: %v
+`, ss.LastPassCompleted.Format(time.RFC822), ss.LastPassCorrupted)
+	}
+}
+
+// hostscrubsetcmd is the handler for the command
+// `siac host scrub set [period] [ratelimit]`. It configures the sector
+// scrubber.
+func hostscrubsetcmd(period, rateLimit string) {
+	rateLimit, err := parseFilesize(rateLimit)
+	if err != nil {
+		die("Could not parse rate limit:", err)
+	}
+	err = post("/host/storage/scrub", "period="+period+"&ratelimit="+rateLimit)
+	if err != nil {
+		die("Could not configure scrubber:", err)
+	}
+	fmt.Println("Updated sector scrubber settings.")
+}
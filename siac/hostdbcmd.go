@@ -2,35 +2,384 @@ package main
 
 import (
 	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
 	hostdbCmd = &cobra.Command{
 		Use:   "hostdb",
 		Short: "View or modify the host database",
-		Long:  "Add and remove hosts, or list active hosts on the network.",
-		Run:   wrap(hostdbcmd),
+		Long: `List the online hosts on the network, sorted by score. Hosts can be
+filtered by price, remaining storage, uptime, and version.`,
+		Run: wrap(hostdbcmd),
+	}
+
+	hostdbBenchmarksCmd = &cobra.Command{
+		Use:   "benchmarks",
+		Short: "View the host benchmark settings",
+		Long:  "View whether hosts are benchmarked, and how often.",
+		Run:   wrap(hostdbbenchmarkscmd),
+	}
+
+	hostdbPruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "View how long offline hosts are kept",
+		Long:  "View how long a host can be offline before it is removed from the host database.",
+		Run:   wrap(hostdbprunecmd),
+	}
+
+	hostdbSetPruneCmd = &cobra.Command{
+		Use:   "setprune [maxdowntime]",
+		Short: "Set how long offline hosts are kept",
+		Long: `Set how long a host can be offline before it is removed from the host
+database, e.g. "720h". Hosts that you have contracts with are never removed. A
+downtime of 0 disables pruning.`,
+		Run: wrap(hostdbsetprunecmd),
+	}
+
+	hostdbSetBenchmarksCmd = &cobra.Command{
+		Use:   "setbenchmarks [off|latency|downloads]",
+		Short: "Enable or disable host benchmarks",
+		Long: `Enable or disable the periodic benchmarking of hosts. "latency" measures
+the latency of every active host. "downloads" also downloads 1 MiB from each
+host that you have a contract with to measure its throughput, which costs a
+small amount of money. Use --interval to set how often hosts are
+benchmarked.`,
+		Run: wrap(hostdbsetbenchmarkscmd),
+	}
+
+	hostdbSetSubnetsCmd = &cobra.Command{
+		Use:   "setsubnets [ipv4] [ipv6]",
+		Short: "Set the subnet sizes used to group hosts",
+		Long: `Set the prefix lengths, in bits, of the subnets that hosts are grouped by.
+At most one host per subnet is selected for new contracts. A size of 0 disables
+the restriction for that address family. The defaults are 24 and 48.`,
+		Run: wrap(hostdbsetsubnetscmd),
+	}
+
+	hostdbSetWeightCmd = &cobra.Command{
+		Use:   "setweight [factor] [weight]",
+		Short: "Set the weight of a host scoring factor",
+		Long: `Set the weight given to one of the factors used to score hosts. The
+factors are uptime, age, storage, version, latency, and throughput. A weight
+of 0 causes
+the factor to be ignored, and larger weights make the factor more significant.
+The weight must be between 0 and 10.`,
+		Run: wrap(hostdbsetweightcmd),
+	}
+
+	hostdbSubnetsCmd = &cobra.Command{
+		Use:   "subnets",
+		Short: "View the subnet sizes used to group hosts",
+		Long:  "View the prefix lengths of the subnets that hosts are grouped by.",
+		Run:   wrap(hostdbsubnetscmd),
+	}
+
+	hostdbViewCmd = &cobra.Command{
+		Use:   "view [pubkey]",
+		Short: "View the scan history of a host",
+		Long:  "View the uptime and recent scans of the host with the given public key.",
+		Run:   wrap(hostdbviewcmd),
+	}
+
+	hostdbWeightsCmd = &cobra.Command{
+		Use:   "weights",
+		Short: "View the host scoring weights",
+		Long:  "View the weights given to each of the factors used to score hosts.",
+		Run:   wrap(hostdbweightscmd),
 	}
 )
 
+// parseHostPrice converts a siacoin amount per unit to hastings per byte
+// (or byte-block, for storage), which is how host prices are expressed.
+func parseHostPrice(amount string, unit types.Currency) (string, error) {
+	hastings, err := parseCurrency(amount)
+	if err != nil {
+		return "", err
+	}
+	var c types.Currency
+	if _, err := fmt.Sscan(hastings, &c); err != nil {
+		return "", err
+	}
+	return c.Div(unit).String(), nil
+}
+
+// hostdbcmd is the handler for the command `siac hostdb`. It lists the hosts
+// that match the filters given as flags.
 func hostdbcmd() {
-	info := new(api.ActiveHosts)
-	err := getAPI("/hostdb/active", info)
+	values := url.Values{}
+	for _, price := range []struct {
+		param, amount string
+		unit          types.Currency
+	}{
+		{"maxstorageprice", hostdbMaxStoragePrice, modules.BlockBytesPerMonthTerabyte},
+		{"maxuploadprice", hostdbMaxUploadPrice, modules.BytesPerTerabyte},
+		{"maxdownloadprice", hostdbMaxDownloadPrice, modules.BytesPerTerabyte},
+	} {
+		if price.amount == "" {
+			continue
+		}
+		hastings, err := parseHostPrice(price.amount, price.unit)
+		if err != nil {
+			die("Could not parse price:", err)
+		}
+		values.Set(price.param, hastings)
+	}
+	if hostdbMinStorage != "" {
+		size, err := parseFilesize(hostdbMinStorage)
+		if err != nil {
+			die("Could not parse minimum storage:", err)
+		}
+		values.Set("minremainingstorage", size)
+	}
+	if hostdbMinUptime != "" {
+		values.Set("minuptime", hostdbMinUptime)
+	}
+	if hostdbMinVersion != "" {
+		values.Set("minversion", hostdbMinVersion)
+	}
+	if hostdbAccepting {
+		values.Set("acceptingcontracts", "true")
+	}
+	if !hostdbOffline {
+		values.Set("online", "true")
+	}
+	values.Set("sortby", hostdbSortBy)
+	values.Set("offset", strconv.Itoa(hostdbOffset))
+	values.Set("limit", strconv.Itoa(hostdbLimit))
+
+	var hh api.HostdbHosts
+	err := getAPI("/hostdb/hosts?"+values.Encode(), &hh)
 	if err != nil {
 		die("Could not fetch host list:", err)
 	}
-	if len(info.Hosts) == 0 {
-		fmt.Println("No known active hosts")
+	if len(hh.Hosts) == 0 {
+		fmt.Println("No matching hosts")
 		return
 	}
-	fmt.Println("Active hosts:")
-	for _, host := range info.Hosts {
+	fmt.Printf("Hosts %v-%v of %v:\n", hostdbOffset+1, hostdbOffset+len(hh.Hosts), hh.Total)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Address\tStorage Price (/ TB / Month)\tRemaining Storage\tUptime\tAccepting Contracts")
+	for _, host := range hh.Hosts {
 		price := host.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte)
-		fmt.Printf("\t%v - %v / TB / Month\n", host.NetAddress, currencyUnits(price))
+		fmt.Fprintf(w, "%v\t%v\t%v\t%.2f%%\t%v\n", host.NetAddress, currencyUnits(price),
+			filesizeUnits(int64(host.RemainingStorage)), host.Uptime, yesNo(host.AcceptingContracts))
+	}
+	w.Flush()
+}
+
+// hostdbviewcmd is the handler for the command `siac hostdb view [pubkey]`.
+// It prints the uptime and scan history of a host.
+func hostdbviewcmd(pubkey string) {
+	var info api.HostDetails
+	err := getAPI("/hostdb/hosts/"+pubkey, &info)
+	if err != nil {
+		die("Could not fetch host details:", err)
+	}
+	fmt.Printf(`Host:       %v
+Online:     %v
+First Seen: %v
+Uptime:     %.2f%%
+Downtime:   %.2f%%
+`, info.Entry.NetAddress, yesNo(info.Online), info.FirstSeen, info.Uptime, info.Downtime)
+	if len(info.Entry.AdditionalAddresses) > 0 {
+		fmt.Println("\nAnnounced Addresses:")
+		for _, addr := range append([]modules.NetAddress{info.Entry.NetAddress}, info.Entry.AdditionalAddresses...) {
+			if addr == info.Entry.ReachableAddress {
+				fmt.Println(" ", addr, "(reachable)")
+			} else {
+				fmt.Println(" ", addr)
+			}
+		}
+	}
+	if len(info.AddressHistory) > 1 {
+		fmt.Println("\nAddress History:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Height\tAddress")
+		for _, change := range info.AddressHistory {
+			fmt.Fprintf(w, "%v\t%v\n", change.Height, change.NetAddress)
+		}
+		w.Flush()
+	}
+	if len(info.ScanHistory) == 0 {
+		fmt.Println("\nThe host has not been scanned.")
+		return
+	}
+	fmt.Println("\nScan History:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Time\tResult\tLatency")
+	for _, scan := range info.ScanHistory {
+		result := "ok"
+		if !scan.Success {
+			result = scan.ErrorType
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", scan.Timestamp.Format(time.RFC822), result, scan.Latency)
+	}
+	w.Flush()
+
+	if len(info.Benchmarks) > 0 {
+		fmt.Println("\nBenchmarks:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Time\tLatency\tThroughput")
+		for _, b := range info.Benchmarks {
+			latency, throughput := "-", "-"
+			if b.Latency > 0 {
+				latency = b.Latency.String()
+			}
+			if b.Throughput > 0 {
+				throughput = filesizeUnits(int64(b.Throughput)) + "/s"
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\n", b.Timestamp.Format(time.RFC822), latency, throughput)
+		}
+		w.Flush()
+	}
+
+	sb := info.ScoreBreakdown
+	fmt.Printf(`
+Score Breakdown:
+  Price:      %v
+  Uptime:     %.4f
+  Age:        %.4f
+  Storage:    %.4f
+  Version:    %.4f
+  Latency:    %.4f
+  Throughput: %.4f
+  Score:      %v
+`, sb.Price, sb.Uptime, sb.Age, sb.Storage, sb.Version, sb.Latency, sb.Throughput, sb.Score)
+}
+
+// hostdbweightscmd is the handler for the command `siac hostdb weights`. It
+// prints the weights used to score hosts.
+func hostdbweightscmd() {
+	var hw api.HostdbWeights
+	err := getAPI("/hostdb/weights", &hw)
+	if err != nil {
+		die("Could not fetch host scoring weights:", err)
+	}
+	fmt.Printf(`Host Scoring Weights:
+  Uptime:     %v
+  Age:        %v
+  Storage:    %v
+  Version:    %v
+  Latency:    %v
+  Throughput: %v
+`, hw.Weights.Uptime, hw.Weights.Age, hw.Weights.Storage, hw.Weights.Version, hw.Weights.Latency, hw.Weights.Throughput)
+}
+
+// hostdbsetweightcmd is the handler for the command
+// `siac hostdb setweight [factor] [weight]`. It sets the weight of a single
+// host scoring factor.
+func hostdbsetweightcmd(factor, weight string) {
+	switch factor {
+	case "uptime", "age", "storage", "version", "latency", "throughput":
+	default:
+		die("Unknown factor:", factor)
+	}
+	err := post("/hostdb/weights", factor+"="+weight)
+	if err != nil {
+		die("Could not set weight:", err)
+	}
+	fmt.Printf("Set the weight of %v to %v.\n", factor, weight)
+}
+
+// hostdbsubnetscmd is the handler for the command `siac hostdb subnets`. It
+// prints the sizes of the subnets that hosts are grouped by.
+func hostdbsubnetscmd() {
+	var hs api.HostdbSubnets
+	err := getAPI("/hostdb/subnets", &hs)
+	if err != nil {
+		die("Could not fetch subnet sizes:", err)
+	}
+	fmt.Printf(`Subnet Sizes:
+  IPv4: /%v
+  IPv6: /%v
+`, hs.SubnetSizes.IPv4, hs.SubnetSizes.IPv6)
+}
+
+// hostdbsetsubnetscmd is the handler for the command
+// `siac hostdb setsubnets [ipv4] [ipv6]`. It sets the sizes of the subnets
+// that hosts are grouped by.
+func hostdbsetsubnetscmd(ipv4, ipv6 string) {
+	err := post("/hostdb/subnets", "ipv4="+ipv4+"&ipv6="+ipv6)
+	if err != nil {
+		die("Could not set subnet sizes:", err)
+	}
+	fmt.Printf("Set subnet sizes to /%v (IPv4) and /%v (IPv6).\n", ipv4, ipv6)
+}
+
+// hostdbbenchmarkscmd is the handler for the command `siac hostdb benchmarks`.
+// It prints the settings that control the benchmarking of hosts.
+func hostdbbenchmarkscmd() {
+	var hb api.HostdbBenchmarks
+	err := getAPI("/hostdb/benchmarks", &hb)
+	if err != nil {
+		die("Could not fetch benchmark settings:", err)
+	}
+	bs := hb.BenchmarkSettings
+	fmt.Printf(`Host Benchmarks:
+  Latency:   %v
+  Downloads: %v
+  Interval:  %v
+`, yesNo(bs.Enabled), yesNo(bs.Enabled && bs.Downloads), bs.Interval)
+}
+
+// hostdbsetbenchmarkscmd is the handler for the command
+// `siac hostdb setbenchmarks [off|latency|downloads]`. It enables or disables
+// the benchmarking of hosts.
+func hostdbsetbenchmarkscmd(mode string) {
+	var enabled, downloads bool
+	switch mode {
+	case "off":
+	case "latency":
+		enabled = true
+	case "downloads":
+		enabled, downloads = true, true
+	default:
+		die("Unknown benchmark mode:", mode)
+	}
+	vals := fmt.Sprintf("enabled=%v&downloads=%v", enabled, downloads)
+	if hostdbBenchmarkInterval != "" {
+		vals += "&interval=" + hostdbBenchmarkInterval
+	}
+	err := post("/hostdb/benchmarks", vals)
+	if err != nil {
+		die("Could not set benchmark settings:", err)
+	}
+	fmt.Println("Updated host benchmark settings.")
+}
+
+// hostdbprunecmd is the handler for the command `siac hostdb prune`. It
+// prints how long a host can be offline before it is removed from the hostdb.
+func hostdbprunecmd() {
+	var hp api.HostdbPrune
+	err := getAPI("/hostdb/prune", &hp)
+	if err != nil {
+		die("Could not fetch prune settings:", err)
+	}
+	if hp.MaxDowntime == 0 {
+		fmt.Println("Offline hosts are never removed.")
+		return
+	}
+	fmt.Printf("Hosts that are offline for more than %v are removed.\n", hp.MaxDowntime)
+}
+
+// hostdbsetprunecmd is the handler for the command
+// `siac hostdb setprune [maxdowntime]`. It sets how long a host can be offline
+// before it is removed from the hostdb.
+func hostdbsetprunecmd(maxDowntime string) {
+	err := post("/hostdb/prune", "maxdowntime="+maxDowntime)
+	if err != nil {
+		die("Could not set prune downtime:", err)
 	}
+	fmt.Println("Updated host prune settings.")
 }
@@ -23,6 +23,43 @@ var (
 	hostVerbose       bool   // display additional host info
 	renterShowHistory bool   // Show download history in addition to download queue.
 	renterListVerbose bool   // Show additional info about uploaded files.
+
+	renterRenewWindow      string // Renew window of the allowance, in weeks.
+	renterMaxPriceIncrease string // Largest host price increase accepted on renewal, in percent.
+	renterMaxFailureRate   string // Largest host failure rate accepted on renewal, in percent.
+
+	hostdbMaxStoragePrice  string // Largest storage price of listed hosts, per TB per month.
+	hostdbMaxUploadPrice   string // Largest upload price of listed hosts, per TB.
+	hostdbMaxDownloadPrice string // Largest download price of listed hosts, per TB.
+	hostdbMinStorage       string // Smallest remaining storage of listed hosts.
+	hostdbMinUptime        string // Smallest uptime of listed hosts, in percent.
+	hostdbMinVersion       string // Oldest version of listed hosts.
+	hostdbSortBy           string // Order of listed hosts, by score or price.
+	hostdbAccepting        bool   // Only list hosts that are accepting contracts.
+	hostdbOffline          bool   // Include offline hosts.
+	hostdbOffset           int    // Number of hosts to skip.
+	hostdbLimit            int    // Maximum number of hosts to list.
+
+	hostdbBenchmarkInterval string // How often hosts are benchmarked.
+
+	hostContractsStatus        string // Only list host contracts with this status.
+	hostContractsExpiresWithin string // Only list host contracts that expire within this many blocks.
+	hostContractsNoProof       bool   // Only list host contracts whose storage proof is not confirmed.
+
+	hostLedgerStart  string // First day of the exported host ledger.
+	hostLedgerEnd    string // Last day of the exported host ledger.
+	hostLedgerFormat string // Format of the exported host ledger, csv or json.
+
+	hostPricingMaxMultiplier string // Maximum multiple of the minimum prices charged by the host.
+	hostPricingPercentile    string // Percentile of the network prices targeted by the host.
+
+	hostCheckPurge bool // Delete orphaned sector files while checking the host's storage.
+
+	hostRentersStorage   string // Maximum amount of data a renter may store on the host.
+	hostRentersContracts string // Maximum number of contracts a renter may have with the host.
+	hostRentersRevisions string // Maximum number of contract revisions per minute of a renter.
+
+	hostFolderMigrateRateLimit string // Maximum rate at which a storage folder is migrated.
 )
 
 // exit codes
@@ -245,12 +282,46 @@ func main() {
 	updateCmd.AddCommand(updateCheckCmd)
 
 	root.AddCommand(hostCmd)
-	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostFolderCmd, hostSectorCmd)
-	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
+	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostCheckCmd, hostContractsCmd, hostFolderCmd, hostLedgerCmd, hostMaintenanceCmd, hostPolicyCmd, hostPricingCmd, hostProofsCmd, hostRentersCmd, hostScrubCmd, hostSectorCmd)
+	hostLedgerCmd.Flags().StringVar(&hostLedgerStart, "start", "", "Only include contracts resolved on or after this day (YYYY-MM-DD)")
+	hostLedgerCmd.Flags().StringVar(&hostLedgerEnd, "end", "", "Only include contracts resolved on or before this day (YYYY-MM-DD)")
+	hostLedgerCmd.Flags().StringVar(&hostLedgerFormat, "format", "", "Export the ledger as csv or json instead of printing a table")
+	hostCheckCmd.Flags().BoolVar(&hostCheckPurge, "purge", false, "Delete orphaned sector files and correct the remaining capacity of storage folders")
+	hostContractsCmd.AddCommand(hostContractsViewCmd)
+	hostMaintenanceCmd.AddCommand(hostMaintenanceEnableCmd, hostMaintenanceDisableCmd)
+	hostPolicyCmd.AddCommand(hostPolicySetCmd)
+	hostPricingCmd.AddCommand(hostPricingSetCmd)
+	hostPricingSetCmd.Flags().StringVar(&hostPricingMaxMultiplier, "max-multiplier", "", "Maximum multiple of the minimum prices to charge")
+	hostPricingSetCmd.Flags().StringVar(&hostPricingPercentile, "percentile", "", "Percentile of the network prices to charge, from 0 to 100")
+	hostRentersCmd.AddCommand(hostRentersBlockCmd, hostRentersLimitCmd, hostRentersRemoveCmd, hostRentersUnblockCmd)
+	hostRentersLimitCmd.Flags().StringVar(&hostRentersStorage, "storage", "", "Maximum amount of data the renter may store, e.g. \"100GB\"")
+	hostRentersLimitCmd.Flags().StringVar(&hostRentersContracts, "contracts", "", "Maximum number of contracts the renter may have")
+	hostRentersLimitCmd.Flags().StringVar(&hostRentersRevisions, "revisions", "", "Maximum number of contract revisions per minute of the renter")
+	hostScrubCmd.AddCommand(hostScrubSetCmd)
+	hostContractsCmd.Flags().StringVar(&hostContractsStatus, "status", "", "Only list contracts with this status: unresolved, rejected, succeeded, or failed")
+	hostContractsCmd.Flags().StringVar(&hostContractsExpiresWithin, "expires-within", "", "Only list contracts that expire within this many blocks")
+	hostContractsCmd.Flags().BoolVar(&hostContractsNoProof, "no-proof", false, "Only list contracts whose storage proof has not been confirmed")
+	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderMigrateCmd, hostFolderMigrationCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
+	hostFolderMigrateCmd.Flags().StringVar(&hostFolderMigrateRateLimit, "ratelimit", "", "Maximum amount of data to move per second, default is unlimited")
 	hostSectorCmd.AddCommand(hostSectorDeleteCmd)
 	hostCmd.Flags().BoolVarP(&hostVerbose, "verbose", "v", false, "Display detailed host info")
 
 	root.AddCommand(hostdbCmd)
+	hostdbCmd.AddCommand(hostdbViewCmd, hostdbWeightsCmd, hostdbSetWeightCmd,
+		hostdbSubnetsCmd, hostdbSetSubnetsCmd, hostdbBenchmarksCmd, hostdbSetBenchmarksCmd,
+		hostdbPruneCmd, hostdbSetPruneCmd)
+	hostdbCmd.Flags().StringVar(&hostdbMaxStoragePrice, "max-storage-price", "", "Only list hosts with a storage price (per TB per month) of at most this amount")
+	hostdbCmd.Flags().StringVar(&hostdbMaxUploadPrice, "max-upload-price", "", "Only list hosts with an upload price (per TB) of at most this amount")
+	hostdbCmd.Flags().StringVar(&hostdbMaxDownloadPrice, "max-download-price", "", "Only list hosts with a download price (per TB) of at most this amount")
+	hostdbCmd.Flags().StringVar(&hostdbMinStorage, "min-storage", "", "Only list hosts with at least this much remaining storage")
+	hostdbCmd.Flags().StringVar(&hostdbMinUptime, "min-uptime", "", "Only list hosts with at least this uptime, in percent")
+	hostdbCmd.Flags().StringVar(&hostdbMinVersion, "min-version", "", "Only list hosts running at least this version")
+	hostdbCmd.Flags().StringVar(&hostdbSortBy, "sort", "score", "Sort hosts by \"score\" or \"price\"")
+	hostdbCmd.Flags().BoolVar(&hostdbAccepting, "accepting", false, "Only list hosts that are accepting contracts")
+	hostdbCmd.Flags().BoolVar(&hostdbOffline, "offline", false, "Include offline hosts")
+	hostdbCmd.Flags().IntVar(&hostdbOffset, "offset", 0, "Number of hosts to skip")
+	hostdbCmd.Flags().IntVar(&hostdbLimit, "limit", 0, "Maximum number of hosts to list (0 for no limit)")
+	hostdbSetBenchmarksCmd.Flags().StringVar(&hostdbBenchmarkInterval, "interval", "", "How often hosts are benchmarked, e.g. \"6h\"")
 
 	root.AddCommand(minerCmd)
 	minerCmd.AddCommand(minerStartCmd, minerStopCmd)
@@ -266,9 +337,15 @@ func main() {
 	root.AddCommand(renterCmd)
 	renterCmd.AddCommand(renterFilesDeleteCmd, renterFilesDownloadCmd,
 		renterDownloadsCmd, renterAllowanceCmd, renterSetAllowanceCmd,
-		renterContractsCmd, renterFilesListCmd, renterFilesRenameCmd,
-		renterFilesUploadCmd, renterUploadsCmd)
+		renterPlanAllowanceCmd, renterContractsCmd, renterFilesListCmd,
+		renterFilesRenameCmd, renterFilesUploadCmd, renterUploadsCmd)
 	renterCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
+	renterSetAllowanceCmd.Flags().StringVar(&renterRenewWindow, "renew-window", "", "Renew contracts this many weeks before they expire (default: half the period)")
+	renterSetAllowanceCmd.Flags().StringVar(&renterMaxPriceIncrease, "max-price-increase", "", "Replace hosts whose storage price rose by more than this percentage (default: unchanged)")
+	renterSetAllowanceCmd.Flags().StringVar(&renterMaxFailureRate, "max-failure-rate", "", "Replace hosts that fail more than this percentage of interactions (default: unchanged)")
+	renterPlanAllowanceCmd.Flags().StringVar(&renterRenewWindow, "renew-window", "", "Renew contracts this many weeks before they expire (default: half the period)")
+	renterPlanAllowanceCmd.Flags().StringVar(&renterMaxPriceIncrease, "max-price-increase", "", "Replace hosts whose storage price rose by more than this percentage (default: unchanged)")
+	renterPlanAllowanceCmd.Flags().StringVar(&renterMaxFailureRate, "max-failure-rate", "", "Replace hosts that fail more than this percentage of interactions (default: unchanged)")
 	renterDownloadsCmd.Flags().BoolVarP(&renterShowHistory, "history", "H", false, "Show download history in addition to the download queue")
 	renterFilesListCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
 
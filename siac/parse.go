@@ -134,6 +134,15 @@ func parseCurrency(amount string) (string, error) {
 	return "", errors.New("amount is missing units; run 'wallet --help' for a list of units")
 }
 
+// limitUnits returns "Unlimited" if limit is 0, and the formatted limit s
+// otherwise.
+func limitUnits(limit uint64, s string) string {
+	if limit == 0 {
+		return "Unlimited"
+	}
+	return s
+}
+
 // yesNo returns "Yes" if b is true, and "No" if b is false.
 func yesNo(b bool) string {
 	if b {
@@ -53,6 +53,15 @@ contracts! You should only set the allowance once you are fully synced and you
 have a reasonable number (>30) of hosts in your hostdb.`,
 		Run: wrap(rentersetallowancecmd),
 	}
+	renterPlanAllowanceCmd = &cobra.Command{
+		Use:   "planallowance [amount] [period]",
+		Short: "Preview the effect of setting the allowance",
+		Long: `List the contracts that would be formed, renewed, or kept if the allowance
+were set, along with their estimated cost. No contracts are formed.
+amount is given in currency units (SC, KS, etc.)
+period is given in weeks; 1 week is roughly 1000 blocks`,
+		Run: wrap(renterplanallowancecmd),
+	}
 
 	renterContractsCmd = &cobra.Command{
 		Use:   "contracts",
@@ -220,16 +229,25 @@ func renterallowancecmd() {
 		die("Could not get allowance:", err)
 	}
 	allowance := rg.Settings.Allowance
+	policy := rg.Settings.RenewalPolicy
 
 	// convert to SC
 	fmt.Printf(`Allowance:
-	Amount: %v
-	Period: %v blocks
-`, currencyUnits(allowance.Funds), allowance.Period)
+	Amount:       %v
+	Period:       %v blocks
+	Renew Window: %v blocks
+
+Renewal Policy:
+	Max Price Increase: %v%%
+	Max Failure Rate:   %v%%
+`, currencyUnits(allowance.Funds), allowance.Period, allowance.RenewWindow,
+		policy.MaxPriceIncrease, policy.MaxFailureRate)
 }
 
-// rentersetallowancecmd allows the user to set the allowance.
-func rentersetallowancecmd(amount, period string) {
+// allowanceQuery returns the query string that sets the allowance to the
+// given amount and period, along with the renew window and renewal policy
+// flags, if specified.
+func allowanceQuery(amount, period string) string {
 	hastings, err := parseCurrency(amount)
 	if err != nil {
 		die("Could not parse amount:", err)
@@ -238,13 +256,58 @@ func rentersetallowancecmd(amount, period string) {
 	if err != nil {
 		die("Could not parse period")
 	}
-	err = post("/renter", fmt.Sprintf("funds=%s&period=%s", hastings, blocks))
+	query := fmt.Sprintf("funds=%s&period=%s", hastings, blocks)
+	if renterRenewWindow != "" {
+		window, err := parsePeriod(renterRenewWindow)
+		if err != nil {
+			die("Could not parse renew window")
+		}
+		query += "&renewwindow=" + window
+	}
+	if renterMaxPriceIncrease != "" {
+		query += "&maxpriceincrease=" + renterMaxPriceIncrease
+	}
+	if renterMaxFailureRate != "" {
+		query += "&maxfailurerate=" + renterMaxFailureRate
+	}
+	return query
+}
+
+// rentersetallowancecmd allows the user to set the allowance.
+func rentersetallowancecmd(amount, period string) {
+	err := post("/renter", allowanceQuery(amount, period))
 	if err != nil {
 		die("Could not set allowance:", err)
 	}
 	fmt.Println("Allowance updated.")
 }
 
+// renterplanallowancecmd displays the contracts that setting the allowance
+// would form, renew, or keep.
+func renterplanallowancecmd(amount, period string) {
+	var plan modules.AllowancePlan
+	err := getAPI("/renter/allowance/plan?"+allowanceQuery(amount, period), &plan)
+	if err != nil {
+		die("Could not plan allowance:", err)
+	}
+	if len(plan.Contracts) == 0 {
+		fmt.Println("Setting the allowance would not affect any contracts.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Action\tHost\tCost\tData\tEnd Height")
+	for _, c := range plan.Contracts {
+		fmt.Fprintf(w, "%v\t%v\t%8s\t%v\t%v\n",
+			c.Action,
+			c.NetAddress,
+			currencyUnits(c.Cost),
+			filesizeUnits(int64(c.Sectors*modules.SectorSize)),
+			c.EndHeight)
+	}
+	w.Flush()
+	fmt.Printf("\nEstimated total cost: %v\n", currencyUnits(plan.TotalCost))
+}
+
 // byValue sorts contracts by their value in siacoins, high to low. If two
 // contracts have the same value, they are sorted by their host's address.
 type byValue []api.RenterContract
@@ -267,23 +330,54 @@ func rentercontractscmd() {
 	if err != nil {
 		die("Could not get contracts:", err)
 	}
+	var ra api.RenterContractAttempts
+	err = getAPI("/renter/contracts/attempts", &ra)
+	if err != nil {
+		die("Could not get contract attempts:", err)
+	}
 	if len(rc.Contracts) == 0 {
 		fmt.Println("No contracts have been formed.")
+	} else {
+		sort.Sort(byValue(rc.Contracts))
+		fmt.Println("Contracts:")
+		w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Host\tValue\tData\tEnd Height\tID")
+		var conflicts int
+		for _, c := range rc.Contracts {
+			host := string(c.NetAddress)
+			if c.SubnetConflict {
+				host += " *"
+				conflicts++
+			}
+			fmt.Fprintf(w, "%v\t%8s\t%v\t%v\t%v\n",
+				host,
+				currencyUnits(c.RenterFunds),
+				filesizeUnits(int64(c.Size)),
+				c.EndHeight,
+				c.ID)
+		}
+		w.Flush()
+		if conflicts > 0 {
+			fmt.Printf("* %v contracts are with hosts that share a subnet with another contracted host.\n", conflicts)
+		}
+	}
+	printContractAttempt("formation", ra.LastFormation)
+	printContractAttempt("renewal", ra.LastRenewal)
+}
+
+// printContractAttempt prints the outcome of a contract formation or renewal
+// attempt if it fell short of the desired number of contracts.
+func printContractAttempt(kind string, a modules.ContractAttempt) {
+	if a.Formed >= a.Wanted {
 		return
 	}
-	sort.Sort(byValue(rc.Contracts))
-	fmt.Println("Contracts:")
-	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Host\tValue\tData\tEnd Height\tID")
-	for _, c := range rc.Contracts {
-		fmt.Fprintf(w, "%v\t%8s\t%v\t%v\t%v\n",
-			c.NetAddress,
-			currencyUnits(c.RenterFunds),
-			filesizeUnits(int64(c.Size)),
-			c.EndHeight,
-			c.ID)
+	fmt.Printf("\nLast %v attempt (height %v) formed %v of %v contracts.\n", kind, a.Height, a.Formed, a.Wanted)
+	if a.Error != "" && len(a.Failures) == 0 {
+		fmt.Println(a.Error)
+	}
+	for _, f := range a.Failures {
+		fmt.Printf("\t%v: %v\n", f.NetAddress, f.Error)
 	}
-	w.Flush()
 }
 
 // renterfilesdeletecmd is the handler for the command `siac renter delete [path]`.
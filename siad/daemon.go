@@ -260,6 +260,12 @@ func startDaemon(config Config) (err error) {
 		}()
 	}
 
+	// A host using network pricing learns the prices of the other hosts from
+	// the renter's hostdb.
+	if h != nil && r != nil {
+		h.SetPriceSource(r)
+	}
+
 	// Create the Sia API
 	a := api.New(
 		config.Siad.RequiredUserAgent,
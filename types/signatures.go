@@ -7,8 +7,10 @@ package types
 // called 'UnlockConditions'.
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
@@ -397,3 +399,23 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 func (spk *SiaPublicKey) String() string {
 	return spk.Algorithm.String() + ":" + fmt.Sprintf("%x", spk.Key)
 }
+
+// LoadString is the inverse of SiaPublicKey.String().
+func (spk *SiaPublicKey) LoadString(s string) error {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return errors.New("public key must contain an algorithm prefix")
+	}
+	key, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var algorithm Specifier
+	if len(parts[0]) > len(algorithm) {
+		return errors.New("public key algorithm is too long")
+	}
+	copy(algorithm[:], parts[0])
+	spk.Algorithm = algorithm
+	spk.Key = key
+	return nil
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -366,3 +367,25 @@ func TestSiaPublicKeyString(t *testing.T) {
 		t.Error("got wrong value for spk.String():", spk.String())
 	}
 }
+
+// TestSiaPublicKeyLoadString checks that LoadString is the inverse of String.
+func TestSiaPublicKeyLoadString(t *testing.T) {
+	spk := SiaPublicKey{
+		Algorithm: SignatureEd25519,
+		Key:       []byte{1, 2, 3, 4},
+	}
+	var loaded SiaPublicKey
+	if err := loaded.LoadString(spk.String()); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Algorithm != spk.Algorithm || !bytes.Equal(loaded.Key, spk.Key) {
+		t.Error("loaded key does not match:", loaded.String())
+	}
+
+	// keys without an algorithm or with invalid hex should be rejected
+	for _, s := range []string{"01020304", "ed25519:zz"} {
+		if err := loaded.LoadString(s); err == nil {
+			t.Error("expected error when loading", s)
+		}
+	}
+}